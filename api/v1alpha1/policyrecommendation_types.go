@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -30,6 +31,47 @@ type PolicyRecommendationSpec struct {
 	TransitionedAt          *metav1.Time     `json:"transitionedAt,omitempty"`
 	QueuedForExecution      *bool            `json:"queuedForExecution,omitempty"`
 	QueuedForExecutionAt    *metav1.Time     `json:"queuedForExecutionAt,omitempty"`
+	// +kubebuilder:validation:Enum=Breach;NewWorkload;WorkloadChange;Periodic
+	QueuePriorityClass QueuePriorityClass `json:"queuePriorityClass,omitempty"`
+}
+
+// QueuePriorityClass is the reason a PolicyRecommendation is queued for execution. The queued recommendations are
+// executed in the order of their priority classes.
+type QueuePriorityClass string
+
+const (
+	// The workload breached its redline and needs a safer policy right away.
+	QueuePriorityBreach QueuePriorityClass = "Breach"
+	// The workload was just created and has no recommendation yet.
+	QueuePriorityNewWorkload QueuePriorityClass = "NewWorkload"
+	// The workload spec changed, e.g. its resources were updated.
+	QueuePriorityWorkloadChange QueuePriorityClass = "WorkloadChange"
+	// The recommendation is refreshed periodically or after a policy update.
+	QueuePriorityPeriodic QueuePriorityClass = "Periodic"
+)
+
+// QueuePriorityClasses lists the priority classes from the highest to the lowest.
+var QueuePriorityClasses = []QueuePriorityClass{
+	QueuePriorityBreach,
+	QueuePriorityNewWorkload,
+	QueuePriorityWorkloadChange,
+	QueuePriorityPeriodic,
+}
+
+// Rank returns the position of the class in QueuePriorityClasses; a lower rank is executed first. An unknown class
+// ranks with Periodic.
+func (c QueuePriorityClass) Rank() int {
+	for i, class := range QueuePriorityClasses {
+		if c == class {
+			return i
+		}
+	}
+	return len(QueuePriorityClasses) - 1
+}
+
+// HigherThan returns true if recommendations of class c are executed before the ones of class other.
+func (c QueuePriorityClass) HigherThan(other QueuePriorityClass) bool {
+	return c.Rank() < other.Rank()
 }
 
 type WorkloadMeta struct {
@@ -38,15 +80,64 @@ type WorkloadMeta struct {
 }
 
 type HPAConfiguration struct {
-	Min               int `json:"min"`
-	Max               int `json:"max"`
+	Min int `json:"min"`
+	Max int `json:"max"`
+	// TargetMetricValue is the target CPU utilization of the autoscaler in percent of the CPU requests of the pods,
+	// the same as the HPA's.
 	TargetMetricValue int `json:"targetMetricValue"`
+	// PreScaleSchedules raise the minimum replicas ahead of the recurring ramps in the utilization so that the
+	// workload doesn't have to wait for a reactive scale up.
+	PreScaleSchedules []ReplicaSchedule `json:"preScaleSchedules,omitempty"`
+	// MinReplicaWindows are the time of day windows with a minimum replicas higher than Min, which then holds only
+	// outside of them.
+	MinReplicaWindows []ReplicaSchedule `json:"minReplicaWindows,omitempty"`
+}
+
+// ReplicaSchedule keeps at least DesiredReplicas replicas from every activation of the Start cron schedule till the
+// next activation of the End cron schedule.
+type ReplicaSchedule struct {
+	Timezone        string `json:"timezone"`
+	Start           string `json:"start"`
+	End             string `json:"end"`
+	DesiredReplicas int    `json:"desiredReplicas"`
+}
+
+// QueuedWithHigherPriority returns true if the recommendation is still waiting for its execution with a priority
+// class higher than priorityClass.
+func (s PolicyRecommendationSpec) QueuedWithHigherPriority(priorityClass QueuePriorityClass) bool {
+	if s.QueuedForExecution == nil || !*s.QueuedForExecution || len(s.QueuePriorityClass) == 0 {
+		return false
+	}
+	return s.QueuePriorityClass.HigherThan(priorityClass)
 }
 
 func (h HPAConfiguration) DeepEquals(h2 HPAConfiguration) bool {
 	if h.Min != h2.Min || h.Max != h2.Max || h.TargetMetricValue != h2.TargetMetricValue {
 		return false
 	}
+	return replicaSchedulesEqual(h.PreScaleSchedules, h2.PreScaleSchedules) &&
+		replicaSchedulesEqual(h.MinReplicaWindows, h2.MinReplicaWindows)
+}
+
+// ReplicaSchedules returns the pre-scale schedules followed by the min replica windows.
+func (h HPAConfiguration) ReplicaSchedules() []ReplicaSchedule {
+	if len(h.PreScaleSchedules) == 0 && len(h.MinReplicaWindows) == 0 {
+		return nil
+	}
+	schedules := make([]ReplicaSchedule, 0, len(h.PreScaleSchedules)+len(h.MinReplicaWindows))
+	schedules = append(schedules, h.PreScaleSchedules...)
+	return append(schedules, h.MinReplicaWindows...)
+}
+
+func replicaSchedulesEqual(s1, s2 []ReplicaSchedule) bool {
+	if len(s1) != len(s2) {
+		return false
+	}
+	for i := range s1 {
+		if s1[i] != s2[i] {
+			return false
+		}
+	}
 	return true
 }
 
@@ -57,6 +148,131 @@ type PolicyRecommendationStatus struct {
 	// +listType=map
 	// +listMapKey=type
 	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+	// DataQuality is the quality of the utilization assessed by the last recommendation.
+	DataQuality *DataQuality `json:"dataQuality,omitempty"`
+	// IgnoredIntervals are the intervals of the utilization the metrics transformers left out of the last
+	// recommendation.
+	IgnoredIntervals []IgnoredInterval `json:"ignoredIntervals,omitempty"`
+	// RecommendedMaxReplicas are the replicas serving the peak demand of the last recommendation's metric window at the
+	// red line utilization, plus headroom.
+	RecommendedMaxReplicas int `json:"recommendedMaxReplicas,omitempty"`
+	// ResourceNormalization is how the utilization recorded before the last change of the CPU resources of the
+	// workload's pods within the metric window was normalized for the last recommendation.
+	ResourceNormalization *ResourceNormalization `json:"resourceNormalization,omitempty"`
+	// RobustnessChecks are the outcomes of the simulation of the last recommendation on the sub-windows of the metric
+	// window.
+	// +listType=map
+	// +listMapKey=window
+	RobustnessChecks []RobustnessCheck `json:"robustnessChecks,omitempty"`
+	// VerticalRecommendation is the last rightsizing recommendation of the CPU resources of the workload's containers.
+	VerticalRecommendation *VerticalRecommendation `json:"verticalRecommendation,omitempty"`
+}
+
+// VerticalRecommendation is the CPU request and limit recommended for every container of the workload, along with the
+// HPA configuration recommended for pods of the recommended size.
+type VerticalRecommendation struct {
+	// +listType=map
+	// +listMapKey=name
+	Containers []ContainerResourceRecommendation `json:"containers,omitempty"`
+	// Min is the min replicas recommended for pods of the recommended size.
+	Min int `json:"min,omitempty"`
+	// Max is the max replicas recommended for pods of the recommended size.
+	Max int `json:"max,omitempty"`
+	// TargetMetricValue is the target utilization recommended for pods of the recommended size.
+	TargetMetricValue int `json:"targetMetricValue,omitempty"`
+	// Enforced is set if the recommended resources were applied to the workload.
+	Enforced    bool        `json:"enforced,omitempty"`
+	GeneratedAt metav1.Time `json:"generatedAt"`
+}
+
+// ContainerResourceRecommendation is the CPU request and limit recommended for a container from its usage.
+type ContainerResourceRecommendation struct {
+	Name                  string             `json:"name"`
+	CurrentCPURequest     *resource.Quantity `json:"currentCPURequest,omitempty"`
+	CurrentCPULimit       *resource.Quantity `json:"currentCPULimit,omitempty"`
+	RecommendedCPURequest resource.Quantity  `json:"recommendedCPURequest"`
+	// RecommendedCPULimit is only set for the containers which have a CPU limit.
+	RecommendedCPULimit *resource.Quantity `json:"recommendedCPULimit,omitempty"`
+}
+
+// DataQuality is the quality of the utilization of the metric window. The workflow doesn't move the workload to a
+// riskier policy while the score is below the threshold.
+type DataQuality struct {
+	// Score is the percentage of the metric window free of issues.
+	Score int `json:"score"`
+	// Issues are the latest issues found in the utilization.
+	Issues     []DataQualityIssue `json:"issues,omitempty"`
+	AssessedAt metav1.Time        `json:"assessedAt"`
+}
+
+// DataQualityIssue is a defect of the utilization over an interval.
+type DataQualityIssue struct {
+	// +kubebuilder:validation:Enum=Gap;Flatline;CounterReset;InstanceDisagreement
+	Type  string      `json:"type"`
+	Start metav1.Time `json:"start"`
+	End   metav1.Time `json:"end"`
+}
+
+// IgnoredInterval is an interval of the utilization left out of the recommendation.
+type IgnoredInterval struct {
+	Start metav1.Time `json:"start"`
+	End   metav1.Time `json:"end"`
+	// Reason is the kind of outlier the interval was ignored as, e.g. Event or StatisticalOutlier.
+	Reason string `json:"reason"`
+}
+
+// ResourceNormalizationStrategy is how the utilization recorded by pods of other CPU resources is normalized.
+type ResourceNormalizationStrategy string
+
+const (
+	// ResourceNormalizationRestrict restricts the metric window to the utilization recorded since the last change.
+	ResourceNormalizationRestrict ResourceNormalizationStrategy = "Restrict"
+	// ResourceNormalizationRescale rescales the utilization recorded before the changes by the ratio of the current
+	// CPU resources of the pods to those they had, keeping the utilization of the pods.
+	ResourceNormalizationRescale ResourceNormalizationStrategy = "Rescale"
+)
+
+// ResourceNormalization is the normalization of the utilization recorded before the CPU resources of the workload's
+// pods last changed.
+type ResourceNormalization struct {
+	// +kubebuilder:validation:Enum=Restrict;Rescale
+	Strategy ResourceNormalizationStrategy `json:"strategy"`
+	// ChangedAt is the time since which the pods have their current CPU resources.
+	ChangedAt metav1.Time `json:"changedAt"`
+	// PreviousCPU is the CPU limits of the pods before the last change.
+	PreviousCPU resource.Quantity `json:"previousCPU"`
+	// CurrentCPU is the current CPU limits of the pods.
+	CurrentCPU resource.Quantity `json:"currentCPU"`
+}
+
+// RobustnessWindow is a sub-window of the metric window the recommendation is checked against.
+type RobustnessWindow string
+
+const (
+	// RobustnessWindowRecent is the most recent days of the metric window.
+	RobustnessWindowRecent RobustnessWindow = "Recent"
+	// RobustnessWindowWeekdays is the Mondays to Fridays of the metric window.
+	RobustnessWindowWeekdays RobustnessWindow = "Weekdays"
+	// RobustnessWindowWeekends is the Saturdays and Sundays of the metric window.
+	RobustnessWindowWeekends RobustnessWindow = "Weekends"
+	// RobustnessWindowWorstDay is the day of the metric window with the highest peak utilization.
+	RobustnessWindowWorstDay RobustnessWindow = "WorstDay"
+)
+
+// RobustnessCheck is the outcome of the simulation of the recommended HPA configuration on a sub-window of the
+// metric window.
+type RobustnessCheck struct {
+	// +kubebuilder:validation:Enum=Recent;Weekdays;Weekends;WorstDay
+	Window RobustnessWindow `json:"window"`
+	Safe   bool             `json:"safe"`
+	// Breaches is the number of data points of the window at which the simulated HPA fell short of the utilization.
+	Breaches int `json:"breaches"`
+	// WindowMin is the min replicas recommended on the window alone. It is only set if the most conservative
+	// recommendation of the windows is picked.
+	WindowMin int `json:"windowMin,omitempty"`
+	// WindowTargetMetricValue is the target utilization recommended on the window alone. It is only set if the most
+	// conservative recommendation of the windows is picked.
+	WindowTargetMetricValue int `json:"windowTargetMetricValue,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -100,6 +316,9 @@ const (
 
 	// HPA Enforced condition
 	HPAEnforced PolicyRecommendationConditionType = "HPAEnforced"
+
+	// The peak demand of the workload reached its max replicas
+	MaxReplicasSaturated PolicyRecommendationConditionType = "MaxReplicasSaturated"
 )
 
 //+kubebuilder:object:root=true
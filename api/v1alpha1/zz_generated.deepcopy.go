@@ -26,9 +26,90 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerResourceRecommendation) DeepCopyInto(out *ContainerResourceRecommendation) {
+	*out = *in
+	if in.CurrentCPURequest != nil {
+		in, out := &in.CurrentCPURequest, &out.CurrentCPURequest
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.CurrentCPULimit != nil {
+		in, out := &in.CurrentCPULimit, &out.CurrentCPULimit
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	out.RecommendedCPURequest = in.RecommendedCPURequest.DeepCopy()
+	if in.RecommendedCPULimit != nil {
+		in, out := &in.RecommendedCPULimit, &out.RecommendedCPULimit
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerResourceRecommendation.
+func (in *ContainerResourceRecommendation) DeepCopy() *ContainerResourceRecommendation {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerResourceRecommendation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataQuality) DeepCopyInto(out *DataQuality) {
+	*out = *in
+	if in.Issues != nil {
+		in, out := &in.Issues, &out.Issues
+		*out = make([]DataQualityIssue, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.AssessedAt.DeepCopyInto(&out.AssessedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataQuality.
+func (in *DataQuality) DeepCopy() *DataQuality {
+	if in == nil {
+		return nil
+	}
+	out := new(DataQuality)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataQualityIssue) DeepCopyInto(out *DataQualityIssue) {
+	*out = *in
+	in.Start.DeepCopyInto(&out.Start)
+	in.End.DeepCopyInto(&out.End)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataQualityIssue.
+func (in *DataQualityIssue) DeepCopy() *DataQualityIssue {
+	if in == nil {
+		return nil
+	}
+	out := new(DataQualityIssue)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HPAConfiguration) DeepCopyInto(out *HPAConfiguration) {
 	*out = *in
+	if in.PreScaleSchedules != nil {
+		in, out := &in.PreScaleSchedules, &out.PreScaleSchedules
+		*out = make([]ReplicaSchedule, len(*in))
+		copy(*out, *in)
+	}
+	if in.MinReplicaWindows != nil {
+		in, out := &in.MinReplicaWindows, &out.MinReplicaWindows
+		*out = make([]ReplicaSchedule, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HPAConfiguration.
@@ -41,6 +122,23 @@ func (in *HPAConfiguration) DeepCopy() *HPAConfiguration {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IgnoredInterval) DeepCopyInto(out *IgnoredInterval) {
+	*out = *in
+	in.Start.DeepCopyInto(&out.Start)
+	in.End.DeepCopyInto(&out.End)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IgnoredInterval.
+func (in *IgnoredInterval) DeepCopy() *IgnoredInterval {
+	if in == nil {
+		return nil
+	}
+	out := new(IgnoredInterval)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Policy) DeepCopyInto(out *Policy) {
 	*out = *in
@@ -163,8 +261,8 @@ func (in *PolicyRecommendationList) DeepCopyObject() runtime.Object {
 func (in *PolicyRecommendationSpec) DeepCopyInto(out *PolicyRecommendationSpec) {
 	*out = *in
 	out.WorkloadMeta = in.WorkloadMeta
-	out.TargetHPAConfiguration = in.TargetHPAConfiguration
-	out.CurrentHPAConfiguration = in.CurrentHPAConfiguration
+	in.TargetHPAConfiguration.DeepCopyInto(&out.TargetHPAConfiguration)
+	in.CurrentHPAConfiguration.DeepCopyInto(&out.CurrentHPAConfiguration)
 	if in.GeneratedAt != nil {
 		in, out := &in.GeneratedAt, &out.GeneratedAt
 		*out = (*in).DeepCopy()
@@ -204,6 +302,33 @@ func (in *PolicyRecommendationStatus) DeepCopyInto(out *PolicyRecommendationStat
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.DataQuality != nil {
+		in, out := &in.DataQuality, &out.DataQuality
+		*out = new(DataQuality)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IgnoredIntervals != nil {
+		in, out := &in.IgnoredIntervals, &out.IgnoredIntervals
+		*out = make([]IgnoredInterval, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ResourceNormalization != nil {
+		in, out := &in.ResourceNormalization, &out.ResourceNormalization
+		*out = new(ResourceNormalization)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RobustnessChecks != nil {
+		in, out := &in.RobustnessChecks, &out.RobustnessChecks
+		*out = make([]RobustnessCheck, len(*in))
+		copy(*out, *in)
+	}
+	if in.VerticalRecommendation != nil {
+		in, out := &in.VerticalRecommendation, &out.VerticalRecommendation
+		*out = new(VerticalRecommendation)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyRecommendationStatus.
@@ -246,6 +371,77 @@ func (in *PolicyStatus) DeepCopy() *PolicyStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReplicaSchedule) DeepCopyInto(out *ReplicaSchedule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReplicaSchedule.
+func (in *ReplicaSchedule) DeepCopy() *ReplicaSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(ReplicaSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceNormalization) DeepCopyInto(out *ResourceNormalization) {
+	*out = *in
+	in.ChangedAt.DeepCopyInto(&out.ChangedAt)
+	out.PreviousCPU = in.PreviousCPU.DeepCopy()
+	out.CurrentCPU = in.CurrentCPU.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceNormalization.
+func (in *ResourceNormalization) DeepCopy() *ResourceNormalization {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceNormalization)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RobustnessCheck) DeepCopyInto(out *RobustnessCheck) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RobustnessCheck.
+func (in *RobustnessCheck) DeepCopy() *RobustnessCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(RobustnessCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VerticalRecommendation) DeepCopyInto(out *VerticalRecommendation) {
+	*out = *in
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = make([]ContainerResourceRecommendation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.GeneratedAt.DeepCopyInto(&out.GeneratedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VerticalRecommendation.
+func (in *VerticalRecommendation) DeepCopy() *VerticalRecommendation {
+	if in == nil {
+		return nil
+	}
+	out := new(VerticalRecommendation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WorkloadMeta) DeepCopyInto(out *WorkloadMeta) {
 	*out = *in
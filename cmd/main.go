@@ -18,11 +18,10 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	_ "net/http/pprof"
 	"os"
-	"os/signal"
 	"strings"
-	"syscall"
 	"time"
 
 	argov1alpha1 "github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
@@ -33,6 +32,7 @@ import (
 	"github.com/flipkart-incubator/ottoscalr/pkg/policy"
 	"github.com/flipkart-incubator/ottoscalr/pkg/reco"
 	"github.com/flipkart-incubator/ottoscalr/pkg/registry"
+	"github.com/flipkart-incubator/ottoscalr/pkg/sharding"
 	"github.com/flipkart-incubator/ottoscalr/pkg/transformer"
 	"github.com/flipkart-incubator/ottoscalr/pkg/trigger"
 	kedaapi "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
@@ -43,6 +43,7 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -75,6 +76,24 @@ type Config struct {
 		PrometheusUrl        string `yaml:"prometheusUrl"`
 		QueryTimeoutSec      int    `yaml:"queryTimeoutSec"`
 		QuerySplitIntervalHr int    `yaml:"querySplitIntervalHr"`
+		MergeStrategy        string `yaml:"mergeStrategy"`
+		QueryLimits          struct {
+			MaxConcurrentQueries            int `yaml:"maxConcurrentQueries"`
+			MaxConcurrentQueriesPerInstance int `yaml:"maxConcurrentQueriesPerInstance"`
+			MaxRetries                      int `yaml:"maxRetries"`
+			RetryBackoffMs                  int `yaml:"retryBackoffMs"`
+			CircuitBreakerFailures          int `yaml:"circuitBreakerFailures"`
+			CircuitBreakerOpenSec           int `yaml:"circuitBreakerOpenSec"`
+		} `yaml:"queryLimits"`
+		UtilizationCache struct {
+			Enabled            bool   `yaml:"enabled"`
+			MaxEntries         int    `yaml:"maxEntries"`
+			RefreshOverlapMin  int    `yaml:"refreshOverlapMin"`
+			DownsampleStepMin  int    `yaml:"downsampleStepMin"`
+			DownsampleAfterHr  int    `yaml:"downsampleAfterHr"`
+			PersistPath        string `yaml:"persistPath"`
+			PersistIntervalMin int    `yaml:"persistIntervalMin"`
+		} `yaml:"utilizationCache"`
 	} `yaml:"metricsScraper"`
 
 	BreachMonitor struct {
@@ -82,12 +101,27 @@ type Config struct {
 		CpuRedLine           float64 `yaml:"cpuRedLine"`
 		StepSec              int     `yaml:"stepSec"`
 		ConcurrentExecutions int     `yaml:"concurrentExecutions"`
+		MonitorGCIntervalMin int     `yaml:"monitorGCIntervalMin"`
 	} `yaml:"breachMonitor"`
 
+	Sharding struct {
+		Enabled          bool   `yaml:"enabled"`
+		ShardGroup       string `yaml:"shardGroup"`
+		LeaseDurationSec int    `yaml:"leaseDurationSec"`
+		RenewIntervalSec int    `yaml:"renewIntervalSec"`
+		VirtualNodes     int    `yaml:"virtualNodes"`
+	} `yaml:"sharding"`
+
 	PeriodicTrigger struct {
 		PollingIntervalMin int `yaml:"pollingIntervalMin"`
 	} `yaml:"periodicTrigger"`
 
+	RecommendationWindow struct {
+		Schedule         string                      `yaml:"schedule"`
+		DurationMin      int                         `yaml:"durationMin"`
+		NamespaceWindows []NamespaceRecoWindowConfig `yaml:"namespaceWindows"`
+	} `yaml:"recommendationWindow"`
+
 	PolicyRecommendationController struct {
 		MaxConcurrentReconciles int    `yaml:"maxConcurrentReconciles"`
 		MinRequiredReplicas     int    `yaml:"minRequiredReplicas"`
@@ -116,10 +150,83 @@ type Config struct {
 		MaxTarget                  int `yaml:"minTarget"`
 		MetricsPercentageThreshold int `yaml:"metricsPercentageThreshold"`
 	} `yaml:"cpuUtilizationBasedRecommender"`
+	ForecastBasedRecommender struct {
+		Enabled            bool    `yaml:"enabled"`
+		SeasonLengthHours  int     `yaml:"seasonLengthHours"`
+		HorizonDays        int     `yaml:"horizonDays"`
+		LevelSmoothing     float64 `yaml:"levelSmoothing"`
+		TrendSmoothing     float64 `yaml:"trendSmoothing"`
+		SeasonSmoothing    float64 `yaml:"seasonSmoothing"`
+		ConfidenceFactor   float64 `yaml:"confidenceFactor"`
+		CombineWithHistory *bool   `yaml:"combineWithHistory"`
+	} `yaml:"forecastBasedRecommender"`
+	PreScaling struct {
+		Enabled    bool    `yaml:"enabled"`
+		SlotMin    int     `yaml:"slotMin"`
+		Recurrence float64 `yaml:"recurrence"`
+		RampFactor float64 `yaml:"rampFactor"`
+		Timezone   string  `yaml:"timezone"`
+	} `yaml:"preScaling"`
+	MinReplicaWindows struct {
+		Enabled     bool   `yaml:"enabled"`
+		WindowHours int    `yaml:"windowHours"`
+		Timezone    string `yaml:"timezone"`
+	} `yaml:"minReplicaWindows"`
+	RobustnessCheck struct {
+		Enabled    bool   `yaml:"enabled"`
+		Mode       string `yaml:"mode"`
+		RecentDays int    `yaml:"recentDays"`
+		Timezone   string `yaml:"timezone"`
+	} `yaml:"robustnessCheck"`
+	MaxReplicaRecommendation struct {
+		Enabled    bool    `yaml:"enabled"`
+		Headroom   float64 `yaml:"headroom"`
+		RaiseMax   bool    `yaml:"raiseMax"`
+		RaiseLimit float64 `yaml:"raiseLimit"`
+	} `yaml:"maxReplicaRecommendation"`
+	Rightsizing struct {
+		Enabled         bool    `yaml:"enabled"`
+		RequestQuantile float64 `yaml:"requestQuantile"`
+		LimitQuantile   float64 `yaml:"limitQuantile"`
+		Headroom        float64 `yaml:"headroom"`
+		MinChange       float64 `yaml:"minChange"`
+		Enforce         bool    `yaml:"enforce"`
+	} `yaml:"rightsizing"`
+	ResourceNormalization struct {
+		Enabled   bool    `yaml:"enabled"`
+		Strategy  string  `yaml:"strategy"`
+		MinChange float64 `yaml:"minChange"`
+		StepSec   int     `yaml:"stepSec"`
+	} `yaml:"resourceNormalization"`
+	DataQuality struct {
+		Enabled         bool    `yaml:"enabled"`
+		MinScore        int     `yaml:"minScore"`
+		MaxGapMin       int     `yaml:"maxGapMin"`
+		MinFlatlineMin  int     `yaml:"minFlatlineMin"`
+		SpikeFactor     float64 `yaml:"spikeFactor"`
+		MaxDisagreement float64 `yaml:"maxDisagreement"`
+	} `yaml:"dataQuality"`
+	PodResources struct {
+		Mode               string   `yaml:"mode"`
+		ExcludeSidecars    bool     `yaml:"excludeSidecars"`
+		ExcludedContainers []string `yaml:"excludedContainers"`
+	} `yaml:"podResources"`
 	MetricIngestionTime      float64 `yaml:"metricIngestionTime"`
 	MetricProbeTime          float64 `yaml:"metricProbeTime"`
 	EnableMetricsTransformer *bool   `yaml:"enableMetricsTransformation"`
-	EventCallIntegration     struct {
+	StatisticalOutliers      struct {
+		Enabled              bool    `yaml:"enabled"`
+		WindowMin            int     `yaml:"windowMin"`
+		Threshold            float64 `yaml:"threshold"`
+		MinRelativeDeviation float64 `yaml:"minRelativeDeviation"`
+		MaxSpikeMin          int     `yaml:"maxSpikeMin"`
+	} `yaml:"statisticalOutliers"`
+	RolloutOutliers struct {
+		Enabled       bool `yaml:"enabled"`
+		MaxRolloutMin int  `yaml:"maxRolloutMin"`
+		WarmUpMin     int  `yaml:"warmUpMin"`
+	} `yaml:"rolloutOutliers"`
+	EventCallIntegration struct {
 		CustomEventDataConfigMapName string `yaml:"customEventDataConfigMapName"`
 	} `yaml:"eventCallIntegration"`
 	AutoscalerClient struct {
@@ -128,10 +235,20 @@ type Config struct {
 			EnableEventAutoscaler *bool `yaml:"enableEventAutoscaler"`
 		} `yaml:"scaledObjectConfigs"`
 		HpaConfigs struct {
-			HpaAPIVersion string `yaml:"hpaAPIVersion"`
+			HpaAPIVersion              string `yaml:"hpaAPIVersion"`
+			ReplicaScheduleIntervalSec int    `yaml:"replicaScheduleIntervalSec"`
 		} `yaml:"hpaConfigs"`
+		CPUTargetType string `yaml:"cpuTargetType"`
 	} `yaml:"autoscalerClient"`
 	EnableArgoRolloutsSupport *bool `yaml:"enableArgoRolloutsSupport"`
+	EnableStatefulSetSupport  *bool `yaml:"enableStatefulSetSupport"`
+	GenericWorkloads          []struct {
+		Group           string `yaml:"group"`
+		Version         string `yaml:"version"`
+		Kind            string `yaml:"kind"`
+		PodTemplatePath string `yaml:"podTemplatePath"`
+		ReplicasPath    string `yaml:"replicasPath"`
+	} `yaml:"genericWorkloads"`
 }
 
 func main() {
@@ -189,6 +306,38 @@ func main() {
 		os.Exit(1)
 	}
 
+	var sharder sharding.Sharder = sharding.NewSingleShard()
+	if config.Sharding.Enabled {
+		identity := os.Getenv("POD_NAME")
+		if len(identity) == 0 {
+			identity, err = os.Hostname()
+			if err != nil {
+				setupLog.Error(err, "unable to determine the shard identity")
+				os.Exit(1)
+			}
+		}
+		leaseSharder := sharding.NewLeaseSharder(mgr.GetClient(),
+			mgr.GetAPIReader(),
+			os.Getenv("DEPLOYMENT_NAMESPACE"),
+			config.Sharding.ShardGroup,
+			identity,
+			time.Duration(config.Sharding.LeaseDurationSec)*time.Second,
+			time.Duration(config.Sharding.RenewIntervalSec)*time.Second,
+			config.Sharding.VirtualNodes,
+			logger)
+		if err = mgr.Add(leaseSharder); err != nil {
+			setupLog.Error(err, "unable to add runnable", "runnable", "LeaseSharder")
+			os.Exit(1)
+		}
+		sharder = leaseSharder
+	}
+
+	recoWindows, err := buildRecommendationWindows(config)
+	if err != nil {
+		setupLog.Error(err, "unable to parse the recommendation windows")
+		os.Exit(1)
+	}
+
 	agingPolicyTTL, err := time.ParseDuration(config.PolicyRecommendationController.PolicyExpiryAge)
 	if err != nil {
 		logger.Error(err, "Failed to parse policyExpiryAge. Defaulting.")
@@ -197,11 +346,24 @@ func main() {
 
 	prometheusInstances := parseCommaSeparatedValues(config.MetricsScraper.PrometheusUrl)
 
+	mergeStrategy, err := metrics.ParseMergeStrategy(config.MetricsScraper.MergeStrategy)
+	if err != nil {
+		setupLog.Error(err, "unable to parse the merge strategy of the prometheus scraper")
+		os.Exit(1)
+	}
+
 	scraper, err := metrics.NewPrometheusScraper(prometheusInstances,
 		time.Duration(config.MetricsScraper.QueryTimeoutSec)*time.Second,
 		time.Duration(config.MetricsScraper.QuerySplitIntervalHr)*time.Hour,
 		config.MetricIngestionTime,
 		config.MetricProbeTime,
+		mergeStrategy,
+		metrics.NewQueryLimiter(config.MetricsScraper.QueryLimits.MaxConcurrentQueries,
+			config.MetricsScraper.QueryLimits.MaxConcurrentQueriesPerInstance,
+			config.MetricsScraper.QueryLimits.MaxRetries,
+			time.Duration(config.MetricsScraper.QueryLimits.RetryBackoffMs)*time.Millisecond,
+			config.MetricsScraper.QueryLimits.CircuitBreakerFailures,
+			time.Duration(config.MetricsScraper.QueryLimits.CircuitBreakerOpenSec)*time.Second),
 		logger,
 	)
 	if err != nil {
@@ -209,6 +371,28 @@ func main() {
 		os.Exit(1)
 	}
 
+	// The utilization of the workloads is cached for the recommender, the breach analyzer, the data quality gate and
+	// the breach monitors, which query the same workloads over and over.
+	var utilizationScraper metrics.Scraper = scraper
+	var instanceScraper metrics.InstanceScraper = scraper
+	if config.MetricsScraper.UtilizationCache.Enabled {
+		cachingScraper := metrics.NewCachingScraper(scraper,
+			time.Duration(config.CpuUtilizationBasedRecommender.MetricWindowInDays)*24*time.Hour,
+			time.Duration(config.MetricsScraper.UtilizationCache.RefreshOverlapMin)*time.Minute,
+			config.MetricsScraper.UtilizationCache.MaxEntries,
+			time.Duration(config.MetricsScraper.UtilizationCache.DownsampleStepMin)*time.Minute,
+			time.Duration(config.MetricsScraper.UtilizationCache.DownsampleAfterHr)*time.Hour,
+			config.MetricsScraper.UtilizationCache.PersistPath,
+			time.Duration(config.MetricsScraper.UtilizationCache.PersistIntervalMin)*time.Minute,
+			logger)
+		if err = mgr.Add(cachingScraper); err != nil {
+			setupLog.Error(err, "unable to add runnable", "runnable", "CachingScraper")
+			os.Exit(1)
+		}
+		utilizationScraper = cachingScraper
+		instanceScraper = cachingScraper
+	}
+
 	var eventIntegrations []integration.EventIntegration
 	customEventIntegration, err := integration.NewCustomEventDataFetcher(mgr.GetClient(),
 		os.Getenv("DEPLOYMENT_NAMESPACE"), config.EventCallIntegration.CustomEventDataConfigMapName, logger)
@@ -230,14 +414,57 @@ func main() {
 
 		metricsTransformer = append(metricsTransformer, outlierInterpolatorTransformer)
 	}
+	if config.StatisticalOutliers.Enabled {
+		statisticalOutlierTransformer, err := transformer.NewStatisticalOutlierTransformer(
+			time.Duration(config.StatisticalOutliers.WindowMin)*time.Minute,
+			config.StatisticalOutliers.Threshold,
+			config.StatisticalOutliers.MinRelativeDeviation,
+			time.Duration(config.StatisticalOutliers.MaxSpikeMin)*time.Minute,
+			logger)
+		if err != nil {
+			setupLog.Error(err, "unable to start statistical outlier transformer")
+			os.Exit(1)
+		}
+		metricsTransformer = append(metricsTransformer, statisticalOutlierTransformer)
+	}
+	if config.RolloutOutliers.Enabled {
+		rolloutOutlierTransformer, err := transformer.NewRolloutOutlierTransformer(mgr.GetClient(),
+			time.Duration(config.RolloutOutliers.MaxRolloutMin)*time.Minute,
+			time.Duration(config.RolloutOutliers.WarmUpMin)*time.Minute,
+			logger)
+		if err != nil {
+			setupLog.Error(err, "unable to start rollout outlier transformer")
+			os.Exit(1)
+		}
+		metricsTransformer = append(metricsTransformer, rolloutOutlierTransformer)
+	}
+	podResourceResolver := registry.NewPodResourceResolver(mgr.GetClient(),
+		registry.ResourceMode(config.PodResources.Mode),
+		!config.PodResources.ExcludeSidecars,
+		config.PodResources.ExcludedContainers)
 	deploymentClientRegistryBuilder := registry.NewDeploymentClientRegistryBuilder().
 		WithK8sClient(mgr.GetClient()).
-		WithCustomDeploymentClient(registry.NewDeploymentClient(mgr.GetClient()))
+		WithCustomDeploymentClient(registry.NewDeploymentClient(mgr.GetClient(), podResourceResolver))
 
 	if *config.EnableArgoRolloutsSupport {
 		utilruntime.Must(argov1alpha1.AddToScheme(scheme))
 		//+kubebuilder:scaffold:scheme
-		deploymentClientRegistryBuilder = deploymentClientRegistryBuilder.WithCustomDeploymentClient(registry.NewRolloutClient(mgr.GetClient()))
+		deploymentClientRegistryBuilder = deploymentClientRegistryBuilder.WithCustomDeploymentClient(registry.NewRolloutClient(mgr.GetClient(), podResourceResolver))
+	}
+	if config.EnableStatefulSetSupport != nil && *config.EnableStatefulSetSupport {
+		deploymentClientRegistryBuilder = deploymentClientRegistryBuilder.WithCustomDeploymentClient(registry.NewStatefulSetClient(mgr.GetClient(), podResourceResolver))
+	}
+	for _, workload := range config.GenericWorkloads {
+		genericClient, err := registry.NewGenericClient(mgr.GetClient(),
+			schema.GroupVersionKind{Group: workload.Group, Version: workload.Version, Kind: workload.Kind},
+			workload.PodTemplatePath,
+			workload.ReplicasPath,
+			podResourceResolver)
+		if err != nil {
+			setupLog.Error(err, "Unable to create the client of the generic workload", "kind", workload.Kind)
+			os.Exit(1)
+		}
+		deploymentClientRegistryBuilder = deploymentClientRegistryBuilder.WithCustomDeploymentClient(genericClient)
 	}
 	deploymentClientRegistry := deploymentClientRegistryBuilder.Build()
 
@@ -249,16 +476,96 @@ func main() {
 		autoscalerClient = autoscaler.NewScaledobjectClient(mgr.GetClient(),
 			config.AutoscalerClient.ScaledObjectConfigs.EnableEventAutoscaler)
 	} else {
+		var hpaClient interface {
+			autoscaler.AutoscalerClient
+			autoscaler.ReplicaScheduleApplier
+		}
 		if config.AutoscalerClient.HpaConfigs.HpaAPIVersion == "v2" {
-			autoscalerClient = autoscaler.NewHPAClientV2(mgr.GetClient())
+			hpaClient = autoscaler.NewHPAClientV2(mgr.GetClient())
 		} else {
-			autoscalerClient = autoscaler.NewHPAClient(mgr.GetClient())
+			hpaClient = autoscaler.NewHPAClient(mgr.GetClient())
+		}
+		autoscalerClient = hpaClient
+
+		// HPAs have no notion of time based scaling, so the replica schedules are applied by moving their min
+		// replicas.
+		if err = mgr.Add(autoscaler.NewMinReplicaScheduler(hpaClient,
+			time.Duration(config.AutoscalerClient.HpaConfigs.ReplicaScheduleIntervalSec)*time.Second,
+			logger.WithName("min-replica-scheduler"))); err != nil {
+			setupLog.Error(err, "unable to add runnable", "runnable", "MinReplicaScheduler")
+			os.Exit(1)
+		}
+	}
+
+	var preScaler *reco.PreScaler
+	if config.PreScaling.Enabled {
+		location, err := time.LoadLocation(config.PreScaling.Timezone)
+		if err != nil {
+			setupLog.Error(err, "unable to load the pre-scaling timezone")
+			os.Exit(1)
+		}
+		preScaler = reco.NewPreScaler(time.Duration(config.PreScaling.SlotMin)*time.Minute,
+			config.PreScaling.Recurrence,
+			config.PreScaling.RampFactor,
+			location)
+	}
+
+	var minReplicaWindowPlanner *reco.MinReplicaWindowPlanner
+	if config.MinReplicaWindows.Enabled {
+		location, err := time.LoadLocation(config.MinReplicaWindows.Timezone)
+		if err != nil {
+			setupLog.Error(err, "unable to load the min replica windows timezone")
+			os.Exit(1)
 		}
+		minReplicaWindowPlanner = reco.NewMinReplicaWindowPlanner(
+			time.Duration(config.MinReplicaWindows.WindowHours)*time.Hour, location)
+	}
+
+	var robustnessChecker *reco.RobustnessChecker
+	if config.RobustnessCheck.Enabled {
+		location, err := time.LoadLocation(config.RobustnessCheck.Timezone)
+		if err != nil {
+			setupLog.Error(err, "unable to load the robustness check timezone")
+			os.Exit(1)
+		}
+		robustnessChecker = reco.NewRobustnessChecker(mgr.GetClient(),
+			reco.RobustnessMode(config.RobustnessCheck.Mode),
+			time.Duration(config.RobustnessCheck.RecentDays)*24*time.Hour,
+			location)
+	}
+
+	var maxReplicaRecommender *reco.MaxReplicaRecommender
+	if config.MaxReplicaRecommendation.Enabled {
+		maxReplicaRecommender = reco.NewMaxReplicaRecommender(mgr.GetClient(),
+			scraper,
+			config.MaxReplicaRecommendation.Headroom,
+			config.MaxReplicaRecommendation.RaiseMax,
+			config.MaxReplicaRecommendation.RaiseLimit)
+	}
+
+	var rightsizingRecommender *reco.RightsizingRecommender
+	if config.Rightsizing.Enabled {
+		rightsizingRecommender = reco.NewRightsizingRecommender(scraper,
+			config.Rightsizing.RequestQuantile,
+			config.Rightsizing.LimitQuantile,
+			config.Rightsizing.Headroom,
+			config.Rightsizing.MinChange,
+			config.Rightsizing.Enforce)
+	}
+
+	var resourceNormalizer *reco.ResourceNormalizer
+	if config.ResourceNormalization.Enabled {
+		resourceNormalizer = reco.NewResourceNormalizer(mgr.GetClient(),
+			scraper,
+			ottoscaleriov1alpha1.ResourceNormalizationStrategy(config.ResourceNormalization.Strategy),
+			config.ResourceNormalization.MinChange,
+			time.Duration(config.ResourceNormalization.StepSec)*time.Second)
 	}
+
 	cpuUtilizationBasedRecommender := reco.NewCpuUtilizationBasedRecommender(mgr.GetClient(),
 		config.BreachMonitor.CpuRedLine,
 		time.Duration(config.CpuUtilizationBasedRecommender.MetricWindowInDays)*24*time.Hour,
-		scraper,
+		utilizationScraper,
 		metricsTransformer,
 		time.Duration(config.CpuUtilizationBasedRecommender.StepSec)*time.Second,
 		config.CpuUtilizationBasedRecommender.MinTarget,
@@ -266,9 +573,31 @@ func main() {
 		config.CpuUtilizationBasedRecommender.MetricsPercentageThreshold,
 		*deploymentClientRegistry,
 		autoscalerClient,
+		preScaler,
+		minReplicaWindowPlanner,
+		robustnessChecker,
+		maxReplicaRecommender,
+		rightsizingRecommender,
+		resourceNormalizer,
 		logger)
 
-	breachAnalyzer, err := reco.NewBreachAnalyzer(mgr.GetClient(), scraper, config.BreachMonitor.CpuRedLine, time.Duration(config.BreachMonitor.StepSec)*time.Second)
+	var recommender reco.Recommender = cpuUtilizationBasedRecommender
+	if config.ForecastBasedRecommender.Enabled {
+		// The forecast is combined with the history unless it's disabled explicitly.
+		combineWithHistory := config.ForecastBasedRecommender.CombineWithHistory == nil ||
+			*config.ForecastBasedRecommender.CombineWithHistory
+		recommender = reco.NewForecastBasedRecommender(cpuUtilizationBasedRecommender,
+			time.Duration(config.ForecastBasedRecommender.SeasonLengthHours)*time.Hour,
+			time.Duration(config.ForecastBasedRecommender.HorizonDays)*24*time.Hour,
+			config.ForecastBasedRecommender.LevelSmoothing,
+			config.ForecastBasedRecommender.TrendSmoothing,
+			config.ForecastBasedRecommender.SeasonSmoothing,
+			config.ForecastBasedRecommender.ConfidenceFactor,
+			combineWithHistory,
+			logger)
+	}
+
+	breachAnalyzer, err := reco.NewBreachAnalyzer(mgr.GetClient(), utilizationScraper, config.BreachMonitor.CpuRedLine, time.Duration(config.BreachMonitor.StepSec)*time.Second)
 	if err != nil {
 		setupLog.Error(err, "unable to initialize breach analyzer")
 		os.Exit(1)
@@ -276,9 +605,23 @@ func main() {
 
 	policyStore := policy.NewPolicyStore(mgr.GetClient())
 
+	policyIterators := []reco.PolicyIterator{reco.NewDefaultPolicyIterator(mgr.GetClient()),
+		reco.NewAgingPolicyIterator(mgr.GetClient(), agingPolicyTTL), breachAnalyzer}
+	if config.DataQuality.Enabled {
+		policyIterators = append(policyIterators, reco.NewDataQualityGate(mgr.GetClient(),
+			instanceScraper,
+			metrics.NewDataQualityChecker(time.Duration(config.DataQuality.MaxGapMin)*time.Minute,
+				time.Duration(config.DataQuality.MinFlatlineMin)*time.Minute,
+				config.DataQuality.SpikeFactor,
+				config.DataQuality.MaxDisagreement),
+			time.Duration(config.CpuUtilizationBasedRecommender.MetricWindowInDays)*24*time.Hour,
+			time.Duration(config.CpuUtilizationBasedRecommender.StepSec)*time.Second,
+			config.DataQuality.MinScore))
+	}
+
 	policyRecoReconciler, err := controller.NewPolicyRecommendationReconciler(mgr.GetClient(),
 		mgr.GetScheme(), mgr.GetEventRecorderFor(controller.PolicyRecoWorkflowCtrlName),
-		config.PolicyRecommendationController.MaxConcurrentReconciles, config.PolicyRecommendationController.MinRequiredReplicas, cpuUtilizationBasedRecommender, policyStore, reco.NewDefaultPolicyIterator(mgr.GetClient()), reco.NewAgingPolicyIterator(mgr.GetClient(), agingPolicyTTL), breachAnalyzer)
+		config.PolicyRecommendationController.MaxConcurrentReconciles, config.PolicyRecommendationController.MinRequiredReplicas, recommender, policyStore, sharder, recoWindows, policyIterators...)
 	if err != nil {
 		setupLog.Error(err, "Unable to initialize policy reco reconciler")
 		os.Exit(1)
@@ -297,19 +640,29 @@ func main() {
 		os.Exit(1)
 	}
 
-	triggerHandler := trigger.NewK8sTriggerHandler(mgr.GetClient(), logger)
-	triggerHandler.Start()
+	triggerHandler := trigger.NewK8sTriggerHandler(mgr.GetClient(), sharder, logger)
+	if err = mgr.Add(triggerHandler); err != nil {
+		setupLog.Error(err, "unable to add runnable", "runnable", "K8sTriggerHandler")
+		os.Exit(1)
+	}
 
 	monitorManager := trigger.NewPolicyRecommendationMonitorManager(mgr.GetClient(),
 		mgr.GetEventRecorderFor(trigger.BreachStatusManager),
-		scraper,
+		utilizationScraper,
 		time.Duration(config.PeriodicTrigger.PollingIntervalMin)*time.Minute,
+		recoWindows,
 		time.Duration(config.BreachMonitor.PollingIntervalSec)*time.Second,
+		time.Duration(config.BreachMonitor.MonitorGCIntervalMin)*time.Minute,
 		config.BreachMonitor.ConcurrentExecutions,
-		triggerHandler.QueueForExecution,
+		triggerHandler.QueueWithPriority,
 		config.BreachMonitor.StepSec,
 		config.BreachMonitor.CpuRedLine,
+		sharder,
 		logger)
+	if err = mgr.Add(monitorManager); err != nil {
+		setupLog.Error(err, "unable to add runnable", "runnable", "PolicyRecommendationMonitorManager")
+		os.Exit(1)
+	}
 
 	excludedNamespaces := parseCommaSeparatedValues(config.PolicyRecommendationRegistrar.ExcludedNamespaces)
 	includedNamespaces := parseCommaSeparatedValues(config.PolicyRecommendationRegistrar.IncludedNamespaces)
@@ -319,7 +672,8 @@ func main() {
 
 	hpaEnforcementController, err := controller.NewHPAEnforcementController(mgr.GetClient(),
 		mgr.GetScheme(), *deploymentClientRegistry, mgr.GetEventRecorderFor(controller.HPAEnforcementCtrlName),
-		config.HPAEnforcer.MaxConcurrentReconciles, config.HPAEnforcer.IsDryRun, &hpaEnforcerExcludedNamespaces, &hpaEnforcerIncludedNamespaces, config.HPAEnforcer.WhitelistMode, config.HPAEnforcer.MinRequiredReplicas, autoscalerClient)
+		config.HPAEnforcer.MaxConcurrentReconciles, config.HPAEnforcer.IsDryRun, &hpaEnforcerExcludedNamespaces, &hpaEnforcerIncludedNamespaces, config.HPAEnforcer.WhitelistMode, config.HPAEnforcer.MinRequiredReplicas, autoscalerClient, sharder,
+		autoscaler.CPUTargetType(config.AutoscalerClient.CPUTargetType))
 	if err != nil {
 		setupLog.Error(err, "Unable to initialize HPA enforcement controller")
 		os.Exit(1)
@@ -335,7 +689,7 @@ func main() {
 		mgr.GetScheme(),
 		config.PolicyRecommendationRegistrar.RequeueDelayMs,
 		monitorManager,
-		policyStore, *deploymentClientRegistry, excludedNamespaces, includedNamespaces).SetupWithManager(mgr); err != nil {
+		policyStore, *deploymentClientRegistry, excludedNamespaces, includedNamespaces, sharder).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller",
 			"controller", "PolicyRecommendationRegistration")
 		os.Exit(1)
@@ -364,15 +718,33 @@ func main() {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
+}
+
+type NamespaceRecoWindowConfig struct {
+	Namespace   string `yaml:"namespace"`
+	Schedule    string `yaml:"schedule"`
+	DurationMin int    `yaml:"durationMin"`
+}
 
-	// Create a channel to listen for OS signals
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		<-sigs
-		monitorManager.Shutdown()
-		os.Exit(0)
-	}()
+func buildRecommendationWindows(config Config) (*trigger.RecommendationWindows, error) {
+	var globalWindow *trigger.Window
+	if len(config.RecommendationWindow.Schedule) > 0 {
+		window, err := trigger.NewWindow(config.RecommendationWindow.Schedule,
+			time.Duration(config.RecommendationWindow.DurationMin)*time.Minute)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recommendation window schedule: %w", err)
+		}
+		globalWindow = window
+	}
+	namespaceWindows := make(map[string]*trigger.Window)
+	for _, namespaceWindow := range config.RecommendationWindow.NamespaceWindows {
+		window, err := trigger.NewWindow(namespaceWindow.Schedule, time.Duration(namespaceWindow.DurationMin)*time.Minute)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recommendation window schedule for namespace %s: %w", namespaceWindow.Namespace, err)
+		}
+		namespaceWindows[namespaceWindow.Namespace] = window
+	}
+	return trigger.NewRecommendationWindows(globalWindow, namespaceWindows), nil
 }
 
 func parseCommaSeparatedValues(givenConfig string) []string {
@@ -3,6 +3,7 @@ package autoscaler
 import (
 	"context"
 
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -13,7 +14,9 @@ var (
 )
 
 type AutoscalerClient interface {
-	CreateOrUpdateAutoscaler(ctx context.Context, workload client.Object, labels map[string]string, max int32, min int32, targetCPUUtilization int32) (string, error)
+	// CreateOrUpdateAutoscaler creates or updates the autoscaler of the workload. The replicaSchedules raise the min
+	// replicas to their desired replicas while they are active.
+	CreateOrUpdateAutoscaler(ctx context.Context, workload client.Object, labels map[string]string, max int32, min int32, targetCPUUtilization int32, replicaSchedules []v1alpha1.ReplicaSchedule) (string, error)
 	DeleteAutoscaler(ctx context.Context, obj client.Object) error
 	GetType() client.Object
 	GetList(ctx context.Context, labelSelector labels.Selector, namespace string, fieldSelector fields.Selector) ([]client.Object, error)
@@ -0,0 +1,38 @@
+package autoscaler
+
+import (
+	"context"
+	"math"
+
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CPUTargetType is the type of the CPU target of the autoscalers.
+type CPUTargetType string
+
+const (
+	// CPUTargetUtilization targets the average utilization of the CPU requests of the pods, in percent.
+	CPUTargetUtilization CPUTargetType = "Utilization"
+	// CPUTargetAverageValue targets the average CPU usage of the pods.
+	CPUTargetAverageValue CPUTargetType = "AverageValue"
+)
+
+// AverageValueAutoscalerClient is implemented by the autoscaler clients which can target the average CPU usage of the
+// pods instead of the utilization of their CPU requests.
+type AverageValueAutoscalerClient interface {
+	// CreateOrUpdateAutoscalerWithAverageValue creates or updates the autoscaler of the workload targeting the
+	// average CPU usage of its pods. The replicaSchedules raise the min replicas to their desired replicas while they
+	// are active.
+	CreateOrUpdateAutoscalerWithAverageValue(ctx context.Context, workload client.Object, labels map[string]string,
+		max int32, min int32, targetAverageValue resource.Quantity, replicaSchedules []v1alpha1.ReplicaSchedule) (string,
+		error)
+}
+
+// AverageValueOf returns the average CPU usage of the pods which amounts to the target utilization of their CPU
+// requests. It's rounded down to the millicore so that the autoscaler scales up no later than with the utilization.
+func AverageValueOf(targetCPUUtilization int32, cpuRequests float64) resource.Quantity {
+	return *resource.NewMilliQuantity(int64(math.Floor(float64(targetCPUUtilization)*cpuRequests*10)),
+		resource.DecimalSI)
+}
@@ -0,0 +1,19 @@
+package autoscaler
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var _ = Describe("AverageValueOf", func() {
+	It("should return the average CPU usage of the pods at the target utilization of their requests", func() {
+		Expect(AverageValueOf(60, 1.5)).To(Equal(*resource.NewMilliQuantity(900, resource.DecimalSI)))
+		averageValue := AverageValueOf(120, 0.5)
+		Expect(averageValue.String()).To(Equal("600m"))
+	})
+
+	It("should round down to the millicore", func() {
+		Expect(AverageValueOf(33, 0.25)).To(Equal(*resource.NewMilliQuantity(82, resource.DecimalSI)))
+	})
+})
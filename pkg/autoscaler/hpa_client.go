@@ -2,6 +2,10 @@ package autoscaler
 
 import (
 	"context"
+	"errors"
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
 	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
@@ -68,7 +72,13 @@ func (hc *HPAClient) GetScaleTargetName(obj client.Object) string {
 }
 
 func (hc *HPAClient) CreateOrUpdateAutoscaler(ctx context.Context, workload client.Object, labels map[string]string,
-	max int32, min int32, targetCPUUtilization int32) (string, error) {
+	max int32, min int32, targetCPUUtilization int32, replicaSchedules []v1alpha1.ReplicaSchedule) (string, error) {
+	// min holds outside the replica schedules and the MinReplicaScheduler moves the min replicas of the HPA as the
+	// schedules start and end.
+	scheduledMin, err := scheduledMinReplicas(replicaSchedules, min, time.Now())
+	if err != nil {
+		return "", err
+	}
 	hpa := autoscalingv1.HorizontalPodAutoscaler{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      workload.GetName(),
@@ -81,20 +91,23 @@ func (hc *HPAClient) CreateOrUpdateAutoscaler(ctx context.Context, workload clie
 				APIVersion: workload.GetObjectKind().GroupVersionKind().GroupVersion().String(),
 				Kind:       workload.GetObjectKind().GroupVersionKind().Kind,
 			},
-			MinReplicas:                    &min,
+			MinReplicas:                    &scheduledMin,
 			MaxReplicas:                    max,
 			TargetCPUUtilizationPercentage: &targetCPUUtilization,
 		},
 	}
 
 	result, err := controllerutil.CreateOrUpdate(ctx, hc.k8sClient, &hpa, func() error {
+		if err := setReplicaScheduleAnnotations(&hpa, min, replicaSchedules); err != nil {
+			return err
+		}
 		hpa.Spec = autoscalingv1.HorizontalPodAutoscalerSpec{
 			ScaleTargetRef: autoscalingv1.CrossVersionObjectReference{
 				Name:       workload.GetName(),
 				APIVersion: workload.GetObjectKind().GroupVersionKind().GroupVersion().String(),
 				Kind:       workload.GetObjectKind().GroupVersionKind().Kind,
 			},
-			MinReplicas:                    &min,
+			MinReplicas:                    &scheduledMin,
 			MaxReplicas:                    max,
 			TargetCPUUtilizationPercentage: &targetCPUUtilization,
 		}
@@ -106,3 +119,29 @@ func (hc *HPAClient) CreateOrUpdateAutoscaler(ctx context.Context, workload clie
 
 	return string(result), nil
 }
+
+func (hc *HPAClient) ApplyReplicaSchedules(ctx context.Context, t time.Time) error {
+	hpas := &autoscalingv1.HorizontalPodAutoscalerList{}
+	if err := hc.k8sClient.List(ctx, hpas); err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range hpas.Items {
+		hpa := &hpas.Items[i]
+		min, ok, err := scheduledMinReplicasOf(hpa, t)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !ok || (hpa.Spec.MinReplicas != nil && *hpa.Spec.MinReplicas == min) {
+			continue
+		}
+		patch := client.MergeFrom(hpa.DeepCopy())
+		hpa.Spec.MinReplicas = &min
+		if err := hc.k8sClient.Patch(ctx, hpa, patch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
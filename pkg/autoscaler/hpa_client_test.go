@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	appsv1 "k8s.io/api/apps/v1"
@@ -115,7 +116,7 @@ var _ = Describe("HPAClient", func() {
 			err := k8sClient.Get(ctx, types.NamespacedName{Namespace: deploymentNamespace, Name: deploymentName}, deployment)
 			Expect(err).ToNot(HaveOccurred())
 			op, err := hpaClient.CreateOrUpdateAutoscaler(ctx, deployment,
-				map[string]string{"created-by": "ottoscalr"}, *int32Ptr(10), *int32Ptr(5), *int32Ptr(4))
+				map[string]string{"created-by": "ottoscalr"}, *int32Ptr(10), *int32Ptr(5), *int32Ptr(4), nil)
 
 			Expect(err).ToNot(HaveOccurred())
 			time.Sleep(2 * time.Second)
@@ -138,7 +139,7 @@ var _ = Describe("HPAClient", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			op, err := hpaClient.CreateOrUpdateAutoscaler(ctx, deployment,
-				map[string]string{"created-by": "ottoscalr"}, *int32Ptr(10), *int32Ptr(5), *int32Ptr(4))
+				map[string]string{"created-by": "ottoscalr"}, *int32Ptr(10), *int32Ptr(5), *int32Ptr(4), nil)
 			Expect(err).ToNot(HaveOccurred())
 			time.Sleep(2 * time.Second)
 			Expect(op).To(Equal("created"))
@@ -151,7 +152,7 @@ var _ = Describe("HPAClient", func() {
 			Expect(hpa.Spec.ScaleTargetRef.Name).To(Equal(deploymentName))
 
 			op, err = hpaClient.CreateOrUpdateAutoscaler(ctx, deployment,
-				map[string]string{"created-by": "ottoscalr"}, *int32Ptr(8), *int32Ptr(5), *int32Ptr(10))
+				map[string]string{"created-by": "ottoscalr"}, *int32Ptr(8), *int32Ptr(5), *int32Ptr(10), nil)
 			Expect(err).ToNot(HaveOccurred())
 			time.Sleep(2 * time.Second)
 			Expect(op).To(Equal("updated"))
@@ -165,5 +166,37 @@ var _ = Describe("HPAClient", func() {
 			Expect(k8sClient.Delete(ctx, hpa)).To(Succeed())
 
 		})
+		It("should move the min replicas of the HPA as the replica schedules start and end", func() {
+			deployment := &appsv1.Deployment{}
+			err := k8sClient.Get(ctx, types.NamespacedName{Namespace: deploymentNamespace, Name: deploymentName}, deployment)
+			Expect(err).ToNot(HaveOccurred())
+
+			schedules := []v1alpha1.ReplicaSchedule{
+				{Timezone: "UTC", Start: "0 9 * * *", End: "0 18 * * *", DesiredReplicas: 8},
+			}
+			_, err = hpaClient.CreateOrUpdateAutoscaler(ctx, deployment,
+				map[string]string{"created-by": "ottoscalr"}, *int32Ptr(10), *int32Ptr(5), *int32Ptr(40), schedules)
+			Expect(err).ToNot(HaveOccurred())
+
+			applier, ok := hpaClient.(ReplicaScheduleApplier)
+			Expect(ok).To(BeTrue())
+			getMinReplicas := func() *int32 {
+				hpa := &autoscalingv1.HorizontalPodAutoscaler{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: deploymentNamespace, Name: deploymentName},
+					hpa)).To(Succeed())
+				Expect(hpa.Annotations).To(HaveKeyWithValue(minReplicasAnnotation, "5"))
+				return hpa.Spec.MinReplicas
+			}
+
+			Expect(applier.ApplyReplicaSchedules(ctx, time.Date(2023, time.May, 1, 12, 0, 0, 0, time.UTC))).To(Succeed())
+			Eventually(getMinReplicas, 5*time.Second).Should(Equal(int32Ptr(8)))
+
+			Expect(applier.ApplyReplicaSchedules(ctx, time.Date(2023, time.May, 1, 20, 0, 0, 0, time.UTC))).To(Succeed())
+			Eventually(getMinReplicas, 5*time.Second).Should(Equal(int32Ptr(5)))
+
+			hpa := &autoscalingv1.HorizontalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: deploymentNamespace, Name: deploymentName}, hpa)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, hpa)).To(Succeed())
+		})
 	})
 })
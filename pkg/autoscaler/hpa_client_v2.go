@@ -2,8 +2,13 @@ package autoscaler
 
 import (
 	"context"
+	"errors"
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
 
 	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
@@ -69,7 +74,30 @@ func (hc *HPAClientV2) GetScaleTargetName(obj client.Object) string {
 }
 
 func (hc *HPAClientV2) CreateOrUpdateAutoscaler(ctx context.Context, workload client.Object, labels map[string]string,
-	max int32, min int32, targetCPUUtilization int32) (string, error) {
+	max int32, min int32, targetCPUUtilization int32, replicaSchedules []v1alpha1.ReplicaSchedule) (string, error) {
+	return hc.createOrUpdateAutoscaler(ctx, workload, labels, max, min, autoscalingv2.MetricTarget{
+		Type:               autoscalingv2.UtilizationMetricType,
+		AverageUtilization: &targetCPUUtilization, // Target CPU utilization percentage
+	}, replicaSchedules)
+}
+
+func (hc *HPAClientV2) CreateOrUpdateAutoscalerWithAverageValue(ctx context.Context, workload client.Object,
+	labels map[string]string, max int32, min int32, targetAverageValue resource.Quantity,
+	replicaSchedules []v1alpha1.ReplicaSchedule) (string, error) {
+	return hc.createOrUpdateAutoscaler(ctx, workload, labels, max, min, autoscalingv2.MetricTarget{
+		Type:         autoscalingv2.AverageValueMetricType,
+		AverageValue: &targetAverageValue,
+	}, replicaSchedules)
+}
+
+func (hc *HPAClientV2) createOrUpdateAutoscaler(ctx context.Context, workload client.Object, labels map[string]string,
+	max int32, min int32, target autoscalingv2.MetricTarget, replicaSchedules []v1alpha1.ReplicaSchedule) (string, error) {
+	// min holds outside the replica schedules and the MinReplicaScheduler moves the min replicas of the HPA as the
+	// schedules start and end.
+	scheduledMin, err := scheduledMinReplicas(replicaSchedules, min, time.Now())
+	if err != nil {
+		return "", err
+	}
 	hpa := autoscalingv2.HorizontalPodAutoscaler{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      workload.GetName(),
@@ -82,17 +110,14 @@ func (hc *HPAClientV2) CreateOrUpdateAutoscaler(ctx context.Context, workload cl
 				APIVersion: workload.GetObjectKind().GroupVersionKind().GroupVersion().String(),
 				Kind:       workload.GetObjectKind().GroupVersionKind().Kind,
 			},
-			MinReplicas: &min,
+			MinReplicas: &scheduledMin,
 			MaxReplicas: max,
 			Metrics: []autoscalingv2.MetricSpec{
 				{
 					Type: "Resource",
 					Resource: &autoscalingv2.ResourceMetricSource{
-						Name: "cpu",
-						Target: autoscalingv2.MetricTarget{
-							Type:               "Utilization",
-							AverageUtilization: &targetCPUUtilization, // Target CPU utilization percentage
-						},
+						Name:   "cpu",
+						Target: target,
 					},
 				},
 			},
@@ -100,23 +125,23 @@ func (hc *HPAClientV2) CreateOrUpdateAutoscaler(ctx context.Context, workload cl
 	}
 
 	result, err := controllerutil.CreateOrUpdate(ctx, hc.k8sClient, &hpa, func() error {
+		if err := setReplicaScheduleAnnotations(&hpa, min, replicaSchedules); err != nil {
+			return err
+		}
 		hpa.Spec = autoscalingv2.HorizontalPodAutoscalerSpec{
 			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
 				Name:       workload.GetName(),
 				APIVersion: workload.GetObjectKind().GroupVersionKind().GroupVersion().String(),
 				Kind:       workload.GetObjectKind().GroupVersionKind().Kind,
 			},
-			MinReplicas: &min,
+			MinReplicas: &scheduledMin,
 			MaxReplicas: max,
 			Metrics: []autoscalingv2.MetricSpec{
 				{
 					Type: "Resource",
 					Resource: &autoscalingv2.ResourceMetricSource{
-						Name: "cpu",
-						Target: autoscalingv2.MetricTarget{
-							Type:               "Utilization",
-							AverageUtilization: &targetCPUUtilization, // Target CPU utilization percentage
-						},
+						Name:   "cpu",
+						Target: target,
 					},
 				},
 			},
@@ -129,3 +154,29 @@ func (hc *HPAClientV2) CreateOrUpdateAutoscaler(ctx context.Context, workload cl
 
 	return string(result), nil
 }
+
+func (hc *HPAClientV2) ApplyReplicaSchedules(ctx context.Context, t time.Time) error {
+	hpas := &autoscalingv2.HorizontalPodAutoscalerList{}
+	if err := hc.k8sClient.List(ctx, hpas); err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range hpas.Items {
+		hpa := &hpas.Items[i]
+		min, ok, err := scheduledMinReplicasOf(hpa, t)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !ok || (hpa.Spec.MinReplicas != nil && *hpa.Spec.MinReplicas == min) {
+			continue
+		}
+		patch := client.MergeFrom(hpa.DeepCopy())
+		hpa.Spec.MinReplicas = &min
+		if err := hc.k8sClient.Patch(ctx, hpa, patch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
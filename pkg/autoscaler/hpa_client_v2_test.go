@@ -126,7 +126,7 @@ var _ = Describe("HPAClientV2", func() {
 			err := k8sClient.Get(ctx, types.NamespacedName{Namespace: deploymentNamespace, Name: deploymentName}, deployment)
 			Expect(err).ToNot(HaveOccurred())
 			op, err := hpaClientV2.CreateOrUpdateAutoscaler(ctx, deployment,
-				map[string]string{"created-by": "ottoscalr"}, *int32Ptr(10), *int32Ptr(5), *int32Ptr(4))
+				map[string]string{"created-by": "ottoscalr"}, *int32Ptr(10), *int32Ptr(5), *int32Ptr(4), nil)
 
 			Expect(err).ToNot(HaveOccurred())
 			time.Sleep(2 * time.Second)
@@ -149,7 +149,7 @@ var _ = Describe("HPAClientV2", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			op, err := hpaClientV2.CreateOrUpdateAutoscaler(ctx, deployment,
-				map[string]string{"created-by": "ottoscalr"}, *int32Ptr(10), *int32Ptr(5), *int32Ptr(4))
+				map[string]string{"created-by": "ottoscalr"}, *int32Ptr(10), *int32Ptr(5), *int32Ptr(4), nil)
 			Expect(err).ToNot(HaveOccurred())
 			time.Sleep(2 * time.Second)
 			Expect(op).To(Equal("created"))
@@ -162,7 +162,7 @@ var _ = Describe("HPAClientV2", func() {
 			Expect(hpa.Spec.ScaleTargetRef.Name).To(Equal(deploymentName))
 
 			op, err = hpaClientV2.CreateOrUpdateAutoscaler(ctx, deployment,
-				map[string]string{"created-by": "ottoscalr"}, *int32Ptr(8), *int32Ptr(5), *int32Ptr(10))
+				map[string]string{"created-by": "ottoscalr"}, *int32Ptr(8), *int32Ptr(5), *int32Ptr(10), nil)
 			Expect(err).ToNot(HaveOccurred())
 			time.Sleep(2 * time.Second)
 			Expect(op).To(Equal("updated"))
@@ -0,0 +1,55 @@
+package autoscaler
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+const defaultMinReplicaSchedulerInterval = time.Minute
+
+// ReplicaScheduleApplier is implemented by the autoscaler clients whose autoscalers have no notion of time based
+// scaling, so the min replicas of the replica schedules have to be applied from outside.
+type ReplicaScheduleApplier interface {
+	// ApplyReplicaSchedules updates the min replicas of every autoscaler with replica schedules to the min replicas
+	// in effect at t.
+	ApplyReplicaSchedules(ctx context.Context, t time.Time) error
+}
+
+// MinReplicaScheduler applies the replica schedules of the HPAs every interval. It runs on the leader only.
+type MinReplicaScheduler struct {
+	applier  ReplicaScheduleApplier
+	interval time.Duration
+	logger   logr.Logger
+}
+
+func NewMinReplicaScheduler(applier ReplicaScheduleApplier, interval time.Duration, logger logr.Logger) *MinReplicaScheduler {
+	if interval <= 0 {
+		interval = defaultMinReplicaSchedulerInterval
+	}
+	return &MinReplicaScheduler{
+		applier:  applier,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+func (s *MinReplicaScheduler) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if err := s.applier.ApplyReplicaSchedules(ctx, time.Now()); err != nil {
+			s.logger.Error(err, "Error while applying the replica schedules.")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *MinReplicaScheduler) NeedLeaderElection() bool {
+	return true
+}
@@ -0,0 +1,104 @@
+package autoscaler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
+	"github.com/robfig/cron/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// The HPA clients record the replica schedules and the min replicas outside of them on the HPA, so that the
+	// MinReplicaScheduler can move the min replicas of the HPA as the schedules start and end.
+	replicaSchedulesAnnotation = "ottoscalr.io/replica-schedules"
+	minReplicasAnnotation      = "ottoscalr.io/min-replicas"
+)
+
+// scheduledMinReplicas returns the min replicas in effect at t, i.e. the highest desired replicas of the schedules
+// active at t, or min if none of them is higher.
+func scheduledMinReplicas(schedules []v1alpha1.ReplicaSchedule, min int32, t time.Time) (int32, error) {
+	for _, schedule := range schedules {
+		active, err := isScheduleActive(schedule, t)
+		if err != nil {
+			return min, err
+		}
+		if active && int32(schedule.DesiredReplicas) > min {
+			min = int32(schedule.DesiredReplicas)
+		}
+	}
+	return min, nil
+}
+
+// isScheduleActive returns true if t falls between an activation of the start of the schedule and the following
+// activation of its end.
+func isScheduleActive(schedule v1alpha1.ReplicaSchedule, t time.Time) (bool, error) {
+	start, err := parseSchedule(schedule.Timezone, schedule.Start)
+	if err != nil {
+		return false, err
+	}
+	end, err := parseSchedule(schedule.Timezone, schedule.End)
+	if err != nil {
+		return false, err
+	}
+	return end.Next(t).Before(start.Next(t)), nil
+}
+
+func parseSchedule(timezone string, spec string) (cron.Schedule, error) {
+	if len(timezone) > 0 {
+		spec = fmt.Sprintf("CRON_TZ=%s %s", timezone, spec)
+	}
+	return cron.ParseStandard(spec)
+}
+
+// setReplicaScheduleAnnotations records the replica schedules and the min replicas on the object, or removes them if
+// there are no schedules.
+func setReplicaScheduleAnnotations(obj metav1.Object, min int32, schedules []v1alpha1.ReplicaSchedule) error {
+	annotations := obj.GetAnnotations()
+	if len(schedules) == 0 {
+		delete(annotations, replicaSchedulesAnnotation)
+		delete(annotations, minReplicasAnnotation)
+		obj.SetAnnotations(annotations)
+		return nil
+	}
+
+	encoded, err := json.Marshal(schedules)
+	if err != nil {
+		return err
+	}
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[replicaSchedulesAnnotation] = string(encoded)
+	annotations[minReplicasAnnotation] = fmt.Sprint(min)
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// scheduledMinReplicasOf returns the min replicas in effect at t according to the annotations of the object. It
+// returns false if the object has no replica schedules.
+func scheduledMinReplicasOf(obj metav1.Object, t time.Time) (int32, bool, error) {
+	annotations := obj.GetAnnotations()
+	encoded, ok := annotations[replicaSchedulesAnnotation]
+	if !ok {
+		return 0, false, nil
+	}
+	var schedules []v1alpha1.ReplicaSchedule
+	if err := json.Unmarshal([]byte(encoded), &schedules); err != nil {
+		return 0, false, fmt.Errorf("invalid %s annotation on %s/%s: %w", replicaSchedulesAnnotation,
+			obj.GetNamespace(), obj.GetName(), err)
+	}
+	min, err := strconv.ParseInt(annotations[minReplicasAnnotation], 10, 32)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid %s annotation on %s/%s: %w", minReplicasAnnotation,
+			obj.GetNamespace(), obj.GetName(), err)
+	}
+	scheduledMin, err := scheduledMinReplicas(schedules, int32(min), t)
+	if err != nil {
+		return 0, false, err
+	}
+	return scheduledMin, true, nil
+}
@@ -0,0 +1,59 @@
+package autoscaler
+
+import (
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("ReplicaSchedule", func() {
+	schedules := []v1alpha1.ReplicaSchedule{
+		{Timezone: "Asia/Kolkata", Start: "0 9 * * *", End: "0 18 * * *", DesiredReplicas: 10},
+		{Timezone: "Asia/Kolkata", Start: "0 22 * * *", End: "0 2 * * *", DesiredReplicas: 6},
+	}
+	kolkata, _ := time.LoadLocation("Asia/Kolkata")
+
+	Describe("scheduledMinReplicas", func() {
+		It("should return the desired replicas of the active schedules", func() {
+			for hour, expected := range map[int]int32{8: 3, 9: 10, 17: 10, 18: 3, 23: 6, 1: 6, 2: 3} {
+				min, err := scheduledMinReplicas(schedules, 3, time.Date(2023, time.May, 1, hour, 0, 0, 0, kolkata))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(min).To(Equal(expected), "at %02d:00", hour)
+			}
+		})
+
+		It("should never lower the min replicas", func() {
+			min, err := scheduledMinReplicas(schedules, 12, time.Date(2023, time.May, 1, 10, 0, 0, 0, kolkata))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(min).To(Equal(int32(12)))
+		})
+
+		It("should return an error for an invalid cron schedule", func() {
+			_, err := scheduledMinReplicas([]v1alpha1.ReplicaSchedule{{Start: "0 9 * *", End: "0 18 * * *"}}, 3,
+				time.Now())
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("replica schedule annotations", func() {
+		It("should record the schedules and evaluate them from the annotations", func() {
+			obj := &metav1.ObjectMeta{Name: "test", Namespace: "default"}
+			Expect(setReplicaScheduleAnnotations(obj, 3, schedules)).To(Succeed())
+
+			min, ok, err := scheduledMinReplicasOf(obj, time.Date(2023, time.May, 1, 12, 0, 0, 0, kolkata))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(min).To(Equal(int32(10)))
+
+			Expect(setReplicaScheduleAnnotations(obj, 3, nil)).To(Succeed())
+			Expect(obj.GetAnnotations()).ToNot(HaveKey(replicaSchedulesAnnotation))
+			Expect(obj.GetAnnotations()).ToNot(HaveKey(minReplicasAnnotation))
+			_, ok, err = scheduledMinReplicasOf(obj, time.Now())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
 	kedaapi "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
@@ -80,7 +82,21 @@ func (soc *ScaledobjectClient) DeleteAutoscaler(ctx context.Context, obj client.
 }
 
 func (soc *ScaledobjectClient) CreateOrUpdateAutoscaler(ctx context.Context, workload client.Object, labels map[string]string,
-	max int32, min int32, targetCPUUtilization int32) (string, error) {
+	max int32, min int32, targetCPUUtilization int32, replicaSchedules []v1alpha1.ReplicaSchedule) (string, error) {
+	return soc.createOrUpdateAutoscaler(ctx, workload, labels, max, min, CPUTargetUtilization,
+		fmt.Sprint(targetCPUUtilization), replicaSchedules)
+}
+
+func (soc *ScaledobjectClient) CreateOrUpdateAutoscalerWithAverageValue(ctx context.Context, workload client.Object,
+	labels map[string]string, max int32, min int32, targetAverageValue resource.Quantity,
+	replicaSchedules []v1alpha1.ReplicaSchedule) (string, error) {
+	return soc.createOrUpdateAutoscaler(ctx, workload, labels, max, min, CPUTargetAverageValue,
+		targetAverageValue.String(), replicaSchedules)
+}
+
+func (soc *ScaledobjectClient) createOrUpdateAutoscaler(ctx context.Context, workload client.Object,
+	labels map[string]string, max int32, min int32, targetType CPUTargetType, targetValue string,
+	replicaSchedules []v1alpha1.ReplicaSchedule) (string, error) {
 	scaledObj := kedaapi.ScaledObject{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      workload.GetName(),
@@ -103,7 +119,7 @@ func (soc *ScaledobjectClient) CreateOrUpdateAutoscaler(ctx context.Context, wor
 			},
 			MinReplicaCount: &min,
 			MaxReplicaCount: &max,
-			Triggers:        soc.setScaleTriggers(targetCPUUtilization),
+			Triggers:        soc.setScaleTriggers(targetType, targetValue, replicaSchedules),
 		},
 	}
 
@@ -117,7 +133,7 @@ func (soc *ScaledobjectClient) CreateOrUpdateAutoscaler(ctx context.Context, wor
 			},
 			MinReplicaCount: &min,
 			MaxReplicaCount: &max,
-			Triggers:        soc.setScaleTriggers(targetCPUUtilization),
+			Triggers:        soc.setScaleTriggers(targetType, targetValue, replicaSchedules),
 		}
 
 		return nil
@@ -128,16 +144,30 @@ func (soc *ScaledobjectClient) CreateOrUpdateAutoscaler(ctx context.Context, wor
 	return string(result), nil
 }
 
-func (soc *ScaledobjectClient) setScaleTriggers(targetCPUUtilization int32) []kedaapi.ScaleTriggers {
+func (soc *ScaledobjectClient) setScaleTriggers(targetType CPUTargetType, targetValue string,
+	replicaSchedules []v1alpha1.ReplicaSchedule) []kedaapi.ScaleTriggers {
 	scaleTriggers := []kedaapi.ScaleTriggers{
 		{
 			Type: "cpu",
 			Metadata: map[string]string{
-				"type":  "Utilization",
-				"value": fmt.Sprint(targetCPUUtilization),
+				"type":  string(targetType),
+				"value": targetValue,
 			},
 		},
 	}
+	// KEDA scales to the highest replica count of all the triggers, so every cron trigger acts as a floor on top of
+	// the cpu trigger while its schedule is active.
+	for _, schedule := range replicaSchedules {
+		scaleTriggers = append(scaleTriggers, kedaapi.ScaleTriggers{
+			Type: "cron",
+			Metadata: map[string]string{
+				"timezone":        schedule.Timezone,
+				"start":           schedule.Start,
+				"end":             schedule.End,
+				"desiredReplicas": fmt.Sprint(schedule.DesiredReplicas),
+			},
+		})
+	}
 	if isEventScalerEnabled(soc.enableEventAutoscaler) {
 		scaleTriggers = append(scaleTriggers, kedaapi.ScaleTriggers{
 			Type: "scheduled-event",
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
 	kedaapi "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -120,7 +121,7 @@ var _ = Describe("ScaledObjectClient", func() {
 			err := k8sClient.Get(ctx, types.NamespacedName{Namespace: deploymentNamespace, Name: deploymentName}, deployment)
 			Expect(err).ToNot(HaveOccurred())
 			_, err = scaledObjectClient.CreateOrUpdateAutoscaler(ctx, deployment,
-				map[string]string{"created-by": "ottoscalr"}, *int32Ptr(10), *int32Ptr(5), *int32Ptr(4))
+				map[string]string{"created-by": "ottoscalr"}, *int32Ptr(10), *int32Ptr(5), *int32Ptr(4), nil)
 
 			Expect(err).ToNot(HaveOccurred())
 			time.Sleep(2 * time.Second)
@@ -144,7 +145,7 @@ var _ = Describe("ScaledObjectClient", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			op, err := scaledObjectClient.CreateOrUpdateAutoscaler(ctx, deployment,
-				map[string]string{"created-by": "ottoscalr"}, *int32Ptr(10), *int32Ptr(5), *int32Ptr(4))
+				map[string]string{"created-by": "ottoscalr"}, *int32Ptr(10), *int32Ptr(5), *int32Ptr(4), nil)
 			Expect(err).ToNot(HaveOccurred())
 			time.Sleep(2 * time.Second)
 			Expect(op).To(Equal("created"))
@@ -159,7 +160,7 @@ var _ = Describe("ScaledObjectClient", func() {
 			Expect(scaledObject.Spec.ScaleTargetRef.Name).To(Equal(deploymentName))
 
 			op, err = scaledObjectClient.CreateOrUpdateAutoscaler(ctx, deployment,
-				map[string]string{"created-by": "ottoscalr"}, *int32Ptr(8), *int32Ptr(5), *int32Ptr(10))
+				map[string]string{"created-by": "ottoscalr"}, *int32Ptr(8), *int32Ptr(5), *int32Ptr(10), nil)
 			Expect(err).ToNot(HaveOccurred())
 			time.Sleep(2 * time.Second)
 			Expect(op).To(Equal("updated"))
@@ -175,6 +176,46 @@ var _ = Describe("ScaledObjectClient", func() {
 			Expect(k8sClient.Delete(ctx, scaledObject)).To(Succeed())
 
 		})
+		It("should add a cron trigger for every pre-scale schedule", func() {
+			deployment := &appsv1.Deployment{}
+			err := k8sClient.Get(ctx, types.NamespacedName{Namespace: deploymentNamespace, Name: deploymentName}, deployment)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = scaledObjectClient.CreateOrUpdateAutoscaler(ctx, deployment,
+				map[string]string{"created-by": "ottoscalr"}, *int32Ptr(10), *int32Ptr(2), *int32Ptr(40),
+				[]v1alpha1.ReplicaSchedule{{Timezone: "UTC", Start: "30 8 * * *", End: "0 11 * * *", DesiredReplicas: 8}})
+			Expect(err).ToNot(HaveOccurred())
+			scaledObject := &kedaapi.ScaledObject{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, types.NamespacedName{Namespace: deploymentNamespace, Name: deploymentName}, scaledObject)
+			}).Should(Succeed())
+			Expect(scaledObject.Spec.Triggers[0].Type).To(Equal("cpu"))
+			Expect(scaledObject.Spec.Triggers[1].Type).To(Equal("cron"))
+			Expect(scaledObject.Spec.Triggers[1].Metadata).To(Equal(map[string]string{
+				"timezone":        "UTC",
+				"start":           "30 8 * * *",
+				"end":             "0 11 * * *",
+				"desiredReplicas": "8",
+			}))
+			Expect(k8sClient.Delete(ctx, scaledObject)).To(Succeed())
+		})
+		It("should target the average CPU usage of the pods", func() {
+			deployment := &appsv1.Deployment{}
+			err := k8sClient.Get(ctx, types.NamespacedName{Namespace: deploymentNamespace, Name: deploymentName}, deployment)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = scaledObjectClient.(AverageValueAutoscalerClient).CreateOrUpdateAutoscalerWithAverageValue(ctx, deployment,
+				map[string]string{"created-by": "ottoscalr"}, *int32Ptr(10), *int32Ptr(5), resource.MustParse("600m"), nil)
+			Expect(err).ToNot(HaveOccurred())
+			scaledObject := &kedaapi.ScaledObject{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, types.NamespacedName{Namespace: deploymentNamespace, Name: deploymentName}, scaledObject)
+			}).Should(Succeed())
+			Expect(scaledObject.Spec.Triggers[0].Type).To(Equal("cpu"))
+			Expect(scaledObject.Spec.Triggers[0].Metadata["type"]).To(Equal("AverageValue"))
+			Expect(scaledObject.Spec.Triggers[0].Metadata["value"]).To(Equal("600m"))
+			Expect(k8sClient.Delete(ctx, scaledObject)).To(Succeed())
+		})
 	})
 })
 
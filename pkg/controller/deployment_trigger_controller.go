@@ -43,6 +43,7 @@ func NewDeploymentTriggerController(client client.Client,
 
 // +kubebuilder:rbac:groups=argoproj.io,resources=rollouts,verbs=get;list;watch
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch
 // +kubebuilder:rbac:groups=your-group.io,resources=policyrecommendations,verbs=create;get;list;watch;update;delete
 //+kubebuilder:rbac:groups=ottoscaler.io,resources=policyrecommendations/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=ottoscaler.io,resources=policyrecommendations/finalizers,verbs=update
@@ -64,7 +65,7 @@ func (r *DeploymentTriggerController) Reconcile(ctx context.Context,
 		}
 	}
 
-	logger.Info("Rollout or Deployment not found.")
+	logger.Info("Rollout, Deployment or StatefulSet not found.")
 	return ctrl.Result{}, nil
 }
 
@@ -85,6 +86,9 @@ func (r *DeploymentTriggerController) requeuePolicyRecommendation(ctx context.Co
 		return client.IgnoreNotFound(err)
 	}
 
+	if !policyRecommendation.Spec.QueuedWithHigherPriority(ottoscaleriov1alpha1.QueuePriorityWorkloadChange) {
+		policyRecommendation.Spec.QueuePriorityClass = ottoscaleriov1alpha1.QueuePriorityWorkloadChange
+	}
 	policyRecommendation.Spec.QueuedForExecution = &trueBool
 	policyRecommendation.Spec.QueuedForExecutionAt = &now
 
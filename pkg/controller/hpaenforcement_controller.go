@@ -23,6 +23,7 @@ import (
 	"github.com/flipkart-incubator/ottoscalr/pkg/autoscaler"
 	"github.com/flipkart-incubator/ottoscalr/pkg/reco"
 	"github.com/flipkart-incubator/ottoscalr/pkg/registry"
+	"github.com/flipkart-incubator/ottoscalr/pkg/sharding"
 	"github.com/go-logr/logr"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -99,11 +100,22 @@ type HPAEnforcementController struct {
 	WhitelistMode           *bool
 	MinRequiredReplicas     int
 	autoscalerClient        autoscaler.AutoscalerClient
+	Sharder                 sharding.Sharder
+	cpuTargetType           autoscaler.CPUTargetType
 }
 
 func NewHPAEnforcementController(client client.Client,
 	scheme *runtime.Scheme, clientsRegistry registry.DeploymentClientRegistry, recorder record.EventRecorder,
-	maxConcurrentReconciles int, isDryRun *bool, excludedNamespaces *[]string, includedNamespaces *[]string, whitelistMode *bool, minRequiredReplicas int, autoscalerClient autoscaler.AutoscalerClient) (*HPAEnforcementController, error) {
+	maxConcurrentReconciles int, isDryRun *bool, excludedNamespaces *[]string, includedNamespaces *[]string, whitelistMode *bool, minRequiredReplicas int, autoscalerClient autoscaler.AutoscalerClient, sharder sharding.Sharder,
+	cpuTargetType autoscaler.CPUTargetType) (*HPAEnforcementController, error) {
+
+	if cpuTargetType == autoscaler.CPUTargetAverageValue {
+		if _, ok := autoscalerClient.(autoscaler.AverageValueAutoscalerClient); !ok {
+			return nil, fmt.Errorf("%s doesn't support the %s cpu target", autoscalerClient.GetName(), cpuTargetType)
+		}
+	} else {
+		cpuTargetType = autoscaler.CPUTargetUtilization
+	}
 
 	HPAEnforcedReason = fmt.Sprintf("%sIsCreated", autoscalerClient.GetName())
 	HPAEnforcedMessage = fmt.Sprintf("%s has been created.", autoscalerClient.GetName())
@@ -123,6 +135,8 @@ func NewHPAEnforcementController(client client.Client,
 		WhitelistMode:           whitelistMode,
 		MinRequiredReplicas:     minRequiredReplicas,
 		autoscalerClient:        autoscalerClient,
+		Sharder:                 sharder,
+		cpuTargetType:           cpuTargetType,
 	}, nil
 }
 
@@ -135,6 +149,11 @@ func (r *HPAEnforcementController) Reconcile(ctx context.Context, req ctrl.Reque
 
 	logger := ctrl.LoggerFrom(ctx).WithName(HPAEnforcementCtrlName)
 
+	if !r.Sharder.Owns(req.NamespacedName) {
+		logger.V(1).Info("PolicyRecommendation doesn't belong to the shard of this replica. Skipping.", "object", req.NamespacedName)
+		return ctrl.Result{}, nil
+	}
+
 	logger.V(0).Info("Reconciling PolicyRecommendation.", "object", req.NamespacedName)
 	if r.ExcludedNamespaces != nil {
 		logger.V(0).Info("HPA enforcer initialized with namespace filters.", "blacklist", *r.ExcludedNamespaces)
@@ -264,7 +283,8 @@ func (r *HPAEnforcementController) Reconcile(ctx context.Context, req ctrl.Reque
 
 		logger.V(0).Info("Creating/Updating "+r.autoscalerClient.GetName()+" for workload.", "workload", workload.GetName())
 
-		result, err := r.autoscalerClient.CreateOrUpdateAutoscaler(ctx, workload, labels, max, min, targetCPU)
+		result, err := r.createOrUpdateAutoscaler(ctx, object, workload, labels, max, min, targetCPU,
+			policyreco.Spec.CurrentHPAConfiguration.ReplicaSchedules())
 		if err != nil {
 			logger.V(0).Error(err, "Error creating or updating "+r.autoscalerClient.GetName())
 			return ctrl.Result{}, err
@@ -288,6 +308,22 @@ func (r *HPAEnforcementController) Reconcile(ctx context.Context, req ctrl.Reque
 	return ctrl.Result{}, nil
 }
 
+// createOrUpdateAutoscaler creates or updates the autoscaler of the workload with the target utilization of the CPU
+// requests of its pods, converted to the equivalent average CPU usage of the pods with the AverageValue cpu target.
+func (r *HPAEnforcementController) createOrUpdateAutoscaler(ctx context.Context, object registry.ObjectClient,
+	workload client.Object, labels map[string]string, max int32, min int32, targetCPU int32,
+	replicaSchedules []v1alpha1.ReplicaSchedule) (string, error) {
+	if r.cpuTargetType != autoscaler.CPUTargetAverageValue {
+		return r.autoscalerClient.CreateOrUpdateAutoscaler(ctx, workload, labels, max, min, targetCPU, replicaSchedules)
+	}
+	cpuRequests, err := object.GetContainerResourceRequests(workload.GetNamespace(), workload.GetName())
+	if err != nil {
+		return "", err
+	}
+	return r.autoscalerClient.(autoscaler.AverageValueAutoscalerClient).CreateOrUpdateAutoscalerWithAverageValue(ctx,
+		workload, labels, max, min, autoscaler.AverageValueOf(targetCPU, cpuRequests), replicaSchedules)
+}
+
 func isRecoGenerated(conditions []metav1.Condition) bool {
 	for _, condition := range conditions {
 		if condition.Type == string(v1alpha1.RecoTaskProgress) {
@@ -488,8 +524,9 @@ func (r *HPAEnforcementController) SetupWithManager(mgr ctrl.Manager) error {
 		return requests
 	}
 
+	needLeaderElection := !r.Sharder.Sharded()
 	controllerBuilder := ctrl.NewControllerManagedBy(mgr).
-		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles, NeedLeaderElection: &needLeaderElection}).
 		Named(HPAEnforcementCtrlName).
 		Watches(
 			&v1alpha1.PolicyRecommendation{},
@@ -499,7 +536,9 @@ func (r *HPAEnforcementController) SetupWithManager(mgr ctrl.Manager) error {
 		Watches(r.autoscalerClient.GetType(),
 			handler.EnqueueRequestsFromMapFunc(enqueueFunc),
 			builder.WithPredicates(deletePredicate),
-		)
+		).
+		WatchesRawSource(sharding.NewRebalanceSource(mgr.GetClient(), r.Sharder, nil, mgr.GetLogger()),
+			&handler.EnqueueRequestForObject{})
 
 	for _, object := range r.clientsRegistry.Clients {
 		controllerBuilder.Watches(
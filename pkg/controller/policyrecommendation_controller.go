@@ -19,18 +19,25 @@ package controller
 import (
 	"context"
 	"fmt"
+	ottoscalrmetrics "github.com/flipkart-incubator/ottoscalr/pkg/metrics"
 	"github.com/flipkart-incubator/ottoscalr/pkg/policy"
 	"github.com/flipkart-incubator/ottoscalr/pkg/reco"
+	"github.com/flipkart-incubator/ottoscalr/pkg/sharding"
+	"github.com/flipkart-incubator/ottoscalr/pkg/trigger"
+	"github.com/go-logr/logr"
 	"github.com/prometheus/client_golang/prometheus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"strconv"
+	"sync"
 	"time"
 
 	v1alpha1 "github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
@@ -92,12 +99,16 @@ var (
 	policyRecoCurrentUtil = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{Name: "policyreco_current_policy_utilization",
 			Help: "PolicyReco Current Policy Utilization"}, []string{"namespace", "policyreco"})
+
+	recoDeferredCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{Name: "policyreco_deferred_count",
+			Help: "Number of recommendations deferred till the recommendation window opens"}, []string{"namespace", "policyreco"})
 )
 
 func init() {
 	metrics.Registry.MustRegister(reconcileCounter, reconcileErroredCounter, targetRecoSLI,
 		policyRecoConditionsGauge, policyRecoTaskProgressReasonsGauge, policyRecoTargetMin, policyRecoTargetMax, policyRecoTargetUtil,
-		policyRecoCurrentMin, policyRecoCurrentMax, policyRecoCurrentUtil)
+		policyRecoCurrentMin, policyRecoCurrentMax, policyRecoCurrentUtil, recoDeferredCounter)
 }
 
 // PolicyRecommendationReconciler reconciles a PolicyRecommendation object
@@ -108,11 +119,15 @@ type PolicyRecommendationReconciler struct {
 	MaxConcurrentReconciles int
 	PolicyExpiryAge         time.Duration
 	RecoWorkflow            reco.RecommendationWorkflow
+	Sharder                 sharding.Sharder
+	RecoWindows             *trigger.RecommendationWindows
+	queue                   *recoPriorityQueue
+	rateLimiter             workqueue.RateLimiter
 }
 
 func NewPolicyRecommendationReconciler(client client.Client,
 	scheme *runtime.Scheme, recorder record.EventRecorder,
-	maxConcurrentReconciles int, minRequiredReplicas int, recommender reco.Recommender, policyStore policy.Store, policyIterators ...reco.PolicyIterator) (*PolicyRecommendationReconciler, error) {
+	maxConcurrentReconciles int, minRequiredReplicas int, recommender reco.Recommender, policyStore policy.Store, sharder sharding.Sharder, recoWindows *trigger.RecommendationWindows, policyIterators ...reco.PolicyIterator) (*PolicyRecommendationReconciler, error) {
 	recoWfBuilder := reco.NewRecommendationWorkflowBuilder().
 		WithRecommender(recommender).WithMinRequiredReplicas(minRequiredReplicas).WithPolicyStore(policyStore).WithK8sClient(client)
 	for _, pi := range policyIterators {
@@ -128,6 +143,10 @@ func NewPolicyRecommendationReconciler(client client.Client,
 		MaxConcurrentReconciles: maxConcurrentReconciles,
 		Recorder:                recorder,
 		RecoWorkflow:            recoWorkflow,
+		Sharder:                 sharder,
+		RecoWindows:             recoWindows,
+		queue:                   newRecoPriorityQueue(),
+		rateLimiter:             workqueue.DefaultControllerRateLimiter(),
 	}, nil
 }
 
@@ -136,10 +155,115 @@ func NewPolicyRecommendationReconciler(client client.Client,
 //+kubebuilder:rbac:groups=ottoscaler.io,resources=policyrecommendations/finalizers,verbs=update
 //+kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch;create;update;patch;delete
 
+// Reconcile only moves the queued PolicyRecommendation to the priority queue of the reconciler. The recommendation is
+// generated by the workers started in Start, in the order of the priority classes.
 func (r *PolicyRecommendationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 
 	logger := ctrl.LoggerFrom(ctx).WithName(PolicyRecoWorkflowCtrlName)
 
+	if !r.Sharder.Owns(req.NamespacedName) {
+		logger.V(1).Info("PolicyRecommendation doesn't belong to the shard of this replica. Skipping.")
+		return ctrl.Result{}, nil
+	}
+
+	policyreco := v1alpha1.PolicyRecommendation{}
+	if err := r.Get(ctx, req.NamespacedName, &policyreco); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	priorityClass := queuePriorityClassOf(policyreco)
+	logger.V(1).Info("Queuing PolicyRecommendation for a fresh recommendation.", "priorityClass", priorityClass)
+	r.queue.Add(req, priorityClass)
+	return ctrl.Result{}, nil
+}
+
+// Start runs MaxConcurrentReconciles workers which generate the recommendations of the queued PolicyRecommendations
+// until the context is cancelled.
+func (r *PolicyRecommendationReconciler) Start(ctx context.Context) error {
+	logger := ctrl.LoggerFrom(ctx).WithValues("controller", PolicyRecoWorkflowCtrlName)
+	workers := r.MaxConcurrentReconciles
+	if workers <= 0 {
+		workers = 1
+	}
+	logger.Info("Starting recommendation workers.", "workers", workers)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r.processNextItem(ctx, logger) {
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	logger.Info("Shutting down recommendation workers.")
+	r.queue.ShutDown()
+	wg.Wait()
+	return nil
+}
+
+// NeedLeaderElection ensures that the workers run only on the leader unless the PolicyRecommendations are sharded
+// across the replicas.
+func (r *PolicyRecommendationReconciler) NeedLeaderElection() bool {
+	return !r.Sharder.Sharded()
+}
+
+func (r *PolicyRecommendationReconciler) processNextItem(ctx context.Context, logger logr.Logger) bool {
+	item, ok := r.queue.Get()
+	if !ok {
+		return false
+	}
+	defer r.queue.Done(item)
+
+	req := item.request
+	// The queries of the recommendation are cancelled along with the workers and traced together.
+	itemCtx := ottoscalrmetrics.ContextWithTrace(ctx)
+	itemCtx = ctrl.LoggerInto(itemCtx, logger.WithValues("PolicyRecommendation", req.NamespacedName,
+		"priorityClass", item.priorityClass, "traceID", ottoscalrmetrics.TraceIDFromContext(itemCtx)))
+	result, err := r.execute(itemCtx, req)
+	switch {
+	case err != nil:
+		ctrl.LoggerFrom(itemCtx).Error(err, "Error while generating the recommendation. Requeuing.")
+		r.queue.AddAfter(req, item.priorityClass, r.rateLimiter.When(req))
+	case result.RequeueAfter > 0:
+		r.rateLimiter.Forget(req)
+		r.queue.AddAfter(req, item.priorityClass, result.RequeueAfter)
+	case result.Requeue:
+		r.queue.AddAfter(req, item.priorityClass, r.rateLimiter.When(req))
+	default:
+		r.rateLimiter.Forget(req)
+	}
+	return true
+}
+
+// queuePriorityClassOf returns the priority class the policyreco is queued with. A breached workload always goes first.
+func queuePriorityClassOf(policyreco v1alpha1.PolicyRecommendation) v1alpha1.QueuePriorityClass {
+	for _, condition := range policyreco.Status.Conditions {
+		if condition.Type == string(v1alpha1.HasBreached) && condition.Status == metav1.ConditionTrue {
+			return v1alpha1.QueuePriorityBreach
+		}
+	}
+	if len(policyreco.Spec.QueuePriorityClass) > 0 {
+		return policyreco.Spec.QueuePriorityClass
+	}
+	if policyreco.Spec.GeneratedAt == nil {
+		return v1alpha1.QueuePriorityNewWorkload
+	}
+	return v1alpha1.QueuePriorityPeriodic
+}
+
+func (r *PolicyRecommendationReconciler) execute(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+
+	logger := ctrl.LoggerFrom(ctx).WithName(PolicyRecoWorkflowCtrlName)
+
+	// The shard membership may have changed while the PolicyRecommendation was waiting in the queue.
+	if !r.Sharder.Owns(req.NamespacedName) {
+		logger.V(1).Info("PolicyRecommendation doesn't belong to the shard of this replica. Skipping.")
+		return ctrl.Result{}, nil
+	}
+
 	// Keeping this here to consider the generatedAt timestamp to be the beginning of the reconcile op
 	generatedAt := metav1.Now()
 
@@ -153,6 +277,16 @@ func (r *PolicyRecommendationReconciler) Reconcile(ctx context.Context, req ctrl
 
 	logger.V(2).Info("PolicyRecomemndation retrieved", "policyreco", policyreco)
 
+	if r.isDeferred(policyreco, generatedAt.Time) {
+		nextOpen := r.RecoWindows.NextOpen(policyreco.Namespace, generatedAt.Time)
+		logger.V(0).Info("Recommendation window is closed. Deferring the recommendation.", "nextOpen", nextOpen)
+		// The deferral is recorded as an event rather than in the RecoTaskProgress condition, which keeps the reason
+		// of the recommendation in force for the HPA enforcer.
+		r.Recorder.Event(&policyreco, eventTypeNormal, RecoTaskDeferred, fmt.Sprintf(RecoTaskDeferredMessage, nextOpen.Format(time.RFC3339)))
+		recoDeferredCounter.WithLabelValues(policyreco.Namespace, policyreco.Name).Inc()
+		return ctrl.Result{RequeueAfter: nextOpen.Sub(generatedAt.Time)}, nil
+	}
+
 	r.Recorder.Event(&policyreco, eventTypeNormal, "HPARecoQueuedForExecution", "This workload has been queued for a fresh HPA recommendation.")
 
 	policyRecoWorkloadGauge.WithLabelValues(policyreco.Namespace, policyreco.Name, policyreco.Spec.WorkloadMeta.TypeMeta.Kind, policyreco.Spec.WorkloadMeta.Name).Set(1)
@@ -283,6 +417,21 @@ func (r *PolicyRecommendationReconciler) Reconcile(ctx context.Context, req ctrl
 	return ctrl.Result{}, nil
 }
 
+// isDeferred returns true if the fresh recommendation of the policyreco has to wait for the next recommendation window.
+// The first recommendation of a workload and the ones after a breach, which demote the workload to a safer policy, are
+// never deferred.
+func (r *PolicyRecommendationReconciler) isDeferred(policyreco v1alpha1.PolicyRecommendation, now time.Time) bool {
+	if r.RecoWindows.IsOpen(policyreco.Namespace, now) || policyreco.Spec.GeneratedAt == nil {
+		return false
+	}
+	for _, condition := range policyreco.Status.Conditions {
+		if condition.Type == string(v1alpha1.HasBreached) && condition.Status == metav1.ConditionTrue {
+			return false
+		}
+	}
+	return true
+}
+
 func logCurrentHPAConfiguration(policyreco v1alpha1.PolicyRecommendation, currentHPAReco *v1alpha1.HPAConfiguration) {
 	policyRecoCurrentMin.WithLabelValues(policyreco.Namespace, policyreco.Name).Set(float64(currentHPAReco.Min))
 	policyRecoCurrentMax.WithLabelValues(policyreco.Namespace, policyreco.Name).Set(float64(currentHPAReco.Max))
@@ -400,9 +549,27 @@ func (r *PolicyRecommendationReconciler) SetupWithManager(mgr ctrl.Manager) erro
 		},
 	}
 	compoundPredicate := predicate.And(predicate.GenerationChangedPredicate{}, queuedTaskPredicate)
+
+	// On a rebalance only the policyrecos with a pending recommendation are requeued on their new replica. The rest
+	// are picked up by their next trigger.
+	pendingRecoFilter := func(policyreco v1alpha1.PolicyRecommendation) bool {
+		spec := policyreco.Spec
+		if spec.QueuedForExecution == nil || !*spec.QueuedForExecution || spec.QueuedForExecutionAt == nil {
+			return false
+		}
+		return spec.GeneratedAt == nil || spec.QueuedForExecutionAt.After(spec.GeneratedAt.Time)
+	}
+
+	if err := mgr.Add(r); err != nil {
+		return err
+	}
+
+	needLeaderElection := !r.Sharder.Sharded()
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.PolicyRecommendation{}).
-		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		WatchesRawSource(sharding.NewRebalanceSource(mgr.GetClient(), r.Sharder, pendingRecoFilter, mgr.GetLogger()),
+			&handler.EnqueueRequestForObject{}).
+		WithOptions(controller.Options{NeedLeaderElection: &needLeaderElection}).
 		WithEventFilter(compoundPredicate).
 		Named(PolicyRecoWorkflowCtrlName).
 		Complete(r)
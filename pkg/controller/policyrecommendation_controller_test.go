@@ -652,6 +652,61 @@ var _ = Describe("PolicyrecommendationController", func() {
 		})
 	})
 
+	Context("When the recommendation window of the namespace is closed", func() {
+		var policyreco *v1alpha1.PolicyRecommendation
+
+		BeforeEach(func() {
+			namespace := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: recoWindowClosedNamespace}}
+			Expect(client.IgnoreAlreadyExists(k8sClient.Create(ctx, namespace))).Should(Succeed())
+		})
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, policyreco)).Should(Succeed())
+		})
+
+		It("Should defer the recommendation of an existing PolicyRecommendation", func() {
+			generatedAt := metav1.NewTime(time.Now().Add(-time.Hour))
+			queuedAt := metav1.Now()
+			policyreco = &v1alpha1.PolicyRecommendation{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      PolicyRecoName,
+					Namespace: recoWindowClosedNamespace,
+				},
+				Spec: v1alpha1.PolicyRecommendationSpec{
+					WorkloadMeta: v1alpha1.WorkloadMeta{
+						Name: PolicyRecoName,
+					},
+					Policy:               "safest-policy",
+					GeneratedAt:          &generatedAt,
+					TransitionedAt:       &generatedAt,
+					QueuedForExecution:   &trueBool,
+					QueuedForExecutionAt: &queuedAt,
+				},
+			}
+			Expect(k8sClient.Create(ctx, policyreco)).Should(Succeed())
+
+			Eventually(func() bool {
+				events := &v1.EventList{}
+				if err := k8sClient.List(ctx, events, client.InNamespace(recoWindowClosedNamespace)); err != nil {
+					return false
+				}
+				for _, event := range events.Items {
+					if event.InvolvedObject.Name == PolicyRecoName && event.Reason == RecoTaskDeferred {
+						return true
+					}
+				}
+				return false
+			}, timeout, interval).Should(BeTrue())
+
+			deferredPolicy := &v1alpha1.PolicyRecommendation{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: PolicyRecoName, Namespace: recoWindowClosedNamespace}, deferredPolicy)).Should(Succeed())
+			for _, condition := range deferredPolicy.Status.Conditions {
+				Expect(condition.Type).ShouldNot(Equal(string(v1alpha1.RecoTaskProgress)))
+			}
+			Expect(*deferredPolicy.Spec.QueuedForExecution).Should(BeTrue())
+			Expect(deferredPolicy.Spec.CurrentHPAConfiguration).Should(Equal(v1alpha1.HPAConfiguration{}))
+		})
+	})
+
 	Context("Test predicates", func() {
 		policyreco := &v1alpha1.PolicyRecommendation{}
 		createdPolicy := &v1alpha1.PolicyRecommendation{}
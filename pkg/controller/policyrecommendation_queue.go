@@ -0,0 +1,202 @@
+package controller
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	v1alpha1 "github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	recoQueueLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "policyreco_queue_latency_seconds",
+			Help:    "Time a policyreco waits in the recommendation queue before its execution starts",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 15),
+		}, []string{"priority_class"},
+	)
+	recoQueueDepth = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "policyreco_queue_depth",
+			Help: "Number of policyrecos waiting in the recommendation queue"}, []string{"priority_class"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(recoQueueLatency, recoQueueDepth)
+}
+
+type recoQueueItem struct {
+	request       ctrl.Request
+	priorityClass v1alpha1.QueuePriorityClass
+	queuedAt      time.Time
+	index         int
+}
+
+// recoQueueItems implements heap.Interface. The items are ordered by their priority class and then by the time they
+// were queued.
+type recoQueueItems []*recoQueueItem
+
+func (items recoQueueItems) Len() int {
+	return len(items)
+}
+
+func (items recoQueueItems) Less(i, j int) bool {
+	if items[i].priorityClass.Rank() != items[j].priorityClass.Rank() {
+		return items[i].priorityClass.HigherThan(items[j].priorityClass)
+	}
+	return items[i].queuedAt.Before(items[j].queuedAt)
+}
+
+func (items recoQueueItems) Swap(i, j int) {
+	items[i], items[j] = items[j], items[i]
+	items[i].index = i
+	items[j].index = j
+}
+
+func (items *recoQueueItems) Push(x interface{}) {
+	item := x.(*recoQueueItem)
+	item.index = len(*items)
+	*items = append(*items, item)
+}
+
+func (items *recoQueueItems) Pop() interface{} {
+	old := *items
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*items = old[:n-1]
+	return item
+}
+
+// recoPriorityQueue is the queue of the PolicyRecommendations waiting for a fresh recommendation. Unlike the
+// controller workqueue it hands out the requests in the order of their priority classes, so that a breached workload
+// doesn't wait behind the periodic requeue of every other workload. Like the workqueue, a request is queued at most
+// once and is never handed out to two workers at the same time.
+type recoPriorityQueue struct {
+	cond         *sync.Cond
+	items        recoQueueItems
+	queued       map[types.NamespacedName]*recoQueueItem
+	processing   map[types.NamespacedName]struct{}
+	dirty        map[types.NamespacedName]*recoQueueItem
+	shuttingDown bool
+}
+
+func newRecoPriorityQueue() *recoPriorityQueue {
+	return &recoPriorityQueue{
+		cond:       sync.NewCond(&sync.Mutex{}),
+		queued:     make(map[types.NamespacedName]*recoQueueItem),
+		processing: make(map[types.NamespacedName]struct{}),
+		dirty:      make(map[types.NamespacedName]*recoQueueItem),
+	}
+}
+
+// Add queues the request with the priority class. A request which is already queued keeps its place unless it is
+// queued again with a higher priority class. A request which is being processed is queued again once it is done.
+func (q *recoPriorityQueue) Add(request ctrl.Request, priorityClass v1alpha1.QueuePriorityClass) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if q.shuttingDown {
+		return
+	}
+
+	key := request.NamespacedName
+	if _, ok := q.processing[key]; ok {
+		if item, ok := q.dirty[key]; ok {
+			if priorityClass.HigherThan(item.priorityClass) {
+				item.priorityClass = priorityClass
+			}
+			return
+		}
+		q.dirty[key] = &recoQueueItem{request: request, priorityClass: priorityClass, queuedAt: time.Now()}
+		return
+	}
+	if item, ok := q.queued[key]; ok {
+		if priorityClass.HigherThan(item.priorityClass) {
+			recoQueueDepth.WithLabelValues(string(item.priorityClass)).Dec()
+			recoQueueDepth.WithLabelValues(string(priorityClass)).Inc()
+			item.priorityClass = priorityClass
+			heap.Fix(&q.items, item.index)
+		}
+		return
+	}
+	q.push(&recoQueueItem{request: request, priorityClass: priorityClass, queuedAt: time.Now()})
+}
+
+// AddAfter queues the request with the priority class once the delay has passed.
+func (q *recoPriorityQueue) AddAfter(request ctrl.Request, priorityClass v1alpha1.QueuePriorityClass, delay time.Duration) {
+	if delay <= 0 {
+		q.Add(request, priorityClass)
+		return
+	}
+	time.AfterFunc(delay, func() {
+		q.Add(request, priorityClass)
+	})
+}
+
+// Get blocks until a request is available and returns the one with the highest priority class. It returns false
+// once the queue is shut down.
+func (q *recoPriorityQueue) Get() (*recoQueueItem, bool) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	for len(q.items) == 0 && !q.shuttingDown {
+		q.cond.Wait()
+	}
+	if q.shuttingDown {
+		return nil, false
+	}
+
+	item := heap.Pop(&q.items).(*recoQueueItem)
+	delete(q.queued, item.request.NamespacedName)
+	q.processing[item.request.NamespacedName] = struct{}{}
+	recoQueueDepth.WithLabelValues(string(item.priorityClass)).Dec()
+	recoQueueLatency.WithLabelValues(string(item.priorityClass)).Observe(time.Since(item.queuedAt).Seconds())
+	return item, true
+}
+
+// Done marks the request as processed. If the request was queued again while it was being processed, it is put back
+// in the queue.
+func (q *recoPriorityQueue) Done(item *recoQueueItem) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	key := item.request.NamespacedName
+	delete(q.processing, key)
+	if dirtyItem, ok := q.dirty[key]; ok {
+		delete(q.dirty, key)
+		if !q.shuttingDown {
+			q.push(dirtyItem)
+		}
+	}
+}
+
+func (q *recoPriorityQueue) Len() int {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return len(q.items)
+}
+
+// ShutDown wakes up the workers waiting in Get and drops the queued requests.
+func (q *recoPriorityQueue) ShutDown() {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.shuttingDown = true
+	for _, item := range q.items {
+		recoQueueDepth.WithLabelValues(string(item.priorityClass)).Dec()
+	}
+	q.items = nil
+	q.queued = make(map[types.NamespacedName]*recoQueueItem)
+	q.cond.Broadcast()
+}
+
+// push must be called with the lock held.
+func (q *recoPriorityQueue) push(item *recoQueueItem) {
+	heap.Push(&q.items, item)
+	q.queued[item.request.NamespacedName] = item
+	recoQueueDepth.WithLabelValues(string(item.priorityClass)).Inc()
+	q.cond.Signal()
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"time"
+
+	v1alpha1 "github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("recoPriorityQueue", func() {
+	var queue *recoPriorityQueue
+
+	requestFor := func(name string) ctrl.Request {
+		return ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: name}}
+	}
+
+	nextName := func() string {
+		item, ok := queue.Get()
+		Expect(ok).To(BeTrue())
+		queue.Done(item)
+		return item.request.Name
+	}
+
+	BeforeEach(func() {
+		queue = newRecoPriorityQueue()
+	})
+
+	AfterEach(func() {
+		queue.ShutDown()
+	})
+
+	It("Should hand out the requests in the order of their priority classes", func() {
+		queue.Add(requestFor("periodic-1"), v1alpha1.QueuePriorityPeriodic)
+		queue.Add(requestFor("workload-change"), v1alpha1.QueuePriorityWorkloadChange)
+		queue.Add(requestFor("periodic-2"), v1alpha1.QueuePriorityPeriodic)
+		queue.Add(requestFor("new-workload"), v1alpha1.QueuePriorityNewWorkload)
+		queue.Add(requestFor("breach"), v1alpha1.QueuePriorityBreach)
+
+		Expect([]string{nextName(), nextName(), nextName(), nextName(), nextName()}).To(Equal(
+			[]string{"breach", "new-workload", "workload-change", "periodic-1", "periodic-2"}))
+	})
+
+	It("Should upgrade but never downgrade the priority class of a queued request", func() {
+		queue.Add(requestFor("periodic"), v1alpha1.QueuePriorityPeriodic)
+		queue.Add(requestFor("upgraded"), v1alpha1.QueuePriorityPeriodic)
+		queue.Add(requestFor("new-workload"), v1alpha1.QueuePriorityNewWorkload)
+		queue.Add(requestFor("upgraded"), v1alpha1.QueuePriorityBreach)
+		queue.Add(requestFor("upgraded"), v1alpha1.QueuePriorityPeriodic)
+
+		Expect(queue.Len()).To(Equal(3))
+		Expect([]string{nextName(), nextName(), nextName()}).To(Equal(
+			[]string{"upgraded", "new-workload", "periodic"}))
+	})
+
+	It("Should queue a request again only after it is done", func() {
+		queue.Add(requestFor("workload"), v1alpha1.QueuePriorityPeriodic)
+		item, ok := queue.Get()
+		Expect(ok).To(BeTrue())
+
+		queue.Add(requestFor("workload"), v1alpha1.QueuePriorityBreach)
+		Expect(queue.Len()).To(Equal(0))
+
+		queue.Done(item)
+		Expect(queue.Len()).To(Equal(1))
+		item, ok = queue.Get()
+		Expect(ok).To(BeTrue())
+		Expect(item.priorityClass).To(Equal(v1alpha1.QueuePriorityBreach))
+		queue.Done(item)
+	})
+
+	It("Should release the workers waiting for a request on shutdown", func() {
+		done := make(chan bool)
+		go func() {
+			_, ok := queue.Get()
+			done <- ok
+		}()
+		queue.ShutDown()
+		Eventually(done, time.Second).Should(Receive(BeFalse()))
+	})
+})
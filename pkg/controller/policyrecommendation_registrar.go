@@ -5,8 +5,11 @@ import (
 	"time"
 
 	ottoscaleriov1alpha1 "github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
+	ottoscalrmetrics "github.com/flipkart-incubator/ottoscalr/pkg/metrics"
 	"github.com/flipkart-incubator/ottoscalr/pkg/policy"
+	"github.com/flipkart-incubator/ottoscalr/pkg/reco"
 	"github.com/flipkart-incubator/ottoscalr/pkg/registry"
+	"github.com/flipkart-incubator/ottoscalr/pkg/sharding"
 	"github.com/flipkart-incubator/ottoscalr/pkg/trigger"
 	"github.com/go-logr/logr"
 	"github.com/prometheus/client_golang/prometheus"
@@ -14,10 +17,13 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	ctrlcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -51,6 +57,7 @@ type PolicyRecommendationRegistrar struct {
 	ClientsRegistry      registry.DeploymentClientRegistry
 	ExcludedNamespaces   []string
 	IncludedNamespaces   []string
+	Sharder              sharding.Sharder
 }
 
 func NewPolicyRecommendationRegistrar(client client.Client,
@@ -59,7 +66,7 @@ func NewPolicyRecommendationRegistrar(client client.Client,
 	monitorManager trigger.MonitorManager,
 	policyStore policy.Store,
 	clientsRegistry registry.DeploymentClientRegistry,
-	excludedNamespaces []string, includedNamespaces []string) *PolicyRecommendationRegistrar {
+	excludedNamespaces []string, includedNamespaces []string, sharder sharding.Sharder) *PolicyRecommendationRegistrar {
 	return &PolicyRecommendationRegistrar{
 		Client:               client,
 		Scheme:               scheme,
@@ -69,39 +76,74 @@ func NewPolicyRecommendationRegistrar(client client.Client,
 		ClientsRegistry:      clientsRegistry,
 		ExcludedNamespaces:   excludedNamespaces,
 		IncludedNamespaces:   includedNamespaces,
+		Sharder:              sharder,
 	}
 }
 
 // +kubebuilder:rbac:groups=argoproj.io,resources=rollouts,verbs=get;list;watch
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch
 // +kubebuilder:rbac:groups=your-group.io,resources=policyrecommendations,verbs=create;get;list;watch;update;delete
 //+kubebuilder:rbac:groups=ottoscaler.io,resources=policyrecommendations/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=ottoscaler.io,resources=policyrecommendations/finalizers,verbs=update
 
-// TODO neerajb Handle the deletion of workloads. We should reregister the monitors.
 func (controller *PolicyRecommendationRegistrar) Reconcile(ctx context.Context,
 	request ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 	logger = logger.WithValues("request", request).WithName(PolicyRecoRegistrarCtrlName)
 
+	// The policyreco is named after its workload, so the workload is handled by the replica owning the policyreco.
+	if !controller.Sharder.Owns(request.NamespacedName) {
+		logger.V(1).Info("Workload doesn't belong to the shard of this replica. Skipping.")
+		return ctrl.Result{}, nil
+	}
+
 	for _, obj := range controller.ClientsRegistry.Clients {
 		object, err := obj.GetObject(request.Namespace, request.Name)
 		if err == nil {
 			return ctrl.Result{}, controller.handleReconcile(ctx, object, controller.Scheme, logger)
 		}
-		if errors.IsNotFound(err) {
-			policyRecoWorkloadGauge.DeletePartialMatch(prometheus.Labels{"namespace": request.Namespace, "policyreco": request.Name})
-		}
 		if !errors.IsNotFound(err) {
 			// Error occurred
 			logger.Error(err, "Failed to get. Requeue the request")
 			return ctrl.Result{RequeueAfter: controller.RequeueDelayDuration}, err
 		}
 	}
-	logger.Info("Rollout or Deployment not found. It could have been deleted.")
+	logger.Info("Rollout, Deployment or StatefulSet not found. It could have been deleted. Deregistering the monitor.")
+	controller.MonitorManager.DeregisterMonitor(request.NamespacedName)
+	deleteWorkloadMetrics(request.Namespace, request.Name)
 	return ctrl.Result{}, nil
 }
 
+// deleteWorkloadMetrics deletes the label sets of all the per workload metrics once the workload is deleted.
+func deleteWorkloadMetrics(namespace, name string) {
+	labels := prometheus.Labels{"namespace": namespace, "policyreco": name}
+	for _, collector := range []interface {
+		DeletePartialMatch(labels prometheus.Labels) int
+	}{
+		policyRecoWorkloadGauge,
+		policyRecoConditionsGauge,
+		policyRecoTaskProgressReasonsGauge,
+		policyRecoTargetMin,
+		policyRecoTargetMax,
+		policyRecoTargetUtil,
+		policyRecoCurrentMin,
+		policyRecoCurrentMax,
+		policyRecoCurrentUtil,
+		targetRecoSLI,
+		reconcileCounter,
+		reconcileErroredCounter,
+		recoDeferredCounter,
+		hpaenforcerReconcileCounter,
+		hpaenforcerAutoscalerObjectUpdatedCounter,
+		hpaenforcerAutoscalerObjectDeletedCounter,
+	} {
+		collector.DeletePartialMatch(labels)
+	}
+	reco.DeleteWorkloadMetrics(namespace, name)
+	ottoscalrmetrics.DeleteWorkloadMetrics(namespace, name)
+}
+
 func (controller *PolicyRecommendationRegistrar) createPolicyRecommendation(
 	ctx context.Context,
 	instance client.Object,
@@ -142,6 +184,7 @@ func (controller *PolicyRecommendationRegistrar) createPolicyRecommendation(
 			TransitionedAt:       &now,
 			QueuedForExecution:   &trueBool,
 			QueuedForExecutionAt: &now,
+			QueuePriorityClass:   ottoscaleriov1alpha1.QueuePriorityNewWorkload,
 		},
 	}
 	policyRecoWorkloadGauge.WithLabelValues(instance.GetNamespace(), instance.GetName(), gvk.Kind, instance.GetName()).Set(1)
@@ -192,12 +235,12 @@ func (controller *PolicyRecommendationRegistrar) handleReconcile(ctx context.Con
 // SetupWithManager sets up the controller with the Manager.
 func (controller *PolicyRecommendationRegistrar) SetupWithManager(mgr ctrl.Manager) error {
 	// TODO: Filter out system and blacklisted namespaces
-	createPredicate := predicate.Funcs{
+	createOrDeletePredicate := predicate.Funcs{
 		CreateFunc: func(e event.CreateEvent) bool {
 			return true
 		},
 		DeleteFunc: func(e event.DeleteEvent) bool {
-			return false
+			return true
 		},
 		UpdateFunc: func(e event.UpdateEvent) bool {
 			return false
@@ -242,8 +285,10 @@ func (controller *PolicyRecommendationRegistrar) SetupWithManager(mgr ctrl.Manag
 			Namespace: obj.GetNamespace()}}}
 	}
 
+	needLeaderElection := !controller.Sharder.Sharded()
 	controllerBuilder := ctrl.NewControllerManagedBy(mgr).
-		Named(PolicyRecoRegistrarCtrlName)
+		Named(PolicyRecoRegistrarCtrlName).
+		WithOptions(ctrlcontroller.Options{NeedLeaderElection: &needLeaderElection})
 
 	for _, object := range controller.ClientsRegistry.Clients {
 		controllerBuilder.
@@ -256,13 +301,28 @@ func (controller *PolicyRecommendationRegistrar) SetupWithManager(mgr ctrl.Manag
 			)
 	}
 
+	var gvks []schema.GroupVersionKind
 	for _, object := range controller.ClientsRegistry.Clients {
 		controllerBuilder.Watches(
 			object.GetObjectType(),
 			handler.EnqueueRequestsFromMapFunc(enqueueFunc),
-			builder.WithPredicates(createPredicate),
+			builder.WithPredicates(createOrDeletePredicate),
 		)
+		gvk, err := apiutil.GVKForObject(object.GetObjectType(), mgr.GetScheme())
+		if err != nil {
+			return err
+		}
+		gvks = append(gvks, gvk)
+	}
+
+	// The create events of the workloads are dropped by the replicas which don't own them, including the ones received
+	// before the first shard membership sync, so the owned workloads are enqueued again on every rebalance.
+	workloadFilter := func(key types.NamespacedName) bool {
+		return controller.isWhitelistedNamespace(key.Namespace)
 	}
+	controllerBuilder.WatchesRawSource(
+		sharding.NewWorkloadRebalanceSource(mgr.GetAPIReader(), controller.Sharder, gvks, workloadFilter, mgr.GetLogger()),
+		&handler.EnqueueRequestForObject{})
 
 	return controllerBuilder.WithEventFilter(namespaceFilter).
 		Complete(controller)
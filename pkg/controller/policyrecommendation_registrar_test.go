@@ -279,6 +279,63 @@ var _ = Describe("PolicyRecommendationRegistrar controller", func() {
 
 	})
 
+	Context("When deleting a Deployment", func() {
+		const deletedDeploymentName = "test-deleted-deployment"
+
+		It("Should deregister the monitor of the Deployment", func() {
+			By("By creating a new Deployment")
+			ctx := context.TODO()
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      deletedDeploymentName,
+					Namespace: DeploymentNamespace,
+				},
+
+				Spec: appsv1.DeploymentSpec{
+					Selector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{
+							"app": "test-app",
+						},
+					},
+					Template: v1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Labels: map[string]string{
+								"app": "test-app",
+							},
+						},
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								{
+									Name:  "test-container",
+									Image: "nginx:1.17.5",
+								},
+							},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, deployment)).Should(Succeed())
+			workload := types.NamespacedName{Name: deletedDeploymentName, Namespace: DeploymentNamespace}
+			createdPolicy := &ottoscaleriov1alpha1.PolicyRecommendation{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, workload, createdPolicy)
+			}, timeout, interval).Should(Succeed())
+			_, deregistered := deregisteredMonitors.Load(workload.String())
+			Expect(deregistered).Should(BeFalse())
+
+			By("By deleting the Deployment")
+			Expect(k8sClient.Delete(ctx, deployment)).Should(Succeed())
+
+			By("Testing that the monitor has been deregistered")
+			Eventually(func() bool {
+				_, ok := deregisteredMonitors.Load(workload.String())
+				return ok
+			}, timeout, interval).Should(BeTrue())
+
+			Expect(k8sClient.Delete(ctx, createdPolicy)).Should(Succeed())
+		})
+	})
+
 	Context("When creating a new Deployment in the excluded namespace", func() {
 		var deployment *appsv1.Deployment
 		var namespace *v1.Namespace
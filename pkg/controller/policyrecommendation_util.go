@@ -17,6 +17,10 @@ const (
 	RecoTaskInProgress        = "RecoTaskInProgress"
 	RecoTaskInProgressMessage = "Recommendation Workflow execution is in progress"
 
+	//Reason for the event recorded for a deferred recommendation
+	RecoTaskDeferred        = "RecoTaskDeferred"
+	RecoTaskDeferredMessage = "Recommendation is deferred till the recommendation window opens at %s"
+
 	RecoTaskErrored        = "RecoTaskErrored"
 	EmptyRecoConfigMessage = "Empty recommendation config could be due to lack of utilization data points or non availability of pod ready time"
 	EmptyHPAConfigMessage  = "HPA config to be applied is empty"
@@ -18,12 +18,14 @@ package controller
 
 import (
 	"errors"
+	"sync"
 	"time"
 
 	rolloutv1alpha1 "github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
 	"github.com/flipkart-incubator/ottoscalr/pkg/autoscaler"
 	"github.com/flipkart-incubator/ottoscalr/pkg/reco"
 	"github.com/flipkart-incubator/ottoscalr/pkg/registry"
+	"github.com/flipkart-incubator/ottoscalr/pkg/sharding"
 	"github.com/flipkart-incubator/ottoscalr/pkg/testutil"
 	"github.com/flipkart-incubator/ottoscalr/pkg/trigger"
 	kedaapi "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
@@ -58,6 +60,8 @@ var (
 	cancel     context.CancelFunc
 
 	queuedAllRecos                 = false
+	recoWindowClosedNamespace      = "reco-window-closed"
+	deregisteredMonitors           sync.Map
 	queuedOneReco                  []bool
 	recommender                    *MockRecommender
 	deploymentTriggerControllerEnv *testutil.TestEnvironment
@@ -115,8 +119,8 @@ var _ = BeforeSuite(func() {
 
 	clientsRegistry = *registry.NewDeploymentClientRegistryBuilder().
 		WithK8sClient(k8sClient1).
-		WithCustomDeploymentClient(registry.NewDeploymentClient(k8sManager1.GetClient())).
-		WithCustomDeploymentClient(registry.NewRolloutClient(k8sManager1.GetClient())).
+		WithCustomDeploymentClient(registry.NewDeploymentClient(k8sManager1.GetClient(), nil)).
+		WithCustomDeploymentClient(registry.NewRolloutClient(k8sManager1.GetClient(), nil)).
 		Build()
 	err = (&DeploymentTriggerController{
 		Client:          k8sManager1.GetClient(),
@@ -136,8 +140,8 @@ var _ = BeforeSuite(func() {
 
 	clientsRegistry = *registry.NewDeploymentClientRegistryBuilder().
 		WithK8sClient(k8sManager.GetClient()).
-		WithCustomDeploymentClient(registry.NewDeploymentClient(k8sManager.GetClient())).
-		WithCustomDeploymentClient(registry.NewRolloutClient(k8sManager.GetClient())).
+		WithCustomDeploymentClient(registry.NewDeploymentClient(k8sManager.GetClient(), nil)).
+		WithCustomDeploymentClient(registry.NewRolloutClient(k8sManager.GetClient(), nil)).
 		Build()
 	err = (&PolicyRecommendationRegistrar{
 		Client:             k8sManager.GetClient(),
@@ -147,6 +151,7 @@ var _ = BeforeSuite(func() {
 		ClientsRegistry:    clientsRegistry,
 		ExcludedNamespaces: excludedNamespaces,
 		IncludedNamespaces: includedNamespaces,
+		Sharder:            sharding.NewSingleShard(),
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
@@ -166,9 +171,14 @@ var _ = BeforeSuite(func() {
 
 	recommender = &MockRecommender{}
 
+	// The recommendation window of this namespace only opens for a minute every new year.
+	closedRecoWindow, err := trigger.NewWindow("0 0 1 1 *", time.Minute)
+	Expect(err).NotTo(HaveOccurred())
+	recoWindows := trigger.NewRecommendationWindows(nil, map[string]*trigger.Window{recoWindowClosedNamespace: closedRecoWindow})
+
 	policyRecoReconciler, err := NewPolicyRecommendationReconciler(k8sManager.GetClient(),
 		k8sManager.GetScheme(), k8sManager.GetEventRecorderFor(PolicyRecoWorkflowCtrlName),
-		1, 3, recommender, newFakePolicyStore(), reco.NewDefaultPolicyIterator(k8sManager.GetClient()),
+		1, 3, recommender, newFakePolicyStore(), sharding.NewSingleShard(), recoWindows, reco.NewDefaultPolicyIterator(k8sManager.GetClient()),
 		reco.NewAgingPolicyIterator(k8sManager.GetClient(), policyAge))
 	Expect(err).NotTo(HaveOccurred())
 	err = policyRecoReconciler.
@@ -186,7 +196,7 @@ var _ = BeforeSuite(func() {
 	autoscalerCRUD = autoscaler.NewScaledobjectClient(k8sManager.GetClient(), &trueBool)
 	hpaenforcer, err := NewHPAEnforcementController(k8sManager.GetClient(),
 		k8sManager.GetScheme(), clientsRegistry, k8sManager.GetEventRecorderFor(HPAEnforcementCtrlName),
-		1, hpaEnforcerIsDryRun, hpaEnforcerExcludedNamespaces, hpaEnforcerIncludedNamespaces, whitelistMode, 3, autoscalerCRUD)
+		1, hpaEnforcerIsDryRun, hpaEnforcerExcludedNamespaces, hpaEnforcerIncludedNamespaces, whitelistMode, 3, autoscalerCRUD, sharding.NewSingleShard(), autoscaler.CPUTargetUtilization)
 	Expect(err).NotTo(HaveOccurred())
 	err = hpaenforcer.
 		SetupWithManager(k8sManager)
@@ -221,8 +231,10 @@ func (f *FakeMonitorManager) RegisterMonitor(workloadType string,
 	return nil
 }
 
-func (f *FakeMonitorManager) DeregisterMonitor(workload types.NamespacedName) {}
-func (f *FakeMonitorManager) Shutdown()                                       {}
+func (f *FakeMonitorManager) DeregisterMonitor(workload types.NamespacedName) {
+	deregisteredMonitors.Store(workload.String(), true)
+}
+func (f *FakeMonitorManager) Shutdown() {}
 
 type FakePolicyStore struct {
 	policies []ottoscaleriov1alpha1.Policy
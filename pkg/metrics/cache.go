@@ -0,0 +1,706 @@
+package metrics
+
+import (
+	"context"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	p8smetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	defaultCacheRefreshOverlap  = 5 * time.Minute
+	defaultCacheMaxEntries      = 5000
+	defaultCacheDownsampleStep  = 5 * time.Minute
+	defaultCacheDownsampleAfter = 24 * time.Hour
+	defaultCachePersistInterval = 10 * time.Minute
+)
+
+var (
+	scraperCacheHitCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{Name: "scraper_cache_hit_count",
+			Help: "Number of scraper queries served from the cache without fetching any data point"}, []string{"query"},
+	)
+
+	scraperCacheDeltaFetchCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{Name: "scraper_cache_delta_fetch_count",
+			Help: "Number of scraper queries served from the cache after fetching the data points missing from it"}, []string{"query"},
+	)
+
+	scraperCacheMissCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{Name: "scraper_cache_miss_count",
+			Help: "Number of scraper queries fetching their whole range as it wasn't cached"}, []string{"query"},
+	)
+
+	scraperCacheUncommittedCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{Name: "scraper_cache_uncommitted_count",
+			Help: "Number of fetches of the scraper cache left out of the cache as some prometheus instances didn't answer"}, []string{"query"},
+	)
+
+	scraperCacheEntries = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "scraper_cache_entries",
+			Help: "Number of series held by the scraper cache"}, []string{},
+	)
+
+	scraperCacheEvictionCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{Name: "scraper_cache_eviction_count",
+			Help: "Number of series evicted from the scraper cache to stay within its max entries"}, []string{},
+	)
+)
+
+func init() {
+	p8smetrics.Registry.MustRegister(scraperCacheHitCount, scraperCacheDeltaFetchCount, scraperCacheMissCount,
+		scraperCacheUncommittedCount, scraperCacheEntries, scraperCacheEvictionCount)
+}
+
+// CachingScraper is a Scraper caching the CPU utilization and the breach data points of the workloads fetched by the
+// wrapped Scraper, so that the recommender, the breach analyzer, the data quality gate and the breach monitors sharing
+// it only fetch the data points of a range missing from the cache. The ranges fetched are aligned to the step of the queries so that the data
+// points of consecutive fetches fall on the same timestamps. The latest refreshOverlap of a series is refetched along
+// with its delta, as the latest data points may not be complete yet. The data points fetched while some Prometheus
+// instances didn't answer are served but not cached, so that they are fetched again on the next query.
+//
+// The utilization older than downsampleAfter is downsampled to the max of every downsampleStep, which keeps its peaks,
+// and is served back at the step of the queries. The breaches are sparse and never downsampled. The data points older
+// than the retention are evicted, and so are the least recently used series beyond maxEntries. If persistPath is set,
+// the cache is loaded from the file and persisted to it periodically and on shutdown so that it survives the restarts
+// of the controller.
+type CachingScraper struct {
+	scraper         Scraper
+	retention       time.Duration
+	refreshOverlap  time.Duration
+	maxEntries      int
+	downsampleStep  time.Duration
+	downsampleAfter time.Duration
+	persistPath     string
+	persistInterval time.Duration
+	entries         map[cacheKey]*cacheEntry
+	mutex           sync.Mutex
+	now             func() time.Time
+	logger          logr.Logger
+}
+
+// cacheKey identifies a cached series.
+type cacheKey struct {
+	Query        string
+	Namespace    string
+	WorkloadType string
+	Workload     string
+	// Params holds the parameters of the query other than the workload, like the red line of the breaches.
+	Params string
+	Step   time.Duration
+}
+
+// cacheEntry is a cached series, covering the range from From to To. The data points from From to DownsampledUntil,
+// if set, are downsampled to the max of every DownsampleStep, and the following ones are kept at the step of the
+// series. The data points are stored as unix milliseconds and values to keep the cache compact.
+type cacheEntry struct {
+	From                  time.Time
+	To                    time.Time
+	DownsampledUntil      time.Time
+	DownsampleStep        time.Duration
+	DownsampledTimestamps []int64
+	DownsampledValues     []float64
+	Timestamps            []int64
+	Values                []float64
+	lastAccess            time.Time
+	mutex                 sync.Mutex
+}
+
+// persistedEntry is a cacheEntry as persisted to the disk.
+type persistedEntry struct {
+	Key                   cacheKey
+	From                  time.Time
+	To                    time.Time
+	DownsampledUntil      time.Time
+	DownsampleStep        time.Duration
+	DownsampledTimestamps []int64
+	DownsampledValues     []float64
+	Timestamps            []int64
+	Values                []float64
+}
+
+// NewCachingScraper returns a CachingScraper wrapping the scraper. The zero values pick the defaults, except for
+// persistPath, which keeps the cache in memory only if empty.
+func NewCachingScraper(scraper Scraper,
+	retention time.Duration,
+	refreshOverlap time.Duration,
+	maxEntries int,
+	downsampleStep time.Duration,
+	downsampleAfter time.Duration,
+	persistPath string,
+	persistInterval time.Duration,
+	logger logr.Logger) *CachingScraper {
+	if refreshOverlap <= 0 {
+		refreshOverlap = defaultCacheRefreshOverlap
+	}
+	if maxEntries <= 0 {
+		maxEntries = defaultCacheMaxEntries
+	}
+	if downsampleStep <= 0 {
+		downsampleStep = defaultCacheDownsampleStep
+	}
+	if downsampleAfter <= 0 {
+		downsampleAfter = defaultCacheDownsampleAfter
+	}
+	// The data points refetched along with the delta are never downsampled.
+	if downsampleAfter < refreshOverlap+downsampleStep {
+		downsampleAfter = refreshOverlap + downsampleStep
+	}
+	if persistInterval <= 0 {
+		persistInterval = defaultCachePersistInterval
+	}
+	cs := &CachingScraper{
+		scraper:         scraper,
+		retention:       retention,
+		refreshOverlap:  refreshOverlap,
+		maxEntries:      maxEntries,
+		downsampleStep:  downsampleStep,
+		downsampleAfter: downsampleAfter,
+		persistPath:     persistPath,
+		persistInterval: persistInterval,
+		entries:         make(map[cacheKey]*cacheEntry),
+		now:             time.Now,
+		logger:          logger,
+	}
+	if len(persistPath) > 0 {
+		if err := cs.load(); err != nil && !os.IsNotExist(err) {
+			logger.Error(err, "Error while loading the scraper cache. Starting with an empty cache.", "path", persistPath)
+		}
+	}
+	return cs
+}
+
+func (cs *CachingScraper) GetAverageCPUUtilizationByWorkload(ctx context.Context,
+	namespace string,
+	workloadType string,
+	workload string,
+	start time.Time,
+	end time.Time,
+	step time.Duration) ([]DataPoint, error) {
+
+	key := cacheKey{Query: CPUUtilizationDataPointsQuery, Namespace: namespace, WorkloadType: workloadType,
+		Workload: workload, Step: step}
+	return cs.get(ctx, key, start, end, true, func(ctx context.Context, start time.Time,
+		end time.Time) ([]DataPoint, error) {
+		return cs.scraper.GetAverageCPUUtilizationByWorkload(ctx, namespace, workloadType, workload, start, end, step)
+	})
+}
+
+func (cs *CachingScraper) GetCPUUtilizationBreachDataPoints(ctx context.Context,
+	namespace,
+	workloadType,
+	workload string,
+	redLineUtilization float64,
+	start time.Time,
+	end time.Time,
+	step time.Duration) ([]DataPoint, error) {
+
+	key := cacheKey{Query: BreachDataPointsQuery, Namespace: namespace, WorkloadType: workloadType,
+		Workload: workload, Params: strconv.FormatFloat(redLineUtilization, 'g', -1, 64), Step: step}
+	dataPoints, err := cs.get(ctx, key, start, end, false, func(ctx context.Context, start time.Time,
+		end time.Time) ([]DataPoint, error) {
+		return cs.scraper.GetCPUUtilizationBreachDataPoints(ctx, namespace, workloadType, workload,
+			redLineUtilization, start, end, step)
+	})
+	if len(dataPoints) == 0 {
+		// The scraper returns no data points if there was no breach.
+		return nil, err
+	}
+	return dataPoints, err
+}
+
+// GetAverageCPUUtilizationByInstance returns the utilization of the workload returned by every instance like the
+// wrapped InstanceScraper does. The series of every instance is cached apart, so that the series of an instance which
+// didn't answer is fetched again on the next query while those of the other instances are served from the cache. The
+// instances are fetched together, once for every range missing from their series. The series are never downsampled,
+// as the data quality checks look at the gaps and the flatlines of the raw data points.
+func (cs *CachingScraper) GetAverageCPUUtilizationByInstance(ctx context.Context,
+	namespace string,
+	workloadType string,
+	workload string,
+	start time.Time,
+	end time.Time,
+	step time.Duration) (map[string][]DataPoint, error) {
+
+	scraper, ok := cs.scraper.(InstanceScraper)
+	if !ok {
+		return nil, fmt.Errorf("the scraper doesn't scrape the utilization of every instance apart")
+	}
+	instances, ok := cs.scraper.(interface{ instanceAddresses() []string })
+	if !ok {
+		return scraper.GetAverageCPUUtilizationByInstance(ctx, namespace, workloadType, workload, start, end, step)
+	}
+
+	// instanceFetch is a fetch of the series of all the instances over a range.
+	type instanceFetch struct {
+		dataPointsByInstance map[string][]DataPoint
+		unanswered           map[string]bool
+		err                  error
+	}
+	fetches := make(map[[2]int64]*instanceFetch)
+	fetchInstance := func(address string) func(ctx context.Context, start time.Time, end time.Time) ([]DataPoint, error) {
+		return func(ctx context.Context, start time.Time, end time.Time) ([]DataPoint, error) {
+			fetchKey := [2]int64{start.UnixNano(), end.UnixNano()}
+			fetch, ok := fetches[fetchKey]
+			if !ok {
+				fetchCtx, report := withFanOutReport(ctx)
+				fetch = &instanceFetch{unanswered: make(map[string]bool)}
+				fetch.dataPointsByInstance, fetch.err = scraper.GetAverageCPUUtilizationByInstance(fetchCtx, namespace,
+					workloadType, workload, start, end, step)
+				for _, unanswered := range report.unansweredInstances() {
+					fetch.unanswered[unanswered] = true
+				}
+				fetches[fetchKey] = fetch
+			}
+			if fetch.err != nil {
+				return nil, fetch.err
+			}
+			if fetch.unanswered[address] {
+				fanOutReportFrom(ctx).recordUnanswered(address)
+			}
+			return fetch.dataPointsByInstance[address], nil
+		}
+	}
+
+	dataPointsByInstance := make(map[string][]DataPoint)
+	for _, address := range instances.instanceAddresses() {
+		key := cacheKey{Query: InstanceCPUUtilizationQuery, Namespace: namespace, WorkloadType: workloadType,
+			Workload: workload, Params: address, Step: step}
+		dataPoints, err := cs.get(ctx, key, start, end, false, fetchInstance(address))
+		if err != nil {
+			return nil, err
+		}
+		if len(dataPoints) > 0 {
+			dataPointsByInstance[address] = dataPoints
+		}
+	}
+	if len(dataPointsByInstance) == 0 {
+		return nil, fmt.Errorf("unable to getCPUUtlizationDataPoints metrics from any of the prometheus instances")
+	}
+	return dataPointsByInstance, nil
+}
+
+func (cs *CachingScraper) GetACLByWorkload(ctx context.Context,
+	namespace,
+	workloadType,
+	workload string) (time.Duration, error) {
+	return cs.scraper.GetACLByWorkload(ctx, namespace, workloadType, workload)
+}
+
+// get returns the data points of the series from start to end, fetching those missing from the cache with fetch. The
+// data points older than downsampleAfter are downsampled if downsample is true.
+func (cs *CachingScraper) get(ctx context.Context,
+	key cacheKey,
+	start time.Time,
+	end time.Time,
+	downsample bool,
+	fetch func(ctx context.Context, start time.Time, end time.Time) ([]DataPoint, error)) ([]DataPoint, error) {
+
+	entry := cs.entry(key)
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+
+	step := key.Step
+	if step <= 0 {
+		step = time.Nanosecond
+	}
+	// fetchAll fetches the data points from start to end, and returns whether all the instances answered.
+	fetchAll := func(start time.Time, end time.Time) ([]DataPoint, bool, error) {
+		fetchCtx, report := withFanOutReport(ctx)
+		dataPoints, err := fetch(fetchCtx, start, end)
+		if err == nil && !report.complete() {
+			scraperCacheUncommittedCount.WithLabelValues(key.Query).Inc()
+			cs.logger.V(1).Info("Not caching the data points as some prometheus instances didn't answer.",
+				"query", key.Query, "workload", key.Workload, "start", start, "end", end)
+			return dataPoints, false, nil
+		}
+		return dataPoints, true, err
+	}
+
+	alignedStart := start.Truncate(step)
+	// The data points fetched from the instances not all answering are served along with the cached ones.
+	var prefix, delta []DataPoint
+	var deltaStart time.Time
+	if entry.To.IsZero() || end.Before(entry.From) || start.After(entry.To) {
+		scraperCacheMissCount.WithLabelValues(key.Query).Inc()
+		dataPoints, complete, err := fetchAll(alignedStart, end)
+		if err != nil {
+			return nil, err
+		}
+		if !complete {
+			return dataPointsBetween(dataPoints, start, end), nil
+		}
+		entry.replace(alignedStart, end, dataPoints)
+	} else {
+		fetched := false
+		// The cached data points are served even if those missing can't be fetched, as the scraper fails when a
+		// short range has no data points at all.
+		if alignedStart.Before(entry.From) {
+			fetched = true
+			dataPoints, complete, err := fetchAll(alignedStart, entry.From)
+			switch {
+			case err != nil:
+				cs.logger.V(1).Info("Unable to fetch the data points missing from the start of the cached series.",
+					"query", key.Query, "workload", key.Workload, "error", err.Error())
+			case complete:
+				entry.prepend(alignedStart, dataPoints)
+			default:
+				prefix = dataPointsBetween(dataPoints, start, entry.From.Add(-time.Nanosecond))
+			}
+		}
+		if end.Sub(entry.To) >= step {
+			fetched = true
+			deltaStart = entry.To.Add(-cs.refreshOverlap).Truncate(step)
+			if deltaStart.Before(entry.rawFrom()) {
+				deltaStart = entry.rawFrom()
+			}
+			dataPoints, complete, err := fetchAll(deltaStart, end)
+			switch {
+			case err != nil:
+				cs.logger.V(1).Info("Unable to fetch the data points missing from the end of the cached series.",
+					"query", key.Query, "workload", key.Workload, "error", err.Error())
+			case complete:
+				entry.append(deltaStart, end, dataPoints)
+			default:
+				delta = dataPointsBetween(dataPoints, deltaStart, end)
+			}
+		}
+		if fetched {
+			scraperCacheDeltaFetchCount.WithLabelValues(key.Query).Inc()
+		} else {
+			scraperCacheHitCount.WithLabelValues(key.Query).Inc()
+		}
+	}
+
+	if cs.retention > 0 {
+		entry.evictBefore(cs.now().Add(-cs.retention).Truncate(step))
+	}
+	if downsampleStep := cs.downsampleStepOf(step); downsample && downsampleStep > 0 {
+		entry.downsample(cs.now().Add(-cs.downsampleAfter), downsampleStep)
+	}
+
+	dataPoints := append(prefix, entry.dataPoints(start, end, step)...)
+	if delta != nil {
+		dataPoints = append(dataPointsBetween(dataPoints, start, deltaStart.Add(-time.Nanosecond)), delta...)
+	}
+	return dataPoints, nil
+}
+
+// downsampleStepOf returns the step the series of the step are downsampled to, zero if they aren't. The series are
+// downsampled to a multiple of their step so that the downsampled data points are served back on their timestamps.
+func (cs *CachingScraper) downsampleStepOf(step time.Duration) time.Duration {
+	if cs.downsampleStep <= step {
+		return 0
+	}
+	return cs.downsampleStep.Truncate(step)
+}
+
+// entry returns the entry of the key, creating it if it isn't cached, and evicts the least recently used entries
+// beyond maxEntries.
+func (cs *CachingScraper) entry(key cacheKey) *cacheEntry {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	entry, ok := cs.entries[key]
+	if !ok {
+		entry = &cacheEntry{}
+		cs.entries[key] = entry
+		cs.evictLeastRecentlyUsed(key)
+		scraperCacheEntries.WithLabelValues().Set(float64(len(cs.entries)))
+	}
+	entry.lastAccess = cs.now()
+	return entry
+}
+
+// evictLeastRecentlyUsed evicts the least recently used entries other than the key beyond maxEntries.
+func (cs *CachingScraper) evictLeastRecentlyUsed(key cacheKey) {
+	for len(cs.entries) > cs.maxEntries {
+		var oldestKey cacheKey
+		var oldest *cacheEntry
+		for k, entry := range cs.entries {
+			if k != key && (oldest == nil || entry.lastAccess.Before(oldest.lastAccess)) {
+				oldestKey, oldest = k, entry
+			}
+		}
+		if oldest == nil {
+			return
+		}
+		delete(cs.entries, oldestKey)
+		scraperCacheEvictionCount.WithLabelValues().Inc()
+	}
+}
+
+// Start persists the cache periodically until the context is cancelled, and once more after that. It's a no-op if
+// the cache isn't persisted.
+func (cs *CachingScraper) Start(ctx context.Context) error {
+	if len(cs.persistPath) == 0 {
+		return nil
+	}
+	ticker := time.NewTicker(cs.persistInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := cs.persist(); err != nil {
+				cs.logger.Error(err, "Error while persisting the scraper cache.", "path", cs.persistPath)
+			}
+			return nil
+		case <-ticker.C:
+			if err := cs.persist(); err != nil {
+				cs.logger.Error(err, "Error while persisting the scraper cache.", "path", cs.persistPath)
+			}
+		}
+	}
+}
+
+// NeedLeaderElection ensures that the cache is persisted on every replica, all of which query through it.
+func (cs *CachingScraper) NeedLeaderElection() bool {
+	return false
+}
+
+// persist writes the cache to a temporary file which is then renamed to persistPath, so that a crash never leaves a
+// partial cache behind.
+func (cs *CachingScraper) persist() error {
+	cs.mutex.Lock()
+	entries := make(map[cacheKey]*cacheEntry, len(cs.entries))
+	for key, entry := range cs.entries {
+		entries[key] = entry
+	}
+	cs.mutex.Unlock()
+
+	persisted := make([]persistedEntry, 0, len(entries))
+	for key, entry := range entries {
+		entry.mutex.Lock()
+		if !entry.To.IsZero() {
+			persisted = append(persisted, persistedEntry{Key: key, From: entry.From, To: entry.To,
+				DownsampledUntil: entry.DownsampledUntil, DownsampleStep: entry.DownsampleStep,
+				DownsampledTimestamps: append([]int64(nil), entry.DownsampledTimestamps...),
+				DownsampledValues:     append([]float64(nil), entry.DownsampledValues...),
+				Timestamps:            append([]int64(nil), entry.Timestamps...),
+				Values:                append([]float64(nil), entry.Values...)})
+		}
+		entry.mutex.Unlock()
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(cs.persistPath), filepath.Base(cs.persistPath)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if err := gob.NewEncoder(file).Encode(persisted); err != nil {
+		file.Close()
+		return fmt.Errorf("error encoding the scraper cache: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), cs.persistPath)
+}
+
+// load loads the cache from persistPath.
+func (cs *CachingScraper) load() error {
+	file, err := os.Open(cs.persistPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var persisted []persistedEntry
+	if err := gob.NewDecoder(file).Decode(&persisted); err != nil {
+		return fmt.Errorf("error decoding the scraper cache: %v", err)
+	}
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+	for _, p := range persisted {
+		if len(p.Timestamps) != len(p.Values) || len(p.DownsampledTimestamps) != len(p.DownsampledValues) {
+			continue
+		}
+		cs.entries[p.Key] = &cacheEntry{From: p.From, To: p.To, DownsampledUntil: p.DownsampledUntil,
+			DownsampleStep: p.DownsampleStep, DownsampledTimestamps: p.DownsampledTimestamps,
+			DownsampledValues: p.DownsampledValues, Timestamps: p.Timestamps, Values: p.Values, lastAccess: cs.now()}
+	}
+	cs.evictLeastRecentlyUsed(cacheKey{})
+	scraperCacheEntries.WithLabelValues().Set(float64(len(cs.entries)))
+	cs.logger.Info("Loaded the scraper cache.", "path", cs.persistPath, "entries", len(cs.entries))
+	return nil
+}
+
+// replace replaces the series with the data points fetched from start to end.
+func (e *cacheEntry) replace(start time.Time, end time.Time, dataPoints []DataPoint) {
+	e.From, e.To = start, end
+	e.DownsampledUntil, e.DownsampleStep = time.Time{}, 0
+	e.DownsampledTimestamps, e.DownsampledValues = nil, nil
+	e.Timestamps, e.Values = nil, nil
+	e.add(dataPoints, start, end)
+}
+
+// prepend adds the data points fetched from start to the beginning of the series, before From. They are downsampled
+// if the beginning of the series is.
+func (e *cacheEntry) prepend(start time.Time, dataPoints []DataPoint) {
+	if !e.DownsampledUntil.IsZero() {
+		timestamps, values := downsampleMax(dataPointsBetween(dataPoints, start, e.From.Add(-time.Nanosecond)),
+			e.DownsampleStep)
+		if n := len(timestamps); n > 0 && len(e.DownsampledTimestamps) > 0 &&
+			timestamps[n-1] == e.DownsampledTimestamps[0] {
+			e.DownsampledValues[0] = math.Max(e.DownsampledValues[0], values[n-1])
+			timestamps, values = timestamps[:n-1], values[:n-1]
+		}
+		e.DownsampledTimestamps = append(timestamps, e.DownsampledTimestamps...)
+		e.DownsampledValues = append(values, e.DownsampledValues...)
+		e.From = start
+		return
+	}
+	timestamps, values := e.Timestamps, e.Values
+	e.Timestamps, e.Values = nil, nil
+	e.add(dataPoints, start, e.From.Add(-time.Nanosecond))
+	e.Timestamps = append(e.Timestamps, timestamps...)
+	e.Values = append(e.Values, values...)
+	e.From = start
+}
+
+// append replaces the data points of the series from start, which mustn't be downsampled, with those fetched from
+// start to end.
+func (e *cacheEntry) append(start time.Time, end time.Time, dataPoints []DataPoint) {
+	keep := sort.Search(len(e.Timestamps), func(i int) bool {
+		return e.Timestamps[i] >= start.UnixMilli()
+	})
+	e.Timestamps, e.Values = e.Timestamps[:keep], e.Values[:keep]
+	e.add(dataPoints, start, end)
+	e.To = end
+}
+
+// add appends the data points from start to end, which must all be after those of the series.
+func (e *cacheEntry) add(dataPoints []DataPoint, start time.Time, end time.Time) {
+	for _, dp := range dataPoints {
+		if dp.Timestamp.Before(start) || dp.Timestamp.After(end) {
+			continue
+		}
+		timestamp := dp.Timestamp.UnixMilli()
+		if n := len(e.Timestamps); n > 0 && e.Timestamps[n-1] >= timestamp {
+			continue
+		}
+		e.Timestamps = append(e.Timestamps, timestamp)
+		e.Values = append(e.Values, dp.Value)
+	}
+}
+
+// rawFrom returns the start of the data points of the series which aren't downsampled.
+func (e *cacheEntry) rawFrom() time.Time {
+	if e.DownsampledUntil.After(e.From) {
+		return e.DownsampledUntil
+	}
+	return e.From
+}
+
+// downsample downsamples the data points of the series before the cutoff, within To, to the max of every
+// downsampleStep. The series downsampled already keep their downsample step.
+func (e *cacheEntry) downsample(cutoff time.Time, downsampleStep time.Duration) {
+	if !e.DownsampledUntil.IsZero() {
+		downsampleStep = e.DownsampleStep
+	}
+	cutoff = cutoff.Truncate(downsampleStep)
+	if e.To.Before(cutoff) {
+		cutoff = e.To.Truncate(downsampleStep)
+	}
+	if !cutoff.After(e.rawFrom()) {
+		return
+	}
+	downsampled := sort.Search(len(e.Timestamps), func(i int) bool {
+		return e.Timestamps[i] >= cutoff.UnixMilli()
+	})
+	var dataPoints []DataPoint
+	for i := 0; i < downsampled; i++ {
+		dataPoints = append(dataPoints, DataPoint{Timestamp: time.UnixMilli(e.Timestamps[i]), Value: e.Values[i]})
+	}
+	timestamps, values := downsampleMax(dataPoints, downsampleStep)
+	e.DownsampledTimestamps = append(e.DownsampledTimestamps, timestamps...)
+	e.DownsampledValues = append(e.DownsampledValues, values...)
+	e.Timestamps = append([]int64(nil), e.Timestamps[downsampled:]...)
+	e.Values = append([]float64(nil), e.Values[downsampled:]...)
+	e.DownsampledUntil, e.DownsampleStep = cutoff, downsampleStep
+}
+
+// evictBefore evicts the data points before the cutoff.
+func (e *cacheEntry) evictBefore(cutoff time.Time) {
+	if !e.From.Before(cutoff) {
+		return
+	}
+	// The downsampled data points are evicted along with the last of the data points they stand for.
+	evicted := sort.Search(len(e.DownsampledTimestamps), func(i int) bool {
+		return e.DownsampledTimestamps[i]+e.DownsampleStep.Milliseconds() > cutoff.UnixMilli()
+	})
+	e.DownsampledTimestamps = append([]int64(nil), e.DownsampledTimestamps[evicted:]...)
+	e.DownsampledValues = append([]float64(nil), e.DownsampledValues[evicted:]...)
+	if !e.DownsampledUntil.After(cutoff) {
+		e.DownsampledUntil, e.DownsampleStep = time.Time{}, 0
+	}
+
+	evicted = sort.Search(len(e.Timestamps), func(i int) bool {
+		return e.Timestamps[i] >= cutoff.UnixMilli()
+	})
+	e.Timestamps = append([]int64(nil), e.Timestamps[evicted:]...)
+	e.Values = append([]float64(nil), e.Values[evicted:]...)
+	e.From = cutoff
+	if e.To.Before(cutoff) {
+		e.To = cutoff
+	}
+}
+
+// dataPoints returns a copy of the data points of the series from start to end, with the downsampled ones served back
+// at every step of their downsample step.
+func (e *cacheEntry) dataPoints(start time.Time, end time.Time, step time.Duration) []DataPoint {
+	var dataPoints []DataPoint
+	for i, timestamp := range e.DownsampledTimestamps {
+		bucket := time.UnixMilli(timestamp)
+		for t := bucket; t.Before(bucket.Add(e.DownsampleStep)) && t.Before(e.DownsampledUntil); t = t.Add(step) {
+			if !t.Before(e.From) && !t.Before(start) && !t.After(end) {
+				dataPoints = append(dataPoints, DataPoint{Timestamp: t, Value: e.DownsampledValues[i]})
+			}
+		}
+	}
+	first := sort.Search(len(e.Timestamps), func(i int) bool {
+		return e.Timestamps[i] >= start.UnixMilli()
+	})
+	for i := first; i < len(e.Timestamps) && e.Timestamps[i] <= end.UnixMilli(); i++ {
+		dataPoints = append(dataPoints, DataPoint{Timestamp: time.UnixMilli(e.Timestamps[i]), Value: e.Values[i]})
+	}
+	return dataPoints
+}
+
+// downsampleMax returns the timestamps and the values of the max of the data points of every downsampleStep.
+func downsampleMax(dataPoints []DataPoint, downsampleStep time.Duration) ([]int64, []float64) {
+	var timestamps []int64
+	var values []float64
+	for _, dp := range dataPoints {
+		bucket := dp.Timestamp.Truncate(downsampleStep).UnixMilli()
+		if n := len(timestamps); n > 0 && timestamps[n-1] == bucket {
+			values[n-1] = math.Max(values[n-1], dp.Value)
+			continue
+		}
+		timestamps = append(timestamps, bucket)
+		values = append(values, dp.Value)
+	}
+	return timestamps, values
+}
+
+// dataPointsBetween returns the data points from start to end.
+func dataPointsBetween(dataPoints []DataPoint, start time.Time, end time.Time) []DataPoint {
+	var between []DataPoint
+	for _, dp := range dataPoints {
+		if !dp.Timestamp.Before(start) && !dp.Timestamp.After(end) {
+			between = append(between, dp)
+		}
+	}
+	return between
+}
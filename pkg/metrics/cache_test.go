@@ -0,0 +1,368 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// fetchedRange is a range fetched by the fakeRangeScraper.
+type fetchedRange struct {
+	start, end time.Time
+}
+
+// fakeRangeScraper returns a data point at every step of the ranges, whose value is the minute of its timestamp, and
+// records the ranges fetched. It reports an instance which didn't answer if unanswered is set.
+type fakeRangeScraper struct {
+	fetched    []fetchedRange
+	err        error
+	unanswered bool
+}
+
+func (fs *fakeRangeScraper) fetch(ctx context.Context, start time.Time, end time.Time) {
+	fs.fetched = append(fs.fetched, fetchedRange{start, end})
+	if fs.unanswered {
+		fanOutReportFrom(ctx).recordUnanswered("p8s-2")
+	}
+}
+
+func (fs *fakeRangeScraper) series(start time.Time, end time.Time, step time.Duration) []DataPoint {
+	var dataPoints []DataPoint
+	for t := start; !t.After(end); t = t.Add(step) {
+		dataPoints = append(dataPoints, DataPoint{Timestamp: t, Value: float64(t.Minute())})
+	}
+	return dataPoints
+}
+
+func (fs *fakeRangeScraper) GetAverageCPUUtilizationByWorkload(ctx context.Context, namespace, workloadType,
+	workload string, start time.Time, end time.Time, step time.Duration) ([]DataPoint, error) {
+	fs.fetch(ctx, start, end)
+	if fs.err != nil {
+		return nil, fs.err
+	}
+	return fs.series(start, end, step), nil
+}
+
+func (fs *fakeRangeScraper) GetCPUUtilizationBreachDataPoints(ctx context.Context, namespace, workloadType,
+	workload string, redLineUtilization float64, start time.Time, end time.Time,
+	step time.Duration) ([]DataPoint, error) {
+	fs.fetch(ctx, start, end)
+	if fs.unanswered {
+		return nil, nil
+	}
+	var breaches []DataPoint
+	for _, dp := range fs.series(start, end, step) {
+		if dp.Value >= redLineUtilization {
+			breaches = append(breaches, dp)
+		}
+	}
+	return breaches, nil
+}
+
+func (fs *fakeRangeScraper) GetACLByWorkload(ctx context.Context, namespace, workloadType,
+	workload string) (time.Duration, error) {
+	return time.Minute, nil
+}
+
+// fakeInstanceScraper is a fakeRangeScraper returning the same series for two instances. The second instance doesn't
+// answer if unanswered is set.
+type fakeInstanceScraper struct {
+	fakeRangeScraper
+}
+
+func (fs *fakeInstanceScraper) instanceAddresses() []string {
+	return []string{"p8s-1", "p8s-2"}
+}
+
+func (fs *fakeInstanceScraper) GetAverageCPUUtilizationByInstance(ctx context.Context, namespace, workloadType,
+	workload string, start time.Time, end time.Time, step time.Duration) (map[string][]DataPoint, error) {
+	fs.fetch(ctx, start, end)
+	if fs.err != nil {
+		return nil, fs.err
+	}
+	dataPointsByInstance := map[string][]DataPoint{"p8s-1": fs.series(start, end, step)}
+	if !fs.unanswered {
+		dataPointsByInstance["p8s-2"] = fs.series(start, end, step)
+	}
+	return dataPointsByInstance, nil
+}
+
+var _ = Describe("CachingScraper", func() {
+
+	var (
+		t0      = time.Date(2023, time.May, 1, 0, 0, 0, 0, time.UTC)
+		step    = time.Minute
+		fake    *fakeRangeScraper
+		now     time.Time
+		newFunc func(retention time.Duration, maxEntries int, persistPath string) *CachingScraper
+	)
+
+	count := func(counter *prometheus.CounterVec) float64 {
+		metric := &dto.Metric{}
+		Expect(counter.WithLabelValues(CPUUtilizationDataPointsQuery).Write(metric)).To(Succeed())
+		return metric.GetCounter().GetValue()
+	}
+
+	BeforeEach(func() {
+		fake = &fakeRangeScraper{}
+		now = t0.Add(2 * time.Hour)
+		newFunc = func(retention time.Duration, maxEntries int, persistPath string) *CachingScraper {
+			cs := NewCachingScraper(fake, retention, 5*time.Minute, maxEntries, 0, 0, persistPath, 0, logr.Discard())
+			cs.now = func() time.Time { return now }
+			return cs
+		}
+	})
+
+	utilization := func(cs *CachingScraper, workload string, start time.Time, end time.Time) []DataPoint {
+		dataPoints, err := cs.GetAverageCPUUtilizationByWorkload(context.TODO(), "default", "Deployment", workload,
+			start, end, step)
+		Expect(err).ToNot(HaveOccurred())
+		return dataPoints
+	}
+
+	expectSeries := func(dataPoints []DataPoint, start time.Time, end time.Time) {
+		Expect(dataPoints).To(HaveLen(int(end.Sub(start)/step) + 1))
+		for i, dp := range dataPoints {
+			Expect(dp.Timestamp).To(BeTemporally("==", start.Add(time.Duration(i)*step)))
+			Expect(dp.Value).To(Equal(float64(dp.Timestamp.Minute())))
+		}
+	}
+
+	It("should fetch only the delta of the cached series along with the refresh overlap", func() {
+		cs := newFunc(0, 0, "")
+		expectSeries(utilization(cs, "workload", t0, t0.Add(time.Hour)), t0, t0.Add(time.Hour))
+		expectSeries(utilization(cs, "workload", t0.Add(10*time.Minute), t0.Add(90*time.Minute)),
+			t0.Add(10*time.Minute), t0.Add(90*time.Minute))
+		expectSeries(utilization(cs, "workload", t0.Add(20*time.Minute), t0.Add(90*time.Minute+10*time.Second)),
+			t0.Add(20*time.Minute), t0.Add(90*time.Minute))
+
+		Expect(fake.fetched).To(Equal([]fetchedRange{
+			{t0, t0.Add(time.Hour)},
+			{t0.Add(55 * time.Minute), t0.Add(90 * time.Minute)},
+		}))
+	})
+
+	It("should count the queries served from the cache apart from those fetching their delta", func() {
+		cs := newFunc(0, 0, "")
+		hits, deltaFetches, misses := count(scraperCacheHitCount), count(scraperCacheDeltaFetchCount),
+			count(scraperCacheMissCount)
+		utilization(cs, "workload", t0, t0.Add(time.Hour))
+		utilization(cs, "workload", t0, t0.Add(time.Hour))
+		utilization(cs, "workload", t0, t0.Add(90*time.Minute))
+
+		Expect(count(scraperCacheMissCount) - misses).To(Equal(1.0))
+		Expect(count(scraperCacheHitCount) - hits).To(Equal(1.0))
+		Expect(count(scraperCacheDeltaFetchCount) - deltaFetches).To(Equal(1.0))
+	})
+
+	It("should serve the data points fetched while some instances didn't answer without caching them", func() {
+		cs := newFunc(0, 0, "")
+		fake.unanswered = true
+		expectSeries(utilization(cs, "workload", t0, t0.Add(time.Hour)), t0, t0.Add(time.Hour))
+		fake.unanswered = false
+		utilization(cs, "workload", t0, t0.Add(time.Hour))
+
+		fake.unanswered = true
+		expectSeries(utilization(cs, "workload", t0, t0.Add(90*time.Minute)), t0, t0.Add(90*time.Minute))
+		fake.unanswered = false
+		utilization(cs, "workload", t0, t0.Add(90*time.Minute))
+
+		Expect(fake.fetched).To(Equal([]fetchedRange{
+			{t0, t0.Add(time.Hour)},
+			{t0, t0.Add(time.Hour)},
+			{t0.Add(55 * time.Minute), t0.Add(90 * time.Minute)},
+			{t0.Add(55 * time.Minute), t0.Add(90 * time.Minute)},
+		}))
+	})
+
+	It("should fetch the breaches again once the instances skipped answer", func() {
+		cs := newFunc(0, 0, "")
+		breaches := func(end time.Time) []DataPoint {
+			dataPoints, err := cs.GetCPUUtilizationBreachDataPoints(context.TODO(), "default", "Deployment",
+				"workload", 58, t0, end, step)
+			Expect(err).ToNot(HaveOccurred())
+			return dataPoints
+		}
+		Expect(breaches(t0.Add(50 * time.Minute))).To(BeNil())
+		fake.unanswered = true
+		Expect(breaches(t0.Add(time.Hour))).To(BeNil())
+		fake.unanswered = false
+		Expect(breaches(t0.Add(time.Hour))).To(HaveLen(2))
+
+		Expect(fake.fetched).To(Equal([]fetchedRange{
+			{t0, t0.Add(50 * time.Minute)},
+			{t0.Add(45 * time.Minute), t0.Add(time.Hour)},
+			{t0.Add(45 * time.Minute), t0.Add(time.Hour)},
+		}))
+	})
+
+	It("should downsample the utilization older than downsampleAfter to the max of every downsample step", func() {
+		cs := NewCachingScraper(fake, 0, 5*time.Minute, 0, 10*time.Minute, time.Hour, "", 0, logr.Discard())
+		cs.now = func() time.Time { return now }
+		utilization(cs, "workload", t0, t0.Add(2*time.Hour))
+		entry := cs.entries[cacheKey{Query: CPUUtilizationDataPointsQuery, Namespace: "default",
+			WorkloadType: "Deployment", Workload: "workload", Step: step}]
+		Expect(entry.DownsampledUntil).To(BeTemporally("==", t0.Add(time.Hour)))
+		Expect(entry.DownsampledTimestamps).To(HaveLen(6))
+		Expect(entry.DownsampledValues).To(Equal([]float64{9, 19, 29, 39, 49, 59}))
+		Expect(entry.Timestamps).To(HaveLen(61))
+
+		dataPoints := utilization(cs, "workload", t0.Add(55*time.Minute), t0.Add(65*time.Minute))
+		Expect(dataPoints).To(HaveLen(11))
+		for i, dp := range dataPoints {
+			Expect(dp.Timestamp).To(BeTemporally("==", t0.Add(55*time.Minute+time.Duration(i)*step)))
+			if i < 5 {
+				Expect(dp.Value).To(Equal(59.0))
+			} else {
+				Expect(dp.Value).To(Equal(float64(dp.Timestamp.Minute())))
+			}
+		}
+		Expect(fake.fetched).To(HaveLen(1))
+	})
+
+	It("should fetch the data points missing from the start of the cached series", func() {
+		cs := newFunc(0, 0, "")
+		utilization(cs, "workload", t0.Add(time.Hour), t0.Add(2*time.Hour))
+		expectSeries(utilization(cs, "workload", t0.Add(30*time.Minute+20*time.Second), t0.Add(2*time.Hour)),
+			t0.Add(31*time.Minute), t0.Add(2*time.Hour))
+
+		Expect(fake.fetched).To(Equal([]fetchedRange{
+			{t0.Add(time.Hour), t0.Add(2 * time.Hour)},
+			{t0.Add(30 * time.Minute), t0.Add(time.Hour)},
+		}))
+	})
+
+	It("should evict the data points older than the retention", func() {
+		cs := newFunc(time.Hour, 0, "")
+		utilization(cs, "workload", t0, t0.Add(2*time.Hour))
+		entry := cs.entries[cacheKey{Query: CPUUtilizationDataPointsQuery, Namespace: "default",
+			WorkloadType: "Deployment", Workload: "workload", Step: step}]
+		Expect(entry.From).To(BeTemporally("==", t0.Add(time.Hour)))
+		Expect(entry.Timestamps).To(HaveLen(61))
+
+		utilization(cs, "workload", t0, t0.Add(2*time.Hour))
+		Expect(fake.fetched).To(HaveLen(2))
+		Expect(fake.fetched[1]).To(Equal(fetchedRange{t0, t0.Add(time.Hour)}))
+	})
+
+	It("should evict the least recently used series beyond the max entries", func() {
+		cs := newFunc(0, 2, "")
+		for i, workload := range []string{"workload-1", "workload-2", "workload-1", "workload-3", "workload-1"} {
+			now = t0.Add(time.Duration(i) * time.Minute)
+			utilization(cs, workload, t0, t0.Add(time.Hour))
+		}
+		Expect(cs.entries).To(HaveLen(2))
+		Expect(fake.fetched).To(HaveLen(3))
+
+		utilization(cs, "workload-2", t0, t0.Add(time.Hour))
+		Expect(fake.fetched).To(HaveLen(4))
+	})
+
+	It("should serve the cached data points if the delta can't be fetched", func() {
+		cs := newFunc(0, 0, "")
+		utilization(cs, "workload", t0, t0.Add(time.Hour))
+		fake.err = fmt.Errorf("unable to getCPUUtlizationDataPoints metrics from any of the prometheus instances")
+		expectSeries(utilization(cs, "workload", t0, t0.Add(70*time.Minute)), t0, t0.Add(time.Hour))
+
+		_, err := cs.GetAverageCPUUtilizationByWorkload(context.TODO(), "default", "Deployment", "other", t0,
+			t0.Add(time.Hour), step)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should cache the breaches by red line", func() {
+		cs := newFunc(0, 0, "")
+		breaches, err := cs.GetCPUUtilizationBreachDataPoints(context.TODO(), "default", "Deployment", "workload", 100,
+			t0, t0.Add(time.Hour), step)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(breaches).To(BeNil())
+
+		breaches, err = cs.GetCPUUtilizationBreachDataPoints(context.TODO(), "default", "Deployment", "workload", 58,
+			t0, t0.Add(time.Hour), step)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(breaches).To(HaveLen(2))
+
+		breaches, err = cs.GetCPUUtilizationBreachDataPoints(context.TODO(), "default", "Deployment", "workload", 58,
+			t0.Add(50*time.Minute), t0.Add(time.Hour), step)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(breaches).To(HaveLen(2))
+		Expect(fake.fetched).To(HaveLen(2))
+	})
+
+	It("should persist the cache and load it back", func() {
+		persistPath := filepath.Join(GinkgoT().TempDir(), "scraper-cache")
+		cs := newFunc(0, 0, persistPath)
+		utilization(cs, "workload", t0, t0.Add(time.Hour))
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+		Expect(cs.Start(ctx)).To(Succeed())
+
+		loaded := newFunc(0, 0, persistPath)
+		expectSeries(utilization(loaded, "workload", t0, t0.Add(time.Hour)), t0, t0.Add(time.Hour))
+		Expect(fake.fetched).To(HaveLen(1))
+	})
+
+	Context("with the utilization of every instance", func() {
+		var instances *fakeInstanceScraper
+
+		BeforeEach(func() {
+			instances = &fakeInstanceScraper{}
+			newFunc = func(retention time.Duration, maxEntries int, persistPath string) *CachingScraper {
+				cs := NewCachingScraper(instances, retention, 5*time.Minute, maxEntries, 0, 0, persistPath, 0,
+					logr.Discard())
+				cs.now = func() time.Time { return now }
+				return cs
+			}
+		})
+
+		utilizationByInstance := func(cs *CachingScraper, start time.Time, end time.Time) map[string][]DataPoint {
+			dataPointsByInstance, err := cs.GetAverageCPUUtilizationByInstance(context.TODO(), "default", "Deployment",
+				"workload", start, end, step)
+			Expect(err).ToNot(HaveOccurred())
+			return dataPointsByInstance
+		}
+
+		It("should fetch the instances together and only the delta of their cached series", func() {
+			cs := newFunc(0, 0, "")
+			dataPointsByInstance := utilizationByInstance(cs, t0, t0.Add(time.Hour))
+			Expect(dataPointsByInstance).To(HaveLen(2))
+			expectSeries(dataPointsByInstance["p8s-1"], t0, t0.Add(time.Hour))
+			expectSeries(dataPointsByInstance["p8s-2"], t0, t0.Add(time.Hour))
+
+			dataPointsByInstance = utilizationByInstance(cs, t0, t0.Add(90*time.Minute))
+			expectSeries(dataPointsByInstance["p8s-1"], t0, t0.Add(90*time.Minute))
+			expectSeries(dataPointsByInstance["p8s-2"], t0, t0.Add(90*time.Minute))
+
+			Expect(instances.fetched).To(Equal([]fetchedRange{
+				{t0, t0.Add(time.Hour)},
+				{t0.Add(55 * time.Minute), t0.Add(90 * time.Minute)},
+			}))
+		})
+
+		It("should cache the series of the instances which answered only", func() {
+			cs := newFunc(0, 0, "")
+			instances.unanswered = true
+			dataPointsByInstance := utilizationByInstance(cs, t0, t0.Add(time.Hour))
+			Expect(dataPointsByInstance).To(HaveLen(1))
+			expectSeries(dataPointsByInstance["p8s-1"], t0, t0.Add(time.Hour))
+
+			instances.unanswered = false
+			dataPointsByInstance = utilizationByInstance(cs, t0, t0.Add(time.Hour))
+			expectSeries(dataPointsByInstance["p8s-1"], t0, t0.Add(time.Hour))
+			expectSeries(dataPointsByInstance["p8s-2"], t0, t0.Add(time.Hour))
+			dataPointsByInstance = utilizationByInstance(cs, t0, t0.Add(time.Hour))
+			Expect(dataPointsByInstance).To(HaveLen(2))
+
+			Expect(instances.fetched).To(Equal([]fetchedRange{
+				{t0, t0.Add(time.Hour)},
+				{t0, t0.Add(time.Hour)},
+			}))
+		})
+	})
+})
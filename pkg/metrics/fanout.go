@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+)
+
+// fanOutReportKey is the key of the fanOutReport in a context.
+type fanOutReportKey struct{}
+
+// fanOutReport collects the instances which didn't answer the queries of a call of the scraper, either because they
+// failed or because their circuit breaker took them out of the fan-out. The data points of such a call may miss those
+// of the instances which didn't answer.
+type fanOutReport struct {
+	mutex      sync.Mutex
+	unanswered []string
+}
+
+// withFanOutReport returns a context carrying a fanOutReport of the calls of the scraper made with it.
+func withFanOutReport(ctx context.Context) (context.Context, *fanOutReport) {
+	report := &fanOutReport{}
+	return context.WithValue(ctx, fanOutReportKey{}, report), report
+}
+
+// fanOutReportFrom returns the fanOutReport of the context, nil if it carries none.
+func fanOutReportFrom(ctx context.Context) *fanOutReport {
+	report, _ := ctx.Value(fanOutReportKey{}).(*fanOutReport)
+	return report
+}
+
+// recordUnanswered records that the instance didn't answer a query. It's a no-op on a nil fanOutReport.
+func (r *fanOutReport) recordUnanswered(address string) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.unanswered = append(r.unanswered, address)
+}
+
+// complete returns true if every instance answered the queries.
+func (r *fanOutReport) complete() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.unanswered) == 0
+}
+
+// unansweredInstances returns the instances which didn't answer the queries.
+func (r *fanOutReport) unansweredInstances() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string(nil), r.unanswered...)
+}
@@ -6,3 +6,26 @@ type MetricsTransformer interface {
 	Transform(
 		startTime time.Time, endTime time.Time, dataPoints []DataPoint) ([]DataPoint, error)
 }
+
+// AuditedMetricsTransformer is a MetricsTransformer which also reports the intervals of the data points it ignored, so
+// that the utilization left out of a recommendation can be audited.
+type AuditedMetricsTransformer interface {
+	MetricsTransformer
+	TransformAndAudit(
+		startTime time.Time, endTime time.Time, dataPoints []DataPoint) ([]DataPoint, []IgnoredInterval, error)
+}
+
+// IgnoredInterval is an interval of the utilization ignored by a MetricsTransformer for the reason.
+type IgnoredInterval struct {
+	StartTime time.Time
+	EndTime   time.Time
+	Reason    string
+}
+
+// WorkloadMetricsTransformer is an AuditedMetricsTransformer which depends on the workload of the data points, like
+// its rollouts. Transform and TransformAndAudit leave the data points of an unknown workload untouched.
+type WorkloadMetricsTransformer interface {
+	AuditedMetricsTransformer
+	TransformWorkloadAndAudit(namespace string, kind string, name string,
+		startTime time.Time, endTime time.Time, dataPoints []DataPoint) ([]DataPoint, []IgnoredInterval, error)
+}
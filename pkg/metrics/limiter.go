@@ -0,0 +1,292 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"golang.org/x/sync/semaphore"
+)
+
+const (
+	defaultMaxConcurrentQueries            = 100
+	defaultMaxConcurrentQueriesPerInstance = 20
+	defaultMaxQueryRetries                 = 3
+	defaultQueryRetryBackoff               = 500 * time.Millisecond
+	// maxQueryRetryBackoffFactor caps the backoff of the retries at this many times the initial backoff.
+	maxQueryRetryBackoffFactor        = 20
+	defaultCircuitBreakerFailures     = 5
+	defaultCircuitBreakerOpenDuration = time.Minute
+)
+
+// ErrCircuitOpen is returned for the queries to an instance taken out of the fan-out by its circuit breaker.
+var ErrCircuitOpen = errors.New("circuit breaker of the prometheus instance is open")
+
+// CircuitState is the state of the circuit breaker of a Prometheus instance.
+type CircuitState int
+
+const (
+	// CircuitClosed lets the queries through.
+	CircuitClosed CircuitState = iota
+	// CircuitHalfOpen lets a single query through to probe whether the instance recovered.
+	CircuitHalfOpen
+	// CircuitOpen takes the instance out of the fan-out.
+	CircuitOpen
+)
+
+// circuitBreaker is the circuit breaker of a Prometheus instance. It opens after failureThreshold consecutive failed
+// queries and half opens after openDuration, closing again on the first successful query.
+type circuitBreaker struct {
+	state     CircuitState
+	failures  int
+	openUntil time.Time
+	// probingSince is when the query probing the half open instance was let through, zero if there's none. A probe
+	// which didn't record its outcome within openDuration is given up.
+	probingSince time.Time
+}
+
+// QueryLimiter guards the Prometheus instances from the queries of the scraper. It bounds the queries in flight,
+// globally and per instance, retries the queries failing with retryable errors with jittered exponential backoff, and
+// trips a circuit breaker per instance which takes the instance out of the fan-out while it keeps failing. A nil
+// QueryLimiter executes the queries once, without any limit.
+type QueryLimiter struct {
+	global                 *semaphore.Weighted
+	perInstanceConcurrency int64
+	maxRetries             int
+	retryBackoff           time.Duration
+	failureThreshold       int
+	openDuration           time.Duration
+	instances              map[string]*semaphore.Weighted
+	breakers               map[string]*circuitBreaker
+	mutex                  sync.Mutex
+	now                    func() time.Time
+	sleep                  func(ctx context.Context, d time.Duration) error
+}
+
+// NewQueryLimiter returns a QueryLimiter. The zero values pick the defaults.
+func NewQueryLimiter(maxConcurrentQueries int,
+	maxConcurrentQueriesPerInstance int,
+	maxRetries int,
+	retryBackoff time.Duration,
+	failureThreshold int,
+	openDuration time.Duration) *QueryLimiter {
+	if maxConcurrentQueries <= 0 {
+		maxConcurrentQueries = defaultMaxConcurrentQueries
+	}
+	if maxConcurrentQueriesPerInstance <= 0 {
+		maxConcurrentQueriesPerInstance = defaultMaxConcurrentQueriesPerInstance
+	}
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxQueryRetries
+	}
+	if retryBackoff <= 0 {
+		retryBackoff = defaultQueryRetryBackoff
+	}
+	if failureThreshold <= 0 {
+		failureThreshold = defaultCircuitBreakerFailures
+	}
+	if openDuration <= 0 {
+		openDuration = defaultCircuitBreakerOpenDuration
+	}
+	return &QueryLimiter{
+		global:                 semaphore.NewWeighted(int64(maxConcurrentQueries)),
+		perInstanceConcurrency: int64(maxConcurrentQueriesPerInstance),
+		maxRetries:             maxRetries,
+		retryBackoff:           retryBackoff,
+		failureThreshold:       failureThreshold,
+		openDuration:           openDuration,
+		instances:              make(map[string]*semaphore.Weighted),
+		breakers:               make(map[string]*circuitBreaker),
+		now:                    time.Now,
+		sleep:                  sleepWithContext,
+	}
+}
+
+// Allow returns whether the instance can be queried, i.e. whether its circuit breaker is closed, or is half open and
+// not probed by another query yet.
+func (ql *QueryLimiter) Allow(address string) bool {
+	if ql == nil {
+		return true
+	}
+	ql.mutex.Lock()
+	defer ql.mutex.Unlock()
+	breaker := ql.breaker(address)
+	if breaker.state == CircuitOpen && !ql.now().Before(breaker.openUntil) {
+		ql.setState(address, breaker, CircuitHalfOpen)
+	}
+	switch breaker.state {
+	case CircuitClosed:
+		return true
+	case CircuitHalfOpen:
+		if !breaker.probingSince.IsZero() && ql.now().Before(breaker.probingSince.Add(ql.openDuration)) {
+			return false
+		}
+		breaker.probingSince = ql.now()
+		return true
+	default:
+		return false
+	}
+}
+
+// Do executes the query to the instance once a slot is free, retrying it while it fails with a retryable error, and
+// records its outcome to the circuit breaker of the instance. It returns ErrCircuitOpen without executing the query
+// if the circuit breaker is open.
+func (ql *QueryLimiter) Do(ctx context.Context, address string, queryName string,
+	query func(ctx context.Context) error) error {
+	if ql == nil {
+		return query(ctx)
+	}
+	if ql.State(address) == CircuitOpen {
+		return ErrCircuitOpen
+	}
+
+	p8sQueuedQueries.WithLabelValues(queryName, address).Add(1)
+	err := ql.acquire(ctx, address)
+	p8sQueuedQueries.WithLabelValues(queryName, address).Sub(1)
+	if err != nil {
+		return err
+	}
+	defer ql.release(address)
+	// The circuit breaker may have opened while the query was queued.
+	if ql.State(address) == CircuitOpen {
+		return ErrCircuitOpen
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = query(ctx)
+		if err == nil || ctx.Err() != nil || !isRetryable(err) || attempt >= ql.maxRetries {
+			break
+		}
+		p8sQueryRetryCount.WithLabelValues(queryName, address).Inc()
+		if sleepErr := ql.sleep(ctx, ql.backoff(attempt)); sleepErr != nil {
+			break
+		}
+	}
+	// The queries cancelled by the callers don't say anything about the health of the instance.
+	if err != nil && ctx.Err() != nil {
+		ql.abandonProbe(address)
+		return err
+	}
+	// The queries failing for a reason of their own, like a bad query, don't either.
+	ql.record(address, err == nil || !isRetryable(err))
+	return err
+}
+
+// State returns the state of the circuit breaker of the instance.
+func (ql *QueryLimiter) State(address string) CircuitState {
+	if ql == nil {
+		return CircuitClosed
+	}
+	ql.mutex.Lock()
+	defer ql.mutex.Unlock()
+	return ql.breaker(address).state
+}
+
+func (ql *QueryLimiter) acquire(ctx context.Context, address string) error {
+	ql.mutex.Lock()
+	instance, ok := ql.instances[address]
+	if !ok {
+		instance = semaphore.NewWeighted(ql.perInstanceConcurrency)
+		ql.instances[address] = instance
+	}
+	ql.mutex.Unlock()
+
+	// The instance's slot is acquired first so that the queries queued for a slow instance don't hold the global slots
+	// of the queries to the other instances.
+	if err := instance.Acquire(ctx, 1); err != nil {
+		return err
+	}
+	if err := ql.global.Acquire(ctx, 1); err != nil {
+		instance.Release(1)
+		return err
+	}
+	return nil
+}
+
+func (ql *QueryLimiter) release(address string) {
+	ql.mutex.Lock()
+	instance := ql.instances[address]
+	ql.mutex.Unlock()
+	instance.Release(1)
+	ql.global.Release(1)
+}
+
+// backoff returns the delay before the retry following the attempt, drawn uniformly up to the exponential backoff of
+// the attempt so that the retries of the concurrent queries are spread out.
+func (ql *QueryLimiter) backoff(attempt int) time.Duration {
+	ceiling := ql.retryBackoff * maxQueryRetryBackoffFactor
+	if attempt < 31 && ql.retryBackoff<<attempt < ceiling {
+		ceiling = ql.retryBackoff << attempt
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)) + 1)
+}
+
+// record records whether the instance served a query to its circuit breaker.
+func (ql *QueryLimiter) record(address string, succeeded bool) {
+	ql.mutex.Lock()
+	defer ql.mutex.Unlock()
+	breaker := ql.breaker(address)
+	breaker.probingSince = time.Time{}
+	if succeeded {
+		breaker.failures = 0
+		ql.setState(address, breaker, CircuitClosed)
+		return
+	}
+	breaker.failures++
+	if breaker.state == CircuitHalfOpen || breaker.failures >= ql.failureThreshold {
+		breaker.openUntil = ql.now().Add(ql.openDuration)
+		ql.setState(address, breaker, CircuitOpen)
+	}
+}
+
+// abandonProbe lets another query probe the half open instance.
+func (ql *QueryLimiter) abandonProbe(address string) {
+	ql.mutex.Lock()
+	defer ql.mutex.Unlock()
+	ql.breaker(address).probingSince = time.Time{}
+}
+
+func (ql *QueryLimiter) breaker(address string) *circuitBreaker {
+	breaker, ok := ql.breakers[address]
+	if !ok {
+		breaker = &circuitBreaker{}
+		ql.breakers[address] = breaker
+	}
+	return breaker
+}
+
+func (ql *QueryLimiter) setState(address string, breaker *circuitBreaker, state CircuitState) {
+	breaker.state = state
+	p8sCircuitBreakerState.WithLabelValues(address).Set(float64(state))
+}
+
+// isRetryable returns whether the query failed for a reason which may be gone on a retry, like the overload of the
+// instance or a network failure, as opposed to a bad query.
+func isRetryable(err error) bool {
+	var apiErr *v1.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Type {
+		case v1.ErrTimeout, v1.ErrServer, v1.ErrBadResponse:
+			return true
+		default:
+			return false
+		}
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func sleepWithContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
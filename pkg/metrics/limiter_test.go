@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+)
+
+var _ = Describe("QueryLimiter", func() {
+
+	var (
+		now     time.Time
+		limiter *QueryLimiter
+	)
+
+	newLimiter := func(maxConcurrentQueries, maxConcurrentQueriesPerInstance, failureThreshold int) *QueryLimiter {
+		ql := NewQueryLimiter(maxConcurrentQueries, maxConcurrentQueriesPerInstance, 2, time.Second,
+			failureThreshold, time.Minute)
+		ql.now = func() time.Time { return now }
+		ql.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+		return ql
+	}
+
+	failing := func(errorType v1.ErrorType) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			return &v1.Error{Type: errorType}
+		}
+	}
+
+	succeeding := func(ctx context.Context) error {
+		return nil
+	}
+
+	BeforeEach(func() {
+		now = time.Date(2023, time.May, 1, 0, 0, 0, 0, time.UTC)
+		limiter = newLimiter(0, 0, 2)
+	})
+
+	It("should bound the queries in flight globally and per instance", func() {
+		limiter = newLimiter(3, 2, 0)
+		var inFlight, maxInFlight int32
+		inFlightByInstance := map[string]*int32{"p8s-1": new(int32), "p8s-2": new(int32)}
+		maxInFlightByInstance := map[string]*int32{"p8s-1": new(int32), "p8s-2": new(int32)}
+		raise := func(counter *int32, max *int32) {
+			value := atomic.AddInt32(counter, 1)
+			for {
+				current := atomic.LoadInt32(max)
+				if value <= current || atomic.CompareAndSwapInt32(max, current, value) {
+					return
+				}
+			}
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			address := []string{"p8s-1", "p8s-2"}[i%2]
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer GinkgoRecover()
+				Expect(limiter.Do(context.TODO(), address, CPUUtilizationDataPointsQuery, func(ctx context.Context) error {
+					raise(&inFlight, &maxInFlight)
+					raise(inFlightByInstance[address], maxInFlightByInstance[address])
+					time.Sleep(5 * time.Millisecond)
+					atomic.AddInt32(inFlightByInstance[address], -1)
+					atomic.AddInt32(&inFlight, -1)
+					return nil
+				})).To(Succeed())
+			}()
+		}
+		wg.Wait()
+
+		Expect(maxInFlight).To(BeNumerically("<=", 3))
+		Expect(*maxInFlightByInstance["p8s-1"]).To(BeNumerically("<=", 2))
+		Expect(*maxInFlightByInstance["p8s-2"]).To(BeNumerically("<=", 2))
+	})
+
+	It("should retry the queries failing with retryable errors only", func() {
+		calls := 0
+		Expect(limiter.Do(context.TODO(), "p8s-1", CPUUtilizationDataPointsQuery, func(ctx context.Context) error {
+			calls++
+			if calls < 3 {
+				return &v1.Error{Type: v1.ErrServer}
+			}
+			return nil
+		})).To(Succeed())
+		Expect(calls).To(Equal(3))
+
+		calls = 0
+		Expect(limiter.Do(context.TODO(), "p8s-1", CPUUtilizationDataPointsQuery, func(ctx context.Context) error {
+			calls++
+			return &v1.Error{Type: v1.ErrBadData}
+		})).ToNot(Succeed())
+		Expect(calls).To(Equal(1))
+
+		calls = 0
+		Expect(limiter.Do(context.TODO(), "p8s-1", CPUUtilizationDataPointsQuery, func(ctx context.Context) error {
+			calls++
+			return &v1.Error{Type: v1.ErrTimeout}
+		})).ToNot(Succeed())
+		Expect(calls).To(Equal(3))
+	})
+
+	It("should jitter the backoff of the retries up to its exponential ceiling", func() {
+		for attempt := 0; attempt < 10; attempt++ {
+			ceiling := time.Second << attempt
+			if ceiling > 20*time.Second {
+				ceiling = 20 * time.Second
+			}
+			Expect(limiter.backoff(attempt)).To(And(BeNumerically(">", 0), BeNumerically("<=", ceiling)))
+		}
+	})
+
+	It("should take the failing instance out of the fan-out until a probe succeeds", func() {
+		Expect(limiter.Do(context.TODO(), "p8s-1", CPUUtilizationDataPointsQuery, failing(v1.ErrServer))).ToNot(Succeed())
+		Expect(limiter.Allow("p8s-1")).To(BeTrue())
+		Expect(limiter.Do(context.TODO(), "p8s-1", CPUUtilizationDataPointsQuery, failing(v1.ErrServer))).ToNot(Succeed())
+
+		Expect(limiter.State("p8s-1")).To(Equal(CircuitOpen))
+		Expect(limiter.Allow("p8s-1")).To(BeFalse())
+		Expect(limiter.Allow("p8s-2")).To(BeTrue())
+		Expect(limiter.Do(context.TODO(), "p8s-1", CPUUtilizationDataPointsQuery, succeeding)).
+			To(MatchError(ErrCircuitOpen))
+
+		now = now.Add(time.Minute)
+		Expect(limiter.Allow("p8s-1")).To(BeTrue())
+		Expect(limiter.State("p8s-1")).To(Equal(CircuitHalfOpen))
+		Expect(limiter.Allow("p8s-1")).To(BeFalse())
+		Expect(limiter.Do(context.TODO(), "p8s-1", CPUUtilizationDataPointsQuery, succeeding)).To(Succeed())
+		Expect(limiter.State("p8s-1")).To(Equal(CircuitClosed))
+		Expect(limiter.Allow("p8s-1")).To(BeTrue())
+	})
+
+	It("should reopen the circuit breaker if the probe fails", func() {
+		for i := 0; i < 2; i++ {
+			Expect(limiter.Do(context.TODO(), "p8s-1", CPUUtilizationDataPointsQuery, failing(v1.ErrServer))).
+				ToNot(Succeed())
+		}
+		now = now.Add(time.Minute)
+		Expect(limiter.Allow("p8s-1")).To(BeTrue())
+		Expect(limiter.Do(context.TODO(), "p8s-1", CPUUtilizationDataPointsQuery, failing(v1.ErrServer))).
+			ToNot(Succeed())
+		Expect(limiter.State("p8s-1")).To(Equal(CircuitOpen))
+		Expect(limiter.Allow("p8s-1")).To(BeFalse())
+	})
+
+	It("should not hold the bad queries and the cancelled ones against the instance", func() {
+		for i := 0; i < 3; i++ {
+			Expect(limiter.Do(context.TODO(), "p8s-1", CPUUtilizationDataPointsQuery, failing(v1.ErrBadData))).
+				ToNot(Succeed())
+		}
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+		for i := 0; i < 3; i++ {
+			Expect(limiter.Do(ctx, "p8s-1", CPUUtilizationDataPointsQuery, failing(v1.ErrServer))).ToNot(Succeed())
+		}
+		Expect(limiter.State("p8s-1")).To(Equal(CircuitClosed))
+	})
+
+	It("should execute the queries once without limits if nil", func() {
+		var nilLimiter *QueryLimiter
+		calls := 0
+		Expect(nilLimiter.Do(context.TODO(), "p8s-1", CPUUtilizationDataPointsQuery, func(ctx context.Context) error {
+			calls++
+			return &v1.Error{Type: v1.ErrServer}
+		})).ToNot(Succeed())
+		Expect(calls).To(Equal(1))
+		Expect(nilLimiter.Allow("p8s-1")).To(BeTrue())
+	})
+})
@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// MergeStrategy is how the data points of the same timestamp returned by different Prometheus instances are merged.
+type MergeStrategy string
+
+const (
+	// MergeStrategyMax keeps the highest value of the instances.
+	MergeStrategyMax MergeStrategy = "Max"
+	// MergeStrategyMean keeps the mean of the values of the instances.
+	MergeStrategyMean MergeStrategy = "Mean"
+	// MergeStrategyPrimaryWithFallback keeps the value of the first instance, in the configured order, which returned
+	// the timestamp, so the other instances only fill in the gaps of the first.
+	MergeStrategyPrimaryWithFallback MergeStrategy = "PrimaryWithFallback"
+)
+
+// ParseMergeStrategy returns the merge strategy of the given name, MergeStrategyMax if it's empty.
+func ParseMergeStrategy(name string) (MergeStrategy, error) {
+	switch strategy := MergeStrategy(name); strategy {
+	case "":
+		return MergeStrategyMax, nil
+	case MergeStrategyMax, MergeStrategyMean, MergeStrategyPrimaryWithFallback:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("unknown merge strategy: %s", name)
+	}
+}
+
+// mergeInstances merges the data points of the instances, keyed by their address, with the strategy. The instances
+// are given in the configured order. The data points of every instance must be sorted by timestamp.
+func mergeInstances(strategy MergeStrategy, instances []string, dataPointsByInstance map[string][]DataPoint) []DataPoint {
+	if strategy == MergeStrategyMax || strategy == "" {
+		var merged []DataPoint
+		for _, instance := range instances {
+			merged = aggregateMetrics(merged, dataPointsByInstance[instance])
+		}
+		return merged
+	}
+
+	type mergedValue struct {
+		timestamp time.Time
+		sum       float64
+		count     int
+	}
+	values := make(map[int64]*mergedValue)
+	for _, instance := range instances {
+		for _, dp := range dataPointsByInstance[instance] {
+			key := dp.Timestamp.UnixNano()
+			if value, ok := values[key]; ok {
+				if strategy == MergeStrategyMean {
+					value.sum += dp.Value
+					value.count++
+				}
+				continue
+			}
+			values[key] = &mergedValue{timestamp: dp.Timestamp, sum: dp.Value, count: 1}
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	merged := make([]DataPoint, 0, len(values))
+	for _, value := range values {
+		merged = append(merged, DataPoint{Timestamp: value.timestamp, Value: value.sum / float64(value.count)})
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Timestamp.Before(merged[j].Timestamp)
+	})
+	return merged
+}
+
+// alignToStep returns the data points of the samples, with their timestamps rounded to the step grid starting at
+// start, sorted by timestamp. The instances and the split ranges of a query may be evaluated at timestamps offset from
+// each other, which would be taken for different timestamps otherwise. Of the samples rounded to the same timestamp,
+// the latest is kept.
+func alignToStep(samples []model.SamplePair, start time.Time, step time.Duration) []DataPoint {
+	origin := start.Truncate(time.Millisecond)
+	var dataPoints []DataPoint
+	for _, sample := range samples {
+		timestamp := sample.Timestamp.Time()
+		if timestamp.IsZero() {
+			continue
+		}
+		if step > 0 {
+			steps := math.Round(float64(timestamp.Sub(origin)) / float64(step))
+			timestamp = origin.Add(time.Duration(steps) * step)
+		}
+		dataPoints = append(dataPoints, DataPoint{Timestamp: timestamp, Value: float64(sample.Value)})
+	}
+	sort.SliceStable(dataPoints, func(i, j int) bool {
+		return dataPoints[i].Timestamp.Before(dataPoints[j].Timestamp)
+	})
+
+	aligned := dataPoints[:0]
+	for _, dp := range dataPoints {
+		if len(aligned) > 0 && aligned[len(aligned)-1].Timestamp.Equal(dp.Timestamp) {
+			aligned[len(aligned)-1] = dp
+			continue
+		}
+		aligned = append(aligned, dp)
+	}
+	return aligned
+}
+
+// mergeSamples merges the samples of two parts of a series, both sorted by timestamp.
+func mergeSamples(samplesA, samplesB []model.SamplePair) []model.SamplePair {
+	merged := make([]model.SamplePair, 0, len(samplesA)+len(samplesB))
+	indexA, indexB := 0, 0
+	for indexA < len(samplesA) && indexB < len(samplesB) {
+		if samplesB[indexB].Timestamp.Before(samplesA[indexA].Timestamp) {
+			merged = append(merged, samplesB[indexB])
+			indexB++
+			continue
+		}
+		merged = append(merged, samplesA[indexA])
+		indexA++
+	}
+	merged = append(merged, samplesA[indexA:]...)
+	return append(merged, samplesB[indexB:]...)
+}
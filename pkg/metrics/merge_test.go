@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/common/model"
+)
+
+var _ = Describe("Merging", func() {
+
+	start := time.Date(2023, time.May, 1, 0, 0, 0, 0, time.UTC)
+	step := time.Minute
+
+	at := func(steps int) time.Time {
+		return start.Add(time.Duration(steps) * step)
+	}
+
+	Context("mergeMatrices", func() {
+		It("should merge the series of the same labels whatever their order", func() {
+			matrix1 := model.Matrix{
+				&model.SampleStream{
+					Metric: model.Metric{"pod": "b"},
+					Values: []model.SamplePair{{Timestamp: 100, Value: 1}, {Timestamp: 200, Value: 2}},
+				},
+				&model.SampleStream{
+					Metric: model.Metric{"pod": "a"},
+					Values: []model.SamplePair{{Timestamp: 100, Value: 10}},
+				},
+			}
+			matrix2 := model.Matrix{
+				&model.SampleStream{
+					Metric: model.Metric{"pod": "a"},
+					Values: []model.SamplePair{{Timestamp: 300, Value: 30}},
+				},
+				&model.SampleStream{
+					Metric: model.Metric{"pod": "c"},
+					Values: []model.SamplePair{{Timestamp: 300, Value: 300}},
+				},
+				&model.SampleStream{
+					Metric: model.Metric{"pod": "b"},
+					Values: []model.SamplePair{{Timestamp: 300, Value: 3}},
+				},
+			}
+
+			mergedMatrix := mergeMatrices(matrix1, matrix2)
+			Expect(mergedMatrix).To(Equal(model.Matrix{
+				&model.SampleStream{
+					Metric: model.Metric{"pod": "a"},
+					Values: []model.SamplePair{{Timestamp: 100, Value: 10}, {Timestamp: 300, Value: 30}},
+				},
+				&model.SampleStream{
+					Metric: model.Metric{"pod": "b"},
+					Values: []model.SamplePair{{Timestamp: 100, Value: 1}, {Timestamp: 200, Value: 2},
+						{Timestamp: 300, Value: 3}},
+				},
+				&model.SampleStream{
+					Metric: model.Metric{"pod": "c"},
+					Values: []model.SamplePair{{Timestamp: 300, Value: 300}},
+				},
+			}))
+		})
+
+		It("should return the other matrix if one is empty", func() {
+			matrix := model.Matrix{&model.SampleStream{Metric: model.Metric{"pod": "a"}}}
+			Expect(mergeMatrices(nil, matrix)).To(Equal(matrix))
+			Expect(mergeMatrices(matrix, model.Matrix{})).To(Equal(matrix))
+		})
+	})
+
+	Context("alignToStep", func() {
+		It("should round the timestamps to the step grid and keep the latest of the same timestamp", func() {
+			samples := []model.SamplePair{
+				{Timestamp: model.TimeFromUnixNano(at(2).Add(-10 * time.Second).UnixNano()), Value: 3},
+				{Timestamp: model.TimeFromUnixNano(at(0).Add(20 * time.Second).UnixNano()), Value: 1},
+				{Timestamp: model.TimeFromUnixNano(at(1).Add(-5 * time.Second).UnixNano()), Value: 2},
+				{Timestamp: model.TimeFromUnixNano(at(2).UnixNano()), Value: 4},
+			}
+
+			dataPoints := alignToStep(samples, start, step)
+			Expect(dataPoints).To(HaveLen(3))
+			for i, expected := range []DataPoint{{at(0), 1}, {at(1), 2}, {at(2), 4}} {
+				Expect(dataPoints[i].Timestamp).To(BeTemporally("==", expected.Timestamp))
+				Expect(dataPoints[i].Value).To(Equal(expected.Value))
+			}
+		})
+
+		It("should return no data points for no samples", func() {
+			Expect(alignToStep(nil, start, step)).To(BeEmpty())
+		})
+	})
+
+	Context("mergeInstances", func() {
+		dataPointsByInstance := map[string][]DataPoint{
+			"p8s-1": {{at(0), 10}, {at(2), 30}},
+			"p8s-2": {{at(0), 20}, {at(1), 40}, {at(2), 10}},
+		}
+		instances := []string{"p8s-1", "p8s-2"}
+
+		values := func(dataPoints []DataPoint) []float64 {
+			var values []float64
+			for i, dp := range dataPoints {
+				Expect(dp.Timestamp).To(BeTemporally("==", at(i)))
+				values = append(values, dp.Value)
+			}
+			return values
+		}
+
+		It("should keep the highest value with the Max strategy", func() {
+			Expect(values(mergeInstances(MergeStrategyMax, instances, dataPointsByInstance))).
+				To(Equal([]float64{20, 40, 30}))
+		})
+
+		It("should keep the mean of the values with the Mean strategy", func() {
+			Expect(values(mergeInstances(MergeStrategyMean, instances, dataPointsByInstance))).
+				To(Equal([]float64{15, 40, 20}))
+		})
+
+		It("should fall back to the other instances only in the gaps of the primary", func() {
+			Expect(values(mergeInstances(MergeStrategyPrimaryWithFallback, instances, dataPointsByInstance))).
+				To(Equal([]float64{10, 40, 30}))
+			Expect(values(mergeInstances(MergeStrategyPrimaryWithFallback, []string{"p8s-2", "p8s-1"},
+				dataPointsByInstance))).To(Equal([]float64{20, 40, 10}))
+		})
+
+		It("should return no data points if no instance returned any", func() {
+			Expect(mergeInstances(MergeStrategyMean, instances, map[string][]DataPoint{})).To(BeEmpty())
+		})
+	})
+
+	Context("ParseMergeStrategy", func() {
+		It("should default to the Max strategy and reject the unknown ones", func() {
+			strategy, err := ParseMergeStrategy("")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(strategy).To(Equal(MergeStrategyMax))
+
+			strategy, err = ParseMergeStrategy("PrimaryWithFallback")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(strategy).To(Equal(MergeStrategyPrimaryWithFallback))
+
+			_, err = ParseMergeStrategy("Min")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,234 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// QualityIssueType is the kind of defect of the data points found by the DataQualityChecker.
+type QualityIssueType string
+
+const (
+	// QualityIssueGap is an interval without data points longer than the max gap, which the scraper would fill in
+	// linearly.
+	QualityIssueGap QualityIssueType = "Gap"
+	// QualityIssueFlatline is an interval over which the data points didn't change at all, as the utilization of a
+	// live workload never does.
+	QualityIssueFlatline QualityIssueType = "Flatline"
+	// QualityIssueCounterReset is an isolated spike or drop of the data points, as left by the resets of the counters
+	// the utilization is the rate of.
+	QualityIssueCounterReset QualityIssueType = "CounterReset"
+	// QualityIssueInstanceDisagreement is an interval over which the Prometheus instances disagree on the data points.
+	QualityIssueInstanceDisagreement QualityIssueType = "InstanceDisagreement"
+)
+
+const (
+	defaultQualityMaxGap          = 30 * time.Minute
+	defaultQualityMinFlatline     = 2 * time.Hour
+	defaultQualitySpikeFactor     = 10.0
+	defaultQualityMaxDisagreement = 0.2
+)
+
+// QualityIssue is a defect of the data points over an interval.
+type QualityIssue struct {
+	Type      QualityIssueType
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+// QualityReport is the quality of the data points of a time range.
+type QualityReport struct {
+	// Score is the fraction of the time range free of issues, from 0 to 1.
+	Score  float64
+	Issues []QualityIssue
+}
+
+// DataQualityChecker assesses the quality of the data points returned by the Prometheus instances for a time range.
+// The data points of the instances are merged like the scraper does, keeping the highest value of a timestamp, and
+// searched for gaps longer than maxGap, flatlines of at least minFlatline and isolated points spikeFactor times above or
+// below both of their neighbours. The timestamps at which the instances differ by more than maxDisagreement of the
+// highest value are reported as disagreement.
+type DataQualityChecker struct {
+	maxGap          time.Duration
+	minFlatline     time.Duration
+	spikeFactor     float64
+	maxDisagreement float64
+}
+
+// NewDataQualityChecker returns a DataQualityChecker. The zero values pick the defaults.
+func NewDataQualityChecker(maxGap time.Duration,
+	minFlatline time.Duration,
+	spikeFactor float64,
+	maxDisagreement float64) *DataQualityChecker {
+	if maxGap <= 0 {
+		maxGap = defaultQualityMaxGap
+	}
+	if minFlatline <= 0 {
+		minFlatline = defaultQualityMinFlatline
+	}
+	if spikeFactor <= 1 {
+		spikeFactor = defaultQualitySpikeFactor
+	}
+	if maxDisagreement <= 0 {
+		maxDisagreement = defaultQualityMaxDisagreement
+	}
+	return &DataQualityChecker{
+		maxGap:          maxGap,
+		minFlatline:     minFlatline,
+		spikeFactor:     spikeFactor,
+		maxDisagreement: maxDisagreement,
+	}
+}
+
+// Assess returns the quality of the data points of the instances, keyed by their address, over the time range sampled
+// every step. The score is the fraction of the time range not covered by the issues, each of the spikes and
+// disagreeing timestamps covering a step.
+func (dc *DataQualityChecker) Assess(dataPointsByInstance map[string][]DataPoint,
+	start time.Time,
+	end time.Time,
+	step time.Duration) QualityReport {
+
+	instances := make([]string, 0, len(dataPointsByInstance))
+	for instance := range dataPointsByInstance {
+		instances = append(instances, instance)
+	}
+	sort.Strings(instances)
+	var merged []DataPoint
+	for _, instance := range instances {
+		merged = aggregateMetrics(merged, dataPointsByInstance[instance])
+	}
+
+	var issues []QualityIssue
+	var affected time.Duration
+	for _, find := range []func([]DataPoint, time.Time, time.Time) []QualityIssue{dc.findGaps, dc.findFlatlines} {
+		for _, issue := range find(merged, start, end) {
+			issues = append(issues, issue)
+			affected += issue.EndTime.Sub(issue.StartTime)
+		}
+	}
+	for _, issue := range dc.findCounterResets(merged, step) {
+		issues = append(issues, issue)
+		affected += step
+	}
+	disagreements, disagreeing := dc.findDisagreements(dataPointsByInstance, instances, step)
+	issues = append(issues, disagreements...)
+	affected += time.Duration(disagreeing) * step
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].StartTime.Before(issues[j].StartTime)
+	})
+	window := end.Sub(start)
+	if window <= 0 {
+		return QualityReport{Score: 0, Issues: issues}
+	}
+	return QualityReport{Score: math.Max(0, 1-float64(affected)/float64(window)), Issues: issues}
+}
+
+// findGaps returns the intervals longer than maxGap without data points, including those at the ends of the range.
+func (dc *DataQualityChecker) findGaps(dataPoints []DataPoint, start time.Time, end time.Time) []QualityIssue {
+	var gaps []QualityIssue
+	previous := start
+	for i := 0; i <= len(dataPoints); i++ {
+		timestamp := end
+		if i < len(dataPoints) {
+			timestamp = dataPoints[i].Timestamp
+		}
+		if timestamp.Sub(previous) > dc.maxGap {
+			gaps = append(gaps, QualityIssue{Type: QualityIssueGap, StartTime: previous, EndTime: timestamp})
+		}
+		if timestamp.After(previous) {
+			previous = timestamp
+		}
+	}
+	return gaps
+}
+
+// findFlatlines returns the intervals of at least minFlatline over which the data points kept the same value. The
+// flatlines are broken by the gaps.
+func (dc *DataQualityChecker) findFlatlines(dataPoints []DataPoint, start time.Time, end time.Time) []QualityIssue {
+	var flatlines []QualityIssue
+	runStart := 0
+	for i := 1; i <= len(dataPoints); i++ {
+		if i < len(dataPoints) && sameValue(dataPoints[i].Value, dataPoints[runStart].Value) &&
+			dataPoints[i].Timestamp.Sub(dataPoints[i-1].Timestamp) <= dc.maxGap {
+			continue
+		}
+		if i-1 > runStart && dataPoints[i-1].Timestamp.Sub(dataPoints[runStart].Timestamp) >= dc.minFlatline {
+			flatlines = append(flatlines, QualityIssue{Type: QualityIssueFlatline,
+				StartTime: dataPoints[runStart].Timestamp, EndTime: dataPoints[i-1].Timestamp})
+		}
+		runStart = i
+	}
+	return flatlines
+}
+
+// findCounterResets returns the data points spikeFactor times above or below both of their neighbours, the
+// neighbours being at most a step apart from them.
+func (dc *DataQualityChecker) findCounterResets(dataPoints []DataPoint, step time.Duration) []QualityIssue {
+	var resets []QualityIssue
+	for i := 1; i+1 < len(dataPoints); i++ {
+		previous, current, next := dataPoints[i-1], dataPoints[i], dataPoints[i+1]
+		if current.Timestamp.Sub(previous.Timestamp) > step || next.Timestamp.Sub(current.Timestamp) > step {
+			continue
+		}
+		low, high := math.Min(previous.Value, next.Value), math.Max(previous.Value, next.Value)
+		spike := high > 0 && current.Value > dc.spikeFactor*high
+		drop := low > 0 && current.Value*dc.spikeFactor < low
+		if spike || drop {
+			resets = append(resets, QualityIssue{Type: QualityIssueCounterReset, StartTime: current.Timestamp,
+				EndTime: current.Timestamp})
+		}
+	}
+	return resets
+}
+
+// findDisagreements returns the intervals of consecutive timestamps at which the instances disagree, along with the
+// number of those timestamps. Only the timestamps returned by more than one instance are compared.
+func (dc *DataQualityChecker) findDisagreements(dataPointsByInstance map[string][]DataPoint,
+	instances []string,
+	step time.Duration) ([]QualityIssue, int) {
+
+	if len(instances) < 2 {
+		return nil, 0
+	}
+	type spread struct {
+		low, high float64
+		count     int
+	}
+	spreads := make(map[time.Time]*spread)
+	for _, instance := range instances {
+		for _, dp := range dataPointsByInstance[instance] {
+			timestamp := dp.Timestamp.UTC()
+			if s, ok := spreads[timestamp]; ok {
+				s.low, s.high, s.count = math.Min(s.low, dp.Value), math.Max(s.high, dp.Value), s.count+1
+				continue
+			}
+			spreads[timestamp] = &spread{low: dp.Value, high: dp.Value, count: 1}
+		}
+	}
+	var disagreeing []time.Time
+	for timestamp, s := range spreads {
+		if s.count > 1 && s.high > 0 && (s.high-s.low)/s.high > dc.maxDisagreement {
+			disagreeing = append(disagreeing, timestamp)
+		}
+	}
+	sort.Slice(disagreeing, func(i, j int) bool {
+		return disagreeing[i].Before(disagreeing[j])
+	})
+
+	var disagreements []QualityIssue
+	for i, timestamp := range disagreeing {
+		if i > 0 && timestamp.Sub(disagreeing[i-1]) <= step {
+			disagreements[len(disagreements)-1].EndTime = timestamp
+			continue
+		}
+		disagreements = append(disagreements, QualityIssue{Type: QualityIssueInstanceDisagreement,
+			StartTime: timestamp, EndTime: timestamp})
+	}
+	return disagreements, len(disagreeing)
+}
+
+func sameValue(v1 float64, v2 float64) bool {
+	return math.Abs(v1-v2) <= 1e-9*math.Max(1, math.Max(math.Abs(v1), math.Abs(v2)))
+}
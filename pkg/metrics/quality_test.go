@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DataQualityChecker", func() {
+
+	var (
+		start   = time.Date(2023, time.May, 1, 0, 0, 0, 0, time.UTC)
+		end     = start.Add(10 * time.Hour)
+		step    = time.Minute
+		checker = NewDataQualityChecker(0, 0, 0, 0)
+	)
+
+	// series returns the data points sampled every step over the range, of a varying value, except for those left
+	// out or overridden.
+	series := func(skip func(time.Duration) bool, override func(time.Duration) (float64, bool)) []DataPoint {
+		var dataPoints []DataPoint
+		for t := start; t.Before(end); t = t.Add(step) {
+			offset := t.Sub(start)
+			if skip != nil && skip(offset) {
+				continue
+			}
+			value := 10 + float64(offset/step%5)
+			if override != nil {
+				if overridden, ok := override(offset); ok {
+					value = overridden
+				}
+			}
+			dataPoints = append(dataPoints, DataPoint{Timestamp: t, Value: value})
+		}
+		return dataPoints
+	}
+
+	It("should score the data points without issues as perfect", func() {
+		report := checker.Assess(map[string][]DataPoint{"p8s-1": series(nil, nil), "p8s-2": series(nil, nil)},
+			start, end, step)
+		Expect(report.Issues).To(BeEmpty())
+		Expect(report.Score).To(BeNumerically("~", 1, 0.01))
+	})
+
+	It("should report the gaps longer than the max gap but not the shorter ones", func() {
+		report := checker.Assess(map[string][]DataPoint{"p8s-1": series(func(offset time.Duration) bool {
+			return (offset >= time.Hour && offset < 3*time.Hour) || (offset >= 5*time.Hour && offset < 5*time.Hour+10*time.Minute)
+		}, nil)}, start, end, step)
+
+		Expect(report.Issues).To(HaveLen(1))
+		Expect(report.Issues[0].Type).To(Equal(QualityIssueGap))
+		Expect(report.Issues[0].StartTime).To(Equal(start.Add(time.Hour - step)))
+		Expect(report.Issues[0].EndTime).To(Equal(start.Add(3 * time.Hour)))
+		Expect(report.Score).To(BeNumerically("~", 0.8, 0.01))
+	})
+
+	It("should report the flatlines and the counter reset artefacts", func() {
+		report := checker.Assess(map[string][]DataPoint{"p8s-1": series(nil, func(offset time.Duration) (float64, bool) {
+			if offset >= 2*time.Hour && offset < 5*time.Hour {
+				return 7, true
+			}
+			if offset == 8*time.Hour {
+				return 500, true
+			}
+			return 0, false
+		})}, start, end, step)
+
+		Expect(report.Issues).To(HaveLen(2))
+		Expect(report.Issues[0].Type).To(Equal(QualityIssueFlatline))
+		Expect(report.Issues[0].StartTime).To(Equal(start.Add(2 * time.Hour)))
+		Expect(report.Issues[0].EndTime).To(Equal(start.Add(5*time.Hour - step)))
+		Expect(report.Issues[1].Type).To(Equal(QualityIssueCounterReset))
+		Expect(report.Issues[1].StartTime).To(Equal(start.Add(8 * time.Hour)))
+		Expect(report.Score).To(BeNumerically("~", 0.7, 0.01))
+	})
+
+	It("should report the intervals over which the instances disagree", func() {
+		disagreeing := series(nil, func(offset time.Duration) (float64, bool) {
+			if offset >= 4*time.Hour && offset < 6*time.Hour {
+				return 30, true
+			}
+			return 0, false
+		})
+		report := checker.Assess(map[string][]DataPoint{"p8s-1": series(nil, nil), "p8s-2": disagreeing},
+			start, end, step)
+
+		Expect(report.Issues).To(HaveLen(1))
+		Expect(report.Issues[0].Type).To(Equal(QualityIssueInstanceDisagreement))
+		Expect(report.Issues[0].StartTime).To(Equal(start.Add(4 * time.Hour)))
+		Expect(report.Issues[0].EndTime).To(Equal(start.Add(6*time.Hour - step)))
+		Expect(report.Score).To(BeNumerically("~", 0.8, 0.01))
+	})
+
+	It("should score the range without data points as worthless", func() {
+		report := checker.Assess(map[string][]DataPoint{}, start, end, step)
+		Expect(report.Issues).To(HaveLen(1))
+		Expect(report.Score).To(BeZero())
+	})
+})
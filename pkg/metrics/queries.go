@@ -1,6 +1,9 @@
 package metrics
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type QueryComponent struct {
 	metric    string
@@ -31,16 +34,115 @@ func (qb *CompositeQueryBuilder) Build() *CompositeQuery {
 	}
 }
 
+// Ownership is the chain of owners linking the pods of the workloads of a kind to them.
+type Ownership string
+
+const (
+	// OwnershipReplicaSet links the pods to the workloads owning their ReplicaSets, i.e. Deployments and Rollouts.
+	OwnershipReplicaSet Ownership = "ReplicaSet"
+	// OwnershipPod links the pods to the workloads owning them, i.e. StatefulSets.
+	OwnershipPod Ownership = "Pod"
+)
+
+// WorkloadType is how the pods of the workloads of a kind are found in the metrics.
+type WorkloadType struct {
+	// Name is the workload_type of the pods.
+	Name string
+	// Ownership is the chain of owners linking the pods to the workloads.
+	Ownership Ownership
+	// Relabeled is set if the pod owner recording rule labels the pods of the kind with their workload. The pods of the
+	// other kinds are joined to their workloads through the ownership chain of the kube-state-metrics.
+	Relabeled bool
+}
+
+var workloadTypes = map[string]WorkloadType{
+	"Deployment":  {Name: "deployment", Ownership: OwnershipReplicaSet, Relabeled: true},
+	"StatefulSet": {Name: "statefulset", Ownership: OwnershipPod, Relabeled: true},
+	"Rollout":     {Name: "rollout", Ownership: OwnershipReplicaSet},
+}
+
+// WorkloadTypeOf returns the workload type of the given kind. The kinds which aren't known otherwise are taken to own
+// their pods, as the workloads of custom operators usually do.
+func WorkloadTypeOf(kind string) WorkloadType {
+	if workloadType, ok := workloadTypes[kind]; ok {
+		return workloadType
+	}
+	return WorkloadType{Name: strings.ToLower(kind), Ownership: OwnershipPod}
+}
+
+// overrideLabels returns a copy of the labels with the given labels set, or removed if empty.
+func overrideLabels(labels map[string]string, overrides map[string]string) map[string]string {
+	result := make(map[string]string, len(labels)+len(overrides))
+	for key, value := range labels {
+		result[key] = value
+	}
+	for key, value := range overrides {
+		if value == "" {
+			delete(result, key)
+			continue
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// renderPodOwner renders the pods of the workload of the kind given by the owner_kind label, labelled with the
+// workload and the workload_type. The pod owner recording rule is used as it is for the kinds it covers and whenever
+// the kind isn't given.
+func (q *CompositeQuery) renderPodOwner(labels map[string]string) string {
+	kind, ok := labels["owner_kind"]
+	workloadType := WorkloadTypeOf(kind)
+	if !ok || workloadType.Relabeled {
+		return q.queries["pod_owner_metric"].Render(labels)
+	}
+
+	var owners string
+	switch workloadType.Ownership {
+	case OwnershipReplicaSet:
+		owners = fmt.Sprintf("label_replace(%s, \"replicaset\", \"$1\", \"owner_name\", \"(.*)\") "+
+			"* on(namespace, replicaset) group_left(workload) max by (namespace, replicaset, workload) "+
+			"(label_replace(%s, \"workload\", \"$1\", \"owner_name\", \"(.*)\"))",
+			q.queries["kube_pod_owner_metric"].Render(overrideLabels(labels,
+				map[string]string{"owner_kind": "ReplicaSet", "owner_name": ""})),
+			q.queries["replicaset_owner_metric"].Render(labels))
+	default:
+		owners = fmt.Sprintf("label_replace(%s, \"workload\", \"$1\", \"owner_name\", \"(.*)\")",
+			q.queries["kube_pod_owner_metric"].Render(labels))
+	}
+	return fmt.Sprintf("max by (namespace, pod, workload, workload_type) "+
+		"(label_replace(%s, \"workload_type\", \"%s\", \"workload\", \".*\"))", owners, workloadType.Name)
+}
+
+// renderReadyReplicas renders the ready replicas of the workload of the kind given by the owner_kind label, labelled
+// with the owner_kind and the owner_name. The ready replicas of the ReplicaSets owned by the workload are used
+// whenever the kind isn't given.
+func (q *CompositeQuery) renderReadyReplicas(labels map[string]string) string {
+	kind, ok := labels["owner_kind"]
+	if !ok || WorkloadTypeOf(kind).Ownership == OwnershipReplicaSet {
+		return fmt.Sprintf("sum(%s * on(replicaset) group_left(namespace, owner_kind, owner_name) %s) by"+
+			" (namespace, owner_kind, owner_name)",
+			q.queries["ready_replicas_metric"].Render(labels),
+			q.queries["replicaset_owner_metric"].Render(labels))
+	}
+	return fmt.Sprintf("sum(%s * on(namespace, pod) group_left(owner_kind, owner_name) %s) by"+
+		" (namespace, owner_kind, owner_name)",
+		q.queries["pod_ready_metric"].Render(overrideLabels(labels, map[string]string{"condition": "true"})),
+		q.queries["kube_pod_owner_metric"].Render(labels))
+}
+
 type CPUUtilizationQuery CompositeQuery
 type CPUUtilizationBreachQuery CompositeQuery
 type PodReadyLatencyQuery CompositeQuery
+type ContainerCPUUsageQuery CompositeQuery
+type PodCPULimitsQuery CompositeQuery
+type ReplicasAtMaxQuery CompositeQuery
 
 func (qb *CPUUtilizationQuery) Render(labels map[string]string) string {
 
 	return fmt.Sprintf("sum(%s * on (namespace,pod) group_left(workload, workload_type)"+
 		"%s) by(namespace, workload, workload_type)",
 		qb.queries["cpu_utilization_metric"].Render(labels),
-		qb.queries["pod_owner_metric"].Render(labels))
+		(*CompositeQuery)(qb).renderPodOwner(labels))
 }
 
 func (qb *CPUUtilizationBreachQuery) Render(redLineUtilization float64, labels map[string]string) string {
@@ -49,20 +151,17 @@ func (qb *CPUUtilizationBreachQuery) Render(redLineUtilization float64, labels m
 		"%s) by (namespace, workload, workload_type)/ on (namespace, workload, workload_type) "+
 		"group_left sum(%s * on(namespace,pod) group_left(workload, workload_type)"+
 		"%s) by (namespace, workload, workload_type) > %.2f) and on(namespace, workload) "+
-		"label_replace(sum(%s * on(replicaset)"+
-		" group_left(namespace, owner_kind, owner_name) %s) by"+
-		" (namespace, owner_kind, owner_name) < on(namespace, owner_kind, owner_name) "+
+		"label_replace(%s < on(namespace, owner_kind, owner_name) "+
 		"(%s * on(namespace, horizontalpodautoscaler) "+
 		"group_left(owner_kind, owner_name) label_replace(label_replace(%s,\"owner_kind\", \"$1\", "+
 		"\"scaletargetref_kind\", \"(.*)\"), \"owner_name\", \"$1\", \"scaletargetref_name\", \"(.*)\")),"+
 		"\"workload\", \"$1\", \"owner_name\", \"(.*)\")",
 		qb.queries["cpu_utilization_metric"].Render(labels),
-		qb.queries["pod_owner_metric"].Render(labels),
+		(*CompositeQuery)(qb).renderPodOwner(labels),
 		qb.queries["resource_limit_metric"].Render(labels),
-		qb.queries["pod_owner_metric"].Render(labels),
+		(*CompositeQuery)(qb).renderPodOwner(labels),
 		redLineUtilization,
-		qb.queries["ready_replicas_metric"].Render(labels),
-		qb.queries["replicaset_owner_metric"].Render(labels),
+		(*CompositeQuery)(qb).renderReadyReplicas(labels),
 		qb.queries["hpa_max_replicas_metric"].Render(labels),
 		qb.queries["hpa_owner_info_metric"].Render(labels))
 
@@ -75,7 +174,41 @@ func (qb *PodReadyLatencyQuery) Render(labels map[string]string) string {
 		"(%s))",
 		qb.queries["pod_ready_time_metric"].Render(labels),
 		qb.queries["pod_created_time_metric"].Render(labels),
-		qb.queries["pod_owner_metric"].Render(labels))
+		(*CompositeQuery)(qb).renderPodOwner(labels))
+}
+
+// Render returns the quantile of the CPU usage of every container of the workload over the window, the highest of its
+// pods.
+func (qb *ContainerCPUUsageQuery) Render(quantile float64, window string, labels map[string]string) string {
+
+	return fmt.Sprintf("max(quantile_over_time(%.2f, %s[%s]) * on (namespace,pod) group_left(workload, workload_type)"+
+		"%s) by(namespace, workload, workload_type, container)",
+		quantile,
+		qb.queries["cpu_utilization_metric"].Render(labels),
+		window,
+		(*CompositeQuery)(qb).renderPodOwner(labels))
+}
+
+// Render returns the CPU limits of the pods of the workload, averaged over its pods.
+func (qb *PodCPULimitsQuery) Render(labels map[string]string) string {
+
+	return fmt.Sprintf("avg(sum(%s) by (namespace, pod) * on (namespace,pod) group_left(workload, workload_type)"+
+		"%s) by(namespace, workload, workload_type)",
+		qb.queries["resource_limit_metric"].Render(labels),
+		(*CompositeQuery)(qb).renderPodOwner(labels))
+}
+
+// Render returns the ready replicas of the workload while they are at or above the max replicas of its HPA.
+func (qb *ReplicasAtMaxQuery) Render(labels map[string]string) string {
+
+	return fmt.Sprintf("label_replace(%s >= on(namespace, owner_kind, owner_name) "+
+		"(%s * on(namespace, horizontalpodautoscaler) "+
+		"group_left(owner_kind, owner_name) label_replace(label_replace(%s,\"owner_kind\", \"$1\", "+
+		"\"scaletargetref_kind\", \"(.*)\"), \"owner_name\", \"$1\", \"scaletargetref_name\", \"(.*)\")),"+
+		"\"workload\", \"$1\", \"owner_name\", \"(.*)\")",
+		(*CompositeQuery)(qb).renderReadyReplicas(labels),
+		qb.queries["hpa_max_replicas_metric"].Render(labels),
+		qb.queries["hpa_owner_info_metric"].Render(labels))
 }
 
 func ValidateQuery(query string) bool {
@@ -36,6 +36,112 @@ var _ = Describe("Queries", func() {
 		})
 	})
 
+	Describe("ContainerCPUUsageQuery", func() {
+		It("should render the quantile of the usage of every container of the workload", func() {
+			query := (*ContainerCPUUsageQuery)(NewPrometheusCompositeQueries()).Render(0.9, "4w",
+				map[string]string{"namespace": "default"})
+			Expect(query).To(Equal("max(quantile_over_time(0.90, " +
+				"node_namespace_pod_container:container_cpu_usage_seconds_total:sum_irate{namespace=\"default\"}[4w]) " +
+				"* on (namespace,pod) group_left(workload, workload_type)" +
+				"namespace_workload_pod:kube_pod_owner:relabel{namespace=\"default\"}) " +
+				"by(namespace, workload, workload_type, container)"))
+			Expect(ValidateQuery(query)).To(BeTrue())
+		})
+	})
+
+	Describe("PodCPULimitsQuery", func() {
+		It("should render the CPU limits of the pods of the workload averaged over its pods", func() {
+			query := (*PodCPULimitsQuery)(NewPrometheusCompositeQueries()).Render(
+				map[string]string{"namespace": "default"})
+			Expect(query).To(Equal("avg(sum(" +
+				"cluster:namespace:pod_cpu:active:kube_pod_container_resource_limits{namespace=\"default\"}) " +
+				"by (namespace, pod) * on (namespace,pod) group_left(workload, workload_type)" +
+				"namespace_workload_pod:kube_pod_owner:relabel{namespace=\"default\"}) " +
+				"by(namespace, workload, workload_type)"))
+			Expect(ValidateQuery(query)).To(BeTrue())
+		})
+	})
+
+	Describe("ReplicasAtMaxQuery", func() {
+		It("should render the ready replicas of the workload at or above the max replicas of its HPA", func() {
+			query := (*ReplicasAtMaxQuery)(NewPrometheusCompositeQueries()).Render(
+				map[string]string{"namespace": "default"})
+			Expect(query).To(Equal("label_replace(sum(" +
+				"kube_replicaset_status_ready_replicas{namespace=\"default\"} * on(replicaset) " +
+				"group_left(namespace, owner_kind, owner_name) kube_replicaset_owner{namespace=\"default\"}) " +
+				"by (namespace, owner_kind, owner_name) >= on(namespace, owner_kind, owner_name) " +
+				"(kube_horizontalpodautoscaler_spec_max_replicas{namespace=\"default\"} " +
+				"* on(namespace, horizontalpodautoscaler) group_left(owner_kind, owner_name) " +
+				"label_replace(label_replace(kube_horizontalpodautoscaler_info{namespace=\"default\"}," +
+				"\"owner_kind\", \"$1\", \"scaletargetref_kind\", \"(.*)\"), " +
+				"\"owner_name\", \"$1\", \"scaletargetref_name\", \"(.*)\"))," +
+				"\"workload\", \"$1\", \"owner_name\", \"(.*)\")"))
+			Expect(ValidateQuery(query)).To(BeTrue())
+		})
+	})
+
+	Describe("WorkloadTypeOf", func() {
+		It("should return the workload type of the kind", func() {
+			Expect(WorkloadTypeOf("Deployment")).To(Equal(WorkloadType{Name: "deployment",
+				Ownership: OwnershipReplicaSet, Relabeled: true}))
+			Expect(WorkloadTypeOf("StatefulSet")).To(Equal(WorkloadType{Name: "statefulset",
+				Ownership: OwnershipPod, Relabeled: true}))
+			Expect(WorkloadTypeOf("Rollout")).To(Equal(WorkloadType{Name: "rollout", Ownership: OwnershipReplicaSet}))
+		})
+
+		It("should take the unknown kinds to own their pods", func() {
+			Expect(WorkloadTypeOf("CloneSet")).To(Equal(WorkloadType{Name: "cloneset", Ownership: OwnershipPod}))
+		})
+	})
+
+	Describe("renderPodOwner", func() {
+		queries := NewPrometheusCompositeQueries()
+
+		It("should use the pod owner recording rule for the kinds it covers", func() {
+			Expect(queries.renderPodOwner(map[string]string{"workload_type": "statefulset",
+				"owner_kind": "StatefulSet"})).To(Equal(
+				"namespace_workload_pod:kube_pod_owner:relabel{workload_type=\"statefulset\"}"))
+		})
+
+		It("should join the pods of a Rollout to it through their ReplicaSets", func() {
+			query := queries.renderPodOwner(map[string]string{"owner_kind": "Rollout"})
+			Expect(query).To(Equal("max by (namespace, pod, workload, workload_type) " +
+				"(label_replace(label_replace(kube_pod_owner{owner_kind=\"ReplicaSet\"}, " +
+				"\"replicaset\", \"$1\", \"owner_name\", \"(.*)\") " +
+				"* on(namespace, replicaset) group_left(workload) max by (namespace, replicaset, workload) " +
+				"(label_replace(kube_replicaset_owner{owner_kind=\"Rollout\"}, \"workload\", \"$1\", \"owner_name\", \"(.*)\")), " +
+				"\"workload_type\", \"rollout\", \"workload\", \".*\"))"))
+			Expect(ValidateQuery(query)).To(BeTrue())
+		})
+
+		It("should join the pods of the other kinds to the workload owning them", func() {
+			query := queries.renderPodOwner(map[string]string{"owner_kind": "CloneSet"})
+			Expect(query).To(Equal("max by (namespace, pod, workload, workload_type) " +
+				"(label_replace(label_replace(kube_pod_owner{owner_kind=\"CloneSet\"}, " +
+				"\"workload\", \"$1\", \"owner_name\", \"(.*)\"), " +
+				"\"workload_type\", \"cloneset\", \"workload\", \".*\"))"))
+			Expect(ValidateQuery(query)).To(BeTrue())
+		})
+	})
+
+	Describe("renderReadyReplicas", func() {
+		queries := NewPrometheusCompositeQueries()
+
+		It("should sum the ready replicas of the ReplicaSets of a Rollout", func() {
+			Expect(queries.renderReadyReplicas(map[string]string{"owner_kind": "Rollout"})).To(Equal(
+				"sum(kube_replicaset_status_ready_replicas * on(replicaset) " +
+					"group_left(namespace, owner_kind, owner_name) kube_replicaset_owner{owner_kind=\"Rollout\"}) " +
+					"by (namespace, owner_kind, owner_name)"))
+		})
+
+		It("should count the ready pods of a StatefulSet", func() {
+			Expect(queries.renderReadyReplicas(map[string]string{"owner_kind": "StatefulSet"})).To(Equal(
+				"sum(kube_pod_status_ready{condition=\"true\"} * on(namespace, pod) " +
+					"group_left(owner_kind, owner_name) kube_pod_owner{owner_kind=\"StatefulSet\"}) " +
+					"by (namespace, owner_kind, owner_name)"))
+		})
+	})
+
 	Describe("ValidateQuery", func() {
 		Context("when the query is valid", func() {
 			It("should return true", func() {
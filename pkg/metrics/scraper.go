@@ -19,8 +19,13 @@ import (
 )
 
 const (
-	CPUUtilizationDataPointsQuery = "cpuUtilizationDataPointsQuery"
-	BreachDataPointsQuery         = "breachDataPointsQuery"
+	CPUUtilizationDataPointsQuery  = "cpuUtilizationDataPointsQuery"
+	InstanceCPUUtilizationQuery    = "instanceCPUUtilizationQuery"
+	BreachDataPointsQuery          = "breachDataPointsQuery"
+	ContainerCPUUsageQuantileQuery = "containerCPUUsageQuantileQuery"
+	PodCPULimitsDataPointsQuery    = "podCPULimitsDataPointsQuery"
+	ReplicasAtMaxDataPointsQuery   = "replicasAtMaxDataPointsQuery"
+	PodReadyLatencyQueryName       = "podReadyLatencyQuery"
 )
 
 var (
@@ -58,10 +63,40 @@ var (
 		prometheus.GaugeOpts{Name: "p8s_concurrent_queries",
 			Help: "Number of concurrent p8s queries"}, []string{"query", "p8sinstance"},
 	)
+
+	p8sQueuedQueries = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "p8s_queued_queries",
+			Help: "Number of p8s queries waiting for a concurrency slot"}, []string{"query", "p8sinstance"},
+	)
+
+	p8sQueryRetryCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{Name: "p8s_query_retry_count",
+			Help: "P8s query retry counter"}, []string{"query", "p8sinstance"},
+	)
+
+	p8sCircuitBreakerState = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "p8s_circuit_breaker_state",
+			Help: "State of the circuit breaker of the p8s instance, 0 closed, 1 half open and 2 open"}, []string{"p8sinstance"},
+	)
+
+	p8sInstanceSkippedCount = promauto.NewCounterVec(
+		prometheus.CounterOpts{Name: "p8s_instance_skipped_count",
+			Help: "Number of times the p8s instance was taken out of the fan-out by its circuit breaker"}, []string{"query", "p8sinstance"},
+	)
 )
 
 func init() {
-	p8smetrics.Registry.MustRegister(prometheusQueryLatency, dataPointsFetched, totalDataPointsFetched, p8sInstanceQueried, p8sQueryErrorCount, p8sQuerySuccessCount, p8sConcurrentQueries)
+	p8smetrics.Registry.MustRegister(prometheusQueryLatency, dataPointsFetched, totalDataPointsFetched, p8sInstanceQueried, p8sQueryErrorCount, p8sQuerySuccessCount, p8sConcurrentQueries, p8sQueuedQueries, p8sQueryRetryCount, p8sCircuitBreakerState, p8sInstanceSkippedCount)
+}
+
+// DeleteWorkloadMetrics deletes the label sets of the per workload scraper metrics. It should be called once the
+// workload has been deleted so that stale series aren't exported.
+func DeleteWorkloadMetrics(namespace, workload string) {
+	labels := prometheus.Labels{"namespace": namespace, "workload": workload}
+	prometheusQueryLatency.DeletePartialMatch(labels)
+	dataPointsFetched.DeletePartialMatch(labels)
+	totalDataPointsFetched.DeletePartialMatch(labels)
+	p8sInstanceQueried.DeletePartialMatch(labels)
 }
 
 type DataPoint struct {
@@ -69,15 +104,19 @@ type DataPoint struct {
 	Value     float64
 }
 
-// Scraper is an interface for scraping metrics data.
+// Scraper is an interface for scraping metrics data. The queries are cancelled along with the context, and their
+// deadline is the earlier of the context's and the scraper's query timeout.
 type Scraper interface {
-	GetAverageCPUUtilizationByWorkload(namespace,
+	GetAverageCPUUtilizationByWorkload(ctx context.Context,
+		namespace,
+		workloadType,
 		workload string,
 		start time.Time,
 		end time.Time,
 		step time.Duration) ([]DataPoint, error)
 
-	GetCPUUtilizationBreachDataPoints(namespace,
+	GetCPUUtilizationBreachDataPoints(ctx context.Context,
+		namespace,
 		workloadType,
 		workload string,
 		redLineUtilization float64,
@@ -85,10 +124,64 @@ type Scraper interface {
 		end time.Time,
 		step time.Duration) ([]DataPoint, error)
 
-	GetACLByWorkload(namespace,
+	GetACLByWorkload(ctx context.Context,
+		namespace,
+		workloadType,
 		workload string) (time.Duration, error)
 }
 
+// ContainerUsageScraper is an interface for scraping the CPU usage of the individual containers of a workload.
+type ContainerUsageScraper interface {
+	// GetContainerCPUUsageQuantile returns the quantile of the CPU usage in cores of every container of the workload
+	// over the window ending at end, keyed by the container name.
+	GetContainerCPUUsageQuantile(ctx context.Context,
+		namespace,
+		workloadType,
+		workload string,
+		quantile float64,
+		window time.Duration,
+		end time.Time) (map[string]float64, error)
+}
+
+// InstanceScraper is an interface for scraping the metrics data of every Prometheus instance apart.
+type InstanceScraper interface {
+	// GetAverageCPUUtilizationByInstance returns the raw data points of the average CPU utilization of the workload
+	// returned by every instance, keyed by its address.
+	GetAverageCPUUtilizationByInstance(ctx context.Context,
+		namespace,
+		workloadType,
+		workload string,
+		start time.Time,
+		end time.Time,
+		step time.Duration) (map[string][]DataPoint, error)
+}
+
+// PodResourceScraper is an interface for scraping the history of the CPU resources of the pods of a workload.
+type PodResourceScraper interface {
+	// GetPodCPULimitsByWorkload returns the average CPU limits in cores of the pods of the workload in the given time
+	// range.
+	GetPodCPULimitsByWorkload(ctx context.Context,
+		namespace,
+		workloadType,
+		workload string,
+		start time.Time,
+		end time.Time,
+		step time.Duration) ([]DataPoint, error)
+}
+
+// MaxReplicasScraper is an interface for scraping the intervals in which a workload ran at the max replicas of its HPA.
+type MaxReplicasScraper interface {
+	// GetReplicasAtMaxDataPoints returns the data points of the ready replicas of the workload while they were at or
+	// above the max replicas of its HPA in the given time range.
+	GetReplicasAtMaxDataPoints(ctx context.Context,
+		namespace,
+		workloadType,
+		workload string,
+		start time.Time,
+		end time.Time,
+		step time.Duration) ([]DataPoint, error)
+}
+
 // PrometheusScraper is a Scraper implementation that scrapes metrics data from Prometheus.
 type PrometheusScraper struct {
 	api                       []PrometheusInstance
@@ -99,6 +192,11 @@ type PrometheusScraper struct {
 	CPUUtilizationQuery       *CPUUtilizationQuery
 	CPUUtilizationBreachQuery *CPUUtilizationBreachQuery
 	PodReadyLatencyQuery      *PodReadyLatencyQuery
+	ContainerCPUUsageQuery    *ContainerCPUUsageQuery
+	PodCPULimitsQuery         *PodCPULimitsQuery
+	ReplicasAtMaxQuery        *ReplicasAtMaxQuery
+	mergeStrategy             MergeStrategy
+	queryLimiter              *QueryLimiter
 	logger                    logr.Logger
 }
 
@@ -107,8 +205,9 @@ type PrometheusQueryResult struct {
 	err    error
 }
 
-func (ps *PrometheusScraper) GetACLByWorkload(namespace string, workload string) (time.Duration, error) {
-	podBootStrapTime, err := ps.getPodReadyLatencyByWorkload(namespace, workload)
+func (ps *PrometheusScraper) GetACLByWorkload(ctx context.Context, namespace string, workloadType string,
+	workload string) (time.Duration, error) {
+	podBootStrapTime, err := ps.getPodReadyLatencyByWorkload(ctx, namespace, workloadType, workload)
 	if err != nil {
 		return 0.0, fmt.Errorf("error getting pod bootstrap time: %v", err)
 	}
@@ -131,11 +230,15 @@ func NewPrometheusCompositeQueries() *CompositeQuery {
 	hpaOwnerInfoMetric := "kube_horizontalpodautoscaler_info"
 	podCreatedTimeMetric := "kube_pod_created"
 	podReadyTimeMetric := "kube_pod_status_ready_time"
+	kubePodOwnerMetric := "kube_pod_owner"
+	podReadyMetric := "kube_pod_status_ready"
 
 	return NewCompositeQueryBuilder().
 		WithQuery("pod_ready_time_metric", (*QueryComponent)(NewQueryComponentBuilder().WithMetric(podReadyTimeMetric).WithLabelKeys([]string{"namespace"}).Build())).
 		WithQuery("pod_created_time_metric", (*QueryComponent)(NewQueryComponentBuilder().WithMetric(podCreatedTimeMetric).WithLabelKeys([]string{"namespace"}).Build())).
 		WithQuery("pod_owner_metric", (*QueryComponent)(NewQueryComponentBuilder().WithMetric(podOwnerMetric).WithLabelKeys([]string{"namespace", "workload", "workload_type"}).Build())).
+		WithQuery("kube_pod_owner_metric", (*QueryComponent)(NewQueryComponentBuilder().WithMetric(kubePodOwnerMetric).WithLabelKeys([]string{"namespace", "owner_kind", "owner_name"}).Build())).
+		WithQuery("pod_ready_metric", (*QueryComponent)(NewQueryComponentBuilder().WithMetric(podReadyMetric).WithLabelKeys([]string{"namespace", "condition"}).Build())).
 		WithQuery("cpu_utilization_metric", (*QueryComponent)(NewQueryComponentBuilder().WithMetric(cpuUtilizationMetric).WithLabelKeys([]string{"namespace"}).Build())).
 		WithQuery("resource_limit_metric", (*QueryComponent)(NewQueryComponentBuilder().WithMetric(resourceLimitMetric).WithLabelKeys([]string{"namespace"}).Build())).
 		WithQuery("ready_replicas_metric", (*QueryComponent)(NewQueryComponentBuilder().WithMetric(readyReplicasMetric).WithLabelKeys([]string{"namespace"}).Build())).
@@ -145,6 +248,17 @@ func NewPrometheusCompositeQueries() *CompositeQuery {
 		Build()
 }
 
+// workloadLabels returns the labels selecting the workload of the given kind in the queries.
+func workloadLabels(namespace string, workloadType string, workload string) map[string]string {
+	return map[string]string{
+		"namespace":     namespace,
+		"workload":      workload,
+		"workload_type": WorkloadTypeOf(workloadType).Name,
+		"owner_kind":    workloadType,
+		"owner_name":    workload,
+	}
+}
+
 // NewPrometheusScraper returns a new PrometheusScraper instance.
 
 func NewPrometheusScraper(apiUrls []string,
@@ -152,13 +266,16 @@ func NewPrometheusScraper(apiUrls []string,
 	splitInterval time.Duration,
 	metricIngestionTime float64,
 	metricProbeTime float64,
+	mergeStrategy MergeStrategy,
+	queryLimiter *QueryLimiter,
 	logger logr.Logger) (*PrometheusScraper, error) {
 
 	var prometheusInstances []PrometheusInstance
 	for _, pi := range apiUrls {
 		logger.Info("prometheus instance ", "endpoint", pi)
 		client, err := api.NewClient(api.Config{
-			Address: pi,
+			Address:      pi,
+			RoundTripper: &tracingRoundTripper{next: api.DefaultRoundTripper},
 		})
 
 		if err != nil {
@@ -175,37 +292,193 @@ func NewPrometheusScraper(apiUrls []string,
 
 	return &PrometheusScraper{api: prometheusInstances,
 		queryTimeout:              timeout,
-		rangeQuerySplitter:        NewRangeQuerySplitter(splitInterval),
+		rangeQuerySplitter:        NewRangeQuerySplitter(splitInterval, queryLimiter),
 		metricProbeTime:           metricProbeTime,
 		metricIngestionTime:       metricIngestionTime,
 		CPUUtilizationQuery:       (*CPUUtilizationQuery)(compositeQuery),
 		CPUUtilizationBreachQuery: (*CPUUtilizationBreachQuery)(compositeQuery),
 		PodReadyLatencyQuery:      (*PodReadyLatencyQuery)(compositeQuery),
+		ContainerCPUUsageQuery:    (*ContainerCPUUsageQuery)(compositeQuery),
+		PodCPULimitsQuery:         (*PodCPULimitsQuery)(compositeQuery),
+		ReplicasAtMaxQuery:        (*ReplicasAtMaxQuery)(compositeQuery),
+		mergeStrategy:             mergeStrategy,
+		queryLimiter:              queryLimiter,
 		logger:                    logger}, nil
 }
 
 // GetAverageCPUUtilizationByWorkload returns the average CPU utilization for the given workload type and name in the
 // specified namespace, in the given time range.
-func (ps *PrometheusScraper) GetAverageCPUUtilizationByWorkload(namespace string,
+func (ps *PrometheusScraper) GetAverageCPUUtilizationByWorkload(ctx context.Context,
+	namespace string,
+	workloadType string,
 	workload string,
 	start time.Time,
 	end time.Time,
 	step time.Duration) ([]DataPoint, error) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), ps.queryTimeout)
-	defer cancel()
+	query := ps.CPUUtilizationQuery.Render(workloadLabels(namespace, workloadType, workload))
+	totalDataPoints, err := ps.queryRangeByWorkload(ctx, namespace, workload, CPUUtilizationDataPointsQuery, query, start,
+		end, step)
+	if err != nil {
+		return nil, err
+	}
+	if totalDataPoints == nil {
+		return nil, fmt.Errorf("unable to getCPUUtlizationDataPoints metrics from any of the prometheus instances")
+	}
+	totalDataPoints = ps.interpolateMissingDataPoints(totalDataPoints, step)
+	return totalDataPoints, nil
+}
 
-	query := ps.CPUUtilizationQuery.Render(map[string]string{"namespace": namespace, "workload": workload, "workload_type": "deployment"})
+// GetPodCPULimitsByWorkload returns the average CPU limits of the pods of the given workload type and name in the
+// specified namespace, in the given time range.
+func (ps *PrometheusScraper) GetPodCPULimitsByWorkload(ctx context.Context,
+	namespace string,
+	workloadType string,
+	workload string,
+	start time.Time,
+	end time.Time,
+	step time.Duration) ([]DataPoint, error) {
+
+	query := ps.PodCPULimitsQuery.Render(workloadLabels(namespace, workloadType, workload))
+	dataPoints, err := ps.queryRangeByWorkload(ctx, namespace, workload, PodCPULimitsDataPointsQuery, query, start, end, step)
+	if err != nil {
+		return nil, err
+	}
+	if dataPoints == nil {
+		return nil, fmt.Errorf("unable to get the pod CPU limits from any of the prometheus instances")
+	}
+	return dataPoints, nil
+}
+
+// GetReplicasAtMaxDataPoints returns the data points where the ready pods of the given workload type and name in the
+// specified namespace were at or above the maxReplicas defined in the HPA, in the given time range. These are the
+// intervals the breach data points leave out.
+func (ps *PrometheusScraper) GetReplicasAtMaxDataPoints(ctx context.Context,
+	namespace string,
+	workloadType string,
+	workload string,
+	start time.Time,
+	end time.Time,
+	step time.Duration) ([]DataPoint, error) {
+
+	query := ps.ReplicasAtMaxQuery.Render(overrideLabels(
+		workloadLabels(namespace, workloadType, workload),
+		map[string]string{"scaletargetref_kind": workloadType, "scaletargetref_name": workload}))
+	return ps.queryRangeByWorkload(ctx, namespace, workload, ReplicasAtMaxDataPointsQuery, query, start, end, step)
+}
+
+// GetAverageCPUUtilizationByInstance returns the average CPU utilization for the given workload type and name in the
+// specified namespace, in the given time range, as returned by every instance, keyed by its address. The data points
+// are neither merged nor interpolated.
+func (ps *PrometheusScraper) GetAverageCPUUtilizationByInstance(ctx context.Context,
+	namespace string,
+	workloadType string,
+	workload string,
+	start time.Time,
+	end time.Time,
+	step time.Duration) (map[string][]DataPoint, error) {
+
+	query := ps.CPUUtilizationQuery.Render(workloadLabels(namespace, workloadType, workload))
+	dataPointsByInstance, err := ps.queryRangeByInstance(ctx, namespace, workload, CPUUtilizationDataPointsQuery, query,
+		start, end, step)
+	if err != nil {
+		return nil, err
+	}
+	if len(dataPointsByInstance) == 0 {
+		return nil, fmt.Errorf("unable to getCPUUtlizationDataPoints metrics from any of the prometheus instances")
+	}
+	return dataPointsByInstance, nil
+}
+
+// queryRangeByWorkload executes the range query of a single time series of the workload on all the instances and
+// merges their data points with the merge strategy. It returns nil if no instance returned the time series.
+func (ps *PrometheusScraper) queryRangeByWorkload(ctx context.Context,
+	namespace string,
+	workload string,
+	queryName string,
+	query string,
+	start time.Time,
+	end time.Time,
+	step time.Duration) ([]DataPoint, error) {
+
+	dataPointsByInstance, err := ps.queryRangeByInstance(ctx, namespace, workload, queryName, query, start, end, step)
+	if err != nil {
+		return nil, err
+	}
+	totalDataPoints := mergeInstances(ps.mergeStrategy, ps.instanceAddresses(), dataPointsByInstance)
+
+	totalDataPointsFetched.WithLabelValues(namespace, queryName, workload).Set(float64(len(totalDataPoints)))
+	return totalDataPoints, nil
+}
+
+// withQueryTimeout returns the context of a call of the scraper, cancelled along with ctx, whose deadline is the
+// earlier of ctx's and the query timeout.
+func (ps *PrometheusScraper) withQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, ps.queryTimeout)
+}
+
+// loggerFrom returns the logger of ctx, which carries the values of the caller like its trace ID, or the scraper's
+// logger with the trace ID of ctx if it has none.
+func (ps *PrometheusScraper) loggerFrom(ctx context.Context) logr.Logger {
+	if logger, err := logr.FromContext(ctx); err == nil {
+		return logger
+	}
+	if traceID := TraceIDFromContext(ctx); traceID != "" {
+		return ps.logger.WithValues("traceID", traceID)
+	}
+	return ps.logger
+}
+
+// skipInstance records that the instance was taken out of the fan-out of the query by its circuit breaker.
+func (ps *PrometheusScraper) skipInstance(ctx context.Context, namespace string, queryName string, address string,
+	workload string) {
+	ps.loggerFrom(ctx).V(1).Info("Skipping the prometheus instance as its circuit breaker is open", "Instance", address)
+	fanOutReportFrom(ctx).recordUnanswered(address)
+	p8sInstanceSkippedCount.WithLabelValues(queryName, address).Inc()
+	p8sInstanceQueried.WithLabelValues(namespace, queryName, address, workload).Set(0)
+}
+
+// instanceDataPoints are the data points of a time series returned by an instance.
+type instanceDataPoints struct {
+	address    string
+	dataPoints []DataPoint
+}
+
+// instanceAddresses returns the addresses of the instances in the configured order.
+func (ps *PrometheusScraper) instanceAddresses() []string {
+	addresses := make([]string, 0, len(ps.api))
+	for _, pi := range ps.api {
+		addresses = append(addresses, pi.address)
+	}
+	return addresses
+}
+
+// queryRangeByInstance executes the range query of a single time series of the workload on all the instances and
+// returns the data points of every instance which returned the time series, keyed by its address.
+func (ps *PrometheusScraper) queryRangeByInstance(ctx context.Context,
+	namespace string,
+	workload string,
+	queryName string,
+	query string,
+	start time.Time,
+	end time.Time,
+	step time.Duration) (map[string][]DataPoint, error) {
+
+	ctx, cancel := ps.withQueryTimeout(ctx)
+	defer cancel()
 
-	var totalDataPoints []DataPoint
 	if ps.api == nil {
 		return nil, fmt.Errorf("no apiurl for executing prometheus query")
 	}
 
 	resultChanLength := len(ps.api) + 5 //Added some buffer
-	resultChan := make(chan []DataPoint, resultChanLength)
+	resultChan := make(chan instanceDataPoints, resultChanLength)
 	var wg sync.WaitGroup
 	for _, pi := range ps.api {
+		if !ps.queryLimiter.Allow(pi.address) {
+			ps.skipInstance(ctx, namespace, queryName, pi.address, workload)
+			continue
+		}
 
 		wg.Add(1)
 		go func(pi PrometheusInstance) {
@@ -215,53 +488,43 @@ func (ps *PrometheusScraper) GetAverageCPUUtilizationByWorkload(namespace string
 			result, err := ps.rangeQuerySplitter.QueryRangeByInterval(ctx, pi, query, start, end, step)
 
 			if err != nil {
-				ps.logger.Error(err, "failed to execute Prometheus query", "Instance", pi.address)
-				logP8sMetrics(p8sQueryStartTime, namespace, CPUUtilizationDataPointsQuery, pi.address, workload, -1, 0)
-				resultChan <- nil
+				ps.loggerFrom(ctx).Error(err, "failed to execute Prometheus query", "Instance", pi.address)
+				logP8sMetrics(p8sQueryStartTime, namespace, queryName, pi.address, workload, -1, 0)
+				fanOutReportFrom(ctx).recordUnanswered(pi.address)
+				resultChan <- instanceDataPoints{address: pi.address}
 				return
 			}
 			if result.Type() != model.ValMatrix {
-				ps.logger.Error(fmt.Errorf("unexpected result type: %v", result.Type()), "Result Type Error", "Instance", pi.address)
-				logP8sMetrics(p8sQueryStartTime, namespace, CPUUtilizationDataPointsQuery, pi.address, workload, -1, 1)
-				resultChan <- nil
+				ps.loggerFrom(ctx).Error(fmt.Errorf("unexpected result type: %v", result.Type()), "Result Type Error", "Instance", pi.address)
+				logP8sMetrics(p8sQueryStartTime, namespace, queryName, pi.address, workload, -1, 1)
+				fanOutReportFrom(ctx).recordUnanswered(pi.address)
+				resultChan <- instanceDataPoints{address: pi.address}
 				return
 			}
 
 			matrix := result.(model.Matrix)
 			if len(matrix) != 1 {
-				ps.logger.Error(fmt.Errorf("unexpected no of time series: %v", len(matrix)), "Zero Datapoints Error", "Instance", pi.address)
-				logP8sMetrics(p8sQueryStartTime, namespace, CPUUtilizationDataPointsQuery, pi.address, workload, 0, 1)
-				resultChan <- nil
+				ps.loggerFrom(ctx).Error(fmt.Errorf("unexpected no of time series: %v", len(matrix)), "Zero Datapoints Error", "Instance", pi.address)
+				logP8sMetrics(p8sQueryStartTime, namespace, queryName, pi.address, workload, 0, 1)
+				resultChan <- instanceDataPoints{address: pi.address}
 				return
 			}
-			var dataPoints []DataPoint
-			for _, sample := range matrix[0].Values {
-				datapoint := DataPoint{sample.Timestamp.Time(), float64(sample.Value)}
-				if !sample.Timestamp.Time().IsZero() {
-					dataPoints = append(dataPoints, datapoint)
-				}
-			}
-			logP8sMetrics(p8sQueryStartTime, namespace, CPUUtilizationDataPointsQuery, pi.address, workload, len(dataPoints), 1)
+			dataPoints := alignToStep(matrix[0].Values, start, step)
+			logP8sMetrics(p8sQueryStartTime, namespace, queryName, pi.address, workload, len(dataPoints), 1)
 
-			sort.SliceStable(dataPoints, func(i, j int) bool {
-				return dataPoints[i].Timestamp.Before(dataPoints[j].Timestamp)
-			})
-			resultChan <- dataPoints
+			resultChan <- instanceDataPoints{address: pi.address, dataPoints: dataPoints}
 		}(pi)
 	}
 	wg.Wait()
 	close(resultChan)
 
+	dataPointsByInstance := make(map[string][]DataPoint)
 	for p8sQueryResult := range resultChan {
-		totalDataPoints = aggregateMetrics(totalDataPoints, p8sQueryResult)
-	}
-
-	totalDataPointsFetched.WithLabelValues(namespace, CPUUtilizationDataPointsQuery, workload).Set(float64(len(totalDataPoints)))
-	if totalDataPoints == nil {
-		return nil, fmt.Errorf("unable to getCPUUtlizationDataPoints metrics from any of the prometheus instances")
+		if p8sQueryResult.dataPoints != nil {
+			dataPointsByInstance[p8sQueryResult.address] = p8sQueryResult.dataPoints
+		}
 	}
-	totalDataPoints = ps.interpolateMissingDataPoints(totalDataPoints, step)
-	return totalDataPoints, nil
+	return dataPointsByInstance, nil
 }
 
 func aggregateMetrics(dataPoints1 []DataPoint, dataPoints2 []DataPoint) []DataPoint {
@@ -300,29 +563,33 @@ func aggregateMetrics(dataPoints1 []DataPoint, dataPoints2 []DataPoint) []DataPo
 
 // GetCPUUtilizationBreachDataPoints returns the data points where avg CPU utilization for a workload goes above the
 // redLineUtilization while no of ready pods for the workload were < maxReplicas defined in the HPA.
-func (ps *PrometheusScraper) GetCPUUtilizationBreachDataPoints(namespace,
+func (ps *PrometheusScraper) GetCPUUtilizationBreachDataPoints(ctx context.Context,
+	namespace,
 	workloadType,
 	workload string,
 	redLineUtilization float64,
 	start time.Time,
 	end time.Time,
 	step time.Duration) ([]DataPoint, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), ps.queryTimeout)
+	ctx, cancel := ps.withQueryTimeout(ctx)
 	defer cancel()
 
-	query := ps.CPUUtilizationBreachQuery.Render(redLineUtilization, map[string]string{"namespace": namespace,
-		"workload": workload, "workload_type": "deployment", "owner_kind": workloadType, "owner_name": workload,
-		"scaletargetref_kind": workloadType, "scaletargetref_name": workload})
+	query := ps.CPUUtilizationBreachQuery.Render(redLineUtilization, overrideLabels(
+		workloadLabels(namespace, workloadType, workload),
+		map[string]string{"scaletargetref_kind": workloadType, "scaletargetref_name": workload}))
 
 	resultChanLength := len(ps.api) + 5 //Added some buffer
-	resultChan := make(chan []DataPoint, resultChanLength)
+	resultChan := make(chan instanceDataPoints, resultChanLength)
 	var wg sync.WaitGroup
 
-	var totalDataPoints []DataPoint
 	if ps.api == nil {
 		return nil, fmt.Errorf("no apiurl for executing prometheus query")
 	}
 	for _, pi := range ps.api {
+		if !ps.queryLimiter.Allow(pi.address) {
+			ps.skipInstance(ctx, namespace, BreachDataPointsQuery, pi.address, workload)
+			continue
+		}
 
 		wg.Add(1)
 		go func(pi PrometheusInstance) {
@@ -330,55 +597,52 @@ func (ps *PrometheusScraper) GetCPUUtilizationBreachDataPoints(namespace,
 			p8sQueryStartTime := time.Now()
 			result, err := ps.rangeQuerySplitter.QueryRangeByInterval(ctx, pi, query, start, end, step)
 			if err != nil {
-				ps.logger.Error(err, "failed to execute Prometheus query", "Instance", pi.address)
+				ps.loggerFrom(ctx).Error(err, "failed to execute Prometheus query", "Instance", pi.address)
 				logP8sMetrics(p8sQueryStartTime, namespace, BreachDataPointsQuery, pi.address, workload, -1, 0)
-				resultChan <- nil
+				fanOutReportFrom(ctx).recordUnanswered(pi.address)
+				resultChan <- instanceDataPoints{address: pi.address}
 				return
 			}
 			if result.Type() != model.ValMatrix {
-				ps.logger.Error(fmt.Errorf("unexpected result type: %v", result.Type()), "Result Type Error", "Instance", pi.address)
+				ps.loggerFrom(ctx).Error(fmt.Errorf("unexpected result type: %v", result.Type()), "Result Type Error", "Instance", pi.address)
 				logP8sMetrics(p8sQueryStartTime, namespace, BreachDataPointsQuery, pi.address, workload, -1, 1)
-				resultChan <- nil
+				fanOutReportFrom(ctx).recordUnanswered(pi.address)
+				resultChan <- instanceDataPoints{address: pi.address}
 				return
 			}
 			matrix := result.(model.Matrix)
 			if len(matrix) != 1 {
 				// if no datapoints are returned which satisfy the query it can be considered that there's no breach to redLineUtilization
-				ps.logger.V(2).Info("no Breach dataPoints found with the p8s instance", "Instance", pi.address)
+				ps.loggerFrom(ctx).V(2).Info("no Breach dataPoints found with the p8s instance", "Instance", pi.address)
 				logP8sMetrics(p8sQueryStartTime, namespace, BreachDataPointsQuery, pi.address, workload, 0, 1)
-				resultChan <- nil
+				resultChan <- instanceDataPoints{address: pi.address}
 				return
 			}
-			var dataPoints []DataPoint
-			for _, sample := range matrix[0].Values {
-				datapoint := DataPoint{sample.Timestamp.Time(), float64(sample.Value)}
-				if !sample.Timestamp.Time().IsZero() {
-					dataPoints = append(dataPoints, datapoint)
-				}
-			}
+			dataPoints := alignToStep(matrix[0].Values, start, step)
 			logP8sMetrics(p8sQueryStartTime, namespace, BreachDataPointsQuery, pi.address, workload, len(dataPoints), 1)
-			sort.SliceStable(dataPoints, func(i, j int) bool {
-				return dataPoints[i].Timestamp.Before(dataPoints[j].Timestamp)
-			})
 
-			resultChan <- dataPoints
+			resultChan <- instanceDataPoints{address: pi.address, dataPoints: dataPoints}
 		}(pi)
 	}
 
 	wg.Wait()
 	close(resultChan)
 
+	dataPointsByInstance := make(map[string][]DataPoint)
 	for p8sQueryResult := range resultChan {
-		totalDataPoints = aggregateMetrics(totalDataPoints, p8sQueryResult)
+		if p8sQueryResult.dataPoints != nil {
+			dataPointsByInstance[p8sQueryResult.address] = p8sQueryResult.dataPoints
+		}
 	}
+	totalDataPoints := mergeInstances(ps.mergeStrategy, ps.instanceAddresses(), dataPointsByInstance)
 
 	totalDataPointsFetched.WithLabelValues(namespace, BreachDataPointsQuery, workload).Set(float64(len(totalDataPoints)))
 	if totalDataPoints == nil {
 		// if no datapoints are returned which satisfy the query it can be considered that there's no breach to redLineUtilization
-		ps.logger.Info("no Breach dataPoints found in any of the p8s instance", "Namespace", namespace, "Workload", workload)
+		ps.loggerFrom(ctx).Info("no Breach dataPoints found in any of the p8s instance", "Namespace", namespace, "Workload", workload)
 		return nil, nil
 	}
-	ps.logger.Info("Breach dataPoints found..", "Namespace", namespace, "Workload", workload)
+	ps.loggerFrom(ctx).Info("Breach dataPoints found..", "Namespace", namespace, "Workload", workload)
 	return totalDataPoints, nil
 }
 
@@ -386,10 +650,13 @@ func (ps *PrometheusScraper) GetCPUUtilizationBreachDataPoints(namespace,
 // avoid loading too many samples into P8s memory.
 type RangeQuerySplitter struct {
 	splitInterval time.Duration
+	limiter       *QueryLimiter
 }
 
-func NewRangeQuerySplitter(splitInterval time.Duration) *RangeQuerySplitter {
-	return &RangeQuerySplitter{splitInterval: splitInterval}
+// NewRangeQuerySplitter returns a RangeQuerySplitter executing the split queries through the limiter, which may be nil
+// to execute them without limits.
+func NewRangeQuerySplitter(splitInterval time.Duration, limiter *QueryLimiter) *RangeQuerySplitter {
+	return &RangeQuerySplitter{splitInterval: splitInterval, limiter: limiter}
 }
 func (rqs *RangeQuerySplitter) QueryRangeByInterval(ctx context.Context,
 	pi PrometheusInstance,
@@ -418,12 +685,20 @@ func (rqs *RangeQuerySplitter) QueryRangeByInterval(ctx context.Context,
 		wg.Add(1)
 		go func(splitRange v1.Range) {
 			defer wg.Done()
-			defer p8sConcurrentQueries.WithLabelValues(getQueryType(query), pi.address).Sub(1)
 
-			p8sConcurrentQueries.WithLabelValues(getQueryType(query), pi.address).Add(1)
-			partialResult, _, err := api.QueryRange(ctx, query, splitRange)
+			var partialResult model.Value
+			err := rqs.limiter.Do(ctx, pi.address, getQueryType(query), func(ctx context.Context) error {
+				defer p8sConcurrentQueries.WithLabelValues(getQueryType(query), pi.address).Sub(1)
+
+				p8sConcurrentQueries.WithLabelValues(getQueryType(query), pi.address).Add(1)
+				var err error
+				partialResult, _, err = api.QueryRange(ctx, query, splitRange)
+				if err != nil {
+					p8sQueryErrorCount.WithLabelValues(getQueryType(query), pi.address).Inc()
+				}
+				return err
+			})
 			if err != nil {
-				p8sQueryErrorCount.WithLabelValues(getQueryType(query), pi.address).Inc()
 				resultChan <- PrometheusQueryResult{nil, fmt.Errorf("failed to execute Prometheus query: %v", err)}
 				return
 			}
@@ -455,6 +730,8 @@ func (rqs *RangeQuerySplitter) QueryRangeByInterval(ctx context.Context,
 	return resultMatrix, nil
 }
 
+// mergeMatrices merges the series of the same labels of the matrices of two split ranges, matching them by their
+// fingerprints as the series aren't returned in the same order by every query. The series are sorted by their labels.
 func mergeMatrices(matrixA, matrixB model.Matrix) model.Matrix {
 	if len(matrixA) == 0 {
 		return matrixB
@@ -464,45 +741,65 @@ func mergeMatrices(matrixA, matrixB model.Matrix) model.Matrix {
 		return matrixA
 	}
 
-	resultMatrix := make(model.Matrix, len(matrixA))
-
-	for i, seriesA := range matrixA {
-		seriesB := matrixB[i]
-		mergedSeries := model.SampleStream{
-			Metric: seriesA.Metric,
-			Values: append(seriesA.Values, seriesB.Values...),
+	series := make(map[model.Fingerprint]*model.SampleStream, len(matrixA))
+	for _, matrix := range []model.Matrix{matrixA, matrixB} {
+		for _, stream := range matrix {
+			fingerprint := stream.Metric.Fingerprint()
+			if merged, ok := series[fingerprint]; ok {
+				series[fingerprint] = &model.SampleStream{
+					Metric: merged.Metric,
+					Values: mergeSamples(merged.Values, stream.Values),
+				}
+				continue
+			}
+			series[fingerprint] = stream
 		}
-		resultMatrix[i] = &mergedSeries
 	}
 
+	resultMatrix := make(model.Matrix, 0, len(series))
+	for _, stream := range series {
+		resultMatrix = append(resultMatrix, stream)
+	}
+	sort.Sort(resultMatrix)
 	return resultMatrix
 }
-func (ps *PrometheusScraper) getPodReadyLatencyByWorkload(namespace string, workload string) (float64, error) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), ps.queryTimeout)
+func (ps *PrometheusScraper) getPodReadyLatencyByWorkload(ctx context.Context, namespace string, workloadType string,
+	workload string) (float64, error) {
+
+	ctx, cancel := ps.withQueryTimeout(ctx)
 	defer cancel()
 
-	query := ps.PodReadyLatencyQuery.Render(map[string]string{"namespace": namespace, "workload": workload, "workload_type": "deployment"})
+	query := ps.PodReadyLatencyQuery.Render(workloadLabels(namespace, workloadType, workload))
 
 	podBootstrapTime := 0.0
 	if ps.api == nil {
 		return 0.0, fmt.Errorf("no apiurl for executing prometheus query")
 	}
 	for _, pi := range ps.api {
-		result, _, err := pi.apiUrl.Query(ctx, query, time.Now())
+		if !ps.queryLimiter.Allow(pi.address) {
+			ps.skipInstance(ctx, namespace, PodReadyLatencyQueryName, pi.address, workload)
+			continue
+		}
+		var result model.Value
+		err := ps.queryLimiter.Do(ctx, pi.address, PodReadyLatencyQueryName, func(ctx context.Context) error {
+			var err error
+			result, _, err = pi.apiUrl.Query(ctx, query, time.Now())
+			return err
+		})
 
 		if err != nil {
-			ps.logger.Error(err, "failed to execute Prometheus query", "Instance", pi.address)
+			ps.loggerFrom(ctx).Error(err, "failed to execute Prometheus query", "Instance", pi.address)
 			continue
 		}
 		if result.Type() != model.ValVector {
-			ps.logger.Error(fmt.Errorf("unexpected result type: %v", result.Type()), "Result Type Error", "Instance", pi.address)
+			ps.loggerFrom(ctx).Error(fmt.Errorf("unexpected result type: %v", result.Type()), "Result Type Error", "Instance", pi.address)
 			continue
 		}
 
 		matrix := result.(model.Vector)
 		if len(matrix) != 1 {
-			ps.logger.Error(fmt.Errorf("unexpected no of time series: %v", len(matrix)), "Zero Datapoints Error", "Instance", pi.address)
+			ps.loggerFrom(ctx).Error(fmt.Errorf("unexpected no of time series: %v", len(matrix)), "Zero Datapoints Error", "Instance", pi.address)
 			continue
 		}
 
@@ -514,6 +811,67 @@ func (ps *PrometheusScraper) getPodReadyLatencyByWorkload(namespace string, work
 	return podBootstrapTime, nil
 }
 
+// GetContainerCPUUsageQuantile returns the quantile of the CPU usage of every container of the workload over the
+// window. The instances are queried for the same window and the highest quantile of every container is returned.
+func (ps *PrometheusScraper) GetContainerCPUUsageQuantile(ctx context.Context,
+	namespace string,
+	workloadType string,
+	workload string,
+	quantile float64,
+	window time.Duration,
+	end time.Time) (map[string]float64, error) {
+
+	ctx, cancel := ps.withQueryTimeout(ctx)
+	defer cancel()
+
+	query := ps.ContainerCPUUsageQuery.Render(quantile, model.Duration(window).String(),
+		workloadLabels(namespace, workloadType, workload))
+
+	if ps.api == nil {
+		return nil, fmt.Errorf("no apiurl for executing prometheus query")
+	}
+	usage := make(map[string]float64)
+	for _, pi := range ps.api {
+		if !ps.queryLimiter.Allow(pi.address) {
+			ps.skipInstance(ctx, namespace, ContainerCPUUsageQuantileQuery, pi.address, workload)
+			continue
+		}
+		p8sQueryStartTime := time.Now()
+		var result model.Value
+		err := ps.queryLimiter.Do(ctx, pi.address, ContainerCPUUsageQuantileQuery, func(ctx context.Context) error {
+			var err error
+			result, _, err = pi.apiUrl.Query(ctx, query, end)
+			return err
+		})
+		if err != nil {
+			ps.loggerFrom(ctx).Error(err, "failed to execute Prometheus query", "Instance", pi.address)
+			p8sQueryErrorCount.WithLabelValues(getQueryType(query), pi.address).Inc()
+			logP8sMetrics(p8sQueryStartTime, namespace, ContainerCPUUsageQuantileQuery, pi.address, workload, -1, 0)
+			continue
+		}
+		if result.Type() != model.ValVector {
+			ps.loggerFrom(ctx).Error(fmt.Errorf("unexpected result type: %v", result.Type()), "Result Type Error", "Instance", pi.address)
+			logP8sMetrics(p8sQueryStartTime, namespace, ContainerCPUUsageQuantileQuery, pi.address, workload, -1, 1)
+			continue
+		}
+		p8sQuerySuccessCount.WithLabelValues(getQueryType(query), pi.address).Inc()
+
+		vector := result.(model.Vector)
+		for _, sample := range vector {
+			container := string(sample.Metric["container"])
+			if container == "" {
+				continue
+			}
+			usage[container] = math.Max(usage[container], float64(sample.Value))
+		}
+		logP8sMetrics(p8sQueryStartTime, namespace, ContainerCPUUsageQuantileQuery, pi.address, workload, len(vector), 1)
+	}
+	if len(usage) == 0 {
+		return nil, fmt.Errorf("unable to get the container CPU usage from any of the prometheus instances")
+	}
+	return usage, nil
+}
+
 func (ps *PrometheusScraper) interpolateMissingDataPoints(dataPoints []DataPoint, step time.Duration) []DataPoint {
 	var interpolatedData []DataPoint
 	prevTimestamp := dataPoints[0].Timestamp
@@ -553,8 +911,14 @@ func logP8sMetrics(p8sQueryStartTime time.Time, namespace string, query string,
 }
 
 func getQueryType(query string) string {
+	if strings.Contains(query, "kube_horizontalpodautoscaler") && !strings.Contains(query, "kube_pod_container_resource_limits") {
+		return ReplicasAtMaxDataPointsQuery
+	}
 	if strings.Contains(query, "kube_horizontalpodautoscaler") {
 		return BreachDataPointsQuery
 	}
+	if strings.Contains(query, "quantile_over_time") {
+		return ContainerCPUUsageQuantileQuery
+	}
 	return CPUUtilizationDataPointsQuery
 }
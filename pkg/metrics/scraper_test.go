@@ -6,6 +6,7 @@ import (
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
 	"math"
+	"sync"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -71,8 +72,8 @@ var _ = Describe("PrometheusScraper", func() {
 			//wait for the metric to be scraped - scraping interval is 1s
 			time.Sleep(5 * time.Second)
 
-			dataPoints, err := scraper.GetAverageCPUUtilizationByWorkload("test-ns-1",
-				"test-workload-1", start, end, time.Second)
+			dataPoints, err := scraper.GetAverageCPUUtilizationByWorkload(context.TODO(), "test-ns-1",
+				"Deployment", "test-workload-1", start, end, time.Second)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(dataPoints).ToNot(BeEmpty())
 
@@ -122,11 +123,11 @@ var _ = Describe("PrometheusScraper", func() {
 			//wait for the metric to be scraped - scraping interval is 1s
 			time.Sleep(2 * time.Second)
 
-			autoscalingLag1, err := scraper.GetACLByWorkload("test-ns-1", "test-workload-1")
+			autoscalingLag1, err := scraper.GetACLByWorkload(context.TODO(), "test-ns-1", "Deployment", "test-workload-1")
 			Expect(err).NotTo(HaveOccurred())
 			Expect(autoscalingLag1).To(Equal(45.0 * time.Second))
 
-			autoscalingLag2, err := scraper.GetACLByWorkload("test-ns-2", "test-workload-3")
+			autoscalingLag2, err := scraper.GetACLByWorkload(context.TODO(), "test-ns-2", "Deployment", "test-workload-3")
 			Expect(err).NotTo(HaveOccurred())
 			Expect(autoscalingLag2).To(Equal(65.0 * time.Second))
 		})
@@ -258,7 +259,7 @@ var _ = Describe("PrometheusScraper", func() {
 			//wait for the metric to be scraped - scraping interval is 1s
 			time.Sleep(2 * time.Second)
 
-			dataPoints, err := scraper.GetCPUUtilizationBreachDataPoints("dep-test-ns-1",
+			dataPoints, err := scraper.GetCPUUtilizationBreachDataPoints(context.TODO(), "dep-test-ns-1",
 				"deployment",
 				"dep-1",
 				0.85, start,
@@ -281,10 +282,10 @@ var _ = Describe("PrometheusScraper", func() {
 			cpuUsageMetric.WithLabelValues("ro-test-ns-1", "ro-test-pod-3", "ro-test-node-2", "ro-test-container-1").Set(5)
 			cpuUsageMetric.WithLabelValues("ro-test-ns-2", "ro-test-pod-4", "ro-test-node-4", "ro-test-container-1").Set(3)
 
-			kubePodOwnerMetric.WithLabelValues("ro-test-ns-1", "ro-test-pod-1", "ro-1", "deployment").Set(1)
-			kubePodOwnerMetric.WithLabelValues("ro-test-ns-1", "ro-test-pod-2", "ro-1", "deployment").Set(1)
-			kubePodOwnerMetric.WithLabelValues("ro-test-ns-1", "ro-test-pod-3", "ro-2", "deployment").Set(1)
-			kubePodOwnerMetric.WithLabelValues("ro-test-ns-2", "ro-test-pod-4", "ro-3", "deployment").Set(1)
+			kubePodOwnerRawMetric.WithLabelValues("ro-test-ns-1", "ro-test-pod-1", "ReplicaSet", "ro-rs-1").Set(1)
+			kubePodOwnerRawMetric.WithLabelValues("ro-test-ns-1", "ro-test-pod-2", "ReplicaSet", "ro-rs-2").Set(1)
+			kubePodOwnerRawMetric.WithLabelValues("ro-test-ns-1", "ro-test-pod-3", "ReplicaSet", "ro-rs-3").Set(1)
+			kubePodOwnerRawMetric.WithLabelValues("ro-test-ns-2", "ro-test-pod-4", "ReplicaSet", "ro-rs-4").Set(1)
 
 			resourceLimitMetric.WithLabelValues("ro-test-ns-1", "ro-test-pod-1", "ro-test-node-1", "ro-test-container-1").Set(5)
 			resourceLimitMetric.WithLabelValues("ro-test-ns-1", "ro-test-pod-2", "ro-test-node-2", "ro-test-container-1").Set(5)
@@ -320,10 +321,10 @@ var _ = Describe("PrometheusScraper", func() {
 			cpuUsageMetric.WithLabelValues("ro-test-ns-1", "ro-test-pod-3", "ro-test-node-2", "ro-test-container-1").Set(5)
 			cpuUsageMetric.WithLabelValues("ro-test-ns-2", "ro-test-pod-4", "ro-test-node-4", "ro-test-container-1").Set(3)
 
-			kubePodOwnerMetric.WithLabelValues("ro-test-ns-1", "ro-test-pod-1", "ro-1", "deployment").Set(1)
-			kubePodOwnerMetric.WithLabelValues("ro-test-ns-1", "ro-test-pod-2", "ro-1", "deployment").Set(1)
-			kubePodOwnerMetric.WithLabelValues("ro-test-ns-1", "ro-test-pod-3", "ro-2", "deployment").Set(1)
-			kubePodOwnerMetric.WithLabelValues("ro-test-ns-2", "ro-test-pod-4", "ro-3", "deployment").Set(1)
+			kubePodOwnerRawMetric.WithLabelValues("ro-test-ns-1", "ro-test-pod-1", "ReplicaSet", "ro-rs-1").Set(1)
+			kubePodOwnerRawMetric.WithLabelValues("ro-test-ns-1", "ro-test-pod-2", "ReplicaSet", "ro-rs-2").Set(1)
+			kubePodOwnerRawMetric.WithLabelValues("ro-test-ns-1", "ro-test-pod-3", "ReplicaSet", "ro-rs-3").Set(1)
+			kubePodOwnerRawMetric.WithLabelValues("ro-test-ns-2", "ro-test-pod-4", "ReplicaSet", "ro-rs-4").Set(1)
 
 			resourceLimitMetric.WithLabelValues("ro-test-ns-1", "ro-test-pod-1", "ro-test-node-1", "ro-test-container-1").Set(5)
 			resourceLimitMetric.WithLabelValues("ro-test-ns-1", "ro-test-pod-2", "ro-test-node-2", "ro-test-container-1").Set(5)
@@ -400,7 +401,7 @@ var _ = Describe("PrometheusScraper", func() {
 			//wait for the metric to be scraped - scraping interval is 1s
 			time.Sleep(2 * time.Second)
 
-			dataPoints, err := scraper.GetCPUUtilizationBreachDataPoints("ro-test-ns-1",
+			dataPoints, err := scraper.GetCPUUtilizationBreachDataPoints(context.TODO(), "ro-test-ns-1",
 				"Rollout",
 				"ro-1",
 				0.85, start,
@@ -489,8 +490,8 @@ var _ = Describe("PrometheusScraper", func() {
 			//wait for the metric to be scraped - scraping interval is 1s
 			time.Sleep(5 * time.Second)
 
-			dataPoints, err := scraper.GetAverageCPUUtilizationByWorkload("test-nsp-1",
-				"test-workload-1", start, end, time.Second)
+			dataPoints, err := scraper.GetAverageCPUUtilizationByWorkload(context.TODO(), "test-nsp-1",
+				"Deployment", "test-workload-1", start, end, time.Second)
 			fmt.Println(dataPoints)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(dataPoints).ToNot(BeEmpty())
@@ -578,7 +579,7 @@ var _ = Describe("RangeQuerySplitter", func() {
 			},
 		}
 
-		splitter := NewRangeQuerySplitter(splitDuration)
+		splitter := NewRangeQuerySplitter(splitDuration, nil)
 		pi := PrometheusInstance{apiUrl: mockApi, address: ""}
 		result, err := splitter.QueryRangeByInterval(context.TODO(), pi, query, start, end, step)
 		Expect(err).NotTo(HaveOccurred())
@@ -590,6 +591,108 @@ var _ = Describe("RangeQuerySplitter", func() {
 	})
 })
 
+var _ = Describe("PrometheusScraper with a context", func() {
+	var (
+		queryCtx context.Context
+		mockApi  *mockAPI
+		ps       *PrometheusScraper
+	)
+
+	BeforeEach(func() {
+		queryCtx = nil
+		mockApi = &mockAPI{}
+		ps = &PrometheusScraper{api: []PrometheusInstance{{apiUrl: mockApi, address: "p8s-1"}},
+			queryTimeout:        time.Minute,
+			rangeQuerySplitter:  NewRangeQuerySplitter(time.Hour, nil),
+			CPUUtilizationQuery: (*CPUUtilizationQuery)(NewPrometheusCompositeQueries()),
+		}
+	})
+
+	It("should cancel the queries along with the context", func() {
+		mockApi.queryRangeFunc = func(ctx context.Context, query string, r v1.Range, options ...v1.Option) (model.Value,
+			v1.Warnings, error) {
+			<-ctx.Done()
+			return nil, nil, ctx.Err()
+		}
+		ctx, cancel := context.WithCancel(context.TODO())
+		time.AfterFunc(100*time.Millisecond, cancel)
+
+		startTime := time.Now()
+		_, err := ps.GetAverageCPUUtilizationByWorkload(ctx, "test-ns-1", "Deployment", "test-workload-1",
+			startTime.Add(-time.Hour), startTime, time.Minute)
+		Expect(err).To(HaveOccurred())
+		Expect(time.Since(startTime)).To(BeNumerically("<", ps.queryTimeout))
+	})
+
+	It("should query with the earlier of the deadlines and the trace of the context", func() {
+		mockApi.queryRangeFunc = func(ctx context.Context, query string, r v1.Range, options ...v1.Option) (model.Value,
+			v1.Warnings, error) {
+			queryCtx = ctx
+			return model.Matrix{}, nil, nil
+		}
+		ctx, cancel := context.WithTimeout(ContextWithTrace(context.TODO()), time.Hour)
+		defer cancel()
+		_, _ = ps.GetAverageCPUUtilizationByWorkload(ctx, "test-ns-1", "Deployment", "test-workload-1",
+			time.Now().Add(-time.Hour), time.Now(), time.Minute)
+		deadline, ok := queryCtx.Deadline()
+		Expect(ok).To(BeTrue())
+		Expect(deadline).To(BeTemporally("~", time.Now().Add(ps.queryTimeout), 5*time.Second))
+		Expect(TraceIDFromContext(queryCtx)).To(Equal(TraceIDFromContext(ctx)))
+
+		ctx, cancel = context.WithTimeout(context.TODO(), 10*time.Second)
+		defer cancel()
+		_, _ = ps.GetAverageCPUUtilizationByWorkload(ctx, "test-ns-1", "Deployment", "test-workload-1",
+			time.Now().Add(-time.Hour), time.Now(), time.Minute)
+		deadline, _ = queryCtx.Deadline()
+		ctxDeadline, _ := ctx.Deadline()
+		Expect(deadline).To(BeTemporally("==", ctxDeadline))
+	})
+})
+
+var _ = Describe("PrometheusScraper with a QueryLimiter", func() {
+	It("should take the instances of an open circuit breaker out of the fan-out", func() {
+		queries := map[string]int{}
+		var mutex sync.Mutex
+		newInstance := func(address string, err error) PrometheusInstance {
+			return PrometheusInstance{address: address, apiUrl: &mockAPI{
+				queryRangeFunc: func(ctx context.Context, query string, r v1.Range, options ...v1.Option) (model.Value,
+					v1.Warnings, error) {
+					mutex.Lock()
+					queries[address]++
+					mutex.Unlock()
+					if err != nil {
+						return nil, nil, err
+					}
+					return model.Matrix{&model.SampleStream{Values: []model.SamplePair{
+						{Timestamp: model.TimeFromUnix(r.Start.Unix()), Value: 1}}}}, nil, nil
+				},
+			}}
+		}
+		limiter := NewQueryLimiter(0, 0, 1, 0, 1, time.Hour)
+		limiter.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+		ps := &PrometheusScraper{
+			api: []PrometheusInstance{newInstance("p8s-1", &v1.Error{Type: v1.ErrServer}),
+				newInstance("p8s-2", nil)},
+			queryTimeout:        time.Minute,
+			rangeQuerySplitter:  NewRangeQuerySplitter(time.Hour, limiter),
+			CPUUtilizationQuery: (*CPUUtilizationQuery)(NewPrometheusCompositeQueries()),
+			queryLimiter:        limiter,
+		}
+
+		for i := 0; i < 2; i++ {
+			ctx, report := withFanOutReport(context.TODO())
+			dataPoints, err := ps.GetAverageCPUUtilizationByWorkload(ctx, "test-ns-1", "Deployment",
+				"test-workload-1", time.Now().Add(-30*time.Minute), time.Now(), time.Minute)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dataPoints).ToNot(BeEmpty())
+			Expect(report.complete()).To(BeFalse())
+		}
+		Expect(queries["p8s-1"]).To(Equal(2))
+		Expect(queries["p8s-2"]).To(Equal(2))
+		Expect(limiter.State("p8s-1")).To(Equal(CircuitOpen))
+	})
+})
+
 var _ = Describe("interpolateMissingDataPoints", func() {
 	It("should interpolate the missing data", func() {
 		dataPoints := []DataPoint{
@@ -49,7 +49,8 @@ var (
 
 	cpuUsageMetric *prometheus.GaugeVec
 
-	kubePodOwnerMetric *prometheus.GaugeVec
+	kubePodOwnerMetric    *prometheus.GaugeVec
+	kubePodOwnerRawMetric *prometheus.GaugeVec
 
 	resourceLimitMetric   *prometheus.GaugeVec
 	readyReplicasMetric   *prometheus.GaugeVec
@@ -108,12 +109,13 @@ var _ = BeforeSuite(func() {
 
 	scraper = &PrometheusScraper{api: v1Api,
 		queryTimeout:              30 * time.Second,
-		rangeQuerySplitter:        NewRangeQuerySplitter(1 * time.Second),
+		rangeQuerySplitter:        NewRangeQuerySplitter(1*time.Second, nil),
 		metricIngestionTime:       metricIngestionTime,
 		metricProbeTime:           metricProbeTime,
 		CPUUtilizationQuery:       (*CPUUtilizationQuery)(compositeQuery),
 		CPUUtilizationBreachQuery: (*CPUUtilizationBreachQuery)(compositeQuery),
 		PodReadyLatencyQuery:      (*PodReadyLatencyQuery)(compositeQuery),
+		ContainerCPUUsageQuery:    (*ContainerCPUUsageQuery)(compositeQuery),
 	}
 
 	go func() {
@@ -159,6 +161,11 @@ func registerMetrics() {
 		Help: "Test metric for Kubernetes pod owner",
 	}, []string{"namespace", "pod", "workload", "workload_type"})
 
+	kubePodOwnerRawMetric = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kube_pod_owner",
+		Help: "Test metric for the owner of a Kubernetes pod",
+	}, []string{"namespace", "pod", "owner_kind", "owner_name"})
+
 	resourceLimitMetric = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "cluster:namespace:pod_cpu:active:kube_pod_container_resource_limits",
 		Help: "Test metric for container resource limits",
@@ -196,6 +203,7 @@ func registerMetrics() {
 
 	registry.MustRegister(cpuUsageMetric)
 	registry.MustRegister(kubePodOwnerMetric)
+	registry.MustRegister(kubePodOwnerRawMetric)
 	registry.MustRegister(resourceLimitMetric)
 	registry.MustRegister(readyReplicasMetric)
 	registry.MustRegister(replicaSetOwnerMetric)
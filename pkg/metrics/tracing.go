@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+)
+
+// traceParentHeader is the W3C Trace Context header propagating the trace of a query to Prometheus and to the proxies
+// in front of it.
+const traceParentHeader = "traceparent"
+
+type traceIDKey struct{}
+
+// ContextWithTrace returns ctx carrying a new trace ID, or ctx itself if it already carries one. The queries executed
+// with the returned context are sent with the trace ID, and the scraper logs it along with their errors, so that the
+// queries of a recommendation or a breach check can be correlated across the replicas and Prometheus.
+func ContextWithTrace(ctx context.Context) context.Context {
+	if TraceIDFromContext(ctx) != "" {
+		return ctx
+	}
+	return context.WithValue(ctx, traceIDKey{}, randomHex(16))
+}
+
+// TraceIDFromContext returns the trace ID carried by ctx, or an empty string if it carries none.
+func TraceIDFromContext(ctx context.Context) string {
+	traceID, _ := ctx.Value(traceIDKey{}).(string)
+	return traceID
+}
+
+// tracingRoundTripper sets the traceparent header of the requests whose context carries a trace ID, every request
+// being a span of its own.
+type tracingRoundTripper struct {
+	next http.RoundTripper
+}
+
+func (rt *tracingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	traceID := TraceIDFromContext(req.Context())
+	if traceID == "" {
+		return rt.next.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set(traceParentHeader, fmt.Sprintf("00-%s-%s-01", traceID, randomHex(8)))
+	return rt.next.RoundTrip(req)
+}
+
+// randomHex returns n random bytes hex encoded.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	// crypto/rand doesn't fail on the supported platforms.
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// roundTripperFunc records the requests sent through it.
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+var _ = Describe("Tracing", func() {
+
+	It("should keep the trace of a context which carries one", func() {
+		Expect(TraceIDFromContext(context.TODO())).To(BeEmpty())
+
+		ctx := ContextWithTrace(context.TODO())
+		Expect(TraceIDFromContext(ctx)).To(MatchRegexp("^[0-9a-f]{32}$"))
+		Expect(TraceIDFromContext(ContextWithTrace(ctx))).To(Equal(TraceIDFromContext(ctx)))
+		Expect(TraceIDFromContext(ContextWithTrace(context.TODO()))).ToNot(Equal(TraceIDFromContext(ctx)))
+	})
+
+	It("should send the trace of the context as the traceparent of the requests", func() {
+		var sent []*http.Request
+		rt := &tracingRoundTripper{next: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			sent = append(sent, req)
+			return &http.Response{StatusCode: http.StatusOK}, nil
+		})}
+
+		ctx := ContextWithTrace(context.TODO())
+		for _, reqCtx := range []context.Context{ctx, ctx, context.TODO()} {
+			req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, "http://p8s-1/api/v1/query_range", nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = rt.RoundTrip(req)
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		traceParent := "^00-" + TraceIDFromContext(ctx) + "-[0-9a-f]{16}-01$"
+		Expect(sent).To(HaveLen(3))
+		Expect(sent[0].Header.Get(traceParentHeader)).To(MatchRegexp(traceParent))
+		Expect(sent[1].Header.Get(traceParentHeader)).To(MatchRegexp(traceParent))
+		Expect(sent[0].Header.Get(traceParentHeader)).ToNot(Equal(sent[1].Header.Get(traceParentHeader)))
+		Expect(sent[2].Header.Get(traceParentHeader)).To(BeEmpty())
+	})
+})
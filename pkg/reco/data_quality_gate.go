@@ -0,0 +1,145 @@
+package reco
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
+	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+	"github.com/flipkart-incubator/ottoscalr/pkg/policy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	p8smetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	DataQualityStatusManager = "DataQualityStatusManager"
+
+	defaultDataQualityMinScore = 80
+
+	// maxDataQualityIssues caps the issues published to the status, keeping the latest ones.
+	maxDataQualityIssues = 20
+)
+
+var dataQualityScoreGauge = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "reco_data_quality_score",
+		Help: "Percentage of the metric window of the workload free of data quality issues",
+	}, []string{"namespace", "policyreco", "workloadKind", "workload"},
+)
+
+func init() {
+	p8smetrics.Registry.MustRegister(dataQualityScoreGauge)
+}
+
+// DataQualityGate is a PolicyIterator assessing the quality of the utilization of the metric window of a workload
+// with a DataQualityChecker. While the score is below minScore, it holds the workload at its current policy, or the
+// safest one if it has none, so that the workflow doesn't make its HPA configuration riskier on data which can't be
+// trusted. It's a no-op otherwise.
+type DataQualityGate struct {
+	client       client.Client
+	store        policy.Store
+	scraper      metrics.InstanceScraper
+	checker      *metrics.DataQualityChecker
+	metricWindow time.Duration
+	metricStep   time.Duration
+	minScore     int
+}
+
+// NewDataQualityGate returns a DataQualityGate which publishes the quality to the status of the policy
+// recommendations. A zero minScore picks the default.
+func NewDataQualityGate(k8sClient client.Client,
+	scraper metrics.InstanceScraper,
+	checker *metrics.DataQualityChecker,
+	metricWindow time.Duration,
+	metricStep time.Duration,
+	minScore int) *DataQualityGate {
+	if minScore <= 0 {
+		minScore = defaultDataQualityMinScore
+	}
+	return &DataQualityGate{
+		client:       k8sClient,
+		store:        policy.NewPolicyStore(k8sClient),
+		scraper:      scraper,
+		checker:      checker,
+		metricWindow: metricWindow,
+		metricStep:   metricStep,
+		minScore:     minScore,
+	}
+}
+
+func (pi *DataQualityGate) NextPolicy(ctx context.Context, wm WorkloadMeta) (*Policy, error) {
+	logger := log.FromContext(ctx)
+	end := time.Now()
+	start := end.Add(-pi.metricWindow)
+	dataPointsByInstance, err := pi.scraper.GetAverageCPUUtilizationByInstance(ctx, wm.Namespace, wm.Kind, wm.Name, start,
+		end, pi.metricStep)
+	if err != nil {
+		logger.V(0).Error(err, "Error while fetching the utilization to assess its quality")
+		return nil, err
+	}
+	report := pi.checker.Assess(dataPointsByInstance, start, end, pi.metricStep)
+	score := int(math.Floor(report.Score * 100))
+	dataQualityScoreGauge.WithLabelValues(wm.Namespace, wm.Name, wm.Kind, wm.Name).Set(float64(score))
+	if err := pi.publishDataQuality(ctx, wm, score, report.Issues); err != nil {
+		logger.V(0).Error(err, "Error while publishing the data quality", "workload", wm.Name)
+	}
+
+	if score >= pi.minScore {
+		return nil, nil
+	}
+	logger.V(0).Info("Data quality below the threshold. Holding the current policy.", "score", score,
+		"minScore", pi.minScore, "issues", len(report.Issues))
+	return pi.currentPolicy(ctx, wm)
+}
+
+// currentPolicy returns the policy currently applied to the workload, or the safest policy if there's none.
+func (pi *DataQualityGate) currentPolicy(ctx context.Context, wm WorkloadMeta) (*Policy, error) {
+	policyreco := &v1alpha1.PolicyRecommendation{}
+	if err := pi.client.Get(ctx, types.NamespacedName{Name: wm.Name, Namespace: wm.Namespace},
+		policyreco); client.IgnoreNotFound(err) != nil {
+		return nil, err
+	}
+	if len(policyreco.Spec.Policy) > 0 {
+		currentPolicy, err := pi.store.GetPolicyByName(policyreco.Spec.Policy)
+		if err == nil {
+			return PolicyFromCR(currentPolicy), nil
+		}
+		if !errors.Is(err, policy.NoPolicyFoundErr) {
+			return nil, err
+		}
+	}
+	safestPolicy, err := pi.store.GetSafestPolicy()
+	if err != nil {
+		return nil, err
+	}
+	return PolicyFromCR(safestPolicy), nil
+}
+
+// publishDataQuality applies the score and the latest issues to the status of the workload's policy recommendation.
+func (pi *DataQualityGate) publishDataQuality(ctx context.Context, wm WorkloadMeta, score int,
+	issues []metrics.QualityIssue) error {
+	if len(issues) > maxDataQualityIssues {
+		issues = issues[len(issues)-maxDataQualityIssues:]
+	}
+	dataQuality := &v1alpha1.DataQuality{Score: score, AssessedAt: metav1.Now()}
+	for _, issue := range issues {
+		dataQuality.Issues = append(dataQuality.Issues, v1alpha1.DataQualityIssue{
+			Type:  string(issue.Type),
+			Start: metav1.NewTime(issue.StartTime.Truncate(time.Second)),
+			End:   metav1.NewTime(issue.EndTime.Truncate(time.Second)),
+		})
+	}
+	return applyPolicyRecoStatus(ctx, pi.client, wm, v1alpha1.PolicyRecommendationStatus{DataQuality: dataQuality},
+		DataQualityStatusManager)
+}
+
+func (pi *DataQualityGate) GetName() string {
+	return "DataQuality"
+}
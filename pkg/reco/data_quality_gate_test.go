@@ -0,0 +1,74 @@
+package reco
+
+import (
+	"context"
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
+	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeInstanceScraper returns the utilization of a single instance, sampled every minute over the range but for the
+// leading fraction left out.
+type fakeInstanceScraper struct {
+	missing float64
+}
+
+func (fs *fakeInstanceScraper) GetAverageCPUUtilizationByInstance(ctx context.Context, namespace, workloadType,
+	workload string,
+	start time.Time, end time.Time, step time.Duration) (map[string][]metrics.DataPoint, error) {
+	var dataPoints []metrics.DataPoint
+	for t := start.Add(time.Duration(fs.missing * float64(end.Sub(start)))); t.Before(end); t = t.Add(step) {
+		dataPoints = append(dataPoints, metrics.DataPoint{Timestamp: t, Value: float64(10 + t.Minute()%7)})
+	}
+	return map[string][]metrics.DataPoint{"p8s-1": dataPoints}, nil
+}
+
+var _ = Describe("DataQualityGate", func() {
+
+	workloadMeta := WorkloadMeta{Name: "workload", Namespace: "default"}
+
+	newPolicy := func(name string, riskIndex int) *v1alpha1.Policy {
+		return &v1alpha1.Policy{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       v1alpha1.PolicySpec{RiskIndex: riskIndex, MinReplicaPercentageCut: 100, TargetUtilization: 10 * riskIndex},
+		}
+	}
+
+	newGate := func(missing float64, objects ...client.Object) *DataQualityGate {
+		testScheme := runtime.NewScheme()
+		Expect(v1alpha1.AddToScheme(testScheme)).To(Succeed())
+		objects = append(objects, newPolicy("safest", 1), newPolicy("riskier", 2), newPolicy("riskiest", 3))
+		k8sClient := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objects...).Build()
+		return NewDataQualityGate(k8sClient, &fakeInstanceScraper{missing: missing},
+			metrics.NewDataQualityChecker(0, 0, 0, 0), 24*time.Hour, time.Minute, 0)
+	}
+
+	It("should be a no-op while the quality is above the threshold", func() {
+		policy, err := newGate(0.1).NextPolicy(context.TODO(), workloadMeta)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(policy).To(BeNil())
+	})
+
+	It("should hold the current policy while the quality is below the threshold", func() {
+		policyReco := &v1alpha1.PolicyRecommendation{
+			ObjectMeta: metav1.ObjectMeta{Name: workloadMeta.Name, Namespace: workloadMeta.Namespace},
+			Spec:       v1alpha1.PolicyRecommendationSpec{Policy: "riskier"},
+		}
+		policy, err := newGate(0.5, policyReco).NextPolicy(context.TODO(), workloadMeta)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(policy.Name).To(Equal("riskier"))
+	})
+
+	It("should hold the safest policy if the workload has no policy yet", func() {
+		policy, err := newGate(0.5).NextPolicy(context.TODO(), workloadMeta)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(policy.Name).To(Equal("safest"))
+	})
+})
@@ -0,0 +1,244 @@
+package reco
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
+	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	p8smetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	defaultSeasonLength     = 24 * time.Hour
+	defaultForecastHorizon  = 7 * 24 * time.Hour
+	defaultLevelSmoothing   = 0.5
+	defaultTrendSmoothing   = 0.01
+	defaultSeasonSmoothing  = 0.3
+	defaultConfidenceFactor = 2.0
+
+	// trendDamping damps the trend of the forecast so that a trend fitted on a few days doesn't grow without bound
+	// over a forecast horizon of a week.
+	trendDamping = 0.98
+)
+
+var (
+	forecastFallbackCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{Name: "forecast_recommender_fallback_count",
+			Help: "Number of recommendations generated on the utilization history as the forecast couldn't be generated"},
+		[]string{"namespace", "policyreco", "workloadKind", "workload"},
+	)
+)
+
+func init() {
+	p8smetrics.Registry.MustRegister(forecastFallbackCounter)
+}
+
+var errInsufficientHistory = errors.New("forecast needs the utilization of at least two seasons")
+
+// ForecastBasedRecommender recommends the HPA configuration for the utilization forecasted over the next horizon
+// rather than the utilization of the metric window. The utilization is forecasted with the additive Holt-Winters
+// method, i.e. exponential smoothing of the level, the trend and the seasonality of the series, and is padded with
+// confidenceFactor times the standard deviation of the one step ahead forecast errors. The HPA is then simulated on
+// the forecast exactly like the CpuUtilizationBasedRecommender does on the history.
+//
+// If combineWithHistory is set the HPA is simulated on the history followed by the forecast, so that the
+// recommendation holds for both. A workload without enough history for a forecast falls back to the history alone.
+type ForecastBasedRecommender struct {
+	cpuRecommender     *CpuUtilizationBasedRecommender
+	seasonLength       time.Duration
+	horizon            time.Duration
+	levelSmoothing     float64
+	trendSmoothing     float64
+	seasonSmoothing    float64
+	confidenceFactor   float64
+	combineWithHistory bool
+	logger             logr.Logger
+}
+
+// NewForecastBasedRecommender returns a ForecastBasedRecommender which scrapes and simulates through cpuRecommender.
+// The smoothing factors must be within (0, 1]; the zero values pick the defaults.
+func NewForecastBasedRecommender(cpuRecommender *CpuUtilizationBasedRecommender,
+	seasonLength time.Duration,
+	horizon time.Duration,
+	levelSmoothing float64,
+	trendSmoothing float64,
+	seasonSmoothing float64,
+	confidenceFactor float64,
+	combineWithHistory bool,
+	logger logr.Logger) *ForecastBasedRecommender {
+
+	if seasonLength <= 0 {
+		seasonLength = defaultSeasonLength
+	}
+	if horizon <= 0 {
+		horizon = defaultForecastHorizon
+	}
+	if levelSmoothing <= 0 || levelSmoothing > 1 {
+		levelSmoothing = defaultLevelSmoothing
+	}
+	if trendSmoothing <= 0 || trendSmoothing > 1 {
+		trendSmoothing = defaultTrendSmoothing
+	}
+	if seasonSmoothing <= 0 || seasonSmoothing > 1 {
+		seasonSmoothing = defaultSeasonSmoothing
+	}
+	if confidenceFactor <= 0 {
+		confidenceFactor = defaultConfidenceFactor
+	}
+	return &ForecastBasedRecommender{
+		cpuRecommender:     cpuRecommender,
+		seasonLength:       seasonLength,
+		horizon:            horizon,
+		levelSmoothing:     levelSmoothing,
+		trendSmoothing:     trendSmoothing,
+		seasonSmoothing:    seasonSmoothing,
+		confidenceFactor:   confidenceFactor,
+		combineWithHistory: combineWithHistory,
+		logger:             logger,
+	}
+}
+
+func (f *ForecastBasedRecommender) Recommend(ctx context.Context, workloadMeta WorkloadMeta) (*v1alpha1.HPAConfiguration,
+	error) {
+	return f.cpuRecommender.recommend(ctx, workloadMeta, func(dataPoints []metrics.DataPoint) []metrics.DataPoint {
+		forecast, err := f.forecast(dataPoints, f.cpuRecommender.metricStep)
+		if err != nil {
+			f.logger.Error(err, "Unable to forecast the utilization. Recommending on the utilization history.",
+				"namespace", workloadMeta.Namespace, "workload", workloadMeta.Name)
+			forecastFallbackCounter.WithLabelValues(workloadMeta.Namespace, workloadMeta.Name, workloadMeta.Kind,
+				workloadMeta.Name).Inc()
+			return dataPoints
+		}
+		if f.combineWithHistory {
+			return append(append(make([]metrics.DataPoint, 0, len(dataPoints)+len(forecast)), dataPoints...),
+				forecast...)
+		}
+		return forecast
+	})
+}
+
+// forecast returns the forecasted utilization at every step of the horizon following the last data point.
+func (f *ForecastBasedRecommender) forecast(dataPoints []metrics.DataPoint, step time.Duration) ([]metrics.DataPoint,
+	error) {
+	if step <= 0 {
+		return nil, fmt.Errorf("invalid metric step: %v", step)
+	}
+	if len(dataPoints) == 0 {
+		return nil, errInsufficientHistory
+	}
+	series := resample(dataPoints, step)
+	model, err := fitHoltWinters(series, int(f.seasonLength/step), f.levelSmoothing, f.trendSmoothing,
+		f.seasonSmoothing, trendDamping)
+	if err != nil {
+		return nil, err
+	}
+
+	last := dataPoints[len(dataPoints)-1].Timestamp
+	steps := int(f.horizon / step)
+	forecast := make([]metrics.DataPoint, steps)
+	for i, value := range model.forecast(steps) {
+		value += f.confidenceFactor * model.residualStdDev
+		forecast[i] = metrics.DataPoint{Timestamp: last.Add(time.Duration(i+1) * step), Value: math.Max(0, value)}
+	}
+	return forecast, nil
+}
+
+// resample places the data points on a grid of the step starting at the first data point. The gaps in the data are
+// filled with the last value seen, as Holt-Winters expects evenly spaced observations.
+func resample(dataPoints []metrics.DataPoint, step time.Duration) []float64 {
+	start := dataPoints[0].Timestamp
+	span := dataPoints[len(dataPoints)-1].Timestamp.Sub(start)
+	series := make([]float64, int(math.Round(float64(span)/float64(step)))+1)
+	present := make([]bool, len(series))
+	for _, dp := range dataPoints {
+		idx := int(math.Round(float64(dp.Timestamp.Sub(start)) / float64(step)))
+		if idx < 0 || idx >= len(series) {
+			continue
+		}
+		series[idx] = dp.Value
+		present[idx] = true
+	}
+	for i := 1; i < len(series); i++ {
+		if !present[i] {
+			series[i] = series[i-1]
+		}
+	}
+	return series
+}
+
+// holtWintersModel is the state of an additive Holt-Winters model with a damped trend after it has been fitted on a
+// series.
+type holtWintersModel struct {
+	level          float64
+	trend          float64
+	damping        float64
+	seasonals      []float64
+	observations   int
+	residualStdDev float64
+}
+
+// fitHoltWinters fits the model on the series. The level and the trend are initialised from the first two seasons and
+// the seasonals from the deviation of the first season from its mean.
+func fitHoltWinters(series []float64, seasonLength int, alpha, beta, gamma, damping float64) (*holtWintersModel, error) {
+	if seasonLength < 2 {
+		return nil, fmt.Errorf("season length must span at least two steps, got %d", seasonLength)
+	}
+	if len(series) < 2*seasonLength {
+		return nil, errInsufficientHistory
+	}
+
+	firstSeasonMean := mean(series[:seasonLength])
+	secondSeasonMean := mean(series[seasonLength : 2*seasonLength])
+	model := &holtWintersModel{
+		level:        firstSeasonMean,
+		trend:        (secondSeasonMean - firstSeasonMean) / float64(seasonLength),
+		damping:      damping,
+		seasonals:    make([]float64, seasonLength),
+		observations: len(series),
+	}
+	for i := 0; i < seasonLength; i++ {
+		model.seasonals[i] = series[i] - firstSeasonMean
+	}
+
+	sumOfSquaredErrors := 0.0
+	for t := seasonLength; t < len(series); t++ {
+		seasonal := model.seasonals[t%seasonLength]
+		predicted := model.level + damping*model.trend + seasonal
+		sumOfSquaredErrors += (series[t] - predicted) * (series[t] - predicted)
+
+		level := alpha*(series[t]-seasonal) + (1-alpha)*(model.level+damping*model.trend)
+		model.trend = beta*(level-model.level) + (1-beta)*damping*model.trend
+		model.seasonals[t%seasonLength] = gamma*(series[t]-level) + (1-gamma)*seasonal
+		model.level = level
+	}
+	model.residualStdDev = math.Sqrt(sumOfSquaredErrors / float64(len(series)-seasonLength))
+	return model, nil
+}
+
+// forecast returns the values forecasted for the steps following the last observation.
+func (m *holtWintersModel) forecast(steps int) []float64 {
+	values := make([]float64, steps)
+	dampedSteps := 0.0
+	factor := 1.0
+	for h := 1; h <= steps; h++ {
+		factor *= m.damping
+		dampedSteps += factor
+		values[h-1] = m.level + dampedSteps*m.trend + m.seasonals[(m.observations+h-1)%len(m.seasonals)]
+	}
+	return values
+}
+
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
@@ -0,0 +1,77 @@
+package reco
+
+import (
+	"math"
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ForecastBasedRecommender", func() {
+
+	// seasonalDataPoints returns a series with a daily sine wave around base, sampled every step.
+	seasonalDataPoints := func(start time.Time, days int, step time.Duration, base float64) []metrics.DataPoint {
+		var dataPoints []metrics.DataPoint
+		for t := start; t.Before(start.Add(time.Duration(days) * 24 * time.Hour)); t = t.Add(step) {
+			phase := 2 * math.Pi * float64(t.Sub(start)) / float64(24*time.Hour)
+			dataPoints = append(dataPoints, metrics.DataPoint{Timestamp: t, Value: base + 20*math.Sin(phase)})
+		}
+		return dataPoints
+	}
+
+	Describe("fitHoltWinters", func() {
+		It("should forecast the next season of a seasonal series", func() {
+			start := time.Now().Add(-7 * 24 * time.Hour)
+			step := 10 * time.Minute
+			dataPoints := seasonalDataPoints(start, 7, step, 100)
+			series := make([]float64, len(dataPoints))
+			for i, dp := range dataPoints {
+				series[i] = dp.Value
+			}
+
+			model, err := fitHoltWinters(series, 144, 0.5, 0.01, 0.3, trendDamping)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(model.residualStdDev).To(BeNumerically("<", 1))
+
+			forecast := model.forecast(144)
+			for h, value := range forecast {
+				phase := 2 * math.Pi * float64(len(series)+h) / 144
+				Expect(value).To(BeNumerically("~", 100+20*math.Sin(phase), 1))
+			}
+		})
+
+		It("should return an error if the series is shorter than two seasons", func() {
+			_, err := fitHoltWinters(make([]float64, 200), 144, 0.5, 0.01, 0.3, trendDamping)
+			Expect(err).To(MatchError(errInsufficientHistory))
+		})
+	})
+
+	Describe("forecast", func() {
+		It("should forecast every step of the horizon padded by the confidence factor", func() {
+			step := 10 * time.Minute
+			recommender := NewForecastBasedRecommender(nil, 24*time.Hour, 48*time.Hour, 0, 0, 0, 0, false, logger)
+			dataPoints := seasonalDataPoints(time.Now().Add(-4*24*time.Hour), 4, step, 50)
+
+			forecast, err := recommender.forecast(dataPoints, step)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(forecast).To(HaveLen(288))
+			Expect(forecast[0].Timestamp).To(Equal(dataPoints[len(dataPoints)-1].Timestamp.Add(step)))
+			Expect(forecast[287].Timestamp).To(Equal(dataPoints[len(dataPoints)-1].Timestamp.Add(288 * step)))
+			for _, dp := range forecast {
+				Expect(dp.Value).To(BeNumerically(">", 29.9))
+			}
+		})
+
+		It("should fill the gaps in the data points with the last value seen", func() {
+			start := time.Now()
+			series := resample([]metrics.DataPoint{
+				{Timestamp: start, Value: 10},
+				{Timestamp: start.Add(time.Minute), Value: 20},
+				{Timestamp: start.Add(4 * time.Minute), Value: 40},
+			}, time.Minute)
+			Expect(series).To(Equal([]float64{10, 20, 20, 20, 40}))
+		})
+	})
+})
@@ -0,0 +1,184 @@
+package reco
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
+	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	p8smetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	MaxReplicasStatusManager = "MaxReplicasStatusManager"
+
+	MaxReplicasSaturatedReason   = "PeakDemandAtMaxReplicas"
+	MaxReplicasSaturatedMessage  = "The peak demand needs %d replicas at the red line utilization, %d with headroom, while the max replicas are %d."
+	MaxReplicasReachedReason     = "ReadyReplicasAtMaxReplicas"
+	MaxReplicasReachedMessage    = "The ready replicas reached the max replicas of %d while the peak demand needs %d replicas at the red line utilization, %d with headroom."
+	MaxReplicasSufficientReason  = "MaxReplicasSufficient"
+	MaxReplicasSufficientMessage = "The peak demand needs %d replicas at the red line utilization, %d with headroom, within the max replicas of %d."
+
+	defaultMaxReplicasHeadroom   = 0.2
+	defaultMaxReplicasRaiseLimit = 2.0
+)
+
+var maxReplicasSaturatedGauge = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "max_replicas_saturated",
+		Help: "Boolean to show if the peak demand of the workload reached its max replicas",
+	}, []string{"namespace", "policyreco", "workloadKind", "workload"},
+)
+
+func init() {
+	p8smetrics.Registry.MustRegister(maxReplicasSaturatedGauge)
+}
+
+// MaxReplicaRecommender recommends the max replicas of a workload from the peak demand of its utilization plus
+// headroom. The max replicas of the workload, taken from the max pods annotation, the existing autoscaler or the
+// current replicas, cap both the simulated HPA and the breach detection, so a workload whose peak demand exceeds them
+// is silently under-provisioned; such a workload is flagged with the MaxReplicasSaturated condition. As the utilization
+// of a workload held at its max replicas is spread over them, its peak demand may stay below the max replicas, so a
+// workload whose ready replicas reached the max replicas of its HPA, the intervals the breach detection leaves out, is
+// flagged too.
+//
+// If raiseMax is set, the recommendation's max replicas are raised to the recommended max replicas, up to raiseLimit
+// times the max replicas of the workload. They are never lowered.
+type MaxReplicaRecommender struct {
+	k8sClient  client.Client
+	scraper    metrics.MaxReplicasScraper
+	headroom   float64
+	raiseMax   bool
+	raiseLimit float64
+}
+
+// NewMaxReplicaRecommender returns a MaxReplicaRecommender which publishes the MaxReplicasSaturated condition to the
+// status of the policy recommendations. The zero values pick the defaults. A nil scraper leaves out the intervals in
+// which the ready replicas reached the max replicas.
+func NewMaxReplicaRecommender(k8sClient client.Client,
+	scraper metrics.MaxReplicasScraper,
+	headroom float64,
+	raiseMax bool,
+	raiseLimit float64) *MaxReplicaRecommender {
+	if headroom <= 0 {
+		headroom = defaultMaxReplicasHeadroom
+	}
+	if raiseLimit < 1 {
+		raiseLimit = defaultMaxReplicasRaiseLimit
+	}
+	return &MaxReplicaRecommender{
+		k8sClient:  k8sClient,
+		scraper:    scraper,
+		headroom:   headroom,
+		raiseMax:   raiseMax,
+		raiseLimit: raiseLimit,
+	}
+}
+
+// maxReplicasRecommendation is the max replicas recommended for the peak demand of a workload.
+type maxReplicasRecommendation struct {
+	// peakReplicas are the replicas serving the peak demand at the red line utilization.
+	peakReplicas int
+	// recommended are the peak replicas plus headroom.
+	recommended int
+	// saturated is set if the peak demand or the ready replicas reached the max replicas of the workload.
+	saturated bool
+	// reachedMax is set if the ready replicas reached the max replicas of the workload's HPA.
+	reachedMax bool
+	// max are the max replicas to recommend, the max replicas of the workload unless they are raised.
+	max int
+}
+
+// recommendMax returns the max replicas recommended for the utilization. As the utilization of a saturated workload
+// is capped by its max replicas, its peak demand is a lower bound of the demand it would have served. reachedMax is
+// set if the ready replicas of the workload reached the max replicas of its HPA.
+func (m *MaxReplicaRecommender) recommendMax(dataPoints []metrics.DataPoint,
+	perPodResources float64,
+	redLineUtil float64,
+	workloadMaxReplicas int,
+	reachedMax bool) maxReplicasRecommendation {
+
+	recommendation := maxReplicasRecommendation{max: workloadMaxReplicas, reachedMax: reachedMax,
+		saturated: reachedMax}
+	if perPodResources <= 0 || redLineUtil <= 0 {
+		return recommendation
+	}
+	peak := 0.0
+	for _, dp := range dataPoints {
+		peak = math.Max(peak, dp.Value)
+	}
+	peakReplicas := peak / (perPodResources * redLineUtil)
+	recommendation.peakReplicas = int(math.Ceil(peakReplicas))
+	recommendation.recommended = int(math.Ceil(peakReplicas * (1 + m.headroom)))
+	recommendation.saturated = reachedMax || (workloadMaxReplicas > 0 && peakReplicas >= float64(workloadMaxReplicas))
+
+	if m.raiseMax && recommendation.recommended > workloadMaxReplicas {
+		limit := int(math.Floor(float64(workloadMaxReplicas) * m.raiseLimit))
+		recommendation.max = recommendation.recommended
+		if recommendation.max > limit {
+			recommendation.max = limit
+		}
+		if recommendation.max < workloadMaxReplicas {
+			recommendation.max = workloadMaxReplicas
+		}
+	}
+	return recommendation
+}
+
+// replicasReachedMax returns true if the ready replicas of the workload reached the max replicas of its HPA in the
+// given time range.
+func (m *MaxReplicaRecommender) replicasReachedMax(ctx context.Context, workloadMeta WorkloadMeta, start time.Time,
+	end time.Time, step time.Duration) (bool, error) {
+	if m.scraper == nil {
+		return false, nil
+	}
+	dataPoints, err := m.scraper.GetReplicasAtMaxDataPoints(ctx, workloadMeta.Namespace, workloadMeta.Kind,
+		workloadMeta.Name, start, end, step)
+	if err != nil {
+		return false, err
+	}
+	return len(dataPoints) > 0, nil
+}
+
+// publishMaxReplicas applies the MaxReplicasSaturated condition and the recommended max replicas to the status of the
+// workload's policy recommendation.
+func (m *MaxReplicaRecommender) publishMaxReplicas(ctx context.Context, workloadMeta WorkloadMeta,
+	recommendation maxReplicasRecommendation, workloadMaxReplicas int) error {
+	saturated := 0.0
+	condition := metav1.Condition{
+		Type:               string(v1alpha1.MaxReplicasSaturated),
+		Status:             metav1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             MaxReplicasSufficientReason,
+		Message: fmt.Sprintf(MaxReplicasSufficientMessage, recommendation.peakReplicas, recommendation.recommended,
+			workloadMaxReplicas),
+	}
+	if recommendation.saturated {
+		saturated = 1.0
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = MaxReplicasSaturatedReason
+		condition.Message = fmt.Sprintf(MaxReplicasSaturatedMessage, recommendation.peakReplicas,
+			recommendation.recommended, workloadMaxReplicas)
+		if recommendation.reachedMax && recommendation.peakReplicas < workloadMaxReplicas {
+			condition.Reason = MaxReplicasReachedReason
+			condition.Message = fmt.Sprintf(MaxReplicasReachedMessage, workloadMaxReplicas,
+				recommendation.peakReplicas, recommendation.recommended)
+		}
+	}
+	maxReplicasSaturatedGauge.WithLabelValues(workloadMeta.Namespace, workloadMeta.Name, workloadMeta.Kind,
+		workloadMeta.Name).Set(saturated)
+
+	if m.k8sClient == nil {
+		return nil
+	}
+	return applyPolicyRecoStatus(ctx, m.k8sClient, workloadMeta, v1alpha1.PolicyRecommendationStatus{
+		Conditions:             []metav1.Condition{condition},
+		RecommendedMaxReplicas: recommendation.recommended,
+	}, MaxReplicasStatusManager)
+}
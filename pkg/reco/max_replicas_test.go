@@ -0,0 +1,99 @@
+package reco
+
+import (
+	"context"
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeMaxReplicasScraper returns its data points as the intervals at the max replicas of any workload.
+type fakeMaxReplicasScraper struct {
+	dataPoints []metrics.DataPoint
+}
+
+func (s *fakeMaxReplicasScraper) GetReplicasAtMaxDataPoints(_ context.Context, _, _, _ string, _ time.Time,
+	_ time.Time, _ time.Duration) ([]metrics.DataPoint, error) {
+	return s.dataPoints, nil
+}
+
+var _ = Describe("MaxReplicaRecommender", func() {
+
+	dataPointsPeakingAt := func(peak float64) []metrics.DataPoint {
+		start := time.Date(2023, time.May, 1, 0, 0, 0, 0, time.UTC)
+		return []metrics.DataPoint{
+			{Timestamp: start, Value: 2},
+			{Timestamp: start.Add(time.Minute), Value: peak},
+			{Timestamp: start.Add(2 * time.Minute), Value: 3},
+		}
+	}
+
+	It("should recommend the peak replicas plus headroom without raising the max replicas", func() {
+		m := NewMaxReplicaRecommender(nil, nil, 0, false, 0)
+		recommendation := m.recommendMax(dataPointsPeakingAt(8.5), 2, 0.85, 10, false)
+
+		Expect(recommendation).To(Equal(maxReplicasRecommendation{
+			peakReplicas: 5,
+			recommended:  6,
+			saturated:    false,
+			max:          10,
+		}))
+	})
+
+	It("should flag a workload whose peak demand reached its max replicas", func() {
+		m := NewMaxReplicaRecommender(nil, nil, 0, false, 0)
+		recommendation := m.recommendMax(dataPointsPeakingAt(17), 2, 0.85, 10, false)
+
+		Expect(recommendation.saturated).To(BeTrue())
+		Expect(recommendation.peakReplicas).To(Equal(10))
+		Expect(recommendation.recommended).To(Equal(12))
+		Expect(recommendation.max).To(Equal(10))
+	})
+
+	It("should flag a workload whose ready replicas reached its max replicas below the peak demand", func() {
+		m := NewMaxReplicaRecommender(nil, nil, 0, false, 0)
+		recommendation := m.recommendMax(dataPointsPeakingAt(8.5), 2, 0.85, 10, true)
+
+		Expect(recommendation).To(Equal(maxReplicasRecommendation{
+			peakReplicas: 5,
+			recommended:  6,
+			saturated:    true,
+			reachedMax:   true,
+			max:          10,
+		}))
+	})
+
+	It("should find whether the ready replicas reached the max replicas", func() {
+		workloadMeta := WorkloadMeta{TypeMeta: metav1.TypeMeta{Kind: "Deployment"}, Name: "app", Namespace: "default"}
+		start := time.Date(2023, time.May, 1, 0, 0, 0, 0, time.UTC)
+		scraper := &fakeMaxReplicasScraper{}
+		m := NewMaxReplicaRecommender(nil, scraper, 0, false, 0)
+
+		reachedMax, err := m.replicasReachedMax(context.TODO(), workloadMeta, start, start.Add(time.Hour), time.Minute)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reachedMax).To(BeFalse())
+
+		scraper.dataPoints = []metrics.DataPoint{{Timestamp: start.Add(time.Minute), Value: 10}}
+		reachedMax, err = m.replicasReachedMax(context.TODO(), workloadMeta, start, start.Add(time.Hour), time.Minute)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reachedMax).To(BeTrue())
+	})
+
+	It("should raise the max replicas of a saturated workload", func() {
+		m := NewMaxReplicaRecommender(nil, nil, 0.5, true, 0)
+		Expect(m.recommendMax(dataPointsPeakingAt(17), 2, 0.85, 10, false).max).To(Equal(15))
+	})
+
+	It("should raise the max replicas only up to the limit", func() {
+		m := NewMaxReplicaRecommender(nil, nil, 0.5, true, 1.1)
+		Expect(m.recommendMax(dataPointsPeakingAt(17), 2, 0.85, 10, false).max).To(Equal(11))
+	})
+
+	It("should never lower the max replicas", func() {
+		m := NewMaxReplicaRecommender(nil, nil, 0, true, 0)
+		Expect(m.recommendMax(dataPointsPeakingAt(3.4), 2, 0.85, 10, false).max).To(Equal(10))
+	})
+})
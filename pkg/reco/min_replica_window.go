@@ -0,0 +1,123 @@
+package reco
+
+import (
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+)
+
+const defaultMinReplicaWindowLength = 4 * time.Hour
+
+// MinReplicaWindowPlanner splits the day into windows of equal length and recommends the min replicas of every
+// window. A single min replicas for the whole day has to hold the peak of the day, so a workload with a large diurnal
+// swing keeps its daytime capacity through the night; with a min replicas per window the HPA configuration's Min
+// drops to the min replicas of the quietest window and the windows raise it for the rest of the day.
+type MinReplicaWindowPlanner struct {
+	windowLength time.Duration
+	location     *time.Location
+}
+
+// NewMinReplicaWindowPlanner returns a MinReplicaWindowPlanner which evaluates the windows in the location. The
+// window length must divide the day; the zero values pick the defaults.
+func NewMinReplicaWindowPlanner(windowLength time.Duration, location *time.Location) *MinReplicaWindowPlanner {
+	if windowLength <= 0 || windowLength > dailyPeriod || dailyPeriod%windowLength != 0 {
+		windowLength = defaultMinReplicaWindowLength
+	}
+	if location == nil {
+		location = time.UTC
+	}
+	return &MinReplicaWindowPlanner{
+		windowLength: windowLength,
+		location:     location,
+	}
+}
+
+// planMinReplicaWindows lowers the min replicas of the optimal HPA configuration within every window of the day, as
+// far as the simulated HPA runs without a breach. It returns the optimal configuration unchanged if none of the windows
+// can run with fewer replicas.
+func (c *CpuUtilizationBasedRecommender) planMinReplicaWindows(dataPoints []metrics.DataPoint,
+	acl time.Duration,
+	perPodResources float64,
+	optimal optimalHPAConfiguration) (optimalHPAConfiguration, error) {
+
+	if c.minReplicaWindowPlanner == nil || optimal.min <= 1 {
+		return optimal, nil
+	}
+	windowLength := c.minReplicaWindowPlanner.windowLength
+	windows := &preScaleSchedule{period: dailyPeriod, location: c.minReplicaWindowPlanner.location}
+	for start := time.Duration(0); start < dailyPeriod; start += windowLength {
+		windows.windows = append(windows.windows, preScaleWindow{
+			start:    start,
+			end:      (start + windowLength) % dailyPeriod,
+			replicas: optimal.min,
+		})
+	}
+
+	simulate := func() ([]metrics.DataPoint, error) {
+		simulated, _, err := c.simulateHPAWithSchedule(dataPoints, acl, optimal.targetUtilization, perPodResources,
+			optimal.max, 1, windows, optimal.schedule)
+		return simulated, err
+	}
+
+	// Every window is lowered with the others at the optimal min replicas. The breaches only depend on the
+	// replicas of the window and the ones preceding it within the autoscaling cycle lag, so the windows are lowered
+	// one at a time and the combination is checked once more at the end.
+	for i := range windows.windows {
+		low, high := 1, optimal.min
+		for low < high {
+			mid := low + (high-low)/2
+			windows.windows[i].replicas = mid
+			simulated, err := simulate()
+			if err != nil {
+				return optimal, err
+			}
+			if c.hasNoBreachOccurred(dataPoints, simulated) {
+				high = mid
+			} else {
+				low = mid + 1
+			}
+		}
+		windows.windows[i].replicas = low
+	}
+
+	simulated, err := simulate()
+	if err != nil {
+		return optimal, err
+	}
+	if !c.hasNoBreachOccurred(dataPoints, simulated) {
+		return optimal, nil
+	}
+
+	min := optimal.min
+	for _, w := range windows.windows {
+		if w.replicas < min {
+			min = w.replicas
+		}
+	}
+	if min == optimal.min {
+		return optimal, nil
+	}
+
+	// The windows at the new min replicas are dropped and the adjacent windows with the same min replicas merged.
+	var merged []preScaleWindow
+	for _, w := range windows.windows {
+		if w.replicas == min {
+			continue
+		}
+		if last := len(merged) - 1; last >= 0 && merged[last].end == w.start && merged[last].replicas == w.replicas {
+			merged[last].end = w.end
+			continue
+		}
+		merged = append(merged, w)
+	}
+	windows.windows = merged
+
+	return optimalHPAConfiguration{
+		targetUtilization: optimal.targetUtilization,
+		min:               min,
+		max:               optimal.max,
+		savings:           c.calculateSavings(optimal.max, simulated, perPodResources),
+		schedule:          optimal.schedule,
+		minReplicaWindows: windows,
+	}, nil
+}
@@ -0,0 +1,66 @@
+package reco
+
+import (
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
+	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MinReplicaWindowPlanner", func() {
+
+	// diurnalDataPoints returns a utilization of 4 cores at night which jumps to 40 cores between 08:00 and 20:00 UTC,
+	// sampled every 30 seconds.
+	diurnalDataPoints := func(days int) []metrics.DataPoint {
+		start := time.Date(2023, time.May, 1, 0, 0, 0, 0, time.UTC)
+		var dataPoints []metrics.DataPoint
+		for t := start; t.Before(start.AddDate(0, 0, days)); t = t.Add(30 * time.Second) {
+			value := 4.0
+			if t.Hour() >= 8 && t.Hour() < 20 {
+				value = 40
+			}
+			dataPoints = append(dataPoints, metrics.DataPoint{Timestamp: t, Value: value})
+		}
+		return dataPoints
+	}
+
+	It("should fall back to the default window length if it doesn't divide the day", func() {
+		Expect(NewMinReplicaWindowPlanner(5*time.Hour, nil).windowLength).To(Equal(defaultMinReplicaWindowLength))
+		Expect(NewMinReplicaWindowPlanner(6*time.Hour, nil).windowLength).To(Equal(6 * time.Hour))
+	})
+
+	It("should lower the min replicas outside of the windows preceding the daily ramp", func() {
+		c := &CpuUtilizationBasedRecommender{logger: logger, redLineUtil: 0.85,
+			minReplicaWindowPlanner: NewMinReplicaWindowPlanner(0, nil)}
+		dataPoints := diurnalDataPoints(5)
+
+		optimal, err := c.findOptimalHPAConfigurationWithSchedule(dataPoints, 5*time.Minute, 10, 60, 1, 60, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(optimal.min).To(Equal(48))
+
+		windowed, err := c.planMinReplicaWindows(dataPoints, 5*time.Minute, 1, optimal)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(windowed.min).To(Equal(1))
+		Expect(windowed.targetUtilization).To(Equal(optimal.targetUtilization))
+		Expect(windowed.savings).To(BeNumerically(">", optimal.savings))
+		Expect(windowed.minReplicaWindows.replicaSchedules()).To(Equal([]v1alpha1.ReplicaSchedule{
+			{Timezone: "UTC", Start: "0 4 * * *", End: "0 8 * * *", DesiredReplicas: 48},
+		}))
+
+		simulated, _, err := c.simulateHPAWithSchedule(dataPoints, 5*time.Minute, windowed.targetUtilization, 1,
+			windowed.max, windowed.min, windowed.minReplicaWindows)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.hasNoBreachOccurred(dataPoints, simulated)).To(BeTrue())
+	})
+
+	It("should leave the configuration unchanged without a planner", func() {
+		c := &CpuUtilizationBasedRecommender{logger: logger, redLineUtil: 0.85}
+		optimal := optimalHPAConfiguration{targetUtilization: 60, min: 48, max: 60, savings: 10}
+
+		windowed, err := c.planMinReplicaWindows(diurnalDataPoints(2), 5*time.Minute, 1, optimal)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(windowed).To(Equal(optimal))
+	})
+})
@@ -0,0 +1,262 @@
+package reco
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
+	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+)
+
+const (
+	defaultPreScaleSlot       = 30 * time.Minute
+	defaultPreScaleRecurrence = 0.5
+	defaultPreScaleRampFactor = 1.5
+
+	dailyPeriod  = 24 * time.Hour
+	weeklyPeriod = 7 * dailyPeriod
+
+	// A weekly schedule is preferred over the daily one only if it saves at least this fraction of the replica hours
+	// the daily schedule keeps, as it takes seven times as many cron triggers.
+	weeklyScheduleMinSavings = 0.1
+)
+
+// PreScaler finds the recurring daily or weekly ramps in the utilization and schedules a minimum number of replicas
+// ahead of them. Reactive scaling reaches a ramp only after the autoscaling cycle lag, which forces a low target
+// utilization; with the replicas scheduled ahead of the ramp the target can be raised safely.
+//
+// The period is split into slots and the demand of a slot is the utilization reached in at least recurrence of the
+// days (or weeks) of the metric window. A slot whose demand exceeds rampFactor times the demand of the quietest slot
+// is part of a ramp.
+type PreScaler struct {
+	slot       time.Duration
+	recurrence float64
+	rampFactor float64
+	location   *time.Location
+}
+
+// NewPreScaler returns a PreScaler which evaluates the schedules in the location. The zero values pick the defaults.
+func NewPreScaler(slot time.Duration, recurrence float64, rampFactor float64, location *time.Location) *PreScaler {
+	if slot <= 0 {
+		slot = defaultPreScaleSlot
+	}
+	if recurrence <= 0 || recurrence > 1 {
+		recurrence = defaultPreScaleRecurrence
+	}
+	if rampFactor <= 1 {
+		rampFactor = defaultPreScaleRampFactor
+	}
+	if location == nil {
+		location = time.UTC
+	}
+	return &PreScaler{
+		slot:       slot,
+		recurrence: recurrence,
+		rampFactor: rampFactor,
+		location:   location,
+	}
+}
+
+// preScaleWindow keeps at least replicas replicas from start till end, both offsets from the beginning of the period.
+// A window with its start after its end wraps around the end of the period.
+type preScaleWindow struct {
+	start    time.Duration
+	end      time.Duration
+	replicas int
+}
+
+func (w preScaleWindow) contains(offset time.Duration) bool {
+	if w.start <= w.end {
+		return offset >= w.start && offset < w.end
+	}
+	return offset >= w.start || offset < w.end
+}
+
+func (w preScaleWindow) length(period time.Duration) time.Duration {
+	if w.start <= w.end {
+		return w.end - w.start
+	}
+	return period - w.start + w.end
+}
+
+// preScaleSchedule is the set of windows recurring every period.
+type preScaleSchedule struct {
+	period   time.Duration
+	location *time.Location
+	windows  []preScaleWindow
+}
+
+// offset returns the time elapsed between the beginning of the period, midnight or midnight of Sunday, and t.
+func (s *preScaleSchedule) offset(t time.Time) time.Duration {
+	t = t.In(s.location)
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+	if s.period == weeklyPeriod {
+		offset += time.Duration(t.Weekday()) * dailyPeriod
+	}
+	return offset
+}
+
+// replicasAt returns the minimum replicas scheduled at t.
+func (s *preScaleSchedule) replicasAt(t time.Time) int {
+	offset := s.offset(t)
+	replicas := 0
+	for _, w := range s.windows {
+		if w.replicas > replicas && w.contains(offset) {
+			replicas = w.replicas
+		}
+	}
+	return replicas
+}
+
+// replicaHours returns the replica hours the schedule keeps every week.
+func (s *preScaleSchedule) replicaHours() float64 {
+	hours := 0.0
+	for _, w := range s.windows {
+		hours += float64(w.replicas) * w.length(s.period).Hours()
+	}
+	return hours * float64(weeklyPeriod/s.period)
+}
+
+// replicaSchedules returns the windows as cron schedules.
+func (s *preScaleSchedule) replicaSchedules() []v1alpha1.ReplicaSchedule {
+	schedules := make([]v1alpha1.ReplicaSchedule, 0, len(s.windows))
+	for _, w := range s.windows {
+		schedules = append(schedules, v1alpha1.ReplicaSchedule{
+			Timezone:        s.location.String(),
+			Start:           s.cronSpec(w.start),
+			End:             s.cronSpec(w.end),
+			DesiredReplicas: w.replicas,
+		})
+	}
+	return schedules
+}
+
+func (s *preScaleSchedule) cronSpec(offset time.Duration) string {
+	minute := int(offset/time.Minute) % 60
+	hour := int(offset/time.Hour) % 24
+	if s.period == weeklyPeriod {
+		return fmt.Sprintf("%d %d * * %d", minute, hour, int(offset/dailyPeriod))
+	}
+	return fmt.Sprintf("%d %d * * *", minute, hour)
+}
+
+// schedule returns the pre-scale schedule for the utilization, or nil if the utilization has no recurring ramps. The
+// weekly schedule is picked over the daily one if the workload ramps differently on different days of the week.
+func (p *PreScaler) schedule(dataPoints []metrics.DataPoint,
+	perPodResources float64,
+	redLineUtil float64,
+	acl time.Duration,
+	maxReplicas int) *preScaleSchedule {
+
+	daily := p.scheduleFor(dailyPeriod, dataPoints, perPodResources, redLineUtil, acl, maxReplicas)
+	weekly := p.scheduleFor(weeklyPeriod, dataPoints, perPodResources, redLineUtil, acl, maxReplicas)
+	if weekly == nil {
+		return daily
+	}
+	if daily == nil || weekly.replicaHours() < (1-weeklyScheduleMinSavings)*daily.replicaHours() {
+		return weekly
+	}
+	return daily
+}
+
+func (p *PreScaler) scheduleFor(period time.Duration,
+	dataPoints []metrics.DataPoint,
+	perPodResources float64,
+	redLineUtil float64,
+	acl time.Duration,
+	maxReplicas int) *preScaleSchedule {
+
+	if perPodResources <= 0 || redLineUtil <= 0 {
+		return nil
+	}
+	schedule := &preScaleSchedule{period: period, location: p.location}
+	demand := p.slotDemand(schedule, dataPoints)
+	if demand == nil {
+		return nil
+	}
+
+	baseline := math.Inf(1)
+	for _, d := range demand {
+		if d >= 0 && d < baseline {
+			baseline = d
+		}
+	}
+	replicasFor := func(d float64) int {
+		return int(math.Min(float64(maxReplicas), math.Ceil(d/(perPodResources*redLineUtil))))
+	}
+	baselineReplicas := replicasFor(baseline)
+
+	// Round the lead up to whole minutes as the cron schedules are at the granularity of a minute.
+	lead := time.Duration(math.Ceil(acl.Minutes())) * time.Minute
+	wrap := func(offset time.Duration) time.Duration {
+		return ((offset % period) + period) % period
+	}
+	for i := 0; i < len(demand); {
+		if demand[i] < 0 || demand[i] <= p.rampFactor*baseline || replicasFor(demand[i]) <= baselineReplicas {
+			i++
+			continue
+		}
+		window := preScaleWindow{start: wrap(time.Duration(i)*p.slot - lead)}
+		for ; i < len(demand) && demand[i] > p.rampFactor*baseline; i++ {
+			if replicas := replicasFor(demand[i]); replicas > window.replicas {
+				window.replicas = replicas
+			}
+		}
+		window.end = wrap(time.Duration(i) * p.slot)
+		schedule.windows = append(schedule.windows, window)
+	}
+	if len(schedule.windows) == 0 {
+		return nil
+	}
+	return schedule
+}
+
+// slotDemand returns the demand of every slot of the period, or nil if the utilization doesn't span at least two
+// periods. The slots without any data point have a negative demand.
+func (p *PreScaler) slotDemand(schedule *preScaleSchedule, dataPoints []metrics.DataPoint) []float64 {
+	slots := int(schedule.period / p.slot)
+	peaks := make(map[int64][]float64)
+	for _, dp := range dataPoints {
+		t := dp.Timestamp.In(p.location)
+		cycleStart := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, p.location)
+		if schedule.period == weeklyPeriod {
+			cycleStart = cycleStart.AddDate(0, 0, -int(t.Weekday()))
+		}
+		cycle, ok := peaks[cycleStart.Unix()]
+		if !ok {
+			cycle = make([]float64, slots)
+			for i := range cycle {
+				cycle[i] = -1
+			}
+			peaks[cycleStart.Unix()] = cycle
+		}
+		slot := int(schedule.offset(t) / p.slot)
+		if slot < slots && dp.Value > cycle[slot] {
+			cycle[slot] = dp.Value
+		}
+	}
+	if len(peaks) < 2 {
+		return nil
+	}
+
+	demand := make([]float64, slots)
+	for i := range demand {
+		var values []float64
+		for _, cycle := range peaks {
+			if cycle[i] >= 0 {
+				values = append(values, cycle[i])
+			}
+		}
+		if len(values) < 2 {
+			demand[i] = -1
+			continue
+		}
+		// The demand is reached in at least recurrence of the cycles which have data for the slot.
+		sort.Sort(sort.Reverse(sort.Float64Slice(values)))
+		demand[i] = values[int(math.Ceil(p.recurrence*float64(len(values))))-1]
+	}
+	return demand
+}
@@ -0,0 +1,109 @@
+package reco
+
+import (
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
+	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PreScaler", func() {
+
+	// rampDataPoints returns a utilization of base cores which ramps up to peak cores between 09:00 and 11:00 UTC
+	// every day, sampled every 5 minutes.
+	rampDataPoints := func(start time.Time, days int, base, peak float64) []metrics.DataPoint {
+		var dataPoints []metrics.DataPoint
+		for t := start; t.Before(start.AddDate(0, 0, days)); t = t.Add(5 * time.Minute) {
+			value := base
+			if t.Hour() >= 9 && t.Hour() < 11 {
+				value = peak
+			}
+			dataPoints = append(dataPoints, metrics.DataPoint{Timestamp: t, Value: value})
+		}
+		return dataPoints
+	}
+
+	// A Monday, so that the five days of utilization fall in the same week.
+	start := time.Date(2023, time.May, 1, 0, 0, 0, 0, time.UTC)
+
+	Describe("schedule", func() {
+		It("should schedule the replicas for a daily ramp ahead of it", func() {
+			preScaler := NewPreScaler(0, 0, 0, nil)
+			schedule := preScaler.schedule(rampDataPoints(start, 5, 10, 40), 1, 0.8, 3*time.Minute, 60)
+
+			Expect(schedule).ToNot(BeNil())
+			Expect(schedule.period).To(Equal(dailyPeriod))
+			Expect(schedule.windows).To(Equal([]preScaleWindow{
+				{start: 8*time.Hour + 57*time.Minute, end: 11 * time.Hour, replicas: 50},
+			}))
+
+			Expect(schedule.replicasAt(start.Add(8*time.Hour + 58*time.Minute))).To(Equal(50))
+			Expect(schedule.replicasAt(start.Add(10 * time.Hour))).To(Equal(50))
+			Expect(schedule.replicasAt(start.Add(11 * time.Hour))).To(Equal(0))
+			Expect(schedule.replicasAt(start.Add(3 * time.Hour))).To(Equal(0))
+		})
+
+		It("should cap the scheduled replicas at the max replicas", func() {
+			preScaler := NewPreScaler(0, 0, 0, nil)
+			schedule := preScaler.schedule(rampDataPoints(start, 5, 10, 40), 1, 0.8, 3*time.Minute, 30)
+
+			Expect(schedule).ToNot(BeNil())
+			Expect(schedule.windows).To(HaveLen(1))
+			Expect(schedule.windows[0].replicas).To(Equal(30))
+		})
+
+		It("should not schedule anything for a utilization without ramps", func() {
+			preScaler := NewPreScaler(0, 0, 0, nil)
+			Expect(preScaler.schedule(rampDataPoints(start, 5, 10, 12), 1, 0.8, 3*time.Minute, 60)).To(BeNil())
+		})
+
+		It("should not schedule anything for a utilization spanning a single day", func() {
+			preScaler := NewPreScaler(0, 0, 0, nil)
+			Expect(preScaler.schedule(rampDataPoints(start, 1, 10, 40), 1, 0.8, 3*time.Minute, 60)).To(BeNil())
+		})
+
+		It("should ignore a ramp that doesn't recur on most of the days", func() {
+			dataPoints := rampDataPoints(start, 5, 10, 10)
+			for i := range dataPoints {
+				t := dataPoints[i].Timestamp
+				if t.Day() == 2 && t.Hour() >= 9 && t.Hour() < 11 {
+					dataPoints[i].Value = 40
+				}
+			}
+			preScaler := NewPreScaler(0, 0, 0, nil)
+			Expect(preScaler.schedule(dataPoints, 1, 0.8, 3*time.Minute, 60)).To(BeNil())
+		})
+	})
+
+	Describe("replicaSchedules", func() {
+		It("should return the daily windows as cron schedules", func() {
+			location, err := time.LoadLocation("Asia/Kolkata")
+			Expect(err).ToNot(HaveOccurred())
+			schedule := &preScaleSchedule{period: dailyPeriod, location: location, windows: []preScaleWindow{
+				{start: 8*time.Hour + 57*time.Minute, end: 11 * time.Hour, replicas: 50},
+				{start: 23*time.Hour + 30*time.Minute, end: 30 * time.Minute, replicas: 20},
+			}}
+
+			Expect(schedule.replicaSchedules()).To(Equal([]v1alpha1.ReplicaSchedule{
+				{Timezone: "Asia/Kolkata", Start: "57 8 * * *", End: "0 11 * * *", DesiredReplicas: 50},
+				{Timezone: "Asia/Kolkata", Start: "30 23 * * *", End: "30 0 * * *", DesiredReplicas: 20},
+			}))
+			Expect(schedule.replicaHours()).To(BeNumerically("~", 7*(50*2.05+20), 1e-9))
+		})
+
+		It("should return the weekly windows as cron schedules on their day of the week", func() {
+			schedule := &preScaleSchedule{period: weeklyPeriod, location: time.UTC, windows: []preScaleWindow{
+				{start: 5*dailyPeriod + 18*time.Hour, end: 5*dailyPeriod + 21*time.Hour, replicas: 10},
+			}}
+
+			Expect(schedule.replicaSchedules()).To(Equal([]v1alpha1.ReplicaSchedule{
+				{Timezone: "UTC", Start: "0 18 * * 5", End: "0 21 * * 5", DesiredReplicas: 10},
+			}))
+			friday := time.Date(2023, time.May, 5, 19, 0, 0, 0, time.UTC)
+			Expect(schedule.replicasAt(friday)).To(Equal(10))
+			Expect(schedule.replicasAt(friday.AddDate(0, 0, 1))).To(Equal(0))
+		})
+	})
+})
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sort"
 	"time"
 
 	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
@@ -29,6 +30,14 @@ var (
 		}, []string{"namespace", "policyreco", "workloadKind", "workload"},
 	)
 
+	recoStageLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "reco_stage_latency_seconds",
+			Help:    "Time to execute a stage of the recommendation generation in seconds",
+			Buckets: append(prometheus.DefBuckets, 15, 20, 50, 100),
+		}, []string{"namespace", "policyreco", "workloadKind", "workload", "stage"},
+	)
+
 	minPercentageOfDataPointsPresent = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{Name: "minimum_percentage_of_datapoints_present",
 			Help: "Boolean to show if min percentage of datapoints is present to generate recommendation"},
@@ -37,7 +46,23 @@ var (
 )
 
 func init() {
-	p8smetrics.Registry.MustRegister(getAverageCPUUtilizationQueryLatency, minPercentageOfDataPointsPresent)
+	p8smetrics.Registry.MustRegister(getAverageCPUUtilizationQueryLatency, recoStageLatency,
+		minPercentageOfDataPointsPresent)
+}
+
+// DeleteWorkloadMetrics deletes the label sets of the per workload metrics exposed by this package. It should be
+// called once the workload has been deleted so that stale series aren't exported.
+func DeleteWorkloadMetrics(namespace, workload string) {
+	policyRecoLabels := prometheus.Labels{"namespace": namespace, "policyreco": workload}
+	getAverageCPUUtilizationQueryLatency.DeletePartialMatch(policyRecoLabels)
+	recoStageLatency.DeletePartialMatch(policyRecoLabels)
+	getRecoGenerationLatency.DeletePartialMatch(policyRecoLabels)
+	breachGauge.DeletePartialMatch(policyRecoLabels)
+	agedPolicyCounter.DeletePartialMatch(policyRecoLabels)
+	minPercentageOfDataPointsPresent.DeletePartialMatch(prometheus.Labels{"namespace": namespace, "workload": workload})
+	forecastFallbackCounter.DeletePartialMatch(policyRecoLabels)
+	maxReplicasSaturatedGauge.DeletePartialMatch(policyRecoLabels)
+	dataQualityScoreGauge.DeletePartialMatch(policyRecoLabels)
 }
 
 var unableToRecommendError = errors.New("Unable to generate recommendation without any breaches.")
@@ -59,6 +84,12 @@ type CpuUtilizationBasedRecommender struct {
 	metricsPercentageThreshold int
 	clientsRegistry            registry.DeploymentClientRegistry
 	autoscalerClient           autoscaler.AutoscalerClient
+	preScaler                  *PreScaler
+	minReplicaWindowPlanner    *MinReplicaWindowPlanner
+	robustnessChecker          *RobustnessChecker
+	maxReplicaRecommender      *MaxReplicaRecommender
+	rightsizingRecommender     *RightsizingRecommender
+	resourceNormalizer         *ResourceNormalizer
 	logger                     logr.Logger
 }
 
@@ -73,6 +104,12 @@ func NewCpuUtilizationBasedRecommender(k8sClient client.Client,
 	metricsPercentageThreshold int,
 	clientsRegistry registry.DeploymentClientRegistry,
 	autoscalerClient autoscaler.AutoscalerClient,
+	preScaler *PreScaler,
+	minReplicaWindowPlanner *MinReplicaWindowPlanner,
+	robustnessChecker *RobustnessChecker,
+	maxReplicaRecommender *MaxReplicaRecommender,
+	rightsizingRecommender *RightsizingRecommender,
+	resourceNormalizer *ResourceNormalizer,
 	logger logr.Logger) *CpuUtilizationBasedRecommender {
 	return &CpuUtilizationBasedRecommender{
 		k8sClient:                  k8sClient,
@@ -86,18 +123,32 @@ func NewCpuUtilizationBasedRecommender(k8sClient client.Client,
 		metricsPercentageThreshold: metricsPercentageThreshold,
 		clientsRegistry:            clientsRegistry,
 		autoscalerClient:           autoscalerClient,
+		preScaler:                  preScaler,
+		minReplicaWindowPlanner:    minReplicaWindowPlanner,
+		robustnessChecker:          robustnessChecker,
+		maxReplicaRecommender:      maxReplicaRecommender,
+		rightsizingRecommender:     rightsizingRecommender,
+		resourceNormalizer:         resourceNormalizer,
 		logger:                     logger,
 	}
 }
 
 func (c *CpuUtilizationBasedRecommender) Recommend(ctx context.Context, workloadMeta WorkloadMeta) (*v1alpha1.HPAConfiguration,
 	error) {
+	return c.recommend(ctx, workloadMeta, nil)
+}
+
+// recommend generates the recommendation by simulating the HPA on the utilization of the metric window. If projection
+// is set, the HPA is simulated on the series it derives from the utilization instead.
+func (c *CpuUtilizationBasedRecommender) recommend(ctx context.Context, workloadMeta WorkloadMeta,
+	projection func(dataPoints []metrics.DataPoint) []metrics.DataPoint) (*v1alpha1.HPAConfiguration, error) {
 
 	end := time.Now()
 	start := end.Add(-c.metricWindow)
 
 	utilizationQueryStartTime := time.Now()
-	dataPoints, err := c.scraper.GetAverageCPUUtilizationByWorkload(workloadMeta.Namespace,
+	dataPoints, err := c.scraper.GetAverageCPUUtilizationByWorkload(ctx, workloadMeta.Namespace,
+		workloadMeta.Kind,
 		workloadMeta.Name,
 		start,
 		end,
@@ -123,42 +174,189 @@ func (c *CpuUtilizationBasedRecommender) Recommend(ctx context.Context, workload
 	}
 	minPercentageOfDataPointsPresent.WithLabelValues(workloadMeta.Namespace, workloadMeta.Name).Set(float64(1))
 
+	observeStage := func(stage string, stageStartTime time.Time) {
+		recoStageLatency.WithLabelValues(workloadMeta.Namespace, workloadMeta.Name, workloadMeta.Kind,
+			workloadMeta.Name, stage).Observe(time.Since(stageStartTime).Seconds())
+	}
+
+	stageStartTime := time.Now()
+	if c.resourceNormalizer != nil {
+		var normalization *v1alpha1.ResourceNormalization
+		dataPoints, normalization, err = c.resourceNormalizer.normalize(ctx, workloadMeta, start, end, dataPoints,
+			c.isMetricsAboveThreshold)
+		if err != nil {
+			c.logger.Error(err, "Error while normalizing the utilization to the pod resources")
+			return nil, err
+		}
+		if normalization != nil {
+			c.logger.V(1).Info("Normalized the utilization to the current pod resources", "workload",
+				workloadMeta.Name, "strategy", normalization.Strategy, "changedAt", normalization.ChangedAt)
+		}
+		if err := c.resourceNormalizer.publishNormalization(ctx, workloadMeta, normalization); err != nil {
+			c.logger.Error(err, "Error while publishing the resource normalization", "workload", workloadMeta.Name)
+		}
+		observeStage("normalize", stageStartTime)
+	}
+
+	stageStartTime = time.Now()
 	if c.metricsTransformer != nil {
+		var ignored []metrics.IgnoredInterval
+		audited := false
 		for _, transformers := range c.metricsTransformer {
-			dataPoints, err = transformers.Transform(start, end, dataPoints)
+			if workloadTransformer, ok := transformers.(metrics.WorkloadMetricsTransformer); ok {
+				var ignoredByTransformer []metrics.IgnoredInterval
+				dataPoints, ignoredByTransformer, err = workloadTransformer.TransformWorkloadAndAudit(
+					workloadMeta.Namespace, workloadMeta.Kind, workloadMeta.Name, start, end, dataPoints)
+				ignored = append(ignored, ignoredByTransformer...)
+				audited = true
+			} else if auditedTransformer, ok := transformers.(metrics.AuditedMetricsTransformer); ok {
+				var ignoredByTransformer []metrics.IgnoredInterval
+				dataPoints, ignoredByTransformer, err = auditedTransformer.TransformAndAudit(start, end, dataPoints)
+				ignored = append(ignored, ignoredByTransformer...)
+				audited = true
+			} else {
+				dataPoints, err = transformers.Transform(start, end, dataPoints)
+			}
 			if err != nil {
 				c.logger.Error(err, "Error while getting outlier interval from event api")
 				return nil, err
 			}
 		}
+		if audited {
+			sort.SliceStable(ignored, func(i, j int) bool {
+				return ignored[i].StartTime.Before(ignored[j].StartTime)
+			})
+			if err := c.publishIgnoredIntervals(ctx, workloadMeta, ignored); err != nil {
+				c.logger.Error(err, "Error while publishing the ignored intervals", "workload", workloadMeta.Name)
+			}
+		}
 	}
 
-	acl, err := c.scraper.GetACLByWorkload(workloadMeta.Namespace, workloadMeta.Name)
+	observeStage("transform", stageStartTime)
+
+	if projection != nil {
+		stageStartTime = time.Now()
+		dataPoints = projection(dataPoints)
+		observeStage("projection", stageStartTime)
+	}
+
+	stageStartTime = time.Now()
+	acl, err := c.scraper.GetACLByWorkload(ctx, workloadMeta.Namespace, workloadMeta.Kind, workloadMeta.Name)
 	if err != nil {
 		c.logger.Error(err, "Error while getting GetACL.")
 		return nil, err
 	}
+	observeStage("acl", stageStartTime)
 
 	perPodResources, err := c.getContainerCPULimitsSum(workloadMeta.Namespace, workloadMeta.Kind, workloadMeta.Name)
 	if err != nil {
 		c.logger.Error(err, "Error while getting getContainerCPULimitsSum")
 		return nil, err
 	}
+	cpuRequests, err := c.getContainerCPURequestsSum(workloadMeta.Namespace, workloadMeta.Kind, workloadMeta.Name)
+	if err != nil {
+		c.logger.V(1).Info("Simulating the HPA in the unit of the pod's capacity as its cpu requests are unknown",
+			"workload", workloadMeta.Name, "error", err.Error())
+	}
+	sim, perPodResources := c.inRequestUnits(perPodResources, cpuRequests)
+
+	if c.maxReplicaRecommender != nil {
+		stageStartTime = time.Now()
+		reachedMax, err := c.maxReplicaRecommender.replicasReachedMax(ctx, workloadMeta, start, end, c.metricStep)
+		if err != nil {
+			c.logger.Error(err, "Error while scraping the intervals at the max replicas", "workload", workloadMeta.Name)
+		}
+		maxReplicas := c.maxReplicaRecommender.recommendMax(dataPoints, perPodResources, sim.redLineUtil,
+			workloadMaxReplicas, reachedMax)
+		if err := c.maxReplicaRecommender.publishMaxReplicas(ctx, workloadMeta, maxReplicas,
+			workloadMaxReplicas); err != nil {
+			c.logger.Error(err, "Error while publishing the recommended max replicas", "workload", workloadMeta.Name)
+		}
+		if maxReplicas.max != workloadMaxReplicas {
+			c.logger.V(1).Info("Raising the max replicas", "workload", workloadMeta.Name,
+				"from", workloadMaxReplicas, "to", maxReplicas.max)
+			workloadMaxReplicas = maxReplicas.max
+		}
+		observeStage("max_replicas", stageStartTime)
+	}
 
-	optimalTargetUtil, minReplicas, maxReplicas, err := c.findOptimalHPAConfigurations(dataPoints,
+	stageStartTime = time.Now()
+	optimal, err := sim.findOptimalHPAConfigurationWithSchedule(dataPoints,
 		acl,
-		c.minTarget,
-		c.maxTarget,
-		perPodResources, workloadMaxReplicas)
+		sim.minTarget,
+		sim.maxTarget,
+		perPodResources, workloadMaxReplicas, nil)
+	observeStage("search", stageStartTime)
+	if c.preScaler != nil && (err == nil || errors.Is(err, unableToRecommendError)) {
+		// Pre-scaling is recommended only if the replicas it schedules pay for themselves.
+		stageStartTime = time.Now()
+		if schedule := c.preScaler.schedule(dataPoints, perPodResources, sim.redLineUtil, acl, workloadMaxReplicas); schedule != nil {
+			preScaled, preScaleErr := sim.findOptimalHPAConfigurationWithSchedule(dataPoints,
+				acl,
+				sim.minTarget,
+				sim.maxTarget,
+				perPodResources, workloadMaxReplicas, schedule)
+			if preScaleErr == nil && (err != nil || preScaled.savings > optimal.savings) {
+				optimal, err = preScaled, nil
+			}
+		}
+		observeStage("pre_scale", stageStartTime)
+	}
 	if err != nil {
 		if errors.Is(err, unableToRecommendError) {
-			return &v1alpha1.HPAConfiguration{Min: workloadMaxReplicas, Max: workloadMaxReplicas, TargetMetricValue: c.minTarget}, nil
+			return &v1alpha1.HPAConfiguration{Min: workloadMaxReplicas, Max: workloadMaxReplicas, TargetMetricValue: sim.minTarget}, nil
 		}
 		c.logger.Error(err, "Error while executing findOptimalTargetUtilization")
 		return nil, err
 	}
+	if c.minReplicaWindowPlanner != nil {
+		stageStartTime = time.Now()
+		optimal, err = sim.planMinReplicaWindows(dataPoints, acl, perPodResources, optimal)
+		if err != nil {
+			c.logger.Error(err, "Error while planning the min replica windows")
+			return nil, err
+		}
+		observeStage("min_replica_windows", stageStartTime)
+	}
+	if c.robustnessChecker != nil {
+		stageStartTime = time.Now()
+		var checks []v1alpha1.RobustnessCheck
+		optimal, checks, err = sim.checkRobustness(dataPoints, acl, perPodResources, optimal)
+		if err != nil && !errors.Is(err, unableToRecommendError) {
+			c.logger.Error(err, "Error while checking the robustness of the recommendation")
+			return nil, err
+		}
+		if publishErr := c.robustnessChecker.publishRobustnessChecks(ctx, workloadMeta, checks); publishErr != nil {
+			c.logger.Error(publishErr, "Error while publishing the robustness checks", "workload", workloadMeta.Name)
+		}
+		observeStage("robustness", stageStartTime)
+		if err != nil {
+			return &v1alpha1.HPAConfiguration{Min: workloadMaxReplicas, Max: workloadMaxReplicas, TargetMetricValue: sim.minTarget}, nil
+		}
+	}
 
-	return &v1alpha1.HPAConfiguration{Min: minReplicas, Max: maxReplicas, TargetMetricValue: optimalTargetUtil}, nil
+	hpaConfiguration := &v1alpha1.HPAConfiguration{Min: optimal.min, Max: optimal.max, TargetMetricValue: optimal.targetUtilization}
+	if optimal.schedule != nil {
+		hpaConfiguration.PreScaleSchedules = optimal.schedule.replicaSchedules()
+	}
+	if optimal.minReplicaWindows != nil {
+		hpaConfiguration.MinReplicaWindows = optimal.minReplicaWindows.replicaSchedules()
+	}
+	if c.rightsizingRecommender != nil && projection == nil {
+		// The vertical recommendation is advisory, it never holds back the HPA configuration.
+		stageStartTime = time.Now()
+		verticalRecommendation, err := c.recommendRightsizing(ctx, workloadMeta, dataPoints, acl, end, workloadMaxReplicas)
+		if err != nil {
+			c.logger.Error(err, "Error while recommending the container resources", "workload", workloadMeta.Name)
+		}
+		if verticalRecommendation != nil {
+			if err := c.publishRightsizing(ctx, workloadMeta, verticalRecommendation); err != nil {
+				c.logger.Error(err, "Error while publishing the vertical recommendation", "workload", workloadMeta.Name)
+			}
+		}
+		observeStage("rightsizing", stageStartTime)
+	}
+	return hpaConfiguration, nil
 }
 
 type TimerEvent struct {
@@ -177,20 +375,41 @@ func (c *CpuUtilizationBasedRecommender) simulateHPA(dataPoints []metrics.DataPo
 	acl time.Duration,
 	targetUtilization int,
 	perPodResources float64, maxReplicas int, minReplicas int) ([]metrics.DataPoint, int, error) {
+	return c.simulateHPAWithSchedule(dataPoints, acl, targetUtilization, perPodResources, maxReplicas, minReplicas)
+}
+
+// simulateHPAWithSchedule simulates the HPA like simulateHPA. The replicas scheduled by the schedules raise the
+// minimum replicas while the schedules are active.
+func (c *CpuUtilizationBasedRecommender) simulateHPAWithSchedule(dataPoints []metrics.DataPoint,
+	acl time.Duration,
+	targetUtilization int,
+	perPodResources float64, maxReplicas int, minReplicas int,
+	schedules ...*preScaleSchedule) ([]metrics.DataPoint, int, error) {
 
 	targetUtilization = int(math.Floor(float64(targetUtilization) * 1.1))
 
 	if len(dataPoints) == 0 {
 		return []metrics.DataPoint{}, 0, nil
 	}
-	if targetUtilization < 1 || targetUtilization > 100 {
+	if targetUtilization < 1 || targetUtilization > maxScaledTarget(c.redLineUtil) {
 		return []metrics.DataPoint{}, 0, errors.New(fmt.Sprintf("Invalid value of target utilization: %v."+
-			" Value should be between 1 and 100", targetUtilization))
+			" Value should be between 1 and %v", targetUtilization, maxScaledTarget(c.redLineUtil)))
 	}
 
 	simulatedDataPoints := make([]metrics.DataPoint, len(dataPoints))
 
-	currentReplicas := math.Min(float64(maxReplicas), math.Max(float64(minReplicas), math.Ceil((dataPoints[0].Value*100)/float64(targetUtilization)/perPodResources)))
+	// The scheduled replicas go through the same autoscaling cycle lag as the reactive scale ups.
+	minReplicasAt := func(t time.Time) float64 {
+		replicas := minReplicas
+		for _, schedule := range schedules {
+			if schedule != nil && schedule.replicasAt(t) > replicas {
+				replicas = schedule.replicasAt(t)
+			}
+		}
+		return float64(replicas)
+	}
+
+	currentReplicas := math.Min(float64(maxReplicas), math.Max(minReplicasAt(dataPoints[0].Timestamp), math.Ceil((dataPoints[0].Value*100)/float64(targetUtilization)/perPodResources)))
 	calculatedMinReplicas := math.Ceil((dataPoints[0].Value * 100) / float64(targetUtilization) / perPodResources)
 	currentResources := currentReplicas * perPodResources
 	readyResources := currentResources
@@ -208,7 +427,7 @@ func (c *CpuUtilizationBasedRecommender) simulateHPA(dataPoints []metrics.DataPo
 			readyResources += readyResourcesTimerList[0].Delta
 			readyResourcesTimerList = readyResourcesTimerList[1:]
 		}
-		newReplicas := math.Min(float64(maxReplicas), math.Max(float64(minReplicas), math.Ceil((100*dp.Value)/float64(targetUtilization)/perPodResources)))
+		newReplicas := math.Min(float64(maxReplicas), math.Max(minReplicasAt(dp.Timestamp), math.Ceil((100*dp.Value)/float64(targetUtilization)/perPodResources)))
 		calculatedMinReplicas = math.Min(calculatedMinReplicas, math.Ceil((100*dp.Value)/float64(targetUtilization)/perPodResources))
 
 		newResources := newReplicas * perPodResources
@@ -253,49 +472,20 @@ func (c *CpuUtilizationBasedRecommender) findOptimalHPAConfigurations(dataPoints
 	minTarget,
 	maxTarget int,
 	perPodResources float64, maxReplicas int) (int, int, int, error) {
+	optimal, err := c.findOptimalHPAConfigurationWithSchedule(dataPoints, acl, minTarget, maxTarget, perPodResources,
+		maxReplicas, nil)
+	return optimal.targetUtilization, optimal.min, optimal.max, err
+}
 
-	optimalTargetThreshold := 0
-	optimalMin := 0
-	savings := 0.0
-
-	minReplicas := 1
-	for ; minReplicas <= maxReplicas; minReplicas++ {
-		calculatedMin := 0
-		low := minTarget
-		high := maxTarget
-		var simulatedHPAList []metrics.DataPoint
-		for low <= high {
-			mid := low + (high-low)/2
-			target := mid
-			var err error
-			simulatedHPAList, calculatedMin, err = c.simulateHPA(dataPoints, acl, target, perPodResources, maxReplicas, minReplicas)
-			if err != nil {
-				c.logger.Error(err, "Error while simulating HPA")
-				return -1, minReplicas, maxReplicas, err
-			}
-
-			if c.hasNoBreachOccurred(dataPoints, simulatedHPAList) {
-				low = mid + 1
-			} else {
-				high = mid - 1
-			}
-		}
-		if high >= minTarget && calculatedMin <= minReplicas {
-			if len(simulatedHPAList) > 0 {
-				newSavings := c.calculateSavings(maxReplicas, simulatedHPAList, perPodResources)
-				if newSavings >= savings {
-					optimalMin = minReplicas
-					optimalTargetThreshold = high
-					savings = newSavings
-				}
-			}
-		}
-	}
-
-	if optimalTargetThreshold < minTarget || savings == 0.0 {
-		return 0, 0, 0, unableToRecommendError
-	}
-	return optimalTargetThreshold, optimalMin, maxReplicas, nil
+// optimalHPAConfiguration is the HPA configuration with the highest savings found by the simulation, along with the
+// pre-scale schedule and the min replica windows it was simulated with.
+type optimalHPAConfiguration struct {
+	targetUtilization int
+	min               int
+	max               int
+	savings           float64
+	schedule          *preScaleSchedule
+	minReplicaWindows *preScaleSchedule
 }
 
 func (c *CpuUtilizationBasedRecommender) calculateSavings(maxReplicas int, simulated []metrics.DataPoint, perPodResources float64) float64 {
@@ -323,6 +513,37 @@ func (c *CpuUtilizationBasedRecommender) getContainerCPULimitsSum(namespace, obj
 	return cpuLimitsSum, nil
 }
 
+func (c *CpuUtilizationBasedRecommender) getContainerCPURequestsSum(namespace, objectKind, objectName string) (float64,
+	error) {
+	deploymentClient, err := c.clientsRegistry.GetObjectClient(objectKind)
+	if err != nil {
+		return 0, fmt.Errorf("unsupported objectKind: %s", objectKind)
+	}
+	return deploymentClient.GetContainerResourceRequests(namespace, objectName)
+}
+
+// maxScaledTarget returns the highest scaled target utilization, 100 or the red line utilization if it's higher, as it
+// is when the HPA is simulated in the unit of the CPU requests of pods whose capacity exceeds them.
+func maxScaledTarget(redLineUtil float64) int {
+	return int(math.Max(100, math.Floor(100*redLineUtil)))
+}
+
+// inRequestUnits returns the recommender simulating the HPA in the unit of the CPU requests of the pods, along with the
+// size of the pods in that unit. The HPA's target utilization is relative to the CPU requests of the pods while the red
+// line utilization and the bounds of the target utilization are relative to their capacity, so they are scaled by the
+// ratio of the two. The recommender is returned as is if the pods have no CPU requests.
+func (c *CpuUtilizationBasedRecommender) inRequestUnits(capacity, cpuRequests float64) (*CpuUtilizationBasedRecommender,
+	float64) {
+	if cpuRequests <= 0 || cpuRequests == capacity {
+		return c, capacity
+	}
+	scaled := *c
+	scaled.redLineUtil = c.redLineUtil * capacity / cpuRequests
+	scaled.minTarget = int(math.Round(float64(c.minTarget) * capacity / cpuRequests))
+	scaled.maxTarget = int(math.Round(float64(c.maxTarget) * capacity / cpuRequests))
+	return &scaled, cpuRequests
+}
+
 func (c *CpuUtilizationBasedRecommender) getMaxPods(namespace string, objectKind string, objectName string) (int, error) {
 	deploymentClient, err := c.clientsRegistry.GetObjectClient(objectKind)
 	if err != nil {
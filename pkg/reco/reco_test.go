@@ -1197,7 +1197,7 @@ var _ = Describe("CpuUtilizationBasedRecommender", func() {
 			totalDataPoints := int(recommender3.metricWindow.Seconds()) / int(recommender3.metricStep.Seconds())
 			Expect(totalDataPoints).To(Equal(80640))
 
-			dataPoints, _ := recommender3.scraper.GetAverageCPUUtilizationByWorkload(deploymentName, deploymentName, time.Now(), time.Now(), recommender3.metricStep)
+			dataPoints, _ := recommender3.scraper.GetAverageCPUUtilizationByWorkload(context.TODO(), deploymentName, "Deployment", deploymentName, time.Now(), time.Now(), recommender3.metricStep)
 			Expect(len(dataPoints)).To(Equal(5))
 			percentageOfDataPointsFetched := (float64(len(dataPoints)) / float64(totalDataPoints)) * 100
 			Expect(percentageOfDataPointsFetched).To(Equal(0.006200396825396825))
@@ -1210,4 +1210,50 @@ var _ = Describe("CpuUtilizationBasedRecommender", func() {
 		})
 
 	})
+
+	Describe("inRequestUnits", func() {
+		It("should simulate the HPA on the CPU requests with the red line on the capacity of the pods", func() {
+			c := &CpuUtilizationBasedRecommender{redLineUtil: 0.85, logger: logger}
+			sim, perPodResources := c.inRequestUnits(2, 1)
+			Expect(perPodResources).To(Equal(1.0))
+			Expect(sim.redLineUtil).To(BeNumerically("~", 1.7, 1e-9))
+			Expect(c.redLineUtil).To(Equal(0.85))
+
+			start := time.Date(2023, time.May, 1, 0, 0, 0, 0, time.UTC)
+			dataPoints := []metrics.DataPoint{
+				{Timestamp: start, Value: 6},
+				{Timestamp: start.Add(time.Minute), Value: 6},
+			}
+			simulated, _, err := sim.simulateHPA(dataPoints, time.Minute, 60, perPodResources, 20, 1)
+			Expect(err).NotTo(HaveOccurred())
+			// The HPA targeting 60% of the CPU requests runs 10 pods with 2 cores of capacity each.
+			Expect(simulated[0].Value).To(BeNumerically("~", 17, 1e-9))
+		})
+
+		It("should recommend a target above the CPU requests of pods whose limits exceed them", func() {
+			c := &CpuUtilizationBasedRecommender{redLineUtil: 0.85, minTarget: 10, maxTarget: 60, logger: logger}
+			sim, perPodResources := c.inRequestUnits(2, 1)
+			Expect(sim.minTarget).To(Equal(20))
+			Expect(sim.maxTarget).To(Equal(120))
+
+			start := time.Date(2023, time.May, 1, 0, 0, 0, 0, time.UTC)
+			var dataPoints []metrics.DataPoint
+			for i := 0; i < 60; i++ {
+				dataPoints = append(dataPoints, metrics.DataPoint{Timestamp: start.Add(time.Duration(i) * time.Minute),
+					Value: 6})
+			}
+			optimal, err := sim.findOptimalHPAConfigurationWithSchedule(dataPoints, time.Minute, sim.minTarget,
+				sim.maxTarget, perPodResources, 20, nil)
+			Expect(err).NotTo(HaveOccurred())
+			// The red line is at 170% of the CPU requests, so the target isn't capped at 100%.
+			Expect(optimal.targetUtilization).To(BeNumerically(">", 100))
+		})
+
+		It("should keep the unit of the capacity of the pods without CPU requests", func() {
+			c := &CpuUtilizationBasedRecommender{redLineUtil: 0.85, logger: logger}
+			sim, perPodResources := c.inRequestUnits(2, 0)
+			Expect(sim).To(BeIdenticalTo(c))
+			Expect(perPodResources).To(Equal(2.0))
+		})
+	})
 })
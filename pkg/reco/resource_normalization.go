@@ -0,0 +1,149 @@
+package reco
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
+	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	ResourceNormalizationStatusManager = "ResourceNormalizationStatusManager"
+
+	defaultResourceNormalizationMinChange = 0.05
+	defaultResourceNormalizationStep      = 5 * time.Minute
+)
+
+// ResourceNormalizer normalizes the utilization recorded before the CPU resources of a workload's pods last changed
+// within the metric window, as the HPA is simulated on the current size of the pods throughout the window. The
+// changes are detected from the history of the average CPU limits of the pods, as limits differing from the current
+// ones by more than minChange.
+//
+// The Restrict strategy restricts the metric window to the utilization recorded since the last change, falling back to
+// Rescale if too little of the window is left. The Rescale strategy multiplies the utilization recorded by pods of
+// other limits by the ratio of the current limits to theirs, keeping the utilization of the pods, i.e. it takes the
+// load a pod serves to grow with its size.
+type ResourceNormalizer struct {
+	k8sClient client.Client
+	scraper   metrics.PodResourceScraper
+	strategy  v1alpha1.ResourceNormalizationStrategy
+	minChange float64
+	step      time.Duration
+}
+
+// NewResourceNormalizer returns a ResourceNormalizer which samples the CPU limits of the pods every step and publishes
+// the normalization to the status of the policy recommendations. The zero values pick the defaults.
+func NewResourceNormalizer(k8sClient client.Client,
+	scraper metrics.PodResourceScraper,
+	strategy v1alpha1.ResourceNormalizationStrategy,
+	minChange float64,
+	step time.Duration) *ResourceNormalizer {
+	if strategy != v1alpha1.ResourceNormalizationRescale {
+		strategy = v1alpha1.ResourceNormalizationRestrict
+	}
+	if minChange <= 0 {
+		minChange = defaultResourceNormalizationMinChange
+	}
+	if step <= 0 {
+		step = defaultResourceNormalizationStep
+	}
+	return &ResourceNormalizer{
+		k8sClient: k8sClient,
+		scraper:   scraper,
+		strategy:  strategy,
+		minChange: minChange,
+		step:      step,
+	}
+}
+
+// normalize returns the data points normalized to the current CPU limits of the workload's pods, along with the
+// normalization applied, which is nil if the limits didn't change within the window. The window is only restricted if
+// sufficient considers the data points left sufficient for a recommendation.
+func (n *ResourceNormalizer) normalize(ctx context.Context,
+	workloadMeta WorkloadMeta,
+	start time.Time,
+	end time.Time,
+	dataPoints []metrics.DataPoint,
+	sufficient func([]metrics.DataPoint) bool) ([]metrics.DataPoint, *v1alpha1.ResourceNormalization, error) {
+
+	limits, err := n.scraper.GetPodCPULimitsByWorkload(ctx, workloadMeta.Namespace, workloadMeta.Kind, workloadMeta.Name,
+		start, end, n.step)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(limits) == 0 {
+		return dataPoints, nil, nil
+	}
+	current := limits[len(limits)-1].Value
+	if current <= 0 {
+		return dataPoints, nil, nil
+	}
+	changed := func(limit float64) bool {
+		return limit > 0 && math.Abs(limit-current) > n.minChange*current
+	}
+
+	lastChanged := -1
+	for i, limit := range limits {
+		if changed(limit.Value) {
+			lastChanged = i
+		}
+	}
+	if lastChanged < 0 {
+		return dataPoints, nil, nil
+	}
+	normalization := &v1alpha1.ResourceNormalization{
+		Strategy:    v1alpha1.ResourceNormalizationRescale,
+		ChangedAt:   metav1.NewTime(limits[lastChanged+1].Timestamp.Truncate(time.Second)),
+		PreviousCPU: cpuQuantity(limits[lastChanged].Value),
+		CurrentCPU:  cpuQuantity(current),
+	}
+
+	if n.strategy == v1alpha1.ResourceNormalizationRestrict {
+		var restricted []metrics.DataPoint
+		for _, dp := range dataPoints {
+			if !dp.Timestamp.Before(limits[lastChanged+1].Timestamp) {
+				restricted = append(restricted, dp)
+			}
+		}
+		if sufficient(restricted) {
+			normalization.Strategy = v1alpha1.ResourceNormalizationRestrict
+			return restricted, normalization, nil
+		}
+	}
+
+	// The data points take the limits of the latest sample at or before them, and those before the first sample the
+	// limits of the first sample.
+	rescaled := make([]metrics.DataPoint, len(dataPoints))
+	i := 0
+	for j, dp := range dataPoints {
+		for i+1 < len(limits) && !limits[i+1].Timestamp.After(dp.Timestamp) {
+			i++
+		}
+		rescaled[j] = dp
+		if changed(limits[i].Value) {
+			rescaled[j].Value = dp.Value * current / limits[i].Value
+		}
+	}
+	return rescaled, normalization, nil
+}
+
+// cpuQuantity returns the quantity of the CPU in cores, rounded to millicores.
+func cpuQuantity(cores float64) resource.Quantity {
+	return *resource.NewMilliQuantity(int64(math.Round(cores*1000)), resource.DecimalSI)
+}
+
+// publishNormalization applies the normalization to the status of the workload's policy recommendation. A nil
+// normalization clears the one of a former recommendation.
+func (n *ResourceNormalizer) publishNormalization(ctx context.Context, workloadMeta WorkloadMeta,
+	normalization *v1alpha1.ResourceNormalization) error {
+	if n.k8sClient == nil {
+		return nil
+	}
+	return applyPolicyRecoStatus(ctx, n.k8sClient, workloadMeta,
+		v1alpha1.PolicyRecommendationStatus{ResourceNormalization: normalization}, ResourceNormalizationStatusManager)
+}
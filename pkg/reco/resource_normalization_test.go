@@ -0,0 +1,81 @@
+package reco
+
+import (
+	"context"
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
+	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakePodResourceScraper returns the CPU limits of the pods as they are.
+type fakePodResourceScraper struct {
+	limits []metrics.DataPoint
+}
+
+func (fs *fakePodResourceScraper) GetPodCPULimitsByWorkload(ctx context.Context, namespace, workloadType, workload string,
+	start time.Time, end time.Time, step time.Duration) ([]metrics.DataPoint, error) {
+	return fs.limits, nil
+}
+
+var _ = Describe("ResourceNormalizer", func() {
+
+	var (
+		workloadMeta = WorkloadMeta{Name: "workload", Namespace: "default"}
+		start        = time.Date(2023, time.May, 1, 0, 0, 0, 0, time.UTC)
+		end          = start.Add(10 * time.Hour)
+		changedAt    = start.Add(6 * time.Hour)
+	)
+
+	// series returns the data points sampled every hour, at before until changedAt and at after since.
+	series := func(before, after float64) []metrics.DataPoint {
+		var dataPoints []metrics.DataPoint
+		for t := start; t.Before(end); t = t.Add(time.Hour) {
+			value := after
+			if t.Before(changedAt) {
+				value = before
+			}
+			dataPoints = append(dataPoints, metrics.DataPoint{Timestamp: t, Value: value})
+		}
+		return dataPoints
+	}
+
+	atLeast := func(n int) func([]metrics.DataPoint) bool {
+		return func(dataPoints []metrics.DataPoint) bool { return len(dataPoints) >= n }
+	}
+
+	It("should restrict the window to the utilization since the last change of the limits", func() {
+		n := NewResourceNormalizer(nil, &fakePodResourceScraper{limits: series(1, 2)}, "", 0, 0)
+		normalized, normalization, err := n.normalize(context.TODO(), workloadMeta, start, end, series(8, 12), atLeast(4))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(normalized).To(HaveLen(4))
+		Expect(normalized[0].Timestamp).To(Equal(changedAt))
+		Expect(normalization.Strategy).To(Equal(v1alpha1.ResourceNormalizationRestrict))
+		Expect(normalization.ChangedAt.Time).To(BeTemporally("==", changedAt))
+		Expect(normalization.PreviousCPU.MilliValue()).To(Equal(int64(1000)))
+		Expect(normalization.CurrentCPU.MilliValue()).To(Equal(int64(2000)))
+	})
+
+	It("should rescale the utilization before the change if too little of the window is left", func() {
+		n := NewResourceNormalizer(nil, &fakePodResourceScraper{limits: series(1, 2)}, "", 0, 0)
+		normalized, normalization, err := n.normalize(context.TODO(), workloadMeta, start, end, series(8, 12), atLeast(5))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(normalization.Strategy).To(Equal(v1alpha1.ResourceNormalizationRescale))
+		Expect(normalized).To(Equal(series(16, 12)))
+	})
+
+	It("should leave the utilization untouched if the limits changed less than the min change", func() {
+		n := NewResourceNormalizer(nil, &fakePodResourceScraper{limits: series(1.96, 2)},
+			v1alpha1.ResourceNormalizationRescale, 0, 0)
+		dataPoints := series(8, 12)
+		normalized, normalization, err := n.normalize(context.TODO(), workloadMeta, start, end, dataPoints, atLeast(1))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(normalization).To(BeNil())
+		Expect(normalized).To(Equal(dataPoints))
+	})
+})
@@ -0,0 +1,239 @@
+package reco
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
+	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	RightsizingStatusManager = "RightsizingStatusManager"
+
+	defaultRightsizingRequestQuantile = 0.9
+	defaultRightsizingLimitQuantile   = 0.99
+	defaultRightsizingHeadroom        = 0.15
+	defaultRightsizingMinChange       = 0.1
+
+	// minContainerCPUMillis is the lowest CPU request recommended for a container.
+	minContainerCPUMillis = 10
+)
+
+// RightsizingRecommender recommends the CPU request and limit of every container of a workload from the quantiles of
+// its CPU usage: the request covers the requestQuantile of the usage plus headroom, and the limit keeps the
+// limitQuantile of the usage at the red line utilization. As the HPA configuration depends on the size of the pods, the
+// HPA configuration is recalculated for pods of the recommended size, keeping the total capacity of the max replicas.
+//
+// The recommendation is only published to the status of the policy recommendation, unless enforce is set, in which case
+// the recommended resources are applied to the workload whenever they differ from its resources by more than
+// minChange. The HPA configuration follows the new size of the pods from the next recommendation onwards.
+type RightsizingRecommender struct {
+	scraper         metrics.ContainerUsageScraper
+	requestQuantile float64
+	limitQuantile   float64
+	headroom        float64
+	minChange       float64
+	enforce         bool
+}
+
+// NewRightsizingRecommender returns a RightsizingRecommender. The zero values pick the defaults.
+func NewRightsizingRecommender(scraper metrics.ContainerUsageScraper,
+	requestQuantile float64,
+	limitQuantile float64,
+	headroom float64,
+	minChange float64,
+	enforce bool) *RightsizingRecommender {
+	if requestQuantile <= 0 || requestQuantile > 1 {
+		requestQuantile = defaultRightsizingRequestQuantile
+	}
+	if limitQuantile <= 0 || limitQuantile > 1 {
+		limitQuantile = defaultRightsizingLimitQuantile
+	}
+	if headroom <= 0 {
+		headroom = defaultRightsizingHeadroom
+	}
+	if minChange <= 0 {
+		minChange = defaultRightsizingMinChange
+	}
+	return &RightsizingRecommender{
+		scraper:         scraper,
+		requestQuantile: requestQuantile,
+		limitQuantile:   limitQuantile,
+		headroom:        headroom,
+		minChange:       minChange,
+		enforce:         enforce,
+	}
+}
+
+// recommendContainers returns the CPU resources recommended for the containers of the pod template from their usage,
+// along with the sum of the CPU limits of the pod template before and after the recommendation and the sum of its CPU
+// requests after the recommendation. The containers without usage keep their resources and are left out of the
+// recommendation.
+func (r *RightsizingRecommender) recommendContainers(template *corev1.PodTemplateSpec,
+	requestUsage map[string]float64,
+	limitUsage map[string]float64,
+	redLineUtil float64) ([]v1alpha1.ContainerResourceRecommendation, float64, float64, float64) {
+
+	var containers []v1alpha1.ContainerResourceRecommendation
+	currentLimits, recommendedLimits, recommendedRequests := 0.0, 0.0, 0.0
+	for _, container := range template.Spec.Containers {
+		currentRequest, hasRequest := container.Resources.Requests[corev1.ResourceCPU]
+		currentLimit, hasLimit := container.Resources.Limits[corev1.ResourceCPU]
+		if hasLimit {
+			currentLimits += float64(currentLimit.MilliValue()) / 1000
+		}
+		usage, ok := requestUsage[container.Name]
+		if !ok {
+			if hasLimit {
+				recommendedLimits += float64(currentLimit.MilliValue()) / 1000
+			}
+			if hasRequest {
+				recommendedRequests += float64(currentRequest.MilliValue()) / 1000
+			}
+			continue
+		}
+
+		requestMillis := int64(math.Ceil(usage * (1 + r.headroom) * 1000))
+		if requestMillis < minContainerCPUMillis {
+			requestMillis = minContainerCPUMillis
+		}
+		recommendedRequests += float64(requestMillis) / 1000
+		recommendation := v1alpha1.ContainerResourceRecommendation{
+			Name:                  container.Name,
+			RecommendedCPURequest: *resource.NewMilliQuantity(requestMillis, resource.DecimalSI),
+		}
+		if hasRequest {
+			recommendation.CurrentCPURequest = &currentRequest
+		}
+		if hasLimit {
+			recommendation.CurrentCPULimit = &currentLimit
+			limitMillis := requestMillis
+			if peak, ok := limitUsage[container.Name]; ok && redLineUtil > 0 {
+				if millis := int64(math.Ceil(peak / redLineUtil * 1000)); millis > limitMillis {
+					limitMillis = millis
+				}
+			}
+			recommendation.RecommendedCPULimit = resource.NewMilliQuantity(limitMillis, resource.DecimalSI)
+			recommendedLimits += float64(limitMillis) / 1000
+		}
+		containers = append(containers, recommendation)
+	}
+	return containers, currentLimits, recommendedLimits, recommendedRequests
+}
+
+// significantChange returns true if the recommended resources of any container differ from its resources by more than
+// minChange.
+func (r *RightsizingRecommender) significantChange(containers []v1alpha1.ContainerResourceRecommendation) bool {
+	changed := func(current *resource.Quantity, recommended *resource.Quantity) bool {
+		if recommended == nil {
+			return false
+		}
+		if current == nil || current.MilliValue() == 0 {
+			return true
+		}
+		return math.Abs(float64(recommended.MilliValue()-current.MilliValue()))/float64(current.MilliValue()) > r.minChange
+	}
+	for i := range containers {
+		if changed(containers[i].CurrentCPURequest, &containers[i].RecommendedCPURequest) ||
+			changed(containers[i].CurrentCPULimit, containers[i].RecommendedCPULimit) {
+			return true
+		}
+	}
+	return false
+}
+
+// recommendRightsizing recommends the CPU resources of the workload's containers and the HPA configuration for pods of
+// the recommended size, enforcing the resources if enabled.
+func (c *CpuUtilizationBasedRecommender) recommendRightsizing(ctx context.Context,
+	workloadMeta WorkloadMeta,
+	dataPoints []metrics.DataPoint,
+	acl time.Duration,
+	end time.Time,
+	workloadMaxReplicas int) (*v1alpha1.VerticalRecommendation, error) {
+
+	r := c.rightsizingRecommender
+	objectClient, err := c.clientsRegistry.GetObjectClient(workloadMeta.Kind)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported objectKind: %s", workloadMeta.Kind)
+	}
+	template, err := objectClient.GetPodTemplateSpec(workloadMeta.Namespace, workloadMeta.Name)
+	if err != nil {
+		return nil, err
+	}
+	requestUsage, err := r.scraper.GetContainerCPUUsageQuantile(ctx, workloadMeta.Namespace, workloadMeta.Kind,
+		workloadMeta.Name, r.requestQuantile, c.metricWindow, end)
+	if err != nil {
+		return nil, err
+	}
+	limitUsage, err := r.scraper.GetContainerCPUUsageQuantile(ctx, workloadMeta.Namespace, workloadMeta.Kind,
+		workloadMeta.Name, r.limitQuantile, c.metricWindow, end)
+	if err != nil {
+		return nil, err
+	}
+
+	containers, currentLimits, recommendedLimits, recommendedRequests := r.recommendContainers(template, requestUsage, limitUsage,
+		c.redLineUtil)
+	recommendation := &v1alpha1.VerticalRecommendation{
+		Containers:  containers,
+		GeneratedAt: metav1.Now(),
+	}
+	if len(containers) == 0 {
+		return recommendation, nil
+	}
+
+	if recommendedLimits > 0 {
+		maxReplicas := workloadMaxReplicas
+		if currentLimits > 0 {
+			maxReplicas = int(math.Ceil(float64(workloadMaxReplicas) * currentLimits / recommendedLimits))
+		}
+		sim, perPodResources := c.inRequestUnits(recommendedLimits, recommendedRequests)
+		optimal, err := sim.findOptimalHPAConfigurationWithSchedule(dataPoints,
+			acl,
+			sim.minTarget,
+			sim.maxTarget,
+			perPodResources, maxReplicas, nil)
+		if err != nil && !errors.Is(err, unableToRecommendError) {
+			return nil, err
+		}
+		if err == nil {
+			recommendation.Min = optimal.min
+			recommendation.Max = optimal.max
+			recommendation.TargetMetricValue = optimal.targetUtilization
+		}
+	}
+
+	if r.enforce && r.significantChange(containers) {
+		resources := make(map[string]corev1.ResourceRequirements, len(containers))
+		for _, container := range containers {
+			requirements := corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: container.RecommendedCPURequest},
+			}
+			if container.RecommendedCPULimit != nil {
+				requirements.Limits = corev1.ResourceList{corev1.ResourceCPU: *container.RecommendedCPULimit}
+			}
+			resources[container.Name] = requirements
+		}
+		if err := objectClient.SetContainerResources(workloadMeta.Namespace, workloadMeta.Name, resources); err != nil {
+			return recommendation, err
+		}
+		recommendation.Enforced = true
+	}
+	return recommendation, nil
+}
+
+// publishRightsizing applies the vertical recommendation to the status of the workload's policy recommendation.
+func (c *CpuUtilizationBasedRecommender) publishRightsizing(ctx context.Context, workloadMeta WorkloadMeta,
+	recommendation *v1alpha1.VerticalRecommendation) error {
+	if c.k8sClient == nil {
+		return nil
+	}
+	return applyPolicyRecoStatus(ctx, c.k8sClient, workloadMeta,
+		v1alpha1.PolicyRecommendationStatus{VerticalRecommendation: recommendation}, RightsizingStatusManager)
+}
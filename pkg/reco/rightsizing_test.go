@@ -0,0 +1,94 @@
+package reco
+
+import (
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var _ = Describe("RightsizingRecommender", func() {
+
+	container := func(name, request, limit string) corev1.Container {
+		c := corev1.Container{Name: name}
+		if request != "" {
+			c.Resources.Requests = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(request)}
+		}
+		if limit != "" {
+			c.Resources.Limits = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(limit)}
+		}
+		return c
+	}
+
+	template := &corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{
+		container("app", "1", "2"),
+		container("sidecar", "100m", "200m"),
+		container("agent", "50m", ""),
+	}}}
+
+	It("should size the requests from the usage plus headroom and the limits from the peak usage", func() {
+		r := NewRightsizingRecommender(nil, 0, 0, 0.25, 0, false)
+		containers, currentLimits, recommendedLimits, recommendedRequests := r.recommendContainers(template,
+			map[string]float64{"app": 0.4, "agent": 0.001},
+			map[string]float64{"app": 0.85, "agent": 0.002},
+			0.85)
+
+		Expect(containers).To(HaveLen(2))
+		Expect(containers[0].Name).To(Equal("app"))
+		Expect(containers[0].CurrentCPURequest.MilliValue()).To(Equal(int64(1000)))
+		Expect(containers[0].CurrentCPULimit.MilliValue()).To(Equal(int64(2000)))
+		Expect(containers[0].RecommendedCPURequest.MilliValue()).To(Equal(int64(500)))
+		Expect(containers[0].RecommendedCPULimit.MilliValue()).To(Equal(int64(1000)))
+
+		// The agent has no limit to recommend and is floored at the min request.
+		Expect(containers[1].Name).To(Equal("agent"))
+		Expect(containers[1].RecommendedCPURequest.MilliValue()).To(Equal(int64(minContainerCPUMillis)))
+		Expect(containers[1].RecommendedCPULimit).To(BeNil())
+
+		// The sidecar has no usage and keeps its limit.
+		Expect(currentLimits).To(BeNumerically("~", 2.2, 1e-9))
+		Expect(recommendedLimits).To(BeNumerically("~", 1.2, 1e-9))
+		Expect(recommendedRequests).To(BeNumerically("~", 0.61, 1e-9))
+	})
+
+	It("should never recommend a limit below the request", func() {
+		r := NewRightsizingRecommender(nil, 0, 0, 0.25, 0, false)
+		containers, _, recommendedLimits, _ := r.recommendContainers(template,
+			map[string]float64{"app": 1.6},
+			map[string]float64{"app": 1.2},
+			0.85)
+
+		Expect(containers).To(HaveLen(1))
+		Expect(containers[0].RecommendedCPURequest.MilliValue()).To(Equal(int64(2000)))
+		Expect(containers[0].RecommendedCPULimit.MilliValue()).To(Equal(int64(2000)))
+		Expect(recommendedLimits).To(BeNumerically("~", 2.2, 1e-9))
+	})
+
+	It("should only report a change of the resources beyond the min change", func() {
+		r := NewRightsizingRecommender(nil, 0, 0, 0, 0.1, false)
+		quantity := func(s string) *resource.Quantity {
+			q := resource.MustParse(s)
+			return &q
+		}
+
+		Expect(r.significantChange([]v1alpha1.ContainerResourceRecommendation{{
+			Name:                  "app",
+			CurrentCPURequest:     quantity("1"),
+			CurrentCPULimit:       quantity("2"),
+			RecommendedCPURequest: *quantity("1050m"),
+			RecommendedCPULimit:   quantity("1900m"),
+		}})).To(BeFalse())
+		Expect(r.significantChange([]v1alpha1.ContainerResourceRecommendation{{
+			Name:                  "app",
+			CurrentCPURequest:     quantity("1"),
+			CurrentCPULimit:       quantity("2"),
+			RecommendedCPURequest: *quantity("1050m"),
+			RecommendedCPULimit:   quantity("1500m"),
+		}})).To(BeTrue())
+		Expect(r.significantChange([]v1alpha1.ContainerResourceRecommendation{{
+			Name:                  "app",
+			RecommendedCPURequest: *quantity("100m"),
+		}})).To(BeTrue())
+	})
+})
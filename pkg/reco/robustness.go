@@ -0,0 +1,210 @@
+package reco
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
+	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	RobustnessCheckStatusManager = "RobustnessCheckStatusManager"
+
+	defaultRobustnessRecentWindow = 7 * dailyPeriod
+)
+
+// RobustnessMode decides what is recommended when the HPA configuration found on the whole metric window isn't robust
+// on its sub-windows.
+type RobustnessMode string
+
+const (
+	// RobustnessModeRequireSafe recommends the HPA configuration only if it doesn't breach on any of the sub-windows,
+	// and the no operation policy otherwise.
+	RobustnessModeRequireSafe RobustnessMode = "RequireSafe"
+	// RobustnessModeMostConservative recommends the most conservative of the HPA configurations found on the whole
+	// metric window and on every sub-window: the highest min replicas with the lowest target utilization.
+	RobustnessModeMostConservative RobustnessMode = "MostConservative"
+)
+
+// RobustnessChecker checks the recommended HPA configuration against sub-windows of the metric window: the most recent
+// days, the weekdays, the weekends and the day with the highest peak. A configuration which is safe over the whole
+// metric window can still be tuned to traffic which no longer holds, e.g. after a recent shift in traffic or on the
+// days of the week which are busier than the rest; checking the sub-windows separately keeps these from being averaged
+// away.
+type RobustnessChecker struct {
+	k8sClient    client.Client
+	mode         RobustnessMode
+	recentWindow time.Duration
+	location     *time.Location
+}
+
+// NewRobustnessChecker returns a RobustnessChecker which splits the days in the location and publishes the checks to
+// the status of the policy recommendations. The zero values pick the defaults.
+func NewRobustnessChecker(k8sClient client.Client,
+	mode RobustnessMode,
+	recentWindow time.Duration,
+	location *time.Location) *RobustnessChecker {
+	if mode != RobustnessModeMostConservative {
+		mode = RobustnessModeRequireSafe
+	}
+	if recentWindow <= 0 {
+		recentWindow = defaultRobustnessRecentWindow
+	}
+	if location == nil {
+		location = time.UTC
+	}
+	return &RobustnessChecker{
+		k8sClient:    k8sClient,
+		mode:         mode,
+		recentWindow: recentWindow,
+		location:     location,
+	}
+}
+
+// robustnessWindow is a sub-window of the metric window along with its data points.
+type robustnessWindow struct {
+	name       v1alpha1.RobustnessWindow
+	dataPoints []metrics.DataPoint
+}
+
+// windows returns the sub-windows of the utilization which have data points.
+func (r *RobustnessChecker) windows(dataPoints []metrics.DataPoint) []robustnessWindow {
+	if len(dataPoints) == 0 {
+		return nil
+	}
+	recentStart := dataPoints[len(dataPoints)-1].Timestamp.Add(-r.recentWindow)
+
+	var recent, weekdays, weekends []metrics.DataPoint
+	days := make(map[time.Time][]metrics.DataPoint)
+	var worstDay time.Time
+	worstPeak := -1.0
+	for _, dp := range dataPoints {
+		if dp.Timestamp.After(recentStart) {
+			recent = append(recent, dp)
+		}
+		t := dp.Timestamp.In(r.location)
+		if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+			weekends = append(weekends, dp)
+		} else {
+			weekdays = append(weekdays, dp)
+		}
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, r.location)
+		days[day] = append(days[day], dp)
+		if dp.Value > worstPeak {
+			worstPeak = dp.Value
+			worstDay = day
+		}
+	}
+
+	var windows []robustnessWindow
+	for _, w := range []robustnessWindow{
+		{name: v1alpha1.RobustnessWindowRecent, dataPoints: recent},
+		{name: v1alpha1.RobustnessWindowWeekdays, dataPoints: weekdays},
+		{name: v1alpha1.RobustnessWindowWeekends, dataPoints: weekends},
+		{name: v1alpha1.RobustnessWindowWorstDay, dataPoints: days[worstDay]},
+	} {
+		if len(w.dataPoints) > 0 {
+			windows = append(windows, w)
+		}
+	}
+	return windows
+}
+
+// checkRobustness simulates the optimal HPA configuration on every sub-window of the utilization. With
+// RobustnessModeRequireSafe it returns the no operation policy if the configuration breaches on any of them; with
+// RobustnessModeMostConservative it returns the most conservative of the optimal configuration and the ones found on
+// every sub-window. unableToRecommendError is returned if the no operation policy has to be recommended.
+func (c *CpuUtilizationBasedRecommender) checkRobustness(dataPoints []metrics.DataPoint,
+	acl time.Duration,
+	perPodResources float64,
+	optimal optimalHPAConfiguration) (optimalHPAConfiguration, []v1alpha1.RobustnessCheck, error) {
+
+	if c.robustnessChecker == nil {
+		return optimal, nil, nil
+	}
+	windows := c.robustnessChecker.windows(dataPoints)
+	checks := make([]v1alpha1.RobustnessCheck, len(windows))
+
+	if c.robustnessChecker.mode == RobustnessModeMostConservative {
+		conservative := optimal
+		noOperation := false
+		for i, w := range windows {
+			checks[i].Window = w.name
+			windowOptimal, err := c.findOptimalHPAConfigurationWithSchedule(w.dataPoints,
+				acl,
+				c.minTarget,
+				c.maxTarget,
+				perPodResources, optimal.max, optimal.schedule)
+			if errors.Is(err, unableToRecommendError) {
+				noOperation = true
+				continue
+			}
+			if err != nil {
+				return optimal, nil, err
+			}
+			checks[i].WindowMin = windowOptimal.min
+			checks[i].WindowTargetMetricValue = windowOptimal.targetUtilization
+			if windowOptimal.min > conservative.min {
+				conservative.min = windowOptimal.min
+			}
+			if windowOptimal.targetUtilization < conservative.targetUtilization {
+				conservative.targetUtilization = windowOptimal.targetUtilization
+			}
+		}
+		if noOperation {
+			if err := c.countBreaches(windows, checks, acl, perPodResources, optimal); err != nil {
+				return optimal, nil, err
+			}
+			return optimal, checks, unableToRecommendError
+		}
+		optimal = conservative
+	}
+
+	if err := c.countBreaches(windows, checks, acl, perPodResources, optimal); err != nil {
+		return optimal, nil, err
+	}
+	for _, check := range checks {
+		if !check.Safe {
+			return optimal, checks, unableToRecommendError
+		}
+	}
+	return optimal, checks, nil
+}
+
+// countBreaches records the breaches of the HPA configuration simulated on every window in its check.
+func (c *CpuUtilizationBasedRecommender) countBreaches(windows []robustnessWindow,
+	checks []v1alpha1.RobustnessCheck,
+	acl time.Duration,
+	perPodResources float64,
+	optimal optimalHPAConfiguration) error {
+
+	for i, w := range windows {
+		simulated, _, err := c.simulateHPAWithSchedule(w.dataPoints, acl, optimal.targetUtilization, perPodResources,
+			optimal.max, optimal.min, optimal.schedule, optimal.minReplicaWindows)
+		if err != nil {
+			return err
+		}
+		checks[i].Window = w.name
+		checks[i].Breaches = 0
+		for j := range w.dataPoints {
+			if w.dataPoints[j].Value > simulated[j].Value {
+				checks[i].Breaches++
+			}
+		}
+		checks[i].Safe = checks[i].Breaches == 0
+	}
+	return nil
+}
+
+// publishRobustnessChecks applies the robustness checks to the status of the workload's policy recommendation.
+func (r *RobustnessChecker) publishRobustnessChecks(ctx context.Context, workloadMeta WorkloadMeta,
+	checks []v1alpha1.RobustnessCheck) error {
+	if r.k8sClient == nil {
+		return nil
+	}
+	return applyPolicyRecoStatus(ctx, r.k8sClient, workloadMeta,
+		v1alpha1.PolicyRecommendationStatus{RobustnessChecks: checks}, RobustnessCheckStatusManager)
+}
@@ -0,0 +1,134 @@
+package reco
+
+import (
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
+	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RobustnessChecker", func() {
+
+	// weeklyDataPoints returns two weeks of utilization sampled every 5 minutes from a Monday, at weekday cores on
+	// the weekdays and weekend cores on the weekends, with a peak of peak cores between 12:00 and 13:00 on the 10th.
+	weeklyDataPoints := func(weekday, weekend, peak float64) []metrics.DataPoint {
+		start := time.Date(2023, time.May, 1, 0, 0, 0, 0, time.UTC)
+		var dataPoints []metrics.DataPoint
+		for t := start; t.Before(start.AddDate(0, 0, 14)); t = t.Add(5 * time.Minute) {
+			value := weekday
+			if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+				value = weekend
+			}
+			if t.Day() == 10 && t.Hour() == 12 {
+				value = peak
+			}
+			dataPoints = append(dataPoints, metrics.DataPoint{Timestamp: t, Value: value})
+		}
+		return dataPoints
+	}
+
+	recommenderWith := func(mode RobustnessMode) *CpuUtilizationBasedRecommender {
+		return &CpuUtilizationBasedRecommender{
+			redLineUtil:       0.85,
+			minTarget:         10,
+			maxTarget:         60,
+			robustnessChecker: NewRobustnessChecker(nil, mode, 0, nil),
+			logger:            logr.Discard(),
+		}
+	}
+
+	Describe("windows", func() {
+		It("should split the utilization into the sub-windows", func() {
+			dataPoints := weeklyDataPoints(10, 20, 30)
+			windows := NewRobustnessChecker(nil, "", 0, nil).windows(dataPoints)
+
+			Expect(windows).To(HaveLen(4))
+			Expect(windows[0].name).To(Equal(v1alpha1.RobustnessWindowRecent))
+			Expect(windows[0].dataPoints[0].Timestamp).To(Equal(time.Date(2023, time.May, 8, 0, 0, 0, 0, time.UTC)))
+			Expect(windows[0].dataPoints).To(HaveLen(7 * 24 * 12))
+
+			Expect(windows[1].name).To(Equal(v1alpha1.RobustnessWindowWeekdays))
+			Expect(windows[1].dataPoints).To(HaveLen(10 * 24 * 12))
+			Expect(windows[2].name).To(Equal(v1alpha1.RobustnessWindowWeekends))
+			Expect(windows[2].dataPoints).To(HaveLen(4 * 24 * 12))
+			for _, dp := range windows[2].dataPoints {
+				Expect(dp.Timestamp.Weekday()).To(BeElementOf(time.Saturday, time.Sunday))
+			}
+
+			Expect(windows[3].name).To(Equal(v1alpha1.RobustnessWindowWorstDay))
+			Expect(windows[3].dataPoints).To(HaveLen(24 * 12))
+			Expect(windows[3].dataPoints[0].Timestamp).To(Equal(time.Date(2023, time.May, 10, 0, 0, 0, 0, time.UTC)))
+		})
+
+		It("should skip the sub-windows without data points", func() {
+			dataPoints := weeklyDataPoints(10, 20, 30)[:5*24*12]
+			windows := NewRobustnessChecker(nil, "", 0, nil).windows(dataPoints)
+
+			Expect(windows).To(HaveLen(3))
+			for _, w := range windows {
+				Expect(w.name).ToNot(Equal(v1alpha1.RobustnessWindowWeekends))
+			}
+		})
+	})
+
+	Describe("checkRobustness", func() {
+		It("should recommend the configuration which is safe in every sub-window", func() {
+			c := recommenderWith(RobustnessModeRequireSafe)
+			dataPoints := weeklyDataPoints(10, 20, 30)
+			optimal, err := c.findOptimalHPAConfigurationWithSchedule(dataPoints, 5*time.Minute, c.minTarget,
+				c.maxTarget, 1, 40, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			robust, checks, err := c.checkRobustness(dataPoints, 5*time.Minute, 1, optimal)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(robust).To(Equal(optimal))
+			Expect(checks).To(HaveLen(4))
+			for _, check := range checks {
+				Expect(check.Safe).To(BeTrue())
+				Expect(check.Breaches).To(BeZero())
+				Expect(check.WindowMin).To(BeZero())
+			}
+		})
+
+		It("should refuse the configuration which breaches in a sub-window", func() {
+			c := recommenderWith(RobustnessModeRequireSafe)
+			dataPoints := weeklyDataPoints(10, 20, 30)
+			// The peak of the 10th comes at once, before the HPA scales up from the min replicas.
+			optimal := optimalHPAConfiguration{targetUtilization: 60, min: 15, max: 40}
+
+			_, checks, err := c.checkRobustness(dataPoints, 5*time.Minute, 1, optimal)
+			Expect(err).To(MatchError(unableToRecommendError))
+			Expect(checks).To(ContainElement(And(
+				HaveField("Window", v1alpha1.RobustnessWindowWorstDay),
+				HaveField("Safe", false),
+				HaveField("Breaches", BeNumerically(">", 0)))))
+			Expect(checks).To(ContainElement(And(
+				HaveField("Window", v1alpha1.RobustnessWindowWeekends),
+				HaveField("Safe", true))))
+		})
+
+		It("should recommend the most conservative configuration of the sub-windows", func() {
+			c := recommenderWith(RobustnessModeMostConservative)
+			dataPoints := weeklyDataPoints(10, 20, 30)
+			optimal, err := c.findOptimalHPAConfigurationWithSchedule(dataPoints, 5*time.Minute, c.minTarget,
+				c.maxTarget, 1, 40, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			robust, checks, err := c.checkRobustness(dataPoints, 5*time.Minute, 1, optimal)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(checks).To(HaveLen(4))
+			for _, check := range checks {
+				Expect(check.Safe).To(BeTrue())
+				Expect(check.WindowMin).To(BeNumerically(">", 0))
+				Expect(robust.min).To(BeNumerically(">=", check.WindowMin))
+				Expect(robust.targetUtilization).To(BeNumerically("<=", check.WindowTargetMetricValue))
+			}
+			Expect(robust.min).To(BeNumerically(">=", optimal.min))
+			Expect(robust.targetUtilization).To(BeNumerically("<=", optimal.targetUtilization))
+			Expect(robust.max).To(Equal(optimal.max))
+		})
+	})
+})
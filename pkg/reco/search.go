@@ -0,0 +1,285 @@
+package reco
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+)
+
+// savingsBoundMargin absorbs the rounding of the savings summed over the data points when they are compared against
+// the upper bound of the savings of a min replicas.
+const savingsBoundMargin = 1e-6
+
+// findOptimalHPAConfigurationWithSchedule finds the min replicas and the target utilization with the highest savings
+// for which the simulated HPA doesn't breach. For every min replicas from 1 to maxReplicas the highest target
+// utilization without a breach is binary searched between minTarget and maxTarget; among the min replicas with equal
+// savings the highest one wins.
+//
+// The min replicas are searched in parallel on an hpaSimulator shared between them. As the simulated resources never
+// drop below the min replicas, the savings of a min replicas are bounded by its share of the max replicas, and the
+// search stops at the first min replicas which can't beat the savings found so far.
+func (c *CpuUtilizationBasedRecommender) findOptimalHPAConfigurationWithSchedule(dataPoints []metrics.DataPoint,
+	acl time.Duration,
+	minTarget,
+	maxTarget int,
+	perPodResources float64, maxReplicas int,
+	schedule *preScaleSchedule) (optimalHPAConfiguration, error) {
+
+	simulator := newHPASimulator(c.redLineUtil, dataPoints, acl, perPodResources, maxReplicas, minTarget, maxTarget,
+		schedule)
+
+	optimal := optimalHPAConfiguration{max: maxReplicas, schedule: schedule}
+	workers := runtime.GOMAXPROCS(0)
+	results := make([]minReplicasResult, workers)
+	for first := 1; first <= maxReplicas; first += workers {
+		if optimal.savings > simulator.savingsBound(first)+savingsBoundMargin {
+			break
+		}
+		last := first + workers - 1
+		if last > maxReplicas {
+			last = maxReplicas
+		}
+
+		var wg sync.WaitGroup
+		for minReplicas := first; minReplicas <= last; minReplicas++ {
+			wg.Add(1)
+			go func(minReplicas int) {
+				defer wg.Done()
+				results[minReplicas-first] = simulator.search(minReplicas)
+			}(minReplicas)
+		}
+		wg.Wait()
+
+		for minReplicas := first; minReplicas <= last; minReplicas++ {
+			if optimal.savings > simulator.savingsBound(minReplicas)+savingsBoundMargin {
+				break
+			}
+			result := results[minReplicas-first]
+			if result.err != nil {
+				c.logger.Error(result.err, "Error while simulating HPA")
+				return optimalHPAConfiguration{targetUtilization: -1, min: minReplicas, max: maxReplicas}, result.err
+			}
+			if result.found && result.savings >= optimal.savings {
+				optimal.min = minReplicas
+				optimal.targetUtilization = result.targetUtilization
+				optimal.savings = result.savings
+			}
+		}
+	}
+
+	if optimal.targetUtilization < minTarget || optimal.savings == 0.0 {
+		return optimalHPAConfiguration{}, unableToRecommendError
+	}
+	return optimal, nil
+}
+
+// hpaSimulator simulates the HPA exactly like simulateHPAWithSchedule, but for the many target utilizations and min
+// replicas probed by the search on the same utilization. Whatever doesn't depend on the probe is computed once: the
+// replicas scheduled at every data point and, per target utilization, the replicas the HPA asks for at every data point.
+// A probe stops at the first breach and is resumed only if its savings are needed.
+type hpaSimulator struct {
+	redLineUtil     float64
+	dataPoints      []metrics.DataPoint
+	timestamps      []int64
+	scheduled       []int
+	acl             int64
+	perPodResources float64
+	maxReplicas     int
+	minTarget       int
+	maxTarget       int
+
+	desired []*desiredReplicas
+}
+
+// desiredReplicas are the replicas the HPA asks for at every data point for a target utilization, ignoring the min and
+// the max replicas.
+type desiredReplicas struct {
+	once          sync.Once
+	replicas      []float64
+	calculatedMin int
+	err           error
+}
+
+func newHPASimulator(redLineUtil float64,
+	dataPoints []metrics.DataPoint,
+	acl time.Duration,
+	perPodResources float64,
+	maxReplicas int,
+	minTarget int,
+	maxTarget int,
+	schedule *preScaleSchedule) *hpaSimulator {
+
+	s := &hpaSimulator{
+		redLineUtil:     redLineUtil,
+		dataPoints:      dataPoints,
+		timestamps:      make([]int64, len(dataPoints)),
+		scheduled:       make([]int, len(dataPoints)),
+		acl:             int64(acl),
+		perPodResources: perPodResources,
+		maxReplicas:     maxReplicas,
+		minTarget:       minTarget,
+		maxTarget:       maxTarget,
+	}
+	for i, dp := range dataPoints {
+		s.timestamps[i] = dp.Timestamp.UnixNano()
+		if schedule != nil {
+			s.scheduled[i] = schedule.replicasAt(dp.Timestamp)
+		}
+	}
+	if maxTarget >= minTarget {
+		s.desired = make([]*desiredReplicas, maxTarget-minTarget+1)
+		for i := range s.desired {
+			s.desired[i] = &desiredReplicas{}
+		}
+	}
+	return s
+}
+
+// desiredReplicasFor returns the desired replicas for the target utilization, computing them on the first call.
+func (s *hpaSimulator) desiredReplicasFor(targetUtilization int) *desiredReplicas {
+	desired := s.desired[targetUtilization-s.minTarget]
+	desired.once.Do(func() {
+		if len(s.dataPoints) == 0 {
+			return
+		}
+		scaledTarget := int(math.Floor(float64(targetUtilization) * 1.1))
+		if scaledTarget < 1 || scaledTarget > maxScaledTarget(s.redLineUtil) {
+			desired.err = errors.New(fmt.Sprintf("Invalid value of target utilization: %v."+
+				" Value should be between 1 and %v", scaledTarget, maxScaledTarget(s.redLineUtil)))
+			return
+		}
+		desired.replicas = make([]float64, len(s.dataPoints))
+		calculatedMin := math.Inf(1)
+		for i, dp := range s.dataPoints {
+			desired.replicas[i] = math.Ceil((100 * dp.Value) / float64(scaledTarget) / s.perPodResources)
+			calculatedMin = math.Min(calculatedMin, desired.replicas[i])
+		}
+		desired.calculatedMin = int(calculatedMin)
+	})
+	return desired
+}
+
+// savingsBound returns the highest savings a simulation with the min replicas can reach.
+func (s *hpaSimulator) savingsBound(minReplicas int) float64 {
+	return 100 * (1 - float64(minReplicas)/float64(s.maxReplicas))
+}
+
+// simulationState is the state of the simulation of a probe after the data points before next.
+type simulationState struct {
+	targetUtilization int
+	minReplicas       int
+	next              int
+	readyResources    float64
+	timers            []simulationTimer
+	savings           float64
+	breached          bool
+}
+
+type simulationTimer struct {
+	at    int64
+	delta float64
+}
+
+// minReplicasResult is the outcome of the search of the target utilization for a min replicas.
+type minReplicasResult struct {
+	found             bool
+	targetUtilization int
+	savings           float64
+	err               error
+}
+
+// search binary searches the highest target utilization without a breach for the min replicas. The savings are those
+// of the simulation of the last probe.
+func (s *hpaSimulator) search(minReplicas int) minReplicasResult {
+	calculatedMin := 0
+	low := s.minTarget
+	high := s.maxTarget
+	var last *simulationState
+	for low <= high {
+		mid := low + (high-low)/2
+		desired := s.desiredReplicasFor(mid)
+		if desired.err != nil {
+			return minReplicasResult{err: desired.err}
+		}
+		last = &simulationState{targetUtilization: mid, minReplicas: minReplicas}
+		s.run(last, desired.replicas, true)
+		calculatedMin = desired.calculatedMin
+
+		if !last.breached {
+			low = mid + 1
+		} else {
+			high = mid - 1
+		}
+	}
+	if high < s.minTarget || calculatedMin > minReplicas || last == nil || len(s.dataPoints) == 0 {
+		return minReplicasResult{}
+	}
+
+	s.run(last, s.desiredReplicasFor(last.targetUtilization).replicas, false)
+	maxResources := float64(s.maxReplicas) * s.perPodResources
+	savings := last.savings / maxResources / float64(len(s.dataPoints))
+	return minReplicasResult{found: true, targetUtilization: high, savings: savings * 100.0}
+}
+
+// run simulates the data points from the state onwards, stopping after the first breach if stopAtBreach is set.
+func (s *hpaSimulator) run(state *simulationState, desired []float64, stopAtBreach bool) {
+	maxReplicas := float64(s.maxReplicas)
+	maxResources := maxReplicas * s.perPodResources
+	minReplicasAt := func(i int) float64 {
+		if s.scheduled[i] > state.minReplicas {
+			return float64(s.scheduled[i])
+		}
+		return float64(state.minReplicas)
+	}
+	observe := func(i int) bool {
+		simulated := state.readyResources * s.redLineUtil
+		state.savings += maxResources - simulated/s.redLineUtil
+		if s.dataPoints[i].Value > simulated {
+			state.breached = true
+			return true
+		}
+		return false
+	}
+
+	if state.next == 0 && len(s.dataPoints) > 0 {
+		currentReplicas := math.Min(maxReplicas, math.Max(minReplicasAt(0), desired[0]))
+		state.readyResources = currentReplicas * s.perPodResources
+		state.next = 1
+		if observe(0) && stopAtBreach {
+			return
+		}
+	}
+
+	for ; state.next < len(s.dataPoints); state.next++ {
+		i := state.next
+		for len(state.timers) > 0 && s.timestamps[i] >= state.timers[0].at {
+			state.readyResources += state.timers[0].delta
+			state.timers = state.timers[1:]
+		}
+		newReplicas := math.Min(maxReplicas, math.Max(minReplicasAt(i), desired[i]))
+		newResources := newReplicas * s.perPodResources
+
+		if newResources > state.readyResources {
+			delta := newResources - state.readyResources
+			for _, timer := range state.timers {
+				delta -= timer.delta
+			}
+			if delta > 0 {
+				state.timers = append(state.timers, simulationTimer{at: s.timestamps[i] + s.acl, delta: delta})
+			}
+		} else {
+			state.readyResources = newResources
+			state.timers = state.timers[:0]
+		}
+
+		if observe(i) && stopAtBreach {
+			state.next++
+			return
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package reco
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// findOptimalHPAConfigurationExhaustive searches every min replicas sequentially, simulating the whole utilization at
+// every probe. findOptimalHPAConfigurationWithSchedule must return exactly what it returns.
+func (c *CpuUtilizationBasedRecommender) findOptimalHPAConfigurationExhaustive(dataPoints []metrics.DataPoint,
+	acl time.Duration,
+	minTarget,
+	maxTarget int,
+	perPodResources float64, maxReplicas int,
+	schedule *preScaleSchedule) (optimalHPAConfiguration, error) {
+
+	optimalTargetThreshold := 0
+	optimalMin := 0
+	savings := 0.0
+
+	for minReplicas := 1; minReplicas <= maxReplicas; minReplicas++ {
+		calculatedMin := 0
+		low := minTarget
+		high := maxTarget
+		var simulatedHPAList []metrics.DataPoint
+		for low <= high {
+			mid := low + (high-low)/2
+			var err error
+			simulatedHPAList, calculatedMin, err = c.simulateHPAWithSchedule(dataPoints, acl, mid, perPodResources,
+				maxReplicas, minReplicas, schedule)
+			if err != nil {
+				return optimalHPAConfiguration{targetUtilization: -1, min: minReplicas, max: maxReplicas}, err
+			}
+			if c.hasNoBreachOccurred(dataPoints, simulatedHPAList) {
+				low = mid + 1
+			} else {
+				high = mid - 1
+			}
+		}
+		if high >= minTarget && calculatedMin <= minReplicas && len(simulatedHPAList) > 0 {
+			newSavings := c.calculateSavings(maxReplicas, simulatedHPAList, perPodResources)
+			if newSavings >= savings {
+				optimalMin = minReplicas
+				optimalTargetThreshold = high
+				savings = newSavings
+			}
+		}
+	}
+
+	if optimalTargetThreshold < minTarget || savings == 0.0 {
+		return optimalHPAConfiguration{}, unableToRecommendError
+	}
+	return optimalHPAConfiguration{
+		targetUtilization: optimalTargetThreshold,
+		min:               optimalMin,
+		max:               maxReplicas,
+		savings:           savings,
+		schedule:          schedule,
+	}, nil
+}
+
+// noisyDiurnalDataPoints returns days of utilization sampled every step, swinging daily between base and peak cores
+// with random noise and the occasional spike.
+func noisyDiurnalDataPoints(seed int64, days int, step time.Duration, base, peak float64) []metrics.DataPoint {
+	random := rand.New(rand.NewSource(seed))
+	start := time.Date(2023, time.May, 1, 0, 0, 0, 0, time.UTC)
+	var dataPoints []metrics.DataPoint
+	for t := start; t.Before(start.AddDate(0, 0, days)); t = t.Add(step) {
+		phase := 2 * math.Pi * float64(t.Sub(start)) / float64(24*time.Hour)
+		value := base + (peak-base)*(1-math.Cos(phase))/2
+		value *= 1 + 0.1*random.NormFloat64()
+		if random.Float64() < 0.001 {
+			value *= 1.5
+		}
+		dataPoints = append(dataPoints, metrics.DataPoint{Timestamp: t, Value: math.Max(0, value)})
+	}
+	return dataPoints
+}
+
+var _ = Describe("findOptimalHPAConfigurationWithSchedule", func() {
+	c := &CpuUtilizationBasedRecommender{redLineUtil: 0.85, logger: logr.Discard()}
+
+	expectSameAsExhaustive := func(dataPoints []metrics.DataPoint, acl time.Duration, minTarget, maxTarget int,
+		perPodResources float64, maxReplicas int, schedule *preScaleSchedule) {
+		expected, expectedErr := c.findOptimalHPAConfigurationExhaustive(dataPoints, acl, minTarget, maxTarget,
+			perPodResources, maxReplicas, schedule)
+		actual, err := c.findOptimalHPAConfigurationWithSchedule(dataPoints, acl, minTarget, maxTarget,
+			perPodResources, maxReplicas, schedule)
+		if expectedErr != nil {
+			Expect(err).To(MatchError(expectedErr))
+			return
+		}
+		Expect(err).ToNot(HaveOccurred())
+		Expect(actual).To(Equal(expected))
+	}
+
+	It("should find the configuration the exhaustive search finds", func() {
+		for seed := int64(1); seed <= 5; seed++ {
+			dataPoints := noisyDiurnalDataPoints(seed, 3, time.Minute, 5+float64(seed), 60)
+			expectSameAsExhaustive(dataPoints, 5*time.Minute, 10, 60, 2, 60, nil)
+			expectSameAsExhaustive(dataPoints, 3*time.Minute, 30, 80, 1.5, 90, nil)
+		}
+	})
+
+	It("should find the configuration the exhaustive search finds with a pre-scale schedule", func() {
+		dataPoints := noisyDiurnalDataPoints(7, 4, time.Minute, 8, 50)
+		schedule := NewPreScaler(0, 0, 0, nil).schedule(dataPoints, 1, 0.85, 5*time.Minute, 80)
+		Expect(schedule).ToNot(BeNil())
+		expectSameAsExhaustive(dataPoints, 5*time.Minute, 10, 60, 1, 80, schedule)
+	})
+
+	It("should fail like the exhaustive search does", func() {
+		flat := noisyDiurnalDataPoints(3, 1, time.Minute, 100, 100)
+		expectSameAsExhaustive(flat, 5*time.Minute, 10, 60, 1, 20, nil)
+		expectSameAsExhaustive(flat, 5*time.Minute, 50, 95, 1, 200, nil)
+		expectSameAsExhaustive(nil, 5*time.Minute, 10, 60, 1, 20, nil)
+	})
+})
+
+func benchmarkFindOptimalHPAConfiguration(b *testing.B, maxReplicas int,
+	search func(c *CpuUtilizationBasedRecommender, dataPoints []metrics.DataPoint, maxReplicas int) (optimalHPAConfiguration, error)) {
+	c := &CpuUtilizationBasedRecommender{redLineUtil: 0.85, logger: logr.Discard()}
+	// 28 days of utilization at a step of 30 seconds on pods of 8 cores.
+	dataPoints := noisyDiurnalDataPoints(1, 28, 30*time.Second, 20, float64(maxReplicas)*0.6)
+
+	expected, err := c.findOptimalHPAConfigurationExhaustive(dataPoints, 5*time.Minute, 10, 60, 8, maxReplicas, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		actual, err := search(c, dataPoints, maxReplicas)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if actual != expected {
+			b.Fatalf("search found %+v, the exhaustive search found %+v", actual, expected)
+		}
+	}
+}
+
+func searchOptimalHPAConfiguration(c *CpuUtilizationBasedRecommender, dataPoints []metrics.DataPoint,
+	maxReplicas int) (optimalHPAConfiguration, error) {
+	return c.findOptimalHPAConfigurationWithSchedule(dataPoints, 5*time.Minute, 10, 60, 8, maxReplicas, nil)
+}
+
+func searchOptimalHPAConfigurationExhaustively(c *CpuUtilizationBasedRecommender, dataPoints []metrics.DataPoint,
+	maxReplicas int) (optimalHPAConfiguration, error) {
+	return c.findOptimalHPAConfigurationExhaustive(dataPoints, 5*time.Minute, 10, 60, 8, maxReplicas, nil)
+}
+
+func BenchmarkFindOptimalHPAConfiguration50(b *testing.B) {
+	benchmarkFindOptimalHPAConfiguration(b, 50, searchOptimalHPAConfiguration)
+}
+
+func BenchmarkFindOptimalHPAConfiguration500(b *testing.B) {
+	benchmarkFindOptimalHPAConfiguration(b, 500, searchOptimalHPAConfiguration)
+}
+
+func BenchmarkFindOptimalHPAConfigurationExhaustive50(b *testing.B) {
+	benchmarkFindOptimalHPAConfiguration(b, 50, searchOptimalHPAConfigurationExhaustively)
+}
+
+func BenchmarkFindOptimalHPAConfigurationExhaustive500(b *testing.B) {
+	benchmarkFindOptimalHPAConfiguration(b, 500, searchOptimalHPAConfigurationExhaustively)
+}
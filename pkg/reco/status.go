@@ -0,0 +1,62 @@
+package reco
+
+import (
+	"context"
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
+	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	IgnoredIntervalsStatusManager = "IgnoredIntervalsStatusManager"
+
+	// maxIgnoredIntervals caps the ignored intervals published to the status, keeping the latest ones.
+	maxIgnoredIntervals = 100
+)
+
+// applyPolicyRecoStatus applies the status fields owned by the field manager to the status of the workload's policy
+// recommendation.
+func applyPolicyRecoStatus(ctx context.Context, k8sClient client.Client, workloadMeta WorkloadMeta,
+	status v1alpha1.PolicyRecommendationStatus, fieldManager string) error {
+	statusPatch := &v1alpha1.PolicyRecommendation{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: v1alpha1.GroupVersion.String(),
+			Kind:       "PolicyRecommendation",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      workloadMeta.Name,
+			Namespace: workloadMeta.Namespace,
+		},
+		Status: status,
+	}
+	patchOpts := client.PatchOptions{}
+	client.ForceOwnership.ApplyToPatch(&patchOpts)
+	client.FieldOwner(fieldManager).ApplyToPatch(&patchOpts)
+	return k8sClient.Status().Patch(ctx, statusPatch, client.Apply,
+		&client.SubResourcePatchOptions{PatchOptions: patchOpts})
+}
+
+// publishIgnoredIntervals applies the intervals ignored by the metrics transformers to the status of the workload's
+// policy recommendation, so that what was left out of the recommendation can be audited.
+func (c *CpuUtilizationBasedRecommender) publishIgnoredIntervals(ctx context.Context, workloadMeta WorkloadMeta,
+	ignored []metrics.IgnoredInterval) error {
+	if c.k8sClient == nil {
+		return nil
+	}
+	if len(ignored) > maxIgnoredIntervals {
+		ignored = ignored[len(ignored)-maxIgnoredIntervals:]
+	}
+	intervals := make([]v1alpha1.IgnoredInterval, 0, len(ignored))
+	for _, interval := range ignored {
+		intervals = append(intervals, v1alpha1.IgnoredInterval{
+			Start:  metav1.NewTime(interval.StartTime.Truncate(time.Second)),
+			End:    metav1.NewTime(interval.EndTime.Truncate(time.Second)),
+			Reason: interval.Reason,
+		})
+	}
+	return applyPolicyRecoStatus(ctx, c.k8sClient, workloadMeta,
+		v1alpha1.PolicyRecommendationStatus{IgnoredIntervals: intervals}, IgnoredIntervalsStatusManager)
+}
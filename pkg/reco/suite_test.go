@@ -107,7 +107,9 @@ func (pi *MockPI) GetName() string {
 
 type FakeMetricsTransformer struct{}
 
-func (fs *FakeScraper) GetAverageCPUUtilizationByWorkload(namespace,
+func (fs *FakeScraper) GetAverageCPUUtilizationByWorkload(ctx context.Context,
+	namespace,
+	workloadType,
 	workload string,
 	start time.Time,
 	end time.Time,
@@ -115,7 +117,8 @@ func (fs *FakeScraper) GetAverageCPUUtilizationByWorkload(namespace,
 	return fs.CPUDataPoints, nil
 }
 
-func (fs *FakeScraper) GetCPUUtilizationBreachDataPoints(namespace,
+func (fs *FakeScraper) GetCPUUtilizationBreachDataPoints(ctx context.Context,
+	namespace,
 	workloadType,
 	workload string,
 	redLineUtilization float64,
@@ -124,7 +127,9 @@ func (fs *FakeScraper) GetCPUUtilizationBreachDataPoints(namespace,
 	step time.Duration) ([]metrics.DataPoint, error) {
 	return fs.BreachDataPoints, nil
 }
-func (fs *FakeScraper) GetACLByWorkload(namespace,
+func (fs *FakeScraper) GetACLByWorkload(ctx context.Context,
+	namespace,
+	workloadType,
 	workload string) (time.Duration, error) {
 	return fs.WorkloadACL, nil
 }
@@ -206,24 +211,24 @@ var _ = BeforeSuite(func() {
 
 	clientsRegistry = *registry.NewDeploymentClientRegistryBuilder().
 		WithK8sClient(k8sClient).
-		WithCustomDeploymentClient(registry.NewDeploymentClient(k8sManager.GetClient())).
-		WithCustomDeploymentClient(registry.NewRolloutClient(k8sManager.GetClient())).
+		WithCustomDeploymentClient(registry.NewDeploymentClient(k8sManager.GetClient(), nil)).
+		WithCustomDeploymentClient(registry.NewRolloutClient(k8sManager.GetClient(), nil)).
 		Build()
 
 	var trueBool = true
 	autoscalerClient := autoscaler.NewScaledobjectClient(k8sManager.GetClient(), &trueBool)
 
 	recommender = NewCpuUtilizationBasedRecommender(k8sClient, redLineUtil,
-		metricWindow, fakeScraper, fakeMetricsTransformer, metricStep, minTarget, maxTarget, minPercentageMetricsRequired, clientsRegistry, autoscalerClient, logger)
+		metricWindow, fakeScraper, fakeMetricsTransformer, metricStep, minTarget, maxTarget, minPercentageMetricsRequired, clientsRegistry, autoscalerClient, nil, nil, nil, nil, nil, nil, logger)
 
 	recommender1 = NewCpuUtilizationBasedRecommender(k8sManager.GetClient(), redLineUtil,
-		metricWindow, fakeScraper, fakeMetricsTransformer, metricStep, minTarget, maxTarget, minPercentageMetricsRequired, clientsRegistry, autoscalerClient, logger)
+		metricWindow, fakeScraper, fakeMetricsTransformer, metricStep, minTarget, maxTarget, minPercentageMetricsRequired, clientsRegistry, autoscalerClient, nil, nil, nil, nil, nil, nil, logger)
 
 	recommender2 = NewCpuUtilizationBasedRecommender(k8sManager.GetClient(), redLineUtil,
-		metricWindow, fakeScraper1, fakeMetricsTransformer, metricStep, minTarget, maxTarget, minPercentageMetricsRequired, clientsRegistry, autoscalerClient, logger)
+		metricWindow, fakeScraper1, fakeMetricsTransformer, metricStep, minTarget, maxTarget, minPercentageMetricsRequired, clientsRegistry, autoscalerClient, nil, nil, nil, nil, nil, nil, logger)
 
 	recommender3 = NewCpuUtilizationBasedRecommender(k8sManager.GetClient(), redLineUtil,
-		28*24*time.Hour, fakeScraper1, fakeMetricsTransformer, 30*time.Second, minTarget, maxTarget, minPercentageMetricsRequired, clientsRegistry, autoscalerClient, logger)
+		28*24*time.Hour, fakeScraper1, fakeMetricsTransformer, 30*time.Second, minTarget, maxTarget, minPercentageMetricsRequired, clientsRegistry, autoscalerClient, nil, nil, nil, nil, nil, nil, logger)
 
 	safestPolicy = &ottoscaleriov1alpha1.Policy{
 		ObjectMeta: metav1.ObjectMeta{Name: "safest-policy"},
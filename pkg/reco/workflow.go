@@ -192,10 +192,24 @@ func createRecoConfigFromPolicy(policy *Policy, recoConfig *v1alpha1.HPAConfigur
 	if policy == nil || recoConfig == nil {
 		return nil, errors.New("Policy or reco config supplied is nil")
 	}
+	minReplicasFor := func(recoMin int) int {
+		return recoConfig.Max - int(math.Ceil(float64(policy.MinReplicaPercentageCut*(recoConfig.Max-recoMin)/100)))
+	}
+	min := minReplicasFor(recoConfig.Min)
+	// The policy cuts the min replicas of every window just like the min replicas of the rest of the day.
+	var minReplicaWindows []v1alpha1.ReplicaSchedule
+	for _, window := range recoConfig.MinReplicaWindows {
+		window.DesiredReplicas = minReplicasFor(window.DesiredReplicas)
+		if window.DesiredReplicas > min {
+			minReplicaWindows = append(minReplicaWindows, window)
+		}
+	}
 	return &v1alpha1.HPAConfiguration{
-		Min:               recoConfig.Max - int(math.Ceil(float64(policy.MinReplicaPercentageCut*(recoConfig.Max-recoConfig.Min)/100))),
+		Min:               min,
 		Max:               recoConfig.Max,
 		TargetMetricValue: policy.TargetUtilization,
+		PreScaleSchedules: recoConfig.PreScaleSchedules,
+		MinReplicaWindows: minReplicaWindows,
 	}, nil
 }
 
@@ -254,7 +268,14 @@ func transformTargetRecoConfig(targetRecoConfig *v1alpha1.HPAConfiguration, minR
 	if maxReplicas >= minRequiredReplicas && minReplicas < minRequiredReplicas {
 		minReplicas = minRequiredReplicas
 	}
-	return &v1alpha1.HPAConfiguration{Min: minReplicas, Max: maxReplicas, TargetMetricValue: targetRecoConfig.TargetMetricValue}
+	var minReplicaWindows []v1alpha1.ReplicaSchedule
+	for _, window := range targetRecoConfig.MinReplicaWindows {
+		if window.DesiredReplicas > minReplicas {
+			minReplicaWindows = append(minReplicaWindows, window)
+		}
+	}
+	return &v1alpha1.HPAConfiguration{Min: minReplicas, Max: maxReplicas, TargetMetricValue: targetRecoConfig.TargetMetricValue,
+		PreScaleSchedules: targetRecoConfig.PreScaleSchedules, MinReplicaWindows: minReplicaWindows}
 }
 
 func (rw *RecommendationWorkflowImpl) findClosestSafePolicy(config *v1alpha1.HPAConfiguration) (*Policy, error) {
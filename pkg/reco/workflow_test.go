@@ -382,3 +382,23 @@ var _ = Describe("RecommendationWorkflow", func() {
 
 	})
 })
+
+var _ = Describe("createRecoConfigFromPolicy", func() {
+	It("should cut the min replicas of the min replica windows like the min replicas", func() {
+		recoConfig := &v1alpha1.HPAConfiguration{Min: 2, Max: 22, TargetMetricValue: 60,
+			MinReplicaWindows: []v1alpha1.ReplicaSchedule{
+				{Timezone: "UTC", Start: "0 4 * * *", End: "0 8 * * *", DesiredReplicas: 12},
+				{Timezone: "UTC", Start: "0 8 * * *", End: "0 20 * * *", DesiredReplicas: 3},
+			}}
+		policy := &Policy{Name: "policy", RiskIndex: 5, MinReplicaPercentageCut: 50, TargetUtilization: 40}
+
+		config, err := createRecoConfigFromPolicy(policy, recoConfig, WorkloadMeta{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.Min).To(Equal(12))
+		Expect(config.Max).To(Equal(22))
+		Expect(config.MinReplicaWindows).To(Equal([]v1alpha1.ReplicaSchedule{
+			{Timezone: "UTC", Start: "0 4 * * *", End: "0 8 * * *", DesiredReplicas: 17},
+			{Timezone: "UTC", Start: "0 8 * * *", End: "0 20 * * *", DesiredReplicas: 13},
+		}))
+	})
+})
@@ -7,7 +7,6 @@ import (
 	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -21,14 +20,21 @@ var DeploymentGVK = schema.GroupVersionKind{
 }
 
 type DeploymentClient struct {
-	k8sClient client.Client
-	gvk       schema.GroupVersionKind
+	k8sClient           client.Client
+	gvk                 schema.GroupVersionKind
+	podResourceResolver *PodResourceResolver
 }
 
-func NewDeploymentClient(k8sClient client.Client) ObjectClient {
+// NewDeploymentClient returns the client of the Deployments which sizes their pods with the podResourceResolver, by the CPU
+// limits of all of their containers if it's nil.
+func NewDeploymentClient(k8sClient client.Client, podResourceResolver *PodResourceResolver) ObjectClient {
+	if podResourceResolver == nil {
+		podResourceResolver = NewPodResourceResolver(k8sClient, ResourceModeLimits, true, nil)
+	}
 	return &DeploymentClient{
-		k8sClient: k8sClient,
-		gvk:       DeploymentGVK,
+		k8sClient:           k8sClient,
+		gvk:                 DeploymentGVK,
+		podResourceResolver: podResourceResolver,
 	}
 }
 
@@ -72,34 +78,15 @@ func (dc *DeploymentClient) GetContainerResourceLimits(namespace string, name st
 	if err := dc.k8sClient.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, deploymentObject); err != nil {
 		return 0, err
 	}
-	podTemplateSpec := deploymentObject.Spec.Template
-
-	podList := &corev1.PodList{}
-
-	if podTemplateSpec.Labels == nil {
-		return 0, fmt.Errorf("no labels present on the workload to fetch pod")
-	}
-
-	labelSet := labels.Set(podTemplateSpec.Labels)
-	selector := labels.SelectorFromSet(labelSet)
+	return dc.podResourceResolver.GetCPU(namespace, &deploymentObject.Spec.Template)
+}
 
-	if err := dc.k8sClient.List(context.Background(), podList, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+func (dc *DeploymentClient) GetContainerResourceRequests(namespace string, name string) (float64, error) {
+	deploymentObject := &appsv1.Deployment{}
+	if err := dc.k8sClient.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, deploymentObject); err != nil {
 		return 0, err
 	}
-
-	cpuLimitsSum := int64(0)
-
-	if len(podList.Items) == 0 {
-		return 0, fmt.Errorf("no pod found for the workload")
-	}
-
-	for _, container := range podList.Items[0].Spec.Containers {
-		if limit, ok := container.Resources.Limits[corev1.ResourceCPU]; ok {
-			cpuLimitsSum += limit.MilliValue()
-		}
-	}
-
-	return float64(cpuLimitsSum) / 1000, nil
+	return dc.podResourceResolver.GetCPURequests(namespace, &deploymentObject.Spec.Template)
 }
 
 func (dc *DeploymentClient) GetReplicaCount(namespace string, name string) (int, error) {
@@ -133,3 +120,24 @@ func (dc *DeploymentClient) Scale(namespace string, name string, replicas int32)
 	}
 	return nil
 }
+
+func (dc *DeploymentClient) GetPodTemplateSpec(namespace string, name string) (*corev1.PodTemplateSpec, error) {
+	deploymentObject := &appsv1.Deployment{}
+	if err := dc.k8sClient.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, deploymentObject); err != nil {
+		return nil, err
+	}
+	return &deploymentObject.Spec.Template, nil
+}
+
+func (dc *DeploymentClient) SetContainerResources(namespace string, name string, resources map[string]corev1.ResourceRequirements) error {
+	deploymentObject := &appsv1.Deployment{}
+	if err := dc.k8sClient.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, deploymentObject); err != nil {
+		return err
+	}
+	original := deploymentObject.DeepCopy()
+	if !mergeContainerResources(&deploymentObject.Spec.Template, resources) {
+		return nil
+	}
+	return dc.k8sClient.Patch(context.Background(), deploymentObject,
+		client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}))
+}
@@ -2,6 +2,7 @@ package registry
 
 import (
 	"fmt"
+	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -11,8 +12,11 @@ type ObjectClient interface {
 	GetKind() string
 	GetMaxReplicaFromAnnotation(namespace string, name string) (int, error)
 	GetContainerResourceLimits(namespace string, name string) (float64, error)
+	GetContainerResourceRequests(namespace string, name string) (float64, error)
 	GetReplicaCount(namespace string, name string) (int, error)
 	Scale(namespace string, name string, replicas int32) error
+	GetPodTemplateSpec(namespace string, name string) (*corev1.PodTemplateSpec, error)
+	SetContainerResources(namespace string, name string, resources map[string]corev1.ResourceRequirements) error
 }
 
 type DeploymentClientRegistry struct {
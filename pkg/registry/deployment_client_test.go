@@ -140,6 +140,29 @@ var _ = Describe("DeploymentClient", func() {
 
 	})
 
+	Describe("SetContainerResources", func() {
+		It("should set the given resources on the containers of the pod template", func() {
+			Expect(deploymentClient.SetContainerResources(deploymentNamespace, deploymentName,
+				map[string]corev1.ResourceRequirements{
+					"container-1": {
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("300m")},
+						Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("600m")},
+					},
+				})).To(Succeed())
+
+			template, err := deploymentClient.GetPodTemplateSpec(deploymentNamespace, deploymentName)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(template.Spec.Containers[0].Resources.Requests.Cpu().MilliValue()).To(Equal(int64(300)))
+			Expect(template.Spec.Containers[0].Resources.Limits.Cpu().MilliValue()).To(Equal(int64(600)))
+			Expect(template.Spec.Containers[1].Resources.Limits.Cpu().MilliValue()).To(Equal(int64(500)))
+		})
+
+		It("should return an error if the object is not found", func() {
+			Expect(deploymentClient.SetContainerResources(deploymentNamespace, "non-existent-deployment",
+				nil)).ToNot(Succeed())
+		})
+	})
+
 	Describe("GetReplicaCount", func() {
 		Context("when the deployment exists", func() {
 			It("returns the deployment replica count", func() {
@@ -0,0 +1,257 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GenericClient is the client of the workloads of any kind implementing the scale subresource, i.e. CloneSets or the
+// CRDs of custom operators. The objects are handled as unstructured objects, with their pod template and replicas read
+// from the fields at the configured paths, and are scaled through their scale subresource.
+type GenericClient struct {
+	k8sClient           client.Client
+	gvk                 schema.GroupVersionKind
+	podTemplatePath     []string
+	replicasPath        []string
+	podResourceResolver *PodResourceResolver
+}
+
+// NewGenericClient returns the client of the workloads of the given kind. The paths are JSONPaths of plain fields,
+// such as .spec.template. If replicasPath is empty, the replicas are read from the scale subresource. The pods are
+// sized with the podResourceResolver, by the CPU limits of all of their containers if it's nil.
+func NewGenericClient(k8sClient client.Client,
+	gvk schema.GroupVersionKind,
+	podTemplatePath string,
+	replicasPath string,
+	podResourceResolver *PodResourceResolver) (ObjectClient, error) {
+	if gvk.Kind == "" || gvk.Version == "" {
+		return nil, fmt.Errorf("version and kind of the workload are required: %s", gvk)
+	}
+	templatePath, err := parseFieldPath(podTemplatePath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pod template path of %s: %w", gvk.Kind, err)
+	}
+	var replicas []string
+	if replicasPath != "" {
+		if replicas, err = parseFieldPath(replicasPath); err != nil {
+			return nil, fmt.Errorf("invalid replicas path of %s: %w", gvk.Kind, err)
+		}
+	}
+	if podResourceResolver == nil {
+		podResourceResolver = NewPodResourceResolver(k8sClient, ResourceModeLimits, true, nil)
+	}
+	return &GenericClient{
+		k8sClient:           k8sClient,
+		gvk:                 gvk,
+		podTemplatePath:     templatePath,
+		replicasPath:        replicas,
+		podResourceResolver: podResourceResolver,
+	}, nil
+}
+
+// parseFieldPath splits a JSONPath of plain fields, with or without the enclosing braces, into the names of the fields.
+func parseFieldPath(path string) ([]string, error) {
+	trimmed := strings.TrimPrefix(strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(path), "{"), "}"), ".")
+	if trimmed == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+	fields := strings.Split(trimmed, ".")
+	for _, field := range fields {
+		if field == "" || strings.ContainsAny(field, "[]*?@$()") {
+			return nil, fmt.Errorf("only paths of plain fields are supported: %s", path)
+		}
+	}
+	return fields, nil
+}
+
+func (gc *GenericClient) GetKind() string {
+	return gc.gvk.Kind
+}
+
+func (gc *GenericClient) GetObjectType() client.Object {
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(gc.gvk)
+	return object
+}
+
+func (gc *GenericClient) getObject(namespace string, name string) (*unstructured.Unstructured, error) {
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(gc.gvk)
+	if err := gc.k8sClient.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+func (gc *GenericClient) GetObject(namespace string, name string) (client.Object, error) {
+	object, err := gc.getObject(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+func (gc *GenericClient) GetMaxReplicaFromAnnotation(namespace string, name string) (int, error) {
+	object, err := gc.getObject(namespace, name)
+	if err != nil {
+		return 0, err
+	}
+	maxPodsAnnotation, ok := object.GetAnnotations()["ottoscalr.io/max-pods"]
+	if ok {
+		maxPods, err := strconv.Atoi(maxPodsAnnotation)
+		if err != nil {
+			return 0, fmt.Errorf("unable to convert maxPods from string to int: %s", err)
+		}
+		return maxPods, nil
+	}
+	return 0, fmt.Errorf("annotation not present")
+}
+
+// podTemplate returns the pod template at the pod template path of the object.
+func (gc *GenericClient) podTemplate(object *unstructured.Unstructured) (*corev1.PodTemplateSpec, error) {
+	fields, found, err := unstructured.NestedMap(object.Object, gc.podTemplatePath...)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("pod template not present at %s", strings.Join(gc.podTemplatePath, "."))
+	}
+	template := &corev1.PodTemplateSpec{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(fields, template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+func (gc *GenericClient) GetContainerResourceLimits(namespace string, name string) (float64, error) {
+	template, err := gc.GetPodTemplateSpec(namespace, name)
+	if err != nil {
+		return 0, err
+	}
+	return gc.podResourceResolver.GetCPU(namespace, template)
+}
+
+func (gc *GenericClient) GetContainerResourceRequests(namespace string, name string) (float64, error) {
+	template, err := gc.GetPodTemplateSpec(namespace, name)
+	if err != nil {
+		return 0, err
+	}
+	return gc.podResourceResolver.GetCPURequests(namespace, template)
+}
+
+func (gc *GenericClient) GetReplicaCount(namespace string, name string) (int, error) {
+	if len(gc.replicasPath) == 0 {
+		scale, err := gc.getScale(namespace, name)
+		if err != nil {
+			return 0, err
+		}
+		replicas, found, err := unstructured.NestedInt64(scale.Object, "spec", "replicas")
+		if err != nil || !found {
+			return 0, fmt.Errorf("replica count not present")
+		}
+		return int(replicas), nil
+	}
+
+	object, err := gc.getObject(namespace, name)
+	if err != nil {
+		return 0, err
+	}
+	replicas, found, err := unstructured.NestedInt64(object.Object, gc.replicasPath...)
+	if err != nil || !found {
+		return 0, fmt.Errorf("replica count not present")
+	}
+	return int(replicas), nil
+}
+
+// getScale returns the scale subresource of the object.
+func (gc *GenericClient) getScale(namespace string, name string) (*unstructured.Unstructured, error) {
+	scale := &unstructured.Unstructured{}
+	scale.SetGroupVersionKind(autoscalingv1.SchemeGroupVersion.WithKind("Scale"))
+	if err := gc.k8sClient.SubResource("scale").Get(context.Background(), gc.objectRef(namespace, name),
+		scale); err != nil {
+		return nil, err
+	}
+	return scale, nil
+}
+
+// objectRef returns an object referring to the workload with the given name, to address its subresources with.
+func (gc *GenericClient) objectRef(namespace string, name string) *unstructured.Unstructured {
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(gc.gvk)
+	object.SetNamespace(namespace)
+	object.SetName(name)
+	return object
+}
+
+func (gc *GenericClient) Scale(namespace string, name string, replicas int32) error {
+	scale := &unstructured.Unstructured{}
+	scale.SetGroupVersionKind(autoscalingv1.SchemeGroupVersion.WithKind("Scale"))
+	if err := unstructured.SetNestedField(scale.Object, int64(replicas), "spec", "replicas"); err != nil {
+		return err
+	}
+	if err := gc.k8sClient.SubResource("scale").Update(context.Background(), gc.objectRef(namespace, name),
+		client.WithSubResourceBody(scale)); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	return nil
+}
+
+func (gc *GenericClient) GetPodTemplateSpec(namespace string, name string) (*corev1.PodTemplateSpec, error) {
+	object, err := gc.getObject(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return gc.podTemplate(object)
+}
+
+// SetContainerResources sets the resources of the containers of the pod template in place, so that the fields of the
+// workload the typed pod template doesn't know of are left untouched.
+func (gc *GenericClient) SetContainerResources(namespace string, name string, resources map[string]corev1.ResourceRequirements) error {
+	object, err := gc.getObject(namespace, name)
+	if err != nil {
+		return err
+	}
+	template, err := gc.podTemplate(object)
+	if err != nil {
+		return err
+	}
+	if !mergeContainerResources(template, resources) {
+		return nil
+	}
+
+	original := object.DeepCopy()
+	containersPath := append(append([]string{}, gc.podTemplatePath...), "spec", "containers")
+	containers, _, err := unstructured.NestedSlice(object.Object, containersPath...)
+	if err != nil {
+		return err
+	}
+	for i := range containers {
+		container, ok := containers[i].(map[string]interface{})
+		if !ok || i >= len(template.Spec.Containers) {
+			continue
+		}
+		if _, ok := resources[template.Spec.Containers[i].Name]; !ok {
+			continue
+		}
+		requirements, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&template.Spec.Containers[i].Resources)
+		if err != nil {
+			return err
+		}
+		container["resources"] = requirements
+	}
+	if err := unstructured.SetNestedSlice(object.Object, containers, containersPath...); err != nil {
+		return err
+	}
+	return gc.k8sClient.Patch(context.Background(), object,
+		client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}))
+}
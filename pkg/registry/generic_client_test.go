@@ -0,0 +1,109 @@
+package registry
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("GenericClient", func() {
+
+	var (
+		namespace     = "generic-workloads"
+		cloneSetName  = "test-cloneset"
+		cloneSetGVK   = schema.GroupVersionKind{Group: "apps.kruise.io", Version: "v1alpha1", Kind: "CloneSet"}
+		genericClient ObjectClient
+	)
+
+	BeforeEach(func() {
+		cloneSet := &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":        cloneSetName,
+				"namespace":   namespace,
+				"annotations": map[string]interface{}{"ottoscalr.io/max-pods": "12"},
+			},
+			"spec": map[string]interface{}{
+				"replicas": int64(4),
+				"template": map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"labels": map[string]interface{}{"app": "test-cloneset"},
+					},
+					"spec": map[string]interface{}{
+						"containers": []interface{}{
+							map[string]interface{}{
+								"name":  "container-1",
+								"image": "container-image",
+								"resources": map[string]interface{}{
+									"requests": map[string]interface{}{"cpu": "500m"},
+									"limits":   map[string]interface{}{"cpu": "2"},
+								},
+								"unknownField": "kept",
+							},
+						},
+					},
+				},
+			},
+		}}
+		cloneSet.SetGroupVersionKind(cloneSetGVK)
+
+		var err error
+		genericClient, err = NewGenericClient(fake.NewClientBuilder().WithObjects(cloneSet).Build(), cloneSetGVK,
+			"{.spec.template}", ".spec.replicas", nil)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should reject the paths which aren't paths of plain fields", func() {
+		_, err := NewGenericClient(nil, cloneSetGVK, ".spec.templates[0]", "", nil)
+		Expect(err).To(HaveOccurred())
+		_, err = NewGenericClient(nil, cloneSetGVK, "", "", nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should return the kind and an unstructured object of the kind as the object type", func() {
+		Expect(genericClient.GetKind()).To(Equal("CloneSet"))
+		Expect(genericClient.GetObjectType().GetObjectKind().GroupVersionKind()).To(Equal(cloneSetGVK))
+	})
+
+	It("should read the max pods annotation, the replicas and the resources of the workload", func() {
+		maxReplicas, err := genericClient.GetMaxReplicaFromAnnotation(namespace, cloneSetName)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(maxReplicas).To(Equal(12))
+
+		replicas, err := genericClient.GetReplicaCount(namespace, cloneSetName)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(replicas).To(Equal(4))
+
+		limits, err := genericClient.GetContainerResourceLimits(namespace, cloneSetName)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(limits).To(Equal(2.0))
+
+		requests, err := genericClient.GetContainerResourceRequests(namespace, cloneSetName)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(requests).To(Equal(0.5))
+	})
+
+	It("should set the resources of the containers keeping their other fields", func() {
+		Expect(genericClient.SetContainerResources(namespace, cloneSetName,
+			map[string]corev1.ResourceRequirements{
+				"container-1": {
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("300m")},
+				},
+			})).To(Succeed())
+
+		template, err := genericClient.GetPodTemplateSpec(namespace, cloneSetName)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(template.Spec.Containers[0].Resources.Requests.Cpu().MilliValue()).To(Equal(int64(300)))
+		Expect(template.Spec.Containers[0].Resources.Limits.Cpu().MilliValue()).To(Equal(int64(2000)))
+
+		object, err := genericClient.GetObject(namespace, cloneSetName)
+		Expect(err).ToNot(HaveOccurred())
+		containers, _, _ := unstructured.NestedSlice(object.(*unstructured.Unstructured).Object,
+			"spec", "template", "spec", "containers")
+		Expect(containers[0].(map[string]interface{})["unknownField"]).To(Equal("kept"))
+	})
+})
@@ -0,0 +1,202 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ResourceMode decides which of the CPU resources of the containers make up the size of a pod.
+type ResourceMode string
+
+const (
+	// ResourceModeLimits sizes the pods by the CPU limits of their containers.
+	ResourceModeLimits ResourceMode = "Limits"
+	// ResourceModeRequests sizes the pods by the CPU requests of their containers.
+	ResourceModeRequests ResourceMode = "Requests"
+)
+
+// PodResourceResolver resolves the CPU resources of the pods of a workload. The pod template is the source of truth
+// for the containers it declares, with the defaults of the namespace's LimitRanges applied to the resources it leaves
+// unset, the same way the admission of the pods does. The pods observed for the workload add the containers which
+// aren't declared in the pod template, i.e. the sidecars injected by admission webhooks, if includeSidecars is set.
+// The containers named in excludedContainers are left out wherever they come from.
+//
+// Init containers are left out as they don't run alongside the containers of the pod.
+type PodResourceResolver struct {
+	k8sClient          client.Client
+	mode               ResourceMode
+	includeSidecars    bool
+	excludedContainers map[string]bool
+}
+
+// NewPodResourceResolver returns a PodResourceResolver. An unknown mode picks ResourceModeLimits.
+func NewPodResourceResolver(k8sClient client.Client,
+	mode ResourceMode,
+	includeSidecars bool,
+	excludedContainers []string) *PodResourceResolver {
+	if mode != ResourceModeRequests {
+		mode = ResourceModeLimits
+	}
+	excluded := make(map[string]bool, len(excludedContainers))
+	for _, name := range excludedContainers {
+		excluded[name] = true
+	}
+	return &PodResourceResolver{
+		k8sClient:          k8sClient,
+		mode:               mode,
+		includeSidecars:    includeSidecars,
+		excludedContainers: excluded,
+	}
+}
+
+// GetCPU returns the CPU resources of a pod of the workload with the given pod template, in cores.
+func (r *PodResourceResolver) GetCPU(namespace string, template *corev1.PodTemplateSpec) (float64, error) {
+	return r.getCPU(namespace, template, r.mode)
+}
+
+// GetCPURequests returns the CPU requests of a pod of the workload with the given pod template, in cores, whatever
+// the mode. The utilization targeted by the HPAs is relative to them.
+func (r *PodResourceResolver) GetCPURequests(namespace string, template *corev1.PodTemplateSpec) (float64, error) {
+	return r.getCPU(namespace, template, ResourceModeRequests)
+}
+
+func (r *PodResourceResolver) getCPU(namespace string, template *corev1.PodTemplateSpec, mode ResourceMode) (float64,
+	error) {
+	defaults, err := r.limitRangeDefaults(namespace)
+	if err != nil {
+		return 0, err
+	}
+
+	declared := make(map[string]bool, len(template.Spec.Containers))
+	cpuMillis := int64(0)
+	for _, container := range template.Spec.Containers {
+		declared[container.Name] = true
+		if r.excludedContainers[container.Name] {
+			continue
+		}
+		cpuMillis += containerCPU(defaults.apply(container.Resources), mode)
+	}
+
+	if r.includeSidecars {
+		sidecars, err := r.sidecarCPU(namespace, template, declared, mode)
+		if err != nil {
+			return 0, err
+		}
+		cpuMillis += sidecars
+	}
+
+	if cpuMillis == 0 {
+		return 0, fmt.Errorf("no cpu %s set on the containers of the workload", mode)
+	}
+	return float64(cpuMillis) / 1000, nil
+}
+
+// containerCPU returns the CPU resource of the container picked by the mode, in millicores.
+func containerCPU(resources corev1.ResourceRequirements, mode ResourceMode) int64 {
+	list := resources.Limits
+	if mode == ResourceModeRequests {
+		list = resources.Requests
+	}
+	if cpu, ok := list[corev1.ResourceCPU]; ok {
+		return cpu.MilliValue()
+	}
+	return 0
+}
+
+// sidecarCPU returns the CPU resources of the containers of the observed pods which aren't declared in the pod
+// template, in millicores. As the pods may belong to different revisions of the workload, the largest resources of
+// every sidecar across the pods are taken, so that the result doesn't depend on the order of the pods.
+func (r *PodResourceResolver) sidecarCPU(namespace string, template *corev1.PodTemplateSpec,
+	declared map[string]bool, mode ResourceMode) (int64, error) {
+	if len(template.Labels) == 0 {
+		return 0, nil
+	}
+	podList := &corev1.PodList{}
+	if err := r.k8sClient.List(context.Background(), podList, client.InNamespace(namespace),
+		client.MatchingLabelsSelector{Selector: labels.SelectorFromSet(template.Labels)}); err != nil {
+		return 0, err
+	}
+
+	sidecars := make(map[string]int64)
+	for _, pod := range podList.Items {
+		for _, container := range pod.Spec.Containers {
+			if declared[container.Name] || r.excludedContainers[container.Name] {
+				continue
+			}
+			if cpu := containerCPU(container.Resources, mode); cpu > sidecars[container.Name] {
+				sidecars[container.Name] = cpu
+			}
+		}
+	}
+	cpuMillis := int64(0)
+	for _, cpu := range sidecars {
+		cpuMillis += cpu
+	}
+	return cpuMillis, nil
+}
+
+// containerDefaults are the CPU resources the LimitRanges of a namespace default the containers to.
+type containerDefaults struct {
+	limit   *resource.Quantity
+	request *resource.Quantity
+}
+
+// limitRangeDefaults returns the CPU defaults of the container LimitRanges of the namespace. As with the admission of
+// the pods, the first LimitRange defining a default wins.
+func (r *PodResourceResolver) limitRangeDefaults(namespace string) (containerDefaults, error) {
+	defaults := containerDefaults{}
+	limitRanges := &corev1.LimitRangeList{}
+	if err := r.k8sClient.List(context.Background(), limitRanges, client.InNamespace(namespace)); err != nil {
+		return defaults, err
+	}
+	for _, limitRange := range limitRanges.Items {
+		for _, item := range limitRange.Spec.Limits {
+			if item.Type != corev1.LimitTypeContainer {
+				continue
+			}
+			if limit, ok := item.Default[corev1.ResourceCPU]; ok && defaults.limit == nil {
+				defaults.limit = &limit
+			}
+			// The default request of a LimitRange defaults to its default limit.
+			request, ok := item.DefaultRequest[corev1.ResourceCPU]
+			if !ok {
+				request, ok = item.Default[corev1.ResourceCPU]
+			}
+			if ok && defaults.request == nil {
+				defaults.request = &request
+			}
+		}
+	}
+	return defaults, nil
+}
+
+// apply returns the resources with the defaults set on the CPU resources left unset. As with the defaulting of the
+// pods, which runs before their admission, an unset request defaults to the limit set on the container if it has one.
+func (d containerDefaults) apply(resources corev1.ResourceRequirements) corev1.ResourceRequirements {
+	resources = *resources.DeepCopy()
+	limit, hasLimit := resources.Limits[corev1.ResourceCPU]
+	if _, ok := resources.Requests[corev1.ResourceCPU]; !ok {
+		request := d.request
+		if hasLimit {
+			request = &limit
+		}
+		if request != nil {
+			if resources.Requests == nil {
+				resources.Requests = corev1.ResourceList{}
+			}
+			resources.Requests[corev1.ResourceCPU] = *request
+		}
+	}
+	if !hasLimit && d.limit != nil {
+		if resources.Limits == nil {
+			resources.Limits = corev1.ResourceList{}
+		}
+		resources.Limits[corev1.ResourceCPU] = *d.limit
+	}
+	return resources
+}
@@ -0,0 +1,109 @@
+package registry
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("PodResourceResolver", func() {
+
+	var (
+		namespace = "pod-resources"
+		template  *corev1.PodTemplateSpec
+	)
+
+	container := func(name, request, limit string) corev1.Container {
+		c := corev1.Container{Name: name, Image: "container-image"}
+		if request != "" {
+			c.Resources.Requests = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(request)}
+		}
+		if limit != "" {
+			c.Resources.Limits = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(limit)}
+		}
+		return c
+	}
+
+	pod := func(name string, containers ...corev1.Container) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    map[string]string{"app": "test-app"},
+			},
+			Spec: corev1.PodSpec{Containers: containers},
+		}
+	}
+
+	BeforeEach(func() {
+		template = &corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "test-app"}},
+			Spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{container("init", "2", "4")},
+				Containers: []corev1.Container{
+					container("app", "500m", "1"),
+					container("worker", "", "500m"),
+					container("logger", "", ""),
+				},
+			},
+		}
+	})
+
+	It("should sum the CPU limits of the pod template without any pods", func() {
+		resolver := NewPodResourceResolver(fake.NewClientBuilder().Build(), ResourceModeLimits, true, nil)
+		cpu, err := resolver.GetCPU(namespace, template)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cpu).To(Equal(1.5))
+	})
+
+	It("should add the largest CPU limits of the sidecars injected into the pods", func() {
+		k8sClient := fake.NewClientBuilder().WithObjects(
+			pod("pod-1", template.Spec.Containers[0], container("istio-proxy", "100m", "300m")),
+			pod("pod-2", template.Spec.Containers[0], container("istio-proxy", "100m", "500m")),
+		).Build()
+
+		cpu, err := NewPodResourceResolver(k8sClient, ResourceModeLimits, true, nil).GetCPU(namespace, template)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cpu).To(Equal(2.0))
+
+		cpu, err = NewPodResourceResolver(k8sClient, ResourceModeLimits, false, nil).GetCPU(namespace, template)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cpu).To(Equal(1.5))
+
+		cpu, err = NewPodResourceResolver(k8sClient, ResourceModeLimits, true,
+			[]string{"istio-proxy", "worker"}).GetCPU(namespace, template)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cpu).To(Equal(1.0))
+	})
+
+	It("should sum the CPU requests with the defaults of the LimitRanges", func() {
+		limitRange := &corev1.LimitRange{
+			ObjectMeta: metav1.ObjectMeta{Name: "defaults", Namespace: namespace},
+			Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{{
+				Type:           corev1.LimitTypeContainer,
+				Default:        corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+				DefaultRequest: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("200m")},
+			}}},
+		}
+		k8sClient := fake.NewClientBuilder().WithObjects(limitRange).Build()
+
+		// The worker's request defaults to its limit and the logger's to the default request.
+		cpu, err := NewPodResourceResolver(k8sClient, ResourceModeRequests, true, nil).GetCPU(namespace, template)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cpu).To(Equal(1.2))
+
+		cpu, err = NewPodResourceResolver(k8sClient, ResourceModeLimits, true, nil).GetCPU(namespace, template)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cpu).To(Equal(3.5))
+	})
+
+	It("should fail if none of the containers have the CPU resources", func() {
+		template.Spec.Containers = []corev1.Container{container("logger", "", "")}
+		_, err := NewPodResourceResolver(fake.NewClientBuilder().Build(), ResourceModeRequests, true, nil).GetCPU(namespace, template)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,33 @@
+package registry
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// mergeContainerResources sets the requests and limits given per container name on the containers of the pod
+// template, leaving the resources which aren't given untouched. It returns true if any of them changed.
+func mergeContainerResources(template *corev1.PodTemplateSpec, resources map[string]corev1.ResourceRequirements) bool {
+	changed := false
+	merge := func(list *corev1.ResourceList, values corev1.ResourceList) {
+		for name, value := range values {
+			if current, ok := (*list)[name]; ok && current.Cmp(value) == 0 {
+				continue
+			}
+			if *list == nil {
+				*list = corev1.ResourceList{}
+			}
+			(*list)[name] = value
+			changed = true
+		}
+	}
+	for i := range template.Spec.Containers {
+		container := &template.Spec.Containers[i]
+		requirements, ok := resources[container.Name]
+		if !ok {
+			continue
+		}
+		merge(&container.Resources.Requests, requirements.Requests)
+		merge(&container.Resources.Limits, requirements.Limits)
+	}
+	return changed
+}
@@ -7,7 +7,6 @@ import (
 	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -21,14 +20,21 @@ var RolloutGVK = schema.GroupVersionKind{
 }
 
 type RolloutClient struct {
-	k8sClient client.Client
-	gvk       schema.GroupVersionKind
+	k8sClient           client.Client
+	gvk                 schema.GroupVersionKind
+	podResourceResolver *PodResourceResolver
 }
 
-func NewRolloutClient(k8sClient client.Client) ObjectClient {
+// NewRolloutClient returns the client of the Rollouts which sizes their pods with the podResourceResolver, by the CPU
+// limits of all of their containers if it's nil.
+func NewRolloutClient(k8sClient client.Client, podResourceResolver *PodResourceResolver) ObjectClient {
+	if podResourceResolver == nil {
+		podResourceResolver = NewPodResourceResolver(k8sClient, ResourceModeLimits, true, nil)
+	}
 	return &RolloutClient{
-		k8sClient: k8sClient,
-		gvk:       RolloutGVK,
+		k8sClient:           k8sClient,
+		gvk:                 RolloutGVK,
+		podResourceResolver: podResourceResolver,
 	}
 }
 
@@ -72,34 +78,15 @@ func (rc *RolloutClient) GetContainerResourceLimits(namespace string, name strin
 	if err := rc.k8sClient.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, rolloutObject); err != nil {
 		return 0, err
 	}
-	podTemplateSpec := rolloutObject.Spec.Template
-
-	podList := &corev1.PodList{}
-
-	if podTemplateSpec.Labels == nil {
-		return 0, fmt.Errorf("no labels present on the workload to fetch pod")
-	}
-
-	labelSet := labels.Set(podTemplateSpec.Labels)
-	selector := labels.SelectorFromSet(labelSet)
+	return rc.podResourceResolver.GetCPU(namespace, &rolloutObject.Spec.Template)
+}
 
-	if err := rc.k8sClient.List(context.Background(), podList, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+func (rc *RolloutClient) GetContainerResourceRequests(namespace string, name string) (float64, error) {
+	rolloutObject := &argov1alpha1.Rollout{}
+	if err := rc.k8sClient.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, rolloutObject); err != nil {
 		return 0, err
 	}
-
-	cpuLimitsSum := int64(0)
-
-	if len(podList.Items) == 0 {
-		return 0, fmt.Errorf("no pod found for the workload")
-	}
-
-	for _, container := range podList.Items[0].Spec.Containers {
-		if limit, ok := container.Resources.Limits[corev1.ResourceCPU]; ok {
-			cpuLimitsSum += limit.MilliValue()
-		}
-	}
-
-	return float64(cpuLimitsSum) / 1000, nil
+	return rc.podResourceResolver.GetCPURequests(namespace, &rolloutObject.Spec.Template)
 }
 
 func (rc *RolloutClient) GetReplicaCount(namespace string, name string) (int, error) {
@@ -133,3 +120,24 @@ func (rc *RolloutClient) Scale(namespace string, name string, replicas int32) er
 	}
 	return nil
 }
+
+func (rc *RolloutClient) GetPodTemplateSpec(namespace string, name string) (*corev1.PodTemplateSpec, error) {
+	rolloutObject := &argov1alpha1.Rollout{}
+	if err := rc.k8sClient.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, rolloutObject); err != nil {
+		return nil, err
+	}
+	return &rolloutObject.Spec.Template, nil
+}
+
+func (rc *RolloutClient) SetContainerResources(namespace string, name string, resources map[string]corev1.ResourceRequirements) error {
+	rolloutObject := &argov1alpha1.Rollout{}
+	if err := rc.k8sClient.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, rolloutObject); err != nil {
+		return err
+	}
+	original := rolloutObject.DeepCopy()
+	if !mergeContainerResources(&rolloutObject.Spec.Template, resources) {
+		return nil
+	}
+	return rc.k8sClient.Patch(context.Background(), rolloutObject,
+		client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}))
+}
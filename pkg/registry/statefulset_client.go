@@ -0,0 +1,143 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strconv"
+)
+
+var StatefulSetGVK = schema.GroupVersionKind{
+	Group:   "apps",
+	Version: "v1",
+	Kind:    "StatefulSet",
+}
+
+type StatefulSetClient struct {
+	k8sClient           client.Client
+	gvk                 schema.GroupVersionKind
+	podResourceResolver *PodResourceResolver
+}
+
+// NewStatefulSetClient returns the client of the StatefulSets which sizes their pods with the podResourceResolver, by
+// the CPU limits of all of their containers if it's nil.
+func NewStatefulSetClient(k8sClient client.Client, podResourceResolver *PodResourceResolver) ObjectClient {
+	if podResourceResolver == nil {
+		podResourceResolver = NewPodResourceResolver(k8sClient, ResourceModeLimits, true, nil)
+	}
+	return &StatefulSetClient{
+		k8sClient:           k8sClient,
+		gvk:                 StatefulSetGVK,
+		podResourceResolver: podResourceResolver,
+	}
+}
+
+func (sc *StatefulSetClient) GetKind() string {
+	return sc.gvk.Kind
+}
+
+func (sc *StatefulSetClient) GetObjectType() client.Object {
+	return &appsv1.StatefulSet{}
+}
+
+func (sc *StatefulSetClient) GetObject(namespace string, name string) (client.Object, error) {
+	statefulSetObject := &appsv1.StatefulSet{}
+	err := sc.k8sClient.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, statefulSetObject)
+	if err != nil {
+		return nil, err
+	}
+	return statefulSetObject, nil
+
+}
+
+func (sc *StatefulSetClient) GetMaxReplicaFromAnnotation(namespace string, name string) (int, error) {
+	statefulSetObject := &appsv1.StatefulSet{}
+	if err := sc.k8sClient.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, statefulSetObject); err != nil {
+		return 0, err
+	}
+	maxPodsAnnotation, ok := statefulSetObject.GetAnnotations()["ottoscalr.io/max-pods"]
+	if ok {
+		var err error
+		maxPods, err := strconv.Atoi(maxPodsAnnotation)
+		if err != nil {
+			return 0, fmt.Errorf("unable to convert maxPods from string to int: %s", err)
+		}
+		return maxPods, nil
+	}
+	return 0, fmt.Errorf("annotation not present")
+}
+
+func (sc *StatefulSetClient) GetContainerResourceLimits(namespace string, name string) (float64, error) {
+	statefulSetObject := &appsv1.StatefulSet{}
+	if err := sc.k8sClient.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, statefulSetObject); err != nil {
+		return 0, err
+	}
+	return sc.podResourceResolver.GetCPU(namespace, &statefulSetObject.Spec.Template)
+}
+
+func (sc *StatefulSetClient) GetContainerResourceRequests(namespace string, name string) (float64, error) {
+	statefulSetObject := &appsv1.StatefulSet{}
+	if err := sc.k8sClient.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, statefulSetObject); err != nil {
+		return 0, err
+	}
+	return sc.podResourceResolver.GetCPURequests(namespace, &statefulSetObject.Spec.Template)
+}
+
+func (sc *StatefulSetClient) GetReplicaCount(namespace string, name string) (int, error) {
+	statefulSetObject := &appsv1.StatefulSet{}
+	if err := sc.k8sClient.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, statefulSetObject); err != nil {
+		return 0, err
+	}
+	if statefulSetObject.Spec.Replicas == nil {
+		return 0, fmt.Errorf("replica count not present")
+	}
+	return int(*statefulSetObject.Spec.Replicas), nil
+}
+
+func (sc *StatefulSetClient) Scale(namespace string, name string, replicas int32) error {
+	var workloadPatch client.Object
+
+	workloadPatch = &appsv1.StatefulSet{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       sc.GetKind(),
+			APIVersion: "apps/v1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+
+	scale := &autoscalingv1.Scale{Spec: autoscalingv1.ScaleSpec{Replicas: replicas}}
+	if err := sc.k8sClient.SubResource("scale").Update(context.Background(), workloadPatch, client.WithSubResourceBody(scale)); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	return nil
+}
+
+func (sc *StatefulSetClient) GetPodTemplateSpec(namespace string, name string) (*corev1.PodTemplateSpec, error) {
+	statefulSetObject := &appsv1.StatefulSet{}
+	if err := sc.k8sClient.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, statefulSetObject); err != nil {
+		return nil, err
+	}
+	return &statefulSetObject.Spec.Template, nil
+}
+
+func (sc *StatefulSetClient) SetContainerResources(namespace string, name string, resources map[string]corev1.ResourceRequirements) error {
+	statefulSetObject := &appsv1.StatefulSet{}
+	if err := sc.k8sClient.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, statefulSetObject); err != nil {
+		return err
+	}
+	original := statefulSetObject.DeepCopy()
+	if !mergeContainerResources(&statefulSetObject.Spec.Template, resources) {
+		return nil
+	}
+	return sc.k8sClient.Patch(context.Background(), statefulSetObject,
+		client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}))
+}
@@ -0,0 +1,104 @@
+package registry
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("StatefulSetClient", func() {
+
+	var (
+		namespace         = "statefulsets"
+		statefulSetName   = "test-statefulset"
+		statefulSetClient ObjectClient
+	)
+
+	BeforeEach(func() {
+		statefulSet := &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        statefulSetName,
+				Namespace:   namespace,
+				Annotations: map[string]string{"ottoscalr.io/max-pods": "8"},
+			},
+			Spec: appsv1.StatefulSetSpec{
+				Replicas: int32Ptr(3),
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test-statefulset"}},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "test-statefulset"}},
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{
+								Name:  "container-1",
+								Image: "container-image",
+								Resources: corev1.ResourceRequirements{
+									Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("250m")},
+									Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+								},
+							},
+							{
+								Name:  "container-2",
+								Image: "container-image",
+								Resources: corev1.ResourceRequirements{
+									Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("250m")},
+									Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		statefulSetClient = NewStatefulSetClient(fake.NewClientBuilder().WithObjects(statefulSet).Build(), nil)
+	})
+
+	It("should return the kind and the object type of the StatefulSets", func() {
+		Expect(statefulSetClient.GetKind()).To(Equal("StatefulSet"))
+		Expect(statefulSetClient.GetObjectType()).To(Equal(&appsv1.StatefulSet{}))
+	})
+
+	It("should return the max pods annotation and the replica count of the statefulset", func() {
+		maxReplicas, err := statefulSetClient.GetMaxReplicaFromAnnotation(namespace, statefulSetName)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(maxReplicas).To(Equal(8))
+
+		replicas, err := statefulSetClient.GetReplicaCount(namespace, statefulSetName)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(replicas).To(Equal(3))
+	})
+
+	It("should return the cpu limits and requests of the pods of the statefulset", func() {
+		limits, err := statefulSetClient.GetContainerResourceLimits(namespace, statefulSetName)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(limits).To(Equal(1.5))
+
+		requests, err := statefulSetClient.GetContainerResourceRequests(namespace, statefulSetName)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(requests).To(Equal(0.5))
+	})
+
+	It("should set the given resources on the containers of the pod template", func() {
+		Expect(statefulSetClient.SetContainerResources(namespace, statefulSetName,
+			map[string]corev1.ResourceRequirements{
+				"container-2": {
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("300m")},
+					Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("600m")},
+				},
+			})).To(Succeed())
+
+		template, err := statefulSetClient.GetPodTemplateSpec(namespace, statefulSetName)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(template.Spec.Containers[0].Resources.Limits.Cpu().MilliValue()).To(Equal(int64(1000)))
+		Expect(template.Spec.Containers[1].Resources.Limits.Cpu().MilliValue()).To(Equal(int64(600)))
+	})
+
+	It("should return an error if the statefulset is not found", func() {
+		_, err := statefulSetClient.GetObject(namespace, "non-existent-statefulset")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -58,8 +58,8 @@ var _ = BeforeSuite(func() {
 		WithK8sClient(k8sClient).
 		Build()
 
-	deploymentClient = NewDeploymentClient(k8sClient)
-	rolloutClient = NewRolloutClient(k8sClient)
+	deploymentClient = NewDeploymentClient(k8sClient, nil)
+	rolloutClient = NewRolloutClient(k8sClient, nil)
 
 	go func() {
 		defer GinkgoRecover()
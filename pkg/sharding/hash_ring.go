@@ -0,0 +1,72 @@
+package sharding
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+)
+
+const defaultVirtualNodes = 100
+
+// hashRing is a consistent hash ring of the replicas. Every replica is placed on the ring multiple times as virtual
+// nodes so that the keys are spread evenly and only ~1/n of the keys move when a replica joins or leaves.
+type hashRing struct {
+	virtualNodes int
+	hashes       []uint32
+	owners       map[uint32]string
+}
+
+func newHashRing(members []string, virtualNodes int) *hashRing {
+	if virtualNodes <= 0 {
+		virtualNodes = defaultVirtualNodes
+	}
+	ring := &hashRing{
+		virtualNodes: virtualNodes,
+		hashes:       make([]uint32, 0, len(members)*virtualNodes),
+		owners:       make(map[uint32]string, len(members)*virtualNodes),
+	}
+	for _, member := range members {
+		for i := 0; i < virtualNodes; i++ {
+			hash := hashOf(fmt.Sprintf("%s#%d", member, i))
+			// On a collision the virtual node of the lexicographically smaller member wins so that every replica
+			// builds the same ring.
+			if owner, ok := ring.owners[hash]; ok {
+				if owner < member {
+					continue
+				}
+			} else {
+				ring.hashes = append(ring.hashes, hash)
+			}
+			ring.owners[hash] = member
+		}
+	}
+	sort.Slice(ring.hashes, func(i, j int) bool { return ring.hashes[i] < ring.hashes[j] })
+	return ring
+}
+
+// ownerOf returns the member which owns the key. It returns an empty string if the ring has no members.
+func (r *hashRing) ownerOf(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+	hash := hashOf(key)
+	idx := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= hash })
+	if idx == len(r.hashes) {
+		idx = 0
+	}
+	return r.owners[r.hashes[idx]]
+}
+
+// hashOf hashes the key with FNV-1a followed by the murmur3 finalizer. FNV alone clusters the hashes of similar keys
+// like the virtual node names of a replica, which skews the ring.
+func hashOf(key string) uint32 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	hash := h.Sum64()
+	hash ^= hash >> 33
+	hash *= 0xff51afd7ed558ccd
+	hash ^= hash >> 33
+	hash *= 0xc4ceb9fe1a85ec53
+	hash ^= hash >> 33
+	return uint32(hash)
+}
@@ -0,0 +1,54 @@
+package sharding
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("hashRing", func() {
+	keys := make([]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		keys = append(keys, fmt.Sprintf("namespace-%d/workload-%d", i%10, i))
+	}
+
+	It("should not own any key without members", func() {
+		ring := newHashRing(nil, 0)
+		Expect(ring.ownerOf("default/workload")).To(BeEmpty())
+	})
+
+	It("should spread the keys across all the members", func() {
+		ring := newHashRing([]string{"replica-0", "replica-1", "replica-2"}, 0)
+		owned := map[string]int{}
+		for _, key := range keys {
+			owned[ring.ownerOf(key)]++
+		}
+		Expect(owned).To(HaveLen(3))
+		for _, count := range owned {
+			Expect(count).To(BeNumerically(">", 200))
+		}
+	})
+
+	It("should build the same ring irrespective of the order of the members", func() {
+		ring1 := newHashRing([]string{"replica-0", "replica-1", "replica-2"}, 0)
+		ring2 := newHashRing([]string{"replica-2", "replica-0", "replica-1"}, 0)
+		for _, key := range keys {
+			Expect(ring1.ownerOf(key)).To(Equal(ring2.ownerOf(key)))
+		}
+	})
+
+	It("should only move the keys of the new member when a member joins", func() {
+		before := newHashRing([]string{"replica-0", "replica-1", "replica-2"}, 0)
+		after := newHashRing([]string{"replica-0", "replica-1", "replica-2", "replica-3"}, 0)
+		moved := 0
+		for _, key := range keys {
+			if before.ownerOf(key) != after.ownerOf(key) {
+				Expect(after.ownerOf(key)).To(Equal("replica-3"))
+				moved++
+			}
+		}
+		Expect(moved).To(BeNumerically(">", 0))
+		Expect(moved).To(BeNumerically("<", 400))
+	})
+})
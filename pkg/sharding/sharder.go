@@ -0,0 +1,315 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	p8smetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	ShardGroupLabel = "ottoscalr.io/shard-group"
+
+	defaultShardGroup    = "ottoscalr-shard"
+	defaultLeaseDuration = 30 * time.Second
+	defaultRenewInterval = 10 * time.Second
+
+	// staleLeaseDurations is the number of lease durations after its last renewal a Lease left behind by a replica
+	// which died without releasing it is deleted.
+	staleLeaseDurations = 2
+)
+
+var (
+	shardMembers = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "shard_members",
+			Help: "Number of live replicas the policyrecos are sharded across"}, []string{"group"},
+	)
+
+	shardRebalanceCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{Name: "shard_rebalance_count",
+			Help: "Number of times the shard membership changed"}, []string{"group"},
+	)
+)
+
+func init() {
+	p8smetrics.Registry.MustRegister(shardMembers, shardRebalanceCounter)
+}
+
+// Sharder decides which PolicyRecommendations are handled by this replica.
+type Sharder interface {
+	// Sharded returns true if the PolicyRecommendations are spread across the replicas. The components which use the
+	// Sharder run on every replica in that case instead of only on the leader.
+	Sharded() bool
+	// Owns returns true if the PolicyRecommendation identified by key belongs to the shard of this replica.
+	Owns(key types.NamespacedName) bool
+	// Subscribe returns a channel which is notified every time the shard membership changes. A channel subscribed
+	// after the membership was first synced is notified right away, so that its subscriber doesn't miss the first sync.
+	Subscribe() <-chan struct{}
+}
+
+// SingleShard is the Sharder used when sharding is disabled. It owns every PolicyRecommendation and never rebalances.
+type SingleShard struct{}
+
+func NewSingleShard() *SingleShard {
+	return &SingleShard{}
+}
+
+func (s *SingleShard) Sharded() bool {
+	return false
+}
+
+func (s *SingleShard) Owns(key types.NamespacedName) bool {
+	return true
+}
+
+func (s *SingleShard) Subscribe() <-chan struct{} {
+	return nil
+}
+
+// LeaseSharder shards the PolicyRecommendations across the replicas using a consistent hash ring. Every replica
+// holds a Lease labelled with the shard group and renews it periodically; the replicas with a live Lease form the
+// ring. A replica which stops renewing its Lease drops out of the ring once the Lease expires.
+type LeaseSharder struct {
+	k8sClient     client.Client
+	apiReader     client.Reader
+	namespace     string
+	group         string
+	identity      string
+	leaseDuration time.Duration
+	renewInterval time.Duration
+	virtualNodes  int
+	logger        logr.Logger
+
+	mutex       sync.RWMutex
+	members     []string
+	ring        *hashRing
+	subscribers []chan struct{}
+}
+
+func NewLeaseSharder(k8sClient client.Client,
+	apiReader client.Reader,
+	namespace string,
+	group string,
+	identity string,
+	leaseDuration time.Duration,
+	renewInterval time.Duration,
+	virtualNodes int,
+	logger logr.Logger) *LeaseSharder {
+
+	if len(group) == 0 {
+		group = defaultShardGroup
+	}
+	if leaseDuration == 0 {
+		leaseDuration = defaultLeaseDuration
+	}
+	if renewInterval == 0 {
+		renewInterval = defaultRenewInterval
+	}
+	return &LeaseSharder{
+		k8sClient:     k8sClient,
+		apiReader:     apiReader,
+		namespace:     namespace,
+		group:         group,
+		identity:      identity,
+		leaseDuration: leaseDuration,
+		renewInterval: renewInterval,
+		virtualNodes:  virtualNodes,
+		ring:          newHashRing(nil, virtualNodes),
+		logger:        logger.WithName("LeaseSharder"),
+	}
+}
+
+func (s *LeaseSharder) Sharded() bool {
+	return true
+}
+
+func (s *LeaseSharder) Owns(key types.NamespacedName) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.ring.ownerOf(key.String()) == s.identity
+}
+
+func (s *LeaseSharder) Subscribe() <-chan struct{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ch := make(chan struct{}, 1)
+	if len(s.members) > 0 {
+		ch <- struct{}{}
+	}
+	s.subscribers = append(s.subscribers, ch)
+	return ch
+}
+
+// Start renews the Lease of this replica and refreshes the shard membership until the context is cancelled.
+func (s *LeaseSharder) Start(ctx context.Context) error {
+	s.logger.Info("Starting sharder.", "identity", s.identity, "group", s.group)
+	ticker := time.NewTicker(s.renewInterval)
+	defer ticker.Stop()
+	for {
+		s.sync(ctx)
+		select {
+		case <-ctx.Done():
+			s.releaseLease()
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection ensures that the sharder runs on every replica.
+func (s *LeaseSharder) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *LeaseSharder) sync(ctx context.Context) {
+	renewed := true
+	if err := s.renewLease(ctx); err != nil {
+		s.logger.Error(err, "Error while renewing the shard lease.")
+		renewed = false
+	}
+	members, err := s.liveMembers(ctx)
+	if err != nil {
+		s.logger.Error(err, "Error while listing the shard leases.")
+		return
+	}
+	if !renewed {
+		// Other replicas will drop this replica from the ring once its lease expires. Stop owning any shard
+		// right away so that no policyreco is handled twice.
+		members = removeMember(members, s.identity)
+	}
+	s.updateMembers(members)
+}
+
+func (s *LeaseSharder) leaseName() string {
+	return fmt.Sprintf("%s-%s", s.group, s.identity)
+}
+
+func (s *LeaseSharder) renewLease(ctx context.Context) error {
+	now := metav1.NewMicroTime(time.Now())
+	leaseDurationSeconds := int32(s.leaseDuration.Seconds())
+
+	lease := &coordinationv1.Lease{}
+	err := s.apiReader.Get(ctx, types.NamespacedName{Namespace: s.namespace, Name: s.leaseName()}, lease)
+	if errors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      s.leaseName(),
+				Namespace: s.namespace,
+				Labels:    map[string]string{ShardGroupLabel: s.group},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &s.identity,
+				LeaseDurationSeconds: &leaseDurationSeconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		return s.k8sClient.Create(ctx, lease)
+	}
+	if err != nil {
+		return err
+	}
+	lease.Spec.HolderIdentity = &s.identity
+	lease.Spec.LeaseDurationSeconds = &leaseDurationSeconds
+	lease.Spec.RenewTime = &now
+	return s.k8sClient.Update(ctx, lease)
+}
+
+// releaseLease deletes the Lease of this replica so that the other replicas take over its shard without waiting for
+// the Lease to expire.
+func (s *LeaseSharder) releaseLease() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.renewInterval)
+	defer cancel()
+	lease := &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Namespace: s.namespace, Name: s.leaseName()}}
+	if err := client.IgnoreNotFound(s.k8sClient.Delete(ctx, lease)); err != nil {
+		s.logger.Error(err, "Error while releasing the shard lease.")
+	}
+}
+
+// liveMembers returns the holders of the live Leases of the shard group. The Leases left behind by the replicas which
+// died without releasing them, like the OOM killed or evicted ones, are deleted once they are stale.
+func (s *LeaseSharder) liveMembers(ctx context.Context) ([]string, error) {
+	leases := &coordinationv1.LeaseList{}
+	if err := s.apiReader.List(ctx, leases, client.InNamespace(s.namespace),
+		client.MatchingLabels{ShardGroupLabel: s.group}); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var members []string
+	for _, lease := range leases.Items {
+		if lease.Spec.HolderIdentity == nil || lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+			continue
+		}
+		leaseDuration := time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+		expiry := lease.Spec.RenewTime.Add(leaseDuration)
+		if expiry.After(now) {
+			members = append(members, *lease.Spec.HolderIdentity)
+		} else if lease.Spec.RenewTime.Add(staleLeaseDurations * leaseDuration).Before(now) {
+			s.deleteStaleLease(ctx, lease)
+		}
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+// deleteStaleLease deletes the Lease unless it was renewed since it was listed.
+func (s *LeaseSharder) deleteStaleLease(ctx context.Context, lease coordinationv1.Lease) {
+	s.logger.Info("Deleting the stale shard lease.", "lease", lease.Name, "renewTime", lease.Spec.RenewTime)
+	err := s.k8sClient.Delete(ctx, &lease, client.Preconditions{ResourceVersion: &lease.ResourceVersion})
+	if err != nil && !errors.IsNotFound(err) && !errors.IsConflict(err) {
+		s.logger.Error(err, "Error while deleting the stale shard lease.", "lease", lease.Name)
+	}
+}
+
+func (s *LeaseSharder) updateMembers(members []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if equalMembers(s.members, members) {
+		return
+	}
+	s.logger.Info("Shard membership changed. Rebalancing.", "previous", s.members, "current", members)
+	s.members = members
+	s.ring = newHashRing(members, s.virtualNodes)
+	shardMembers.WithLabelValues(s.group).Set(float64(len(members)))
+	shardRebalanceCounter.WithLabelValues(s.group).Inc()
+	for _, ch := range s.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+			// A rebalance is already pending for this subscriber.
+		}
+	}
+}
+
+func equalMembers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func removeMember(members []string, member string) []string {
+	var result []string
+	for _, m := range members {
+		if m != member {
+			result = append(result, m)
+		}
+	}
+	return result
+}
@@ -0,0 +1,147 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+var _ = Describe("LeaseSharder", func() {
+	const namespace = "ottoscalr"
+
+	var (
+		k8sClient client.Client
+		keys      []types.NamespacedName
+	)
+
+	newSharder := func(identity string) *LeaseSharder {
+		return NewLeaseSharder(k8sClient, k8sClient, namespace, "", identity, 30*time.Second, time.Second, 0,
+			zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
+	}
+
+	ownersOf := func(key types.NamespacedName, sharders ...*LeaseSharder) int {
+		owners := 0
+		for _, sharder := range sharders {
+			if sharder.Owns(key) {
+				owners++
+			}
+		}
+		return owners
+	}
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(coordinationv1.AddToScheme(scheme)).To(Succeed())
+		k8sClient = fake.NewClientBuilder().WithScheme(scheme).Build()
+		keys = nil
+		for i := 0; i < 100; i++ {
+			keys = append(keys, types.NamespacedName{Namespace: "default", Name: fmt.Sprintf("workload-%d", i)})
+		}
+	})
+
+	It("should not own any policyreco before the membership is known", func() {
+		sharder := newSharder("replica-0")
+		Expect(sharder.Owns(keys[0])).To(BeFalse())
+	})
+
+	It("should split the policyrecos between the live replicas", func() {
+		sharder0 := newSharder("replica-0")
+		sharder1 := newSharder("replica-1")
+		sharder0.sync(context.TODO())
+		sharder1.sync(context.TODO())
+		sharder0.sync(context.TODO())
+
+		for _, key := range keys {
+			Expect(ownersOf(key, sharder0, sharder1)).To(Equal(1))
+		}
+	})
+
+	It("should notify the subscribers and take over the shard of a replica which left", func() {
+		sharder0 := newSharder("replica-0")
+		sharder1 := newSharder("replica-1")
+		rebalanced := sharder0.Subscribe()
+		sharder1.sync(context.TODO())
+		sharder0.sync(context.TODO())
+		Eventually(rebalanced).Should(Receive())
+
+		By("Expiring the lease of the other replica")
+		lease := &coordinationv1.Lease{}
+		Expect(k8sClient.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: sharder1.leaseName()}, lease)).To(Succeed())
+		expired := metav1.NewMicroTime(time.Now().Add(-time.Minute))
+		lease.Spec.RenewTime = &expired
+		Expect(k8sClient.Update(context.TODO(), lease)).To(Succeed())
+
+		sharder0.sync(context.TODO())
+		Eventually(rebalanced).Should(Receive())
+		for _, key := range keys {
+			Expect(sharder0.Owns(key)).To(BeTrue())
+		}
+	})
+
+	It("should notify a subscriber which subscribed after the first sync", func() {
+		sharder := newSharder("replica-0")
+		sharder.sync(context.TODO())
+		Eventually(sharder.Subscribe()).Should(Receive())
+	})
+
+	It("should delete the stale leases of the replicas which died without releasing them", func() {
+		newLease := func(identity string, renewedAgo time.Duration) *coordinationv1.Lease {
+			renewTime := metav1.NewMicroTime(time.Now().Add(-renewedAgo))
+			leaseDurationSeconds := int32(30)
+			return &coordinationv1.Lease{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: namespace,
+					Name:      fmt.Sprintf("%s-%s", defaultShardGroup, identity),
+					Labels:    map[string]string{ShardGroupLabel: defaultShardGroup},
+				},
+				Spec: coordinationv1.LeaseSpec{
+					HolderIdentity:       &identity,
+					LeaseDurationSeconds: &leaseDurationSeconds,
+					RenewTime:            &renewTime,
+				},
+			}
+		}
+		stale := newLease("replica-1", time.Hour)
+		expired := newLease("replica-2", 45*time.Second)
+		Expect(k8sClient.Create(context.TODO(), stale)).To(Succeed())
+		Expect(k8sClient.Create(context.TODO(), expired)).To(Succeed())
+
+		sharder := newSharder("replica-0")
+		sharder.sync(context.TODO())
+		for _, key := range keys {
+			Expect(sharder.Owns(key)).To(BeTrue())
+		}
+
+		err := k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(stale), &coordinationv1.Lease{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		Expect(k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(expired), &coordinationv1.Lease{})).To(Succeed())
+	})
+
+	It("should release the lease when stopped", func() {
+		sharder := newSharder("replica-0")
+		ctx, cancel := context.WithCancel(context.TODO())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = sharder.Start(ctx)
+		}()
+		Eventually(func() bool { return sharder.Owns(keys[0]) }).Should(BeTrue())
+
+		cancel()
+		Eventually(done).Should(BeClosed())
+		leases := &coordinationv1.LeaseList{}
+		Expect(k8sClient.List(context.TODO(), leases)).To(Succeed())
+		Expect(leases.Items).To(BeEmpty())
+	})
+})
@@ -0,0 +1,118 @@
+package sharding
+
+import (
+	"context"
+
+	"github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// RebalanceSource is a source.Source which enqueues the objects owned by this replica every time the shard membership
+// changes, so that the objects moved to this replica are picked up without waiting for a fresh event. The first
+// membership sync counts as a change too, so the events dropped while no shard was owned yet are made up for.
+type RebalanceSource struct {
+	sharder Sharder
+	list    func(ctx context.Context) ([]types.NamespacedName, error)
+	logger  logr.Logger
+}
+
+// NewRebalanceSource returns a RebalanceSource which enqueues the owned PolicyRecommendations accepted by the filter.
+// A nil filter accepts every PolicyRecommendation.
+func NewRebalanceSource(k8sClient client.Reader,
+	sharder Sharder,
+	filter func(policyreco v1alpha1.PolicyRecommendation) bool,
+	logger logr.Logger) *RebalanceSource {
+	list := func(ctx context.Context) ([]types.NamespacedName, error) {
+		policyRecos := v1alpha1.PolicyRecommendationList{}
+		if err := k8sClient.List(ctx, &policyRecos); err != nil {
+			return nil, err
+		}
+		var keys []types.NamespacedName
+		for _, policyreco := range policyRecos.Items {
+			if filter != nil && !filter(policyreco) {
+				continue
+			}
+			keys = append(keys, types.NamespacedName{Namespace: policyreco.Namespace, Name: policyreco.Name})
+		}
+		return keys, nil
+	}
+	return &RebalanceSource{
+		sharder: sharder,
+		list:    list,
+		logger:  logger,
+	}
+}
+
+// NewWorkloadRebalanceSource returns a RebalanceSource which enqueues the owned workloads of the given kinds accepted
+// by the filter. Only the metadata of the workloads is listed. A nil filter accepts every workload.
+func NewWorkloadRebalanceSource(k8sClient client.Reader,
+	sharder Sharder,
+	gvks []schema.GroupVersionKind,
+	filter func(key types.NamespacedName) bool,
+	logger logr.Logger) *RebalanceSource {
+	list := func(ctx context.Context) ([]types.NamespacedName, error) {
+		var keys []types.NamespacedName
+		for _, gvk := range gvks {
+			workloads := metav1.PartialObjectMetadataList{}
+			workloads.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+			if err := k8sClient.List(ctx, &workloads); err != nil {
+				return nil, err
+			}
+			for _, workload := range workloads.Items {
+				key := types.NamespacedName{Namespace: workload.Namespace, Name: workload.Name}
+				if filter != nil && !filter(key) {
+					continue
+				}
+				keys = append(keys, key)
+			}
+		}
+		return keys, nil
+	}
+	return &RebalanceSource{
+		sharder: sharder,
+		list:    list,
+		logger:  logger,
+	}
+}
+
+// Start enqueues the owned objects directly on the queue. The event handler and the predicates are not used as there
+// is no object event to run them against.
+func (s *RebalanceSource) Start(ctx context.Context,
+	_ handler.EventHandler,
+	queue workqueue.RateLimitingInterface,
+	_ ...predicate.Predicate) error {
+	rebalanced := s.sharder.Subscribe()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-rebalanced:
+				s.enqueueOwned(ctx, queue)
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *RebalanceSource) enqueueOwned(ctx context.Context, queue workqueue.RateLimitingInterface) {
+	keys, err := s.list(ctx)
+	if err != nil {
+		s.logger.Error(err, "Error while listing the objects to enqueue after rebalance.")
+		return
+	}
+	for _, key := range keys {
+		if !s.sharder.Owns(key) {
+			continue
+		}
+		queue.Add(reconcile.Request{NamespacedName: key})
+	}
+}
@@ -0,0 +1,60 @@
+package sharding
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("WorkloadRebalanceSource", func() {
+	var k8sClient client.Client
+
+	deployment := func(namespace, name string) *appsv1.Deployment {
+		return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	}
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(coordinationv1.AddToScheme(scheme)).To(Succeed())
+		Expect(appsv1.AddToScheme(scheme)).To(Succeed())
+		k8sClient = fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(deployment("default", "workload-0"), deployment("excluded", "workload-1")).
+			Build()
+	})
+
+	It("should enqueue the owned workloads once the membership is first synced", func() {
+		logger := zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true))
+		sharder := NewLeaseSharder(k8sClient, k8sClient, "ottoscalr", "", "replica-0", 30*time.Second, time.Second, 0,
+			logger)
+		filter := func(key types.NamespacedName) bool {
+			return key.Namespace != "excluded"
+		}
+		source := NewWorkloadRebalanceSource(k8sClient, sharder,
+			[]schema.GroupVersionKind{appsv1.SchemeGroupVersion.WithKind("Deployment")}, filter, logger)
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		defer cancel()
+		queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+		defer queue.ShutDown()
+		Expect(source.Start(ctx, nil, queue)).To(Succeed())
+		Consistently(queue.Len).Should(BeZero())
+
+		sharder.sync(context.TODO())
+		Eventually(queue.Len).Should(Equal(1))
+		item, _ := queue.Get()
+		Expect(item).To(Equal(reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "workload-0"}}))
+	})
+})
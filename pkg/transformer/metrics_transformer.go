@@ -9,6 +9,9 @@ import (
 	"time"
 )
 
+// EventOutlierReason is the reason of the intervals ignored for the events declared by the event integrations.
+const EventOutlierReason = "Event"
+
 type OutlierInterval struct {
 	StartTime time.Time
 	EndTime   time.Time
@@ -28,18 +31,33 @@ func NewOutlierInterpolatorTransformer(eventIntegration []integration.EventInteg
 }
 
 func (ot *OutlierInterpolatorTransformer) Transform(startTime time.Time, endTime time.Time, dataPoints []metrics.DataPoint) ([]metrics.DataPoint, error) {
+	newDataPoints, _, err := ot.TransformAndAudit(startTime, endTime, dataPoints)
+	return newDataPoints, err
+}
+
+// TransformAndAudit interpolates the data points during the events like Transform, and reports the intervals of the
+// events as ignored.
+func (ot *OutlierInterpolatorTransformer) TransformAndAudit(startTime time.Time, endTime time.Time, dataPoints []metrics.DataPoint) ([]metrics.DataPoint, []metrics.IgnoredInterval, error) {
 	var eventDetails []integration.EventDetails
 	for _, ei := range ot.EventIntegration {
 		events, err := ei.GetDesiredEvents(startTime, endTime)
 		if err != nil {
-			return nil, fmt.Errorf("error in getting events from event integration: %v", err)
+			return nil, nil, fmt.Errorf("error in getting events from event integration: %v", err)
 		}
 		eventDetails = append(eventDetails, events...)
 	}
 	intervals := getOutlierIntervals(eventDetails)
 	intervals = filterIntervals(intervals, startTime, endTime)
 	newDataPoints := ot.cleanOutliersAndInterpolate(dataPoints, intervals)
-	return newDataPoints, nil
+	var ignored []metrics.IgnoredInterval
+	for _, interval := range intervals {
+		ignored = append(ignored, metrics.IgnoredInterval{
+			StartTime: interval.StartTime,
+			EndTime:   interval.EndTime,
+			Reason:    EventOutlierReason,
+		})
+	}
+	return newDataPoints, ignored, nil
 }
 
 func getOutlierIntervals(eventDetails []integration.EventDetails) []OutlierInterval {
@@ -90,12 +108,18 @@ func filterIntervals(intervals []OutlierInterval, start time.Time, end time.Time
 
 // CleanOutliersAndInterpolate - Linear Interpolation for the dataPoints in interval range.
 func (ot *OutlierInterpolatorTransformer) cleanOutliersAndInterpolate(dataPoints []metrics.DataPoint, intervals []OutlierInterval) []metrics.DataPoint {
+	return cleanOutliersAndInterpolate(dataPoints, intervals, ot.logger)
+}
+
+// cleanOutliersAndInterpolate linearly interpolates the data points strictly within the intervals from the data points
+// bordering them. The intervals at the start or the end of the data points are removed instead.
+func cleanOutliersAndInterpolate(dataPoints []metrics.DataPoint, intervals []OutlierInterval, logger logr.Logger) []metrics.DataPoint {
 	var newDataPoints []metrics.DataPoint
 	for _, dataPoint := range dataPoints {
 		newDataPoints = append(newDataPoints, dataPoint)
 	}
 	for _, interval := range intervals {
-		ot.logger.V(2).Info("Interpolating for interval: ", "start", interval.StartTime, "end", interval.EndTime)
+		logger.V(2).Info("Interpolating for interval: ", "start", interval.StartTime, "end", interval.EndTime)
 		startIndex := -1
 		endIndex := -1
 		for i := 0; i < len(newDataPoints); i++ {
@@ -0,0 +1,238 @@
+package transformer
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	argov1alpha1 "github.com/argoproj/argo-rollouts/pkg/apis/rollouts/v1alpha1"
+	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// RolloutOutlierReason is the reason of the intervals ignored for the rollouts of the workloads.
+	RolloutOutlierReason = "Rollout"
+
+	defaultMaxRolloutDuration = 30 * time.Minute
+	defaultRolloutWarmUp      = 5 * time.Minute
+)
+
+// RolloutOutlierTransformer interpolates the utilization of the workloads during their rollouts, which is distorted
+// by the surge pods and the warm-up of the new pods. A rollout starts with the creation of a ReplicaSet owned by the
+// workload, i.e. a Deployment or an Argo Rollout, and lasts until the last of its pods got ready, plus warmUp. The
+// pods of the ReplicaSets scaled down since are gone, so their rollouts are taken to last maxRolloutDuration.
+//
+// The rollout still in progress, i.e. a Deployment whose pods aren't all updated yet or an Argo Rollout going through
+// its steps, lasts until the end of the utilization, within maxRolloutDuration of its start. The newest ReplicaSet
+// without all its replicas ready after its rollout completed, e.g. on a scale-up or a crashing pod, isn't rolling out.
+// The rollbacks to the ReplicaSet of a former revision don't create a ReplicaSet and are left out.
+type RolloutOutlierTransformer struct {
+	k8sClient          client.Client
+	maxRolloutDuration time.Duration
+	warmUp             time.Duration
+	logger             logr.Logger
+}
+
+// NewRolloutOutlierTransformer returns a RolloutOutlierTransformer. The zero values pick the defaults.
+func NewRolloutOutlierTransformer(k8sClient client.Client,
+	maxRolloutDuration time.Duration,
+	warmUp time.Duration,
+	logger logr.Logger) (*RolloutOutlierTransformer, error) {
+	if maxRolloutDuration <= 0 {
+		maxRolloutDuration = defaultMaxRolloutDuration
+	}
+	if warmUp <= 0 {
+		warmUp = defaultRolloutWarmUp
+	}
+	return &RolloutOutlierTransformer{
+		k8sClient:          k8sClient,
+		maxRolloutDuration: maxRolloutDuration,
+		warmUp:             warmUp,
+		logger:             logger,
+	}, nil
+}
+
+// Transform leaves the data points untouched, as the rollouts are those of a workload.
+func (rt *RolloutOutlierTransformer) Transform(startTime time.Time, endTime time.Time, dataPoints []metrics.DataPoint) ([]metrics.DataPoint, error) {
+	return dataPoints, nil
+}
+
+// TransformAndAudit leaves the data points untouched, as the rollouts are those of a workload.
+func (rt *RolloutOutlierTransformer) TransformAndAudit(startTime time.Time, endTime time.Time, dataPoints []metrics.DataPoint) ([]metrics.DataPoint, []metrics.IgnoredInterval, error) {
+	return dataPoints, nil, nil
+}
+
+// TransformWorkloadAndAudit interpolates the data points during the rollouts of the workload, and reports the
+// intervals of the rollouts as ignored.
+func (rt *RolloutOutlierTransformer) TransformWorkloadAndAudit(namespace string, kind string, name string,
+	startTime time.Time, endTime time.Time, dataPoints []metrics.DataPoint) ([]metrics.DataPoint, []metrics.IgnoredInterval, error) {
+	intervals, err := rt.getRolloutIntervals(namespace, kind, name, endTime)
+	if err != nil {
+		return nil, nil, err
+	}
+	intervals = filterIntervals(intervals, startTime, endTime)
+	if len(intervals) == 0 {
+		return dataPoints, nil, nil
+	}
+
+	// The intervals are widened by the start of the rollouts, as the data points bordering them are excluded from the
+	// interpolation.
+	var ignored []metrics.IgnoredInterval
+	widened := make([]OutlierInterval, 0, len(intervals))
+	for _, interval := range intervals {
+		ignored = append(ignored, metrics.IgnoredInterval{
+			StartTime: interval.StartTime,
+			EndTime:   interval.EndTime,
+			Reason:    RolloutOutlierReason,
+		})
+		widened = append(widened, OutlierInterval{StartTime: interval.StartTime.Add(-time.Nanosecond),
+			EndTime: interval.EndTime})
+		rt.logger.V(1).Info("Ignoring rollout", "workload", name, "start", interval.StartTime,
+			"end", interval.EndTime)
+	}
+	return cleanOutliersAndInterpolate(dataPoints, widened, rt.logger), ignored, nil
+}
+
+// getRolloutIntervals returns the intervals of the rollouts of the ReplicaSets owned by the workload, in the order of
+// their start.
+func (rt *RolloutOutlierTransformer) getRolloutIntervals(namespace string, kind string, name string,
+	endTime time.Time) ([]OutlierInterval, error) {
+	replicaSetList := &appsv1.ReplicaSetList{}
+	if err := rt.k8sClient.List(context.Background(), replicaSetList, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	var replicaSets []appsv1.ReplicaSet
+	for _, replicaSet := range replicaSetList.Items {
+		if isOwnedBy(replicaSet.OwnerReferences, kind, name) {
+			replicaSets = append(replicaSets, replicaSet)
+		}
+	}
+	if len(replicaSets) == 0 {
+		return nil, nil
+	}
+	sort.SliceStable(replicaSets, func(i, j int) bool {
+		return replicaSets[i].CreationTimestamp.Before(&replicaSets[j].CreationTimestamp)
+	})
+
+	progressing, err := rt.isRolloutProgressing(namespace, kind, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var intervals []OutlierInterval
+	for i, replicaSet := range replicaSets {
+		start := replicaSet.CreationTimestamp.Time
+		latest := i == len(replicaSets)-1
+		end, err := rt.getRolloutEnd(&replicaSet)
+		if err != nil {
+			return nil, err
+		}
+		if latest && progressing {
+			end = endTime
+			if maxEnd := start.Add(rt.maxRolloutDuration); maxEnd.Before(end) {
+				end = maxEnd
+			}
+		}
+		intervals = append(intervals, OutlierInterval{StartTime: start, EndTime: end})
+	}
+	return intervals, nil
+}
+
+// getRolloutEnd returns the time the last of the pods of the ReplicaSet got ready plus the warm-up, within
+// maxRolloutDuration of its creation.
+func (rt *RolloutOutlierTransformer) getRolloutEnd(replicaSet *appsv1.ReplicaSet) (time.Time, error) {
+	maxEnd := replicaSet.CreationTimestamp.Add(rt.maxRolloutDuration)
+	if replicaSet.Spec.Replicas == nil || *replicaSet.Spec.Replicas == 0 {
+		return maxEnd, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(replicaSet.Spec.Selector)
+	if err != nil {
+		return time.Time{}, err
+	}
+	podList := &corev1.PodList{}
+	if err := rt.k8sClient.List(context.Background(), podList, client.InNamespace(replicaSet.Namespace),
+		client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return time.Time{}, err
+	}
+
+	var readyAt time.Time
+	for _, pod := range podList.Items {
+		if !isOwnedByUID(pod.OwnerReferences, replicaSet.UID) {
+			continue
+		}
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue &&
+				condition.LastTransitionTime.After(readyAt) {
+				readyAt = condition.LastTransitionTime.Time
+			}
+		}
+	}
+	if readyAt.IsZero() {
+		return maxEnd, nil
+	}
+	if end := readyAt.Add(rt.warmUp); end.Before(maxEnd) {
+		return end, nil
+	}
+	return maxEnd, nil
+}
+
+// isRolloutProgressing returns true if the rollout of the workload is in progress, i.e. if it is a Deployment whose
+// pods aren't all updated to its current revision yet, or an Argo Rollout whose current revision hasn't become stable
+// yet.
+func (rt *RolloutOutlierTransformer) isRolloutProgressing(namespace string, kind string, name string) (bool, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	switch kind {
+	case "Deployment":
+		deployment := &appsv1.Deployment{}
+		if err := rt.k8sClient.Get(context.Background(), key, deployment); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+		return isDeploymentProgressing(deployment), nil
+	case "Rollout":
+		rollout := &argov1alpha1.Rollout{}
+		if err := rt.k8sClient.Get(context.Background(), key, rollout); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+		return rollout.Status.StableRS != "" && rollout.Status.StableRS != rollout.Status.CurrentPodHash, nil
+	default:
+		return false, nil
+	}
+}
+
+// isDeploymentProgressing returns true if the Deployment controller hasn't observed the current revision of the
+// Deployment yet, or if some pods of the former revisions haven't been replaced yet. The readiness of the pods is left
+// out, as the pods of a completed rollout may be unready for other reasons.
+func isDeploymentProgressing(deployment *appsv1.Deployment) bool {
+	if deployment.Generation > deployment.Status.ObservedGeneration {
+		return true
+	}
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	return deployment.Status.UpdatedReplicas < replicas || deployment.Status.Replicas > deployment.Status.UpdatedReplicas
+}
+
+func isOwnedBy(ownerReferences []metav1.OwnerReference, kind string, name string) bool {
+	for _, owner := range ownerReferences {
+		if owner.Kind == kind && owner.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isOwnedByUID(ownerReferences []metav1.OwnerReference, uid types.UID) bool {
+	for _, owner := range ownerReferences {
+		if owner.UID == uid {
+			return true
+		}
+	}
+	return false
+}
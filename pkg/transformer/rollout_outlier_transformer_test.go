@@ -0,0 +1,186 @@
+package transformer
+
+import (
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("RolloutOutlierTransformer", func() {
+
+	var (
+		namespace = "rollouts"
+		start     = time.Date(2023, time.May, 1, 0, 0, 0, 0, time.UTC)
+		end       = start.Add(6 * time.Hour)
+	)
+
+	// flatDataPoints returns the utilization sampled every minute, at 10 cores but during the rollouts.
+	flatDataPoints := func(rollouts ...[2]time.Duration) []metrics.DataPoint {
+		var dataPoints []metrics.DataPoint
+		for t := start; t.Before(end); t = t.Add(time.Minute) {
+			value := 10.0
+			for _, rollout := range rollouts {
+				if offset := t.Sub(start); offset >= rollout[0] && offset < rollout[1] {
+					value = 40
+				}
+			}
+			dataPoints = append(dataPoints, metrics.DataPoint{Timestamp: t, Value: value})
+		}
+		return dataPoints
+	}
+
+	replicaSet := func(name, owner string, created time.Duration, replicas, ready int32) *appsv1.ReplicaSet {
+		return &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         namespace,
+				UID:               types.UID(name),
+				CreationTimestamp: metav1.NewTime(start.Add(created)),
+				OwnerReferences:   []metav1.OwnerReference{{Kind: "Deployment", Name: owner, UID: types.UID(owner)}},
+			},
+			Spec: appsv1.ReplicaSetSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"rs": name}},
+			},
+			Status: appsv1.ReplicaSetStatus{ReadyReplicas: ready},
+		}
+	}
+
+	deployment := func(name string, replicas, updated, total int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Generation: 2},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			Status: appsv1.DeploymentStatus{
+				ObservedGeneration: 2,
+				Replicas:           total,
+				UpdatedReplicas:    updated,
+			},
+		}
+	}
+
+	readyPod := func(name, replicaSet string, readyAt time.Duration) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				Namespace:       namespace,
+				Labels:          map[string]string{"rs": replicaSet},
+				OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: replicaSet, UID: types.UID(replicaSet)}},
+			},
+			Status: corev1.PodStatus{Conditions: []corev1.PodCondition{{
+				Type:               corev1.PodReady,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(start.Add(readyAt)),
+			}}},
+		}
+	}
+
+	// expectIgnored expects the ignored intervals to be those given, as the timestamps read back lose their location.
+	expectIgnored := func(ignored []metrics.IgnoredInterval, expected ...metrics.IgnoredInterval) {
+		Expect(ignored).To(HaveLen(len(expected)))
+		for i := range expected {
+			Expect(ignored[i].StartTime).To(BeTemporally("==", expected[i].StartTime))
+			Expect(ignored[i].EndTime).To(BeTemporally("==", expected[i].EndTime))
+			Expect(ignored[i].Reason).To(Equal(expected[i].Reason))
+		}
+	}
+
+	newTransformer := func(objects ...client.Object) *RolloutOutlierTransformer {
+		rt, err := NewRolloutOutlierTransformer(fake.NewClientBuilder().WithObjects(objects...).Build(), 0, 0, logger)
+		Expect(err).ToNot(HaveOccurred())
+		return rt
+	}
+
+	It("should interpolate the rollouts of the ReplicaSets of the workload and report them", func() {
+		rt := newTransformer(
+			replicaSet("rs-1", "workload", time.Hour, 0, 0),
+			replicaSet("rs-2", "workload", 3*time.Hour, 2, 2),
+			readyPod("pod-1", "rs-2", 3*time.Hour+4*time.Minute),
+			readyPod("pod-2", "rs-2", 3*time.Hour+8*time.Minute),
+			replicaSet("rs-3", "other-workload", 2*time.Hour, 1, 0),
+		)
+		dataPoints := flatDataPoints([2]time.Duration{time.Hour, time.Hour + 20*time.Minute},
+			[2]time.Duration{3 * time.Hour, 3*time.Hour + 10*time.Minute})
+
+		transformed, ignored, err := rt.TransformWorkloadAndAudit(namespace, "Deployment", "workload", start, end,
+			dataPoints)
+		Expect(err).ToNot(HaveOccurred())
+		expectIgnored(ignored,
+			metrics.IgnoredInterval{StartTime: start.Add(time.Hour), EndTime: start.Add(time.Hour + 30*time.Minute),
+				Reason: RolloutOutlierReason},
+			metrics.IgnoredInterval{StartTime: start.Add(3 * time.Hour), EndTime: start.Add(3*time.Hour + 13*time.Minute),
+				Reason: RolloutOutlierReason})
+		Expect(transformed).To(HaveLen(len(dataPoints)))
+		for _, dataPoint := range transformed {
+			Expect(dataPoint.Value).To(BeNumerically("~", 10, 1e-9))
+		}
+	})
+
+	It("should ignore the utilization since the start of a rollout in progress", func() {
+		rt := newTransformer(
+			deployment("workload", 3, 1, 4),
+			replicaSet("rs-1", "workload", time.Hour, 3, 3),
+			replicaSet("rs-2", "workload", 5*time.Hour+45*time.Minute, 1, 0),
+		)
+		dataPoints := flatDataPoints([2]time.Duration{5*time.Hour + 45*time.Minute, 6 * time.Hour})
+
+		transformed, ignored, err := rt.TransformWorkloadAndAudit(namespace, "Deployment", "workload", start, end,
+			dataPoints)
+		Expect(err).ToNot(HaveOccurred())
+		expectIgnored(ignored,
+			metrics.IgnoredInterval{StartTime: start.Add(time.Hour), EndTime: start.Add(time.Hour + 30*time.Minute),
+				Reason: RolloutOutlierReason},
+			metrics.IgnoredInterval{StartTime: start.Add(5*time.Hour + 45*time.Minute), EndTime: end,
+				Reason: RolloutOutlierReason})
+		Expect(transformed[len(transformed)-1].Timestamp.Before(start.Add(5*time.Hour + 45*time.Minute))).To(BeTrue())
+	})
+
+	It("should ignore a rollout in progress for the max rollout duration only", func() {
+		rt := newTransformer(
+			deployment("workload", 3, 1, 4),
+			replicaSet("rs-1", "workload", 2*time.Hour, 1, 0),
+		)
+		dataPoints := flatDataPoints([2]time.Duration{2 * time.Hour, 2*time.Hour + 20*time.Minute})
+
+		transformed, ignored, err := rt.TransformWorkloadAndAudit(namespace, "Deployment", "workload", start, end,
+			dataPoints)
+		Expect(err).ToNot(HaveOccurred())
+		expectIgnored(ignored,
+			metrics.IgnoredInterval{StartTime: start.Add(2 * time.Hour), EndTime: start.Add(2*time.Hour + 30*time.Minute),
+				Reason: RolloutOutlierReason})
+		Expect(transformed).To(HaveLen(len(dataPoints)))
+	})
+
+	It("should not take the unready pods of the ReplicaSet of a completed rollout for a rollout", func() {
+		rt := newTransformer(
+			deployment("workload", 3, 3, 3),
+			replicaSet("rs-1", "workload", -30*24*time.Hour, 3, 2),
+			readyPod("pod-1", "rs-1", -30*24*time.Hour+2*time.Minute),
+			readyPod("pod-2", "rs-1", -30*24*time.Hour+3*time.Minute),
+		)
+		dataPoints := flatDataPoints()
+
+		transformed, ignored, err := rt.TransformWorkloadAndAudit(namespace, "Deployment", "workload", start, end,
+			dataPoints)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ignored).To(BeEmpty())
+		Expect(transformed).To(Equal(dataPoints))
+	})
+
+	It("should leave the data points untouched without the workload", func() {
+		rt := newTransformer(replicaSet("rs-1", "workload", time.Hour, 0, 0))
+		dataPoints := flatDataPoints([2]time.Duration{time.Hour, time.Hour + 20*time.Minute})
+
+		transformed, ignored, err := rt.TransformAndAudit(start, end, dataPoints)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ignored).To(BeEmpty())
+		Expect(transformed).To(Equal(dataPoints))
+	})
+})
@@ -0,0 +1,183 @@
+package transformer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+	"github.com/go-logr/logr"
+)
+
+const (
+	// StatisticalOutlierReason is the reason of the intervals ignored as statistical outliers.
+	StatisticalOutlierReason = "StatisticalOutlier"
+
+	defaultOutlierWindow               = time.Hour
+	defaultOutlierThreshold            = 6.0
+	defaultOutlierMinRelativeDeviation = 0.2
+	defaultOutlierMaxSpikeDuration     = 15 * time.Minute
+
+	// madScale turns the median absolute deviation into an estimate of the standard deviation of normally distributed
+	// data points.
+	madScale = 1.4826
+)
+
+// StatisticalOutlierTransformer interpolates the spikes of the utilization which aren't announced as events, like load
+// tests or bots. A data point is part of a spike if it exceeds the median of the data points within window around it by
+// more than threshold times their scaled median absolute deviation (MAD), and by at least minRelativeDeviation of the
+// median. The median and the MAD are robust to the spike itself as long as it lasts less than half the window. The
+// data points are kept when the median or the MAD around them is zero, as in a mostly idle or flat utilization every
+// rise would count as a spike however small it is.
+//
+// Runs of such data points lasting longer than maxSpikeDuration are kept, as a sustained rise is a shift of the traffic
+// the recommendation has to hold rather than an outlier.
+type StatisticalOutlierTransformer struct {
+	window               time.Duration
+	threshold            float64
+	minRelativeDeviation float64
+	maxSpikeDuration     time.Duration
+	logger               logr.Logger
+}
+
+// NewStatisticalOutlierTransformer returns a StatisticalOutlierTransformer. The zero values pick the defaults. The
+// max spike duration has to be shorter than half the window.
+func NewStatisticalOutlierTransformer(window time.Duration,
+	threshold float64,
+	minRelativeDeviation float64,
+	maxSpikeDuration time.Duration,
+	logger logr.Logger) (*StatisticalOutlierTransformer, error) {
+	if window <= 0 {
+		window = defaultOutlierWindow
+	}
+	if threshold <= 0 {
+		threshold = defaultOutlierThreshold
+	}
+	if minRelativeDeviation <= 0 {
+		minRelativeDeviation = defaultOutlierMinRelativeDeviation
+	}
+	if maxSpikeDuration <= 0 {
+		maxSpikeDuration = defaultOutlierMaxSpikeDuration
+	}
+	if 2*maxSpikeDuration >= window {
+		return nil, fmt.Errorf("max spike duration %v must be shorter than half the window %v", maxSpikeDuration,
+			window)
+	}
+	return &StatisticalOutlierTransformer{
+		window:               window,
+		threshold:            threshold,
+		minRelativeDeviation: minRelativeDeviation,
+		maxSpikeDuration:     maxSpikeDuration,
+		logger:               logger,
+	}, nil
+}
+
+func (st *StatisticalOutlierTransformer) Transform(startTime time.Time, endTime time.Time, dataPoints []metrics.DataPoint) ([]metrics.DataPoint, error) {
+	newDataPoints, _, err := st.TransformAndAudit(startTime, endTime, dataPoints)
+	return newDataPoints, err
+}
+
+// TransformAndAudit interpolates the spikes like Transform, and reports the intervals of the spikes as ignored.
+func (st *StatisticalOutlierTransformer) TransformAndAudit(startTime time.Time, endTime time.Time, dataPoints []metrics.DataPoint) ([]metrics.DataPoint, []metrics.IgnoredInterval, error) {
+	spikes := st.findSpikes(dataPoints)
+	if len(spikes) == 0 {
+		return dataPoints, nil, nil
+	}
+
+	// The intervals are widened to the data points bordering the spikes, which are excluded from the interpolation.
+	var intervals []OutlierInterval
+	var ignored []metrics.IgnoredInterval
+	for _, spike := range spikes {
+		interval := OutlierInterval{
+			StartTime: dataPoints[spike.first].Timestamp.Add(-time.Nanosecond),
+			EndTime:   dataPoints[spike.last].Timestamp.Add(time.Nanosecond),
+		}
+		if spike.first > 0 {
+			interval.StartTime = dataPoints[spike.first-1].Timestamp
+		}
+		if spike.last < len(dataPoints)-1 {
+			interval.EndTime = dataPoints[spike.last+1].Timestamp
+		}
+		intervals = append(intervals, interval)
+		ignored = append(ignored, metrics.IgnoredInterval{
+			StartTime: dataPoints[spike.first].Timestamp,
+			EndTime:   dataPoints[spike.last].Timestamp,
+			Reason:    StatisticalOutlierReason,
+		})
+		st.logger.V(1).Info("Ignoring statistical outlier", "start", dataPoints[spike.first].Timestamp,
+			"end", dataPoints[spike.last].Timestamp)
+	}
+	return cleanOutliersAndInterpolate(dataPoints, intervals, st.logger), ignored, nil
+}
+
+// spike is a run of outlying data points, from first to last.
+type spike struct {
+	first int
+	last  int
+}
+
+// findSpikes returns the runs of outlying data points lasting at most maxSpikeDuration.
+func (st *StatisticalOutlierTransformer) findSpikes(dataPoints []metrics.DataPoint) []spike {
+	var spikes []spike
+	values := make([]float64, 0)
+	deviations := make([]float64, 0)
+	lo, hi := 0, 0
+	current := spike{first: -1}
+	for i, dp := range dataPoints {
+		for lo < i && dataPoints[lo].Timestamp.Before(dp.Timestamp.Add(-st.window/2)) {
+			lo++
+		}
+		for hi < len(dataPoints) && !dataPoints[hi].Timestamp.After(dp.Timestamp.Add(st.window/2)) {
+			hi++
+		}
+
+		values = values[:0]
+		for _, neighbour := range dataPoints[lo:hi] {
+			values = append(values, neighbour.Value)
+		}
+		median := medianOf(values)
+		deviations = deviations[:0]
+		for _, v := range values {
+			deviations = append(deviations, math.Abs(v-median))
+		}
+		mad := medianOf(deviations)
+
+		deviation := dp.Value - median
+		outlying := median > 0 && mad > 0 &&
+			deviation > st.threshold*madScale*mad && deviation > st.minRelativeDeviation*median
+		switch {
+		case outlying && current.first < 0:
+			current = spike{first: i, last: i}
+		case outlying:
+			current.last = i
+		case current.first >= 0:
+			spikes = st.appendSpike(spikes, current, dataPoints)
+			current = spike{first: -1}
+		}
+	}
+	if current.first >= 0 {
+		spikes = st.appendSpike(spikes, current, dataPoints)
+	}
+	return spikes
+}
+
+func (st *StatisticalOutlierTransformer) appendSpike(spikes []spike, s spike, dataPoints []metrics.DataPoint) []spike {
+	if dataPoints[s.last].Timestamp.Sub(dataPoints[s.first].Timestamp) > st.maxSpikeDuration {
+		return spikes
+	}
+	return append(spikes, s)
+}
+
+// medianOf returns the median of the values, sorting them in place.
+func medianOf(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
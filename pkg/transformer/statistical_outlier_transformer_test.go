@@ -0,0 +1,111 @@
+package transformer
+
+import (
+	"math"
+	"time"
+
+	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StatisticalOutlierTransformer", func() {
+
+	start := time.Date(2023, time.May, 1, 0, 0, 0, 0, time.UTC)
+
+	// diurnalDataPoints returns a day of utilization sampled every minute, swinging between 10 and 50 cores.
+	diurnalDataPoints := func() []metrics.DataPoint {
+		var dataPoints []metrics.DataPoint
+		for t := start; t.Before(start.Add(24 * time.Hour)); t = t.Add(time.Minute) {
+			phase := 2 * math.Pi * float64(t.Sub(start)) / float64(24*time.Hour)
+			// A little jitter so that the MAD isn't zero.
+			jitter := 0.5 * math.Sin(float64(t.Minute()))
+			dataPoints = append(dataPoints, metrics.DataPoint{Timestamp: t, Value: 30 - 20*math.Cos(phase) + jitter})
+		}
+		return dataPoints
+	}
+
+	raise := func(dataPoints []metrics.DataPoint, from, to time.Duration, value float64) {
+		for i := range dataPoints {
+			offset := dataPoints[i].Timestamp.Sub(start)
+			if offset >= from && offset < to {
+				dataPoints[i].Value = value
+			}
+		}
+	}
+
+	It("should fail for a max spike duration longer than half the window", func() {
+		_, err := NewStatisticalOutlierTransformer(time.Hour, 0, 0, 30*time.Minute, logger)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should interpolate a short spike and report it", func() {
+		st, err := NewStatisticalOutlierTransformer(0, 0, 0, 0, logger)
+		Expect(err).ToNot(HaveOccurred())
+		original := diurnalDataPoints()
+		dataPoints := diurnalDataPoints()
+		raise(dataPoints, 6*time.Hour, 6*time.Hour+10*time.Minute, 200)
+
+		transformed, ignored, err := st.TransformAndAudit(start, start.Add(24*time.Hour), dataPoints)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ignored).To(Equal([]metrics.IgnoredInterval{{
+			StartTime: start.Add(6 * time.Hour),
+			EndTime:   start.Add(6*time.Hour + 9*time.Minute),
+			Reason:    StatisticalOutlierReason,
+		}}))
+		Expect(transformed).To(HaveLen(len(dataPoints)))
+		for i := range transformed {
+			Expect(transformed[i].Value).To(BeNumerically("~", original[i].Value, 1.5))
+		}
+	})
+
+	It("should keep a sustained rise", func() {
+		st, err := NewStatisticalOutlierTransformer(0, 0, 0, 0, logger)
+		Expect(err).ToNot(HaveOccurred())
+		dataPoints := diurnalDataPoints()
+		raise(dataPoints, 6*time.Hour, 6*time.Hour+25*time.Minute, 200)
+
+		transformed, ignored, err := st.TransformAndAudit(start, start.Add(24*time.Hour), dataPoints)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ignored).To(BeEmpty())
+		Expect(transformed).To(Equal(dataPoints))
+	})
+
+	It("should keep the utilization without spikes untouched", func() {
+		st, err := NewStatisticalOutlierTransformer(0, 0, 0, 0, logger)
+		Expect(err).ToNot(HaveOccurred())
+		dataPoints := diurnalDataPoints()
+
+		transformed, err := st.Transform(start, start.Add(24*time.Hour), dataPoints)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(transformed).To(Equal(dataPoints))
+	})
+
+	It("should remove a spike at the end of the utilization", func() {
+		st, err := NewStatisticalOutlierTransformer(0, 0, 0, 0, logger)
+		Expect(err).ToNot(HaveOccurred())
+		dataPoints := diurnalDataPoints()
+		raise(dataPoints, 24*time.Hour-5*time.Minute, 24*time.Hour, 200)
+
+		transformed, ignored, err := st.TransformAndAudit(start, start.Add(24*time.Hour), dataPoints)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ignored).To(HaveLen(1))
+		Expect(transformed).To(HaveLen(len(dataPoints) - 5))
+	})
+
+	It("should keep the rises of a mostly idle utilization", func() {
+		st, err := NewStatisticalOutlierTransformer(0, 0, 0, 0, logger)
+		Expect(err).ToNot(HaveOccurred())
+		var dataPoints []metrics.DataPoint
+		for t := start; t.Before(start.Add(24 * time.Hour)); t = t.Add(time.Minute) {
+			dataPoints = append(dataPoints, metrics.DataPoint{Timestamp: t, Value: 0})
+		}
+		raise(dataPoints, 6*time.Hour, 6*time.Hour+5*time.Minute, 0.5)
+		raise(dataPoints, 12*time.Hour, 12*time.Hour+time.Minute, 2)
+
+		transformed, ignored, err := st.TransformAndAudit(start, start.Add(24*time.Hour), dataPoints)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ignored).To(BeEmpty())
+		Expect(transformed).To(Equal(dataPoints))
+	})
+})
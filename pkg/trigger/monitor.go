@@ -4,10 +4,12 @@ import (
 	"context"
 	ottoscaleriov1alpha1 "github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
 	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+	"github.com/flipkart-incubator/ottoscalr/pkg/sharding"
 	"github.com/go-logr/logr"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"golang.org/x/sync/semaphore"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
@@ -38,10 +40,16 @@ var (
 		prometheus.CounterOpts{Name: "breachmonitor_execution_rate",
 			Help: "Rate of breach monitor executions"}, []string{},
 	)
+
+	garbageCollectedMonitors = promauto.NewCounterVec(
+		prometheus.CounterOpts{Name: "breachmonitor_garbage_collected_count",
+			Help: "Number of monitors garbage collected as their policyreco doesn't exist"}, []string{},
+	)
 )
 
 func init() {
-	p8smetrics.Registry.MustRegister(breachGauge, timeToMitigateLatency, concurrentBreachMonitorExecutions, breachMonitorExecutionRate)
+	p8smetrics.Registry.MustRegister(breachGauge, timeToMitigateLatency, concurrentBreachMonitorExecutions, breachMonitorExecutionRate,
+		garbageCollectedMonitors)
 }
 
 const (
@@ -60,6 +68,10 @@ type MonitorManager interface {
 	DeregisterMonitor(workload types.NamespacedName)
 	Shutdown()
 }
+
+// PolicyRecommendationMonitorManager owns the breach and periodic monitors of all the workloads. It is meant to be
+// added to the controller manager as a Runnable so that the monitors only run on the elected leader, or on every
+// replica for the workloads of its shard when sharding is enabled.
 type PolicyRecommendationMonitorManager struct {
 	k8sClient                   client.Client
 	recorder                    record.EventRecorder
@@ -67,11 +79,15 @@ type PolicyRecommendationMonitorManager struct {
 	metricStep                  time.Duration
 	cpuRedLine                  float64
 	periodicRequeueFrequency    time.Duration
+	recoWindows                 *RecommendationWindows
 	breachCheckFrequency        time.Duration
+	monitorGCFrequency          time.Duration
 	concurrencyControlSemaphore *semaphore.Weighted
-	handlerFunc                 func(workloadName types.NamespacedName)
+	handlerFunc                 func(workloadName types.NamespacedName, priorityClass ottoscaleriov1alpha1.QueuePriorityClass)
 	monitors                    map[string]*Monitor
 	monitorMutex                sync.Mutex
+	started                     bool
+	sharder                     sharding.Sharder
 	logger                      logr.Logger
 }
 
@@ -79,16 +95,22 @@ func NewPolicyRecommendationMonitorManager(k8sClient client.Client,
 	recorder record.EventRecorder,
 	metricScraper metrics.Scraper,
 	periodicRequeueFrequency time.Duration,
+	recoWindows *RecommendationWindows,
 	breachCheckFrequency time.Duration,
+	monitorGCFrequency time.Duration,
 	concurrentExecutions int,
-	handlerFunc func(workloadName types.NamespacedName),
+	handlerFunc func(workloadName types.NamespacedName, priorityClass ottoscaleriov1alpha1.QueuePriorityClass),
 	stepSec int,
 	cpuRedLine float64,
+	sharder sharding.Sharder,
 	logger logr.Logger) *PolicyRecommendationMonitorManager {
 
 	if concurrentExecutions == 0 {
 		concurrentExecutions = 50
 	}
+	if monitorGCFrequency == 0 {
+		monitorGCFrequency = 30 * time.Minute
+	}
 	concurrencySemaphore := semaphore.NewWeighted(int64(concurrentExecutions))
 
 	return &PolicyRecommendationMonitorManager{
@@ -98,10 +120,13 @@ func NewPolicyRecommendationMonitorManager(k8sClient client.Client,
 		metricStep:                  time.Duration(stepSec) * time.Second,
 		cpuRedLine:                  cpuRedLine,
 		periodicRequeueFrequency:    periodicRequeueFrequency,
+		recoWindows:                 recoWindows,
 		breachCheckFrequency:        breachCheckFrequency,
+		monitorGCFrequency:          monitorGCFrequency,
 		concurrencyControlSemaphore: concurrencySemaphore,
 		handlerFunc:                 handlerFunc,
 		monitors:                    make(map[string]*Monitor),
+		sharder:                     sharder,
 		logger:                      logger,
 	}
 }
@@ -109,6 +134,10 @@ func NewPolicyRecommendationMonitorManager(k8sClient client.Client,
 func (mf *PolicyRecommendationMonitorManager) RegisterMonitor(workloadType string,
 	workload types.NamespacedName) *Monitor {
 
+	if !mf.sharder.Owns(workload) {
+		return nil
+	}
+
 	mf.monitorMutex.Lock()
 	defer mf.monitorMutex.Unlock()
 
@@ -125,13 +154,18 @@ func (mf *PolicyRecommendationMonitorManager) RegisterMonitor(workloadType strin
 		mf.cpuRedLine,
 		mf.metricStep,
 		mf.periodicRequeueFrequency,
+		mf.recoWindows,
 		mf.breachCheckFrequency,
 		mf.concurrencyControlSemaphore,
 		mf.handlerFunc,
 		mf.logger)
 
 	mf.monitors[workload.String()] = monitor
-	monitor.Start()
+	// Monitors registered before the manager has started (i.e. before this replica became the leader) are started
+	// along with the manager.
+	if mf.started {
+		monitor.Start()
+	}
 	return monitor
 }
 
@@ -143,6 +177,112 @@ func (mf *PolicyRecommendationMonitorManager) DeregisterMonitor(workload types.N
 		monitor.Stop()
 		delete(mf.monitors, workload.String())
 	}
+	deleteMonitorMetrics(workload)
+}
+
+// deleteMonitorMetrics deletes the label sets of the per workload metrics exposed by the monitors.
+func deleteMonitorMetrics(workload types.NamespacedName) {
+	labels := prometheus.Labels{"namespace": workload.Namespace, "policyreco": workload.Name}
+	breachGauge.DeletePartialMatch(labels)
+	timeToMitigateLatency.DeletePartialMatch(labels)
+}
+
+// Start starts the monitors of all the existing PolicyRecommendations and blocks until the context is cancelled, after
+// which all the monitors are stopped.
+func (mf *PolicyRecommendationMonitorManager) Start(ctx context.Context) error {
+	mf.logger.Info("Starting monitor manager.")
+	mf.monitorMutex.Lock()
+	mf.started = true
+	for _, monitor := range mf.monitors {
+		monitor.Start()
+	}
+	mf.monitorMutex.Unlock()
+
+	if err := mf.rebuildMonitors(ctx); err != nil {
+		mf.logger.Error(err, "Error while rebuilding monitors from the existing policyRecommendations.")
+	}
+
+	rebalanced := mf.sharder.Subscribe()
+	gcTicker := time.NewTicker(mf.monitorGCFrequency)
+	defer gcTicker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			mf.Shutdown()
+			return nil
+		case <-gcTicker.C:
+			mf.garbageCollectMonitors(ctx)
+		case <-rebalanced:
+			mf.rebalanceMonitors(ctx)
+		}
+	}
+}
+
+// NeedLeaderElection ensures that the monitors run only on the leader unless they are sharded across the replicas.
+func (mf *PolicyRecommendationMonitorManager) NeedLeaderElection() bool {
+	return !mf.sharder.Sharded()
+}
+
+// rebalanceMonitors stops the monitors of the workloads which moved out of the shard of this replica and starts the
+// monitors of the workloads which moved in.
+func (mf *PolicyRecommendationMonitorManager) rebalanceMonitors(ctx context.Context) {
+	mf.monitorMutex.Lock()
+	var movedOut []types.NamespacedName
+	for _, monitor := range mf.monitors {
+		if !mf.sharder.Owns(monitor.workload) {
+			movedOut = append(movedOut, monitor.workload)
+		}
+	}
+	mf.monitorMutex.Unlock()
+
+	for _, workload := range movedOut {
+		mf.DeregisterMonitor(workload)
+	}
+	if err := mf.rebuildMonitors(ctx); err != nil {
+		mf.logger.Error(err, "Error while rebuilding monitors after rebalance.")
+	}
+	mf.logger.Info("Rebalanced monitors.", "movedOut", len(movedOut))
+}
+
+func (mf *PolicyRecommendationMonitorManager) rebuildMonitors(ctx context.Context) error {
+	policyRecos := ottoscaleriov1alpha1.PolicyRecommendationList{}
+	if err := mf.k8sClient.List(ctx, &policyRecos); err != nil {
+		return err
+	}
+	for _, policyreco := range policyRecos.Items {
+		mf.RegisterMonitor(policyreco.Spec.WorkloadMeta.Kind, types.NamespacedName{
+			Namespace: policyreco.Namespace,
+			Name:      policyreco.Name,
+		})
+	}
+	mf.logger.Info("Rebuilt monitors from existing policyRecommendations.", "count", len(policyRecos.Items))
+	return nil
+}
+
+// garbageCollectMonitors deregisters the monitors whose PolicyRecommendation doesn't exist anymore. This covers the
+// workload deletions which were missed by the registrar.
+func (mf *PolicyRecommendationMonitorManager) garbageCollectMonitors(ctx context.Context) {
+	mf.monitorMutex.Lock()
+	workloads := make([]types.NamespacedName, 0, len(mf.monitors))
+	for _, monitor := range mf.monitors {
+		workloads = append(workloads, monitor.workload)
+	}
+	mf.monitorMutex.Unlock()
+
+	for _, workload := range workloads {
+		policyreco := ottoscaleriov1alpha1.PolicyRecommendation{}
+		err := mf.k8sClient.Get(ctx, workload, &policyreco)
+		if err == nil {
+			continue
+		}
+		if !errors.IsNotFound(err) {
+			mf.logger.Error(err, "Error while getting policyRecommendation for monitor garbage collection.", "workload", workload)
+			continue
+		}
+		mf.logger.Info("Garbage collecting monitor as the policyRecommendation doesn't exist.", "workload", workload)
+		garbageCollectedMonitors.WithLabelValues().Inc()
+		mf.DeregisterMonitor(workload)
+	}
 }
 
 func (mf *PolicyRecommendationMonitorManager) Shutdown() {
@@ -150,9 +290,11 @@ func (mf *PolicyRecommendationMonitorManager) Shutdown() {
 	mf.monitorMutex.Lock()
 	defer mf.monitorMutex.Unlock()
 
-	for _, monitor := range mf.monitors {
+	for key, monitor := range mf.monitors {
 		monitor.Stop()
+		delete(mf.monitors, key)
 	}
+	mf.started = false
 }
 
 type Monitor struct {
@@ -165,9 +307,10 @@ type Monitor struct {
 	cpuRedLine                  float64
 	metricStep                  time.Duration
 	periodicRequeueFrequency    time.Duration
+	recoWindows                 *RecommendationWindows
 	breachCheckFrequency        time.Duration
 	concurrencyControlSemaphore *semaphore.Weighted
-	handlerFunc                 func(workload types.NamespacedName)
+	handlerFunc                 func(workload types.NamespacedName, priorityClass ottoscaleriov1alpha1.QueuePriorityClass)
 	ctx                         context.Context
 	cancel                      context.CancelFunc
 	wg                          sync.WaitGroup
@@ -183,9 +326,10 @@ func NewMonitor(k8sClient client.Client,
 	cpuRedLine float64,
 	metricStep time.Duration,
 	periodicRequeueFrequency time.Duration,
+	recoWindows *RecommendationWindows,
 	breachCheckFrequency time.Duration,
 	concurrencyControlSemaphore *semaphore.Weighted,
-	handlerFunc func(workload types.NamespacedName),
+	handlerFunc func(workload types.NamespacedName, priorityClass ottoscaleriov1alpha1.QueuePriorityClass),
 	logger logr.Logger) *Monitor {
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -199,6 +343,7 @@ func NewMonitor(k8sClient client.Client,
 		cpuRedLine:                  cpuRedLine,
 		metricStep:                  metricStep,
 		periodicRequeueFrequency:    periodicRequeueFrequency,
+		recoWindows:                 recoWindows,
 		breachCheckFrequency:        breachCheckFrequency,
 		concurrencyControlSemaphore: concurrencyControlSemaphore,
 		handlerFunc:                 handlerFunc,
@@ -213,7 +358,7 @@ func (m *Monitor) Start() {
 	go m.monitorBreaches()
 
 	m.wg.Add(1)
-	go m.requeueAfterFixedInterval()
+	go m.requeuePeriodically()
 }
 
 func (m *Monitor) monitorBreaches() {
@@ -235,9 +380,12 @@ func (m *Monitor) monitorBreaches() {
 			concurrentBreachMonitorExecutions.WithLabelValues().Add(1)
 			end := time.Now()
 			start := end.Add(-m.breachCheckFrequency)
+			// The check is cancelled along with the monitor, and its queries are traced together.
+			ctx := metrics.ContextWithTrace(m.ctx)
+			ctx = log.IntoContext(ctx, m.logger.WithValues("traceID", metrics.TraceIDFromContext(ctx)))
 
 			policyreco := ottoscaleriov1alpha1.PolicyRecommendation{}
-			if err := m.k8sClient.Get(context.Background(), types.NamespacedName{
+			if err := m.k8sClient.Get(ctx, types.NamespacedName{
 				Namespace: m.workload.Namespace,
 				Name:      m.workload.Name,
 			}, &policyreco); err != nil {
@@ -258,7 +406,8 @@ func (m *Monitor) monitorBreaches() {
 				}
 			}
 			var statusPatch *ottoscaleriov1alpha1.PolicyRecommendation
-			if err := m.concurrencyControlSemaphore.Acquire(context.Background(), 1); err != nil {
+			if err := m.concurrencyControlSemaphore.Acquire(ctx, 1); err != nil {
+				concurrentBreachMonitorExecutions.WithLabelValues().Sub(1)
 				// Return if the context is canceled.
 				if err == context.Canceled || err == context.DeadlineExceeded {
 					m.logger.Error(err, "Failed to acquire semaphore as context is cancelled: %v\n")
@@ -268,23 +417,28 @@ func (m *Monitor) monitorBreaches() {
 				continue
 			}
 			//TODO: Handle Error
-			breached, _ := HasBreached(log.IntoContext(context.Background(), m.logger), start, end, m.workloadType, m.workload, m.metricScraper, m.cpuRedLine, m.metricStep)
+			breached, err := HasBreached(ctx, start, end, m.workloadType, m.workload, m.metricScraper, m.cpuRedLine, m.metricStep)
 			m.concurrencyControlSemaphore.Release(1)
+			if err != nil && m.ctx.Err() != nil {
+				// The monitor has been stopped while the breach was being checked.
+				concurrentBreachMonitorExecutions.WithLabelValues().Sub(1)
+				return
+			}
 			if breached {
 				m.recorder.Event(&policyreco, eventTypeWarning, "BreachDetected", "A breach has been detected for the current policy")
 				if !breachedInPast {
 					statusPatch = m.createBreachCondition(ottoscaleriov1alpha1.HasBreached, metav1.ConditionTrue, BreachDetectedReason, BreachDetectedMessage, time.Now())
-					if err := m.k8sClient.Status().Patch(context.Background(), statusPatch, client.Apply, getSubresourcePatchOptions(BreachStatusManager)); err != nil {
+					if err := m.k8sClient.Status().Patch(ctx, statusPatch, client.Apply, getSubresourcePatchOptions(BreachStatusManager)); err != nil {
 						m.logger.Error(err, "Error updating the status of the policy reco object")
 					}
 				}
 				breachGauge.WithLabelValues(policyreco.Namespace, policyreco.Name, policyreco.Spec.WorkloadMeta.Kind, policyreco.Spec.WorkloadMeta.Name).Set(1)
-				m.handlerFunc(m.workload)
+				m.handlerFunc(m.workload, ottoscaleriov1alpha1.QueuePriorityBreach)
 			} else {
 				breachGauge.WithLabelValues(policyreco.Namespace, policyreco.Name, policyreco.Spec.WorkloadMeta.Kind, policyreco.Spec.WorkloadMeta.Name).Set(0)
 				if breachedInPast {
 					statusPatch = m.createBreachCondition(ottoscaleriov1alpha1.HasBreached, metav1.ConditionFalse, NoBreachDetectedReason, NoBreachDetectedMessage, time.Now())
-					if err := m.k8sClient.Status().Patch(context.Background(), statusPatch, client.Apply, getSubresourcePatchOptions(BreachStatusManager)); err != nil {
+					if err := m.k8sClient.Status().Patch(ctx, statusPatch, client.Apply, getSubresourcePatchOptions(BreachStatusManager)); err != nil {
 						m.logger.Error(err, "Error updating the status of the policy reco object")
 					}
 					mitigationLatency := time.Since(lastBreachedTime).Seconds()
@@ -302,7 +456,7 @@ func HasBreached(ctx context.Context, start, end time.Time, workloadType string,
 	cpuRedLine float64,
 	metricStep time.Duration) (bool, error) {
 	logger := log.FromContext(ctx)
-	dataPoints, err := metricScraper.GetCPUUtilizationBreachDataPoints(workload.Namespace,
+	dataPoints, err := metricScraper.GetCPUUtilizationBreachDataPoints(ctx, workload.Namespace,
 		workloadType,
 		workload.Name,
 		cpuRedLine,
@@ -322,27 +476,39 @@ func HasBreached(ctx context.Context, start, end time.Time, workloadType string,
 	return false, nil
 }
 
-func (m *Monitor) requeueAfterFixedInterval() {
+func (m *Monitor) requeuePeriodically() {
 	defer m.wg.Done()
 
 	m.logger.Info("Starting the periodic check routine.")
-	//Add a jitter of 10%
-	jitter := time.Duration(rand.Int63n(int64(m.periodicRequeueFrequency) / 10))
-	queueTicker := time.NewTicker(m.periodicRequeueFrequency + jitter)
-
-	defer queueTicker.Stop()
-
 	for {
+		queueTimer := time.NewTimer(m.nextPeriodicRequeue(time.Now()))
 		select {
 		case <-m.ctx.Done():
+			queueTimer.Stop()
 			return
-		case <-queueTicker.C:
+		case <-queueTimer.C:
 			m.logger.Info("Executing the periodic check routine.")
-			m.handlerFunc(m.workload)
+			m.handlerFunc(m.workload, ottoscaleriov1alpha1.QueuePriorityPeriodic)
 		}
 	}
 }
 
+// nextPeriodicRequeue returns the delay until the next periodic requeue. A workload in a namespace with a
+// recommendation window is requeued every time the window opens, at a random offset within the first half of the
+// window to spread the load. The rest are requeued at the fixed interval.
+func (m *Monitor) nextPeriodicRequeue(now time.Time) time.Duration {
+	if window := m.recoWindows.WindowFor(m.workload.Namespace); window != nil {
+		var offset time.Duration
+		if window.duration/2 > 0 {
+			offset = time.Duration(rand.Int63n(int64(window.duration / 2)))
+		}
+		return window.schedule.Next(now).Add(offset).Sub(now)
+	}
+	//Add a jitter of 10%
+	jitter := time.Duration(rand.Int63n(int64(m.periodicRequeueFrequency) / 10))
+	return m.periodicRequeueFrequency + jitter
+}
+
 func (m *Monitor) Stop() {
 	m.logger.Info("Stopping monitor.")
 	m.cancel()
@@ -1,9 +1,11 @@
 package trigger
 
 import (
+	"context"
 	"fmt"
 	ottoscaleriov1alpha1 "github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
 	"github.com/flipkart-incubator/ottoscalr/pkg/metrics"
+	"github.com/flipkart-incubator/ottoscalr/pkg/sharding"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
@@ -17,7 +19,9 @@ import (
 // FakeScraper mocks the metrics.Scraper for testing purposes
 type FakeScraper struct{}
 
-func (fs *FakeScraper) GetAverageCPUUtilizationByWorkload(namespace,
+func (fs *FakeScraper) GetAverageCPUUtilizationByWorkload(ctx context.Context,
+	namespace,
+	workloadType,
 	workload string,
 	start time.Time,
 	end time.Time,
@@ -25,7 +29,8 @@ func (fs *FakeScraper) GetAverageCPUUtilizationByWorkload(namespace,
 	return []metrics.DataPoint{}, nil
 }
 
-func (fs *FakeScraper) GetCPUUtilizationBreachDataPoints(namespace,
+func (fs *FakeScraper) GetCPUUtilizationBreachDataPoints(ctx context.Context,
+	namespace,
 	workloadType,
 	workload string,
 	redLineUtilization float64,
@@ -35,7 +40,9 @@ func (fs *FakeScraper) GetCPUUtilizationBreachDataPoints(namespace,
 	datapoint := metrics.DataPoint{Timestamp: time.Now(), Value: 1.3}
 	return []metrics.DataPoint{datapoint}, nil
 }
-func (fs *FakeScraper) GetACLByWorkload(namespace,
+func (fs *FakeScraper) GetACLByWorkload(ctx context.Context,
+	namespace,
+	workloadType,
 	workload string) (time.Duration, error) {
 	return 5 * time.Minute, nil
 }
@@ -48,8 +55,10 @@ func (fs *FakeScraper) GetPodReadyLatencyByWorkload(namespace,
 var _ = Describe("PolicyRecommendationMonitorManager and Monitor", func() {
 	var (
 		manager            *PolicyRecommendationMonitorManager
+		managerCtx         context.Context
+		managerCancel      context.CancelFunc
 		handlerCallCounter int32
-		handlerFunc        = func(workload types.NamespacedName) {
+		handlerFunc        = func(workload types.NamespacedName, priorityClass ottoscaleriov1alpha1.QueuePriorityClass) {
 			atomic.AddInt32(&handlerCallCounter, 1)
 		}
 		policyreco ottoscaleriov1alpha1.PolicyRecommendation
@@ -59,10 +68,12 @@ var _ = Describe("PolicyRecommendationMonitorManager and Monitor", func() {
 		err := createPolicyReco("test-workload", "default", "")
 		Expect(err).ToNot(HaveOccurred())
 		handlerCallCounter = 0
+		managerCtx, managerCancel = context.WithCancel(context.TODO())
 	})
 
 	AfterEach(func() {
 		Expect(k8sClient.Delete(ctx, &policyreco)).Should(Succeed())
+		managerCancel()
 		manager.Shutdown()
 	})
 
@@ -73,12 +84,16 @@ var _ = Describe("PolicyRecommendationMonitorManager and Monitor", func() {
 			recorder,
 			&FakeScraper{},
 			1*time.Hour,
+			NewRecommendationWindows(nil, nil),
 			1*time.Second,
+			1*time.Hour,
 			50,
 			handlerFunc,
 			10,
 			80,
+			sharding.NewSingleShard(),
 			zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
+		go manager.Start(managerCtx)
 		workload := types.NamespacedName{Name: "test-workload", Namespace: "default"}
 		workloadType := "test-workload-type"
 
@@ -121,12 +136,16 @@ var _ = Describe("PolicyRecommendationMonitorManager and Monitor", func() {
 			manager.recorder,
 			&FakeScraper{},
 			1*time.Second,
+			NewRecommendationWindows(nil, nil),
+			1*time.Hour,
 			1*time.Hour,
 			50,
 			handlerFunc,
 			10,
 			80,
+			sharding.NewSingleShard(),
 			zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
+		go manager.Start(managerCtx)
 		workload := types.NamespacedName{Name: "test-workload", Namespace: "default"}
 		workloadType := "test-workload-type"
 
@@ -152,6 +171,95 @@ var _ = Describe("PolicyRecommendationMonitorManager and Monitor", func() {
 		time.Sleep(3 * time.Second)
 		Expect(handlerCallCounter).To(BeNumerically(">", currentCallCounter))
 	})
+
+	It("should rebuild monitors for existing policyrecos only once started", func() {
+
+		By("Creating a monitor mgr that only handles periodic trigger")
+		manager = NewPolicyRecommendationMonitorManager(k8sClient,
+			recorder,
+			&FakeScraper{},
+			1*time.Second,
+			NewRecommendationWindows(nil, nil),
+			1*time.Hour,
+			1*time.Hour,
+			50,
+			handlerFunc,
+			10,
+			80,
+			sharding.NewSingleShard(),
+			zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
+		workload := types.NamespacedName{Name: "test-workload", Namespace: "default"}
+
+		By("Checking that no monitor runs before the manager is started")
+		time.Sleep(2 * time.Second)
+		Expect(atomic.LoadInt32(&handlerCallCounter)).To(Equal(int32(0)))
+
+		By("Starting the manager and checking that the monitor is rebuilt from the policyreco")
+		go manager.Start(managerCtx)
+		Eventually(func() bool {
+			manager.monitorMutex.Lock()
+			defer manager.monitorMutex.Unlock()
+			_, ok := manager.monitors[workload.String()]
+			return ok
+		}, 5*time.Second).Should(BeTrue())
+		Eventually(func() int32 {
+			return atomic.LoadInt32(&handlerCallCounter)
+		}, 5*time.Second).Should(BeNumerically(">=", 1))
+
+		By("Stopping the manager and checking that all monitors are stopped")
+		managerCancel()
+		Eventually(func() int {
+			manager.monitorMutex.Lock()
+			defer manager.monitorMutex.Unlock()
+			return len(manager.monitors)
+		}, 5*time.Second).Should(Equal(0))
+		currentCallCounter := atomic.LoadInt32(&handlerCallCounter)
+		time.Sleep(3 * time.Second)
+		Expect(atomic.LoadInt32(&handlerCallCounter)).To(Equal(currentCallCounter))
+	})
+
+	It("should garbage collect monitors whose policyreco doesn't exist", func() {
+
+		By("Creating a monitor mgr with a short garbage collection interval")
+		manager = NewPolicyRecommendationMonitorManager(k8sClient,
+			recorder,
+			&FakeScraper{},
+			1*time.Hour,
+			NewRecommendationWindows(nil, nil),
+			1*time.Hour,
+			1*time.Second,
+			50,
+			handlerFunc,
+			10,
+			80,
+			sharding.NewSingleShard(),
+			zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
+		go manager.Start(managerCtx)
+		workload := types.NamespacedName{Name: "test-gc-workload", Namespace: "default"}
+		Expect(createPolicyReco(workload.Name, workload.Namespace, "")).To(Succeed())
+
+		By("Registering the monitor and deleting its policyreco")
+		Expect(manager.RegisterMonitor("test-workload-type", workload)).ToNot(BeNil())
+		gcPolicyReco := ottoscaleriov1alpha1.PolicyRecommendation{}
+		Expect(k8sClient.Get(ctx, workload, &gcPolicyReco)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, &gcPolicyReco)).To(Succeed())
+
+		By("Checking that the monitor is garbage collected")
+		Eventually(func() bool {
+			manager.monitorMutex.Lock()
+			defer manager.monitorMutex.Unlock()
+			_, ok := manager.monitors[workload.String()]
+			return ok
+		}, 5*time.Second).Should(BeFalse())
+
+		By("Checking that the monitor of the existing policyreco is retained")
+		Eventually(func() bool {
+			manager.monitorMutex.Lock()
+			defer manager.monitorMutex.Unlock()
+			_, ok := manager.monitors[types.NamespacedName{Name: "test-workload", Namespace: "default"}.String()]
+			return ok
+		}, 5*time.Second).Should(BeTrue())
+	})
 })
 
 func createPolicyReco(name, namespace, policy string) error {
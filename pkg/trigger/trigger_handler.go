@@ -3,6 +3,7 @@ package trigger
 import (
 	"context"
 	ottoscaleriov1alpha1 "github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
+	"github.com/flipkart-incubator/ottoscalr/pkg/sharding"
 	"github.com/go-logr/logr"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -19,28 +20,66 @@ const (
 
 type Handler interface {
 	queuePolicyRecommendations()
-	QueueForExecution(workloadName string)
+	QueueForExecution(workload types.NamespacedName)
+	QueueWithPriority(workload types.NamespacedName, priorityClass ottoscaleriov1alpha1.QueuePriorityClass)
 }
+
+type queueRequest struct {
+	workload      types.NamespacedName
+	priorityClass ottoscaleriov1alpha1.QueuePriorityClass
+}
+
+// K8sTriggerHandler marks the PolicyRecommendations queued for execution. It is meant to be added to the controller
+// manager as a Runnable so that only the leader queues the recommendations, or every replica when the monitors are
+// sharded across the replicas.
 type K8sTriggerHandler struct {
 	k8sClient            client.Client
-	queuedForExecutionCh chan types.NamespacedName
+	queuedForExecutionCh chan queueRequest
+	stopCh               chan struct{}
+	sharder              sharding.Sharder
 	logger               logr.Logger
 }
 
-func NewK8sTriggerHandler(k8sClient client.Client, logger logr.Logger) *K8sTriggerHandler {
+func NewK8sTriggerHandler(k8sClient client.Client, sharder sharding.Sharder, logger logr.Logger) *K8sTriggerHandler {
 	return &K8sTriggerHandler{
 		k8sClient:            k8sClient,
-		queuedForExecutionCh: make(chan types.NamespacedName),
+		queuedForExecutionCh: make(chan queueRequest),
+		stopCh:               make(chan struct{}),
+		sharder:              sharder,
 		logger:               logger,
 	}
 }
 
-func (h *K8sTriggerHandler) Start() {
+// Start processes the queued recommendations until the context is cancelled.
+func (h *K8sTriggerHandler) Start(ctx context.Context) error {
+	h.logger.Info("Starting trigger handler.")
 	go h.queuePolicyRecommendations()
+	<-ctx.Done()
+	h.logger.Info("Shutting down trigger handler.")
+	close(h.stopCh)
+	return nil
+}
+
+// NeedLeaderElection ensures that the recommendations are queued only by the leader unless the monitors, which queue
+// them, are sharded across the replicas.
+func (h *K8sTriggerHandler) NeedLeaderElection() bool {
+	return !h.sharder.Sharded()
 }
 
+// QueueForExecution queues the recommendation with the Periodic priority class.
 func (h *K8sTriggerHandler) QueueForExecution(recommendation types.NamespacedName) {
-	h.queuedForExecutionCh <- recommendation
+	h.QueueWithPriority(recommendation, ottoscaleriov1alpha1.QueuePriorityPeriodic)
+}
+
+// QueueWithPriority queues the recommendation with the given priority class. A recommendation which is still queued
+// with a higher priority class keeps it.
+func (h *K8sTriggerHandler) QueueWithPriority(recommendation types.NamespacedName,
+	priorityClass ottoscaleriov1alpha1.QueuePriorityClass) {
+	select {
+	case h.queuedForExecutionCh <- queueRequest{workload: recommendation, priorityClass: priorityClass}:
+	case <-h.stopCh:
+		h.logger.V(1).Info("Trigger handler has been stopped. Dropping the recommendation.", "workload", recommendation)
+	}
 }
 
 // TODO: @neerajb Handle passing error back to the controllers, so that the reconcile can be run again.
@@ -50,14 +89,27 @@ func (h *K8sTriggerHandler) QueueAllForExecution() {
 		h.logger.Error(err, "Error getting allRecommendations")
 	}
 	for _, reco := range allRecommendations.Items {
-		h.logger.V(0).Info("Queuing policy recommendation for execution", "name", reco.Name, "namespace", reco.Namespace)
-		h.queuedForExecutionCh <- types.NamespacedName{Name: reco.GetName(), Namespace: reco.GetNamespace()}
+		// The breached workloads keep their priority so that they don't wait behind the healthy ones.
+		priorityClass := ottoscaleriov1alpha1.QueuePriorityPeriodic
+		if isBreached(reco) {
+			priorityClass = ottoscaleriov1alpha1.QueuePriorityBreach
+		}
+		h.logger.V(0).Info("Queuing policy recommendation for execution", "name", reco.Name, "namespace", reco.Namespace,
+			"priorityClass", priorityClass)
+		h.QueueWithPriority(types.NamespacedName{Name: reco.GetName(), Namespace: reco.GetNamespace()}, priorityClass)
 	}
 }
 
 func (h *K8sTriggerHandler) queuePolicyRecommendations() {
 	TRUE := true
-	for workload := range h.queuedForExecutionCh {
+	for {
+		var request queueRequest
+		select {
+		case <-h.stopCh:
+			return
+		case request = <-h.queuedForExecutionCh:
+		}
+		workload := request.workload
 		now := metav1.Now()
 		policyRecommendation := &ottoscaleriov1alpha1.PolicyRecommendation{}
 
@@ -70,6 +122,9 @@ func (h *K8sTriggerHandler) queuePolicyRecommendations() {
 			continue
 		}
 
+		if !policyRecommendation.Spec.QueuedWithHigherPriority(request.priorityClass) {
+			policyRecommendation.Spec.QueuePriorityClass = request.priorityClass
+		}
 		policyRecommendation.Spec.QueuedForExecution = &TRUE
 		policyRecommendation.Spec.QueuedForExecutionAt = &now
 
@@ -107,6 +162,15 @@ func (h *K8sTriggerHandler) queuePolicyRecommendations() {
 	}
 }
 
+func isBreached(policyreco ottoscaleriov1alpha1.PolicyRecommendation) bool {
+	for _, condition := range policyreco.Status.Conditions {
+		if condition.Type == string(ottoscaleriov1alpha1.HasBreached) && condition.Status == metav1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
 func getSubresourcePatchOptions(fieldOwner string) *client.SubResourcePatchOptions {
 	patchOpts := client.PatchOptions{}
 	client.ForceOwnership.ApplyToPatch(&patchOpts)
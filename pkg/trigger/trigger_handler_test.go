@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	ottoscaleriov1alpha1 "github.com/flipkart-incubator/ottoscalr/api/v1alpha1"
+	"github.com/flipkart-incubator/ottoscalr/pkg/sharding"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -14,15 +15,22 @@ import (
 
 var _ = Describe("K8sTriggerHandler", func() {
 	var (
-		handler *K8sTriggerHandler
-		ctx     context.Context
+		handler       *K8sTriggerHandler
+		ctx           context.Context
+		handlerCtx    context.Context
+		handlerCancel context.CancelFunc
 	)
 
 	FALSE := false
 
 	BeforeEach(func() {
-		handler = NewK8sTriggerHandler(k8sClient, zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
+		handler = NewK8sTriggerHandler(k8sClient, sharding.NewSingleShard(), zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
 		ctx = context.TODO()
+		handlerCtx, handlerCancel = context.WithCancel(context.TODO())
+	})
+
+	AfterEach(func() {
+		handlerCancel()
 	})
 
 	Context("For QueueForExecution", func() {
@@ -41,7 +49,7 @@ var _ = Describe("K8sTriggerHandler", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			// Start the handler
-			handler.Start()
+			go handler.Start(handlerCtx)
 
 			// Queue the PolicyRecommendation for execution
 			handler.QueueForExecution(types.NamespacedName{Name: policyRecommendation.Name, Namespace: "default"})
@@ -95,7 +103,7 @@ var _ = Describe("K8sTriggerHandler", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			// Start the handler
-			handler.Start()
+			go handler.Start(handlerCtx)
 
 			// Queue the PolicyRecommendation for execution
 			handler.QueueAllForExecution()
@@ -120,4 +128,56 @@ var _ = Describe("K8sTriggerHandler", func() {
 		})
 	})
 
+	Context("For QueueWithPriority", func() {
+		It("should not downgrade the priority class of a queued PolicyRecommendation", func() {
+			policyRecommendation := &ottoscaleriov1alpha1.PolicyRecommendation{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-policy-recommendation-priority",
+					Namespace: "default",
+				},
+				Spec: ottoscaleriov1alpha1.PolicyRecommendationSpec{
+					QueuedForExecution: &FALSE,
+				},
+			}
+			Expect(k8sClient.Create(ctx, policyRecommendation)).Should(Succeed())
+
+			go handler.Start(handlerCtx)
+
+			key := types.NamespacedName{Name: policyRecommendation.Name, Namespace: "default"}
+			handler.QueueWithPriority(key, ottoscaleriov1alpha1.QueuePriorityBreach)
+			Eventually(func() ottoscaleriov1alpha1.QueuePriorityClass {
+				updated := &ottoscaleriov1alpha1.PolicyRecommendation{}
+				if err := k8sClient.Get(ctx, key, updated); err != nil {
+					return ""
+				}
+				return updated.Spec.QueuePriorityClass
+			}, 2*time.Second).Should(Equal(ottoscaleriov1alpha1.QueuePriorityBreach))
+
+			handler.QueueForExecution(key)
+			Consistently(func() ottoscaleriov1alpha1.QueuePriorityClass {
+				updated := &ottoscaleriov1alpha1.PolicyRecommendation{}
+				if err := k8sClient.Get(ctx, key, updated); err != nil {
+					return ""
+				}
+				return updated.Spec.QueuePriorityClass
+			}, time.Second).Should(Equal(ottoscaleriov1alpha1.QueuePriorityBreach))
+
+			Expect(k8sClient.Delete(ctx, policyRecommendation)).Should(Succeed())
+		})
+	})
+
+	Context("When the handler is stopped", func() {
+		It("should not block the callers queueing recommendations", func() {
+			go handler.Start(handlerCtx)
+			handlerCancel()
+
+			queued := make(chan struct{})
+			go func() {
+				handler.QueueForExecution(types.NamespacedName{Name: "test-policy-recommendation", Namespace: "default"})
+				close(queued)
+			}()
+			Eventually(queued, 2*time.Second).Should(BeClosed())
+		})
+	})
+
 })
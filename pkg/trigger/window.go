@@ -0,0 +1,76 @@
+package trigger
+
+import (
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Window is a recurring period in which fresh recommendations may be generated and applied. It opens at every
+// activation of a cron schedule and stays open for the configured duration.
+type Window struct {
+	schedule cron.Schedule
+	duration time.Duration
+}
+
+// NewWindow parses a standard cron spec. The spec can be prefixed with CRON_TZ=<zone> to evaluate it in a time zone
+// other than the local one.
+func NewWindow(spec string, duration time.Duration) (*Window, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &Window{schedule: schedule, duration: duration}, nil
+}
+
+// IsOpen returns true if the window opened within duration before t.
+func (w *Window) IsOpen(t time.Time) bool {
+	lastOpen := w.schedule.Next(t.Add(-w.duration))
+	return !lastOpen.After(t)
+}
+
+// NextOpen returns t if the window is open at t, otherwise the time the window opens next.
+func (w *Window) NextOpen(t time.Time) time.Time {
+	if w.IsOpen(t) {
+		return t
+	}
+	return w.schedule.Next(t)
+}
+
+// RecommendationWindows holds the global recommendation window and the per namespace overrides. A namespace without
+// any window, either its own or a global one, is always open.
+type RecommendationWindows struct {
+	global     *Window
+	namespaces map[string]*Window
+}
+
+func NewRecommendationWindows(global *Window, namespaces map[string]*Window) *RecommendationWindows {
+	if namespaces == nil {
+		namespaces = make(map[string]*Window)
+	}
+	return &RecommendationWindows{
+		global:     global,
+		namespaces: namespaces,
+	}
+}
+
+// WindowFor returns the window of the namespace, or nil if the namespace is always open.
+func (r *RecommendationWindows) WindowFor(namespace string) *Window {
+	if window, ok := r.namespaces[namespace]; ok {
+		return window
+	}
+	return r.global
+}
+
+func (r *RecommendationWindows) IsOpen(namespace string, t time.Time) bool {
+	window := r.WindowFor(namespace)
+	return window == nil || window.IsOpen(t)
+}
+
+func (r *RecommendationWindows) NextOpen(namespace string, t time.Time) time.Time {
+	window := r.WindowFor(namespace)
+	if window == nil {
+		return t
+	}
+	return window.NextOpen(t)
+}
@@ -0,0 +1,69 @@
+package trigger
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("RecommendationWindows", func() {
+	var nightly, weekly *Window
+
+	BeforeEach(func() {
+		var err error
+		nightly, err = NewWindow("CRON_TZ=UTC 0 2 * * *", 2*time.Hour)
+		Expect(err).ToNot(HaveOccurred())
+		weekly, err = NewWindow("CRON_TZ=UTC 0 3 * * SUN", time.Hour)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should reject an invalid schedule", func() {
+		_, err := NewWindow("every night", time.Hour)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should be open only within the duration after the schedule fires", func() {
+		Expect(nightly.IsOpen(time.Date(2023, 6, 1, 1, 59, 0, 0, time.UTC))).To(BeFalse())
+		Expect(nightly.IsOpen(time.Date(2023, 6, 1, 2, 0, 0, 0, time.UTC))).To(BeTrue())
+		Expect(nightly.IsOpen(time.Date(2023, 6, 1, 3, 59, 0, 0, time.UTC))).To(BeTrue())
+		Expect(nightly.IsOpen(time.Date(2023, 6, 1, 4, 0, 0, 0, time.UTC))).To(BeFalse())
+	})
+
+	It("should return the next time the window opens", func() {
+		open := time.Date(2023, 6, 1, 3, 0, 0, 0, time.UTC)
+		Expect(nightly.NextOpen(open)).To(Equal(open))
+		Expect(nightly.NextOpen(time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC))).
+			To(BeTemporally("==", time.Date(2023, 6, 2, 2, 0, 0, 0, time.UTC)))
+	})
+
+	It("should prefer the namespace window over the global window", func() {
+		windows := NewRecommendationWindows(nightly, map[string]*Window{"weekly": weekly})
+		// 2023-06-01 is a Thursday.
+		thursdayNight := time.Date(2023, 6, 1, 2, 30, 0, 0, time.UTC)
+		Expect(windows.IsOpen("default", thursdayNight)).To(BeTrue())
+		Expect(windows.IsOpen("weekly", thursdayNight)).To(BeFalse())
+		Expect(windows.NextOpen("weekly", thursdayNight)).
+			To(BeTemporally("==", time.Date(2023, 6, 4, 3, 0, 0, 0, time.UTC)))
+	})
+
+	It("should always be open without any window", func() {
+		windows := NewRecommendationWindows(nil, nil)
+		now := time.Now()
+		Expect(windows.IsOpen("default", now)).To(BeTrue())
+		Expect(windows.NextOpen("default", now)).To(Equal(now))
+	})
+
+	It("should requeue the monitor when the window opens", func() {
+		monitor := &Monitor{
+			workload:                 types.NamespacedName{Namespace: "default", Name: "test-workload"},
+			periodicRequeueFrequency: time.Hour,
+			recoWindows:              NewRecommendationWindows(nightly, nil),
+		}
+		now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+		delay := monitor.nextPeriodicRequeue(now)
+		Expect(delay).To(BeNumerically(">=", 14*time.Hour))
+		Expect(delay).To(BeNumerically("<", 15*time.Hour))
+	})
+})